@@ -23,6 +23,11 @@ type AnalyticsOptions struct {
 	// server. If not provided will be assigned a uuid value.
 	ClientContextID string
 
+	// ClientContextIDFromTrace specifies that, when ClientContextID is not provided and the span context of this
+	// query implements W3CTraceContext, the client context ID should be the trace ID of the trace.
+	// VOLATILE: This API is subject to change at any time.
+	ClientContextIDFromTrace bool
+
 	// Priority sets whether this query should be assigned as high priority by the analytics engine.
 	Priority             bool
 	PositionalParameters []interface{}
@@ -36,7 +41,9 @@ type AnalyticsOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
 
-	parentSpan requestSpanContext
+	// ParentSpan is the span context which the spans of this query are created under. The trace is only
+	// propagated to the analytics service as the client context ID, see ClientContextIDFromTrace.
+	ParentSpan RequestSpanContext
 }
 
 func (opts *AnalyticsOptions) toMap() (map[string]interface{}, error) {
@@ -1,6 +1,7 @@
 package gocb
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/couchbase/gocbcore/v9"
@@ -10,6 +11,13 @@ import (
 // Bucket represents a single bucket within a cluster.
 type Bucket struct {
 	sb stateBlock
+
+	bucketClient *bucketClient
+	closed       uint32
+}
+
+// BucketCloseOptions is the set of options available when closing a Bucket.
+type BucketCloseOptions struct {
 }
 
 func newBucket(sb *stateBlock, bucketName string) *Bucket {
@@ -33,6 +41,12 @@ func newBucket(sb *stateBlock, bucketName string) *Bucket {
 			RetryStrategyWrapper: sb.RetryStrategyWrapper,
 
 			Tracer: sb.Tracer,
+			Meter:  sb.Meter,
+
+			HTTPCircuitBreakers: sb.HTTPCircuitBreakers,
+			Bulkheads:           sb.Bulkheads,
+			Ops:                 sb.Ops,
+			KvLatencies:         sb.KvLatencies,
 
 			UseServerDurations: sb.UseServerDurations,
 			UseMutationTokens:  sb.UseMutationTokens,
@@ -48,6 +62,26 @@ func (b *Bucket) cacheClient(cli client) {
 	b.sb.cacheClient(cli)
 }
 
+func (b *Bucket) cacheBucketClient(cli *bucketClient) {
+	b.bucketClient = cli
+	b.sb.cacheClient(cli)
+}
+
+// Close releases this Bucket instance. The connections to the bucket are closed once every Bucket instance
+// for it, as returned by Cluster.Bucket, has been closed. The Bucket, and any scopes and collections obtained
+// from it, must not be used after it has been closed.
+func (b *Bucket) Close(opts *BucketCloseOptions) error {
+	if b.bucketClient == nil {
+		return nil
+	}
+
+	if !atomic.CompareAndSwapUint32(&b.closed, 0, 1) {
+		return nil
+	}
+
+	return b.bucketClient.cluster.releaseBucketClient(b.bucketClient)
+}
+
 func (b *Bucket) clone() *Bucket {
 	newB := *b
 	return &newB
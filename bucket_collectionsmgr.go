@@ -46,7 +46,7 @@ type jsonManifestCollection struct {
 type CollectionManager struct {
 	mgmtProvider mgmtProvider
 	bucketName   string
-	tracer       requestTracer
+	tracer       RequestTracer
 }
 
 func (cm *CollectionManager) tryParseErrorMessage(req *mgmtRequest, resp *mgmtResponse) error {
@@ -79,6 +79,8 @@ func (cm *CollectionManager) tryParseErrorMessage(req *mgmtRequest, resp *mgmtRe
 type GetAllScopesOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // GetAllScopes gets all scopes from the bucket.
@@ -87,7 +89,7 @@ func (cm *CollectionManager) GetAllScopes(opts *GetAllScopesOptions) ([]ScopeSpe
 		opts = &GetAllScopesOptions{}
 	}
 
-	span := cm.tracer.StartSpan("GetAllScopes", nil).
+	span := cm.tracer.StartSpan("GetAllScopes", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -171,6 +173,8 @@ func (cm *CollectionManager) GetAllScopes(opts *GetAllScopesOptions) ([]ScopeSpe
 type CreateCollectionOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // CreateCollection creates a new collection on the bucket.
@@ -187,7 +191,7 @@ func (cm *CollectionManager) CreateCollection(spec CollectionSpec, opts *CreateC
 		opts = &CreateCollectionOptions{}
 	}
 
-	span := cm.tracer.StartSpan("CreateCollection", nil).
+	span := cm.tracer.StartSpan("CreateCollection", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -231,6 +235,8 @@ func (cm *CollectionManager) CreateCollection(spec CollectionSpec, opts *CreateC
 type DropCollectionOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // DropCollection removes a collection.
@@ -247,7 +253,7 @@ func (cm *CollectionManager) DropCollection(spec CollectionSpec, opts *DropColle
 		opts = &DropCollectionOptions{}
 	}
 
-	span := cm.tracer.StartSpan("DropCollection", nil).
+	span := cm.tracer.StartSpan("DropCollection", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -286,6 +292,8 @@ func (cm *CollectionManager) DropCollection(spec CollectionSpec, opts *DropColle
 type CreateScopeOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // CreateScope creates a new scope on the bucket.
@@ -298,7 +306,7 @@ func (cm *CollectionManager) CreateScope(scopeName string, opts *CreateScopeOpti
 		opts = &CreateScopeOptions{}
 	}
 
-	span := cm.tracer.StartSpan("CreateScope", nil).
+	span := cm.tracer.StartSpan("CreateScope", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -342,6 +350,8 @@ func (cm *CollectionManager) CreateScope(scopeName string, opts *CreateScopeOpti
 type DropScopeOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // DropScope removes a scope.
@@ -350,7 +360,7 @@ func (cm *CollectionManager) DropScope(scopeName string, opts *DropScopeOptions)
 		opts = &DropScopeOptions{}
 	}
 
-	span := cm.tracer.StartSpan("DropScope", nil).
+	span := cm.tracer.StartSpan("DropScope", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
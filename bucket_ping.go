@@ -80,6 +80,8 @@ type PingOptions struct {
 	ServiceTypes []ServiceType
 	ReportID     string
 	Timeout      time.Duration
+
+	ParentSpan RequestSpanContext
 }
 
 // Ping will ping a list of services and verify they are active and
@@ -111,8 +113,12 @@ func (b *Bucket) Ping(opts *PingOptions) (*PingResult, error) {
 		gocbcoreServices[i] = gocbcore.ServiceType(svc)
 	}
 
+	span := b.sb.Tracer.StartSpan("Ping", opts.ParentSpan)
+	defer span.Finish()
+
 	coreopts := gocbcore.PingOptions{
 		ServiceTypes: gocbcoreServices,
+		TraceContext: span.Context(),
 	}
 	now := time.Now()
 	timeout := opts.Timeout
@@ -135,7 +135,7 @@ func (b *Bucket) ViewQuery(designDoc string, viewName string, opts *ViewOptions)
 		opts = &ViewOptions{}
 	}
 
-	span := b.sb.Tracer.StartSpan("ViewQuery", opts.parentSpan).
+	span := b.sb.Tracer.StartSpan("ViewQuery", opts.ParentSpan).
 		SetTag("couchbase.service", "view")
 	defer span.Finish()
 
@@ -149,7 +149,7 @@ func (b *Bucket) ViewQuery(designDoc string, viewName string, opts *ViewOptions)
 
 	retryWrapper := b.sb.RetryStrategyWrapper
 	if opts.RetryStrategy != nil {
-		retryWrapper = newRetryStrategyWrapper(opts.RetryStrategy)
+		retryWrapper = b.sb.RetryStrategyWrapper.withStrategy(opts.RetryStrategy)
 	}
 
 	urlValues, err := opts.toURLValues()
@@ -157,16 +157,29 @@ func (b *Bucket) ViewQuery(designDoc string, viewName string, opts *ViewOptions)
 		return nil, errors.Wrap(err, "could not parse query options")
 	}
 
-	return b.execViewQuery(span.Context(), "_view", designDoc, viewName, *urlValues, deadline, retryWrapper)
+	start := time.Now()
+	res, err := b.execViewQuery(span.Context(), "_view", designDoc, viewName, *urlValues, deadline, retryWrapper)
+	b.sb.Meter.recordOperation(meterValueServiceViews, "views", start, err)
+	return res, err
 }
 
 func (b *Bucket) execViewQuery(
-	span requestSpanContext,
+	span RequestSpanContext,
 	viewType, ddoc, viewName string,
 	options url.Values,
 	deadline time.Time,
 	wrapper *retryStrategyWrapper,
 ) (*ViewResult, error) {
+	op, err := b.sb.Ops.begin()
+	if err != nil {
+		return nil, ViewError{
+			InnerError:         err,
+			DesignDocumentName: ddoc,
+			ViewName:           viewName,
+		}
+	}
+	defer op.finishUnlessStreaming()
+
 	cli := b.sb.getCachedClient()
 	provider, err := cli.getViewProvider()
 	if err != nil {
@@ -177,6 +190,26 @@ func (b *Bucket) execViewQuery(
 		}
 	}
 
+	canary := newHTTPCircuitBreakerCanary(cli.getDiagnosticsProvider, ServiceTypeViews)
+	if !b.sb.HTTPCircuitBreakers.allowsRequest(ServiceTypeViews, canary) {
+		return nil, ViewError{
+			InnerError:         ErrCircuitBreakerOpen,
+			DesignDocumentName: ddoc,
+			ViewName:           viewName,
+		}
+	}
+
+	release, err := b.sb.Bulkheads.forService(ServiceTypeViews).acquire(deadline, nil)
+	if err != nil {
+		return nil, ViewError{
+			InnerError:         err,
+			DesignDocumentName: ddoc,
+			ViewName:           viewName,
+		}
+	}
+	slot := &streamingBulkheadRelease{release: release}
+	defer slot.releaseUnlessStreaming()
+
 	res, err := provider.ViewQuery(gocbcore.ViewQueryOptions{
 		DesignDocumentName: ddoc,
 		ViewType:           viewType,
@@ -187,10 +220,17 @@ func (b *Bucket) execViewQuery(
 		TraceContext:       span,
 	})
 	if err != nil {
-		return nil, maybeEnhanceViewError(err)
+		err = maybeEnhanceViewError(err)
+		b.sb.HTTPCircuitBreakers.markCompletion(ServiceTypeViews, err)
+		return nil, err
 	}
+	releaseSlot := slot.startStreaming()
+	rows := onRowsComplete(res, func(err error) {
+		b.sb.HTTPCircuitBreakers.markCompletion(ServiceTypeViews, maybeEnhanceViewError(err))
+		releaseSlot()
+	})
 
-	return newViewResult(res), nil
+	return newViewResult(op.trackRows(rows)), nil
 }
 
 func (b *Bucket) maybePrefixDevDocument(namespace DesignDocumentNamespace, ddoc string) string {
@@ -0,0 +1,648 @@
+package gocb
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v9"
+)
+
+// bucketClient is the client shared by every Bucket opened for the same bucket name. It counts the Bucket
+// handles which reference it so that the underlying client is only closed once the last of them is closed.
+// When the cluster has an idle timeout the underlying client is closed once it has gone unused for that long,
+// and has no KV operations in flight or results still streaming, and transparently reconnected the next time
+// that it is used.
+type bucketClient struct {
+	// lastUsed is accessed atomically so must remain 64-bit aligned.
+	lastUsed int64
+	// inUse is the number of KV operations in flight and results being streamed from the client, it is accessed
+	// atomically.
+	inUse int32
+
+	cluster   *Cluster
+	state     clientStateBlock
+	connectFn func() client
+
+	// connecting is closed once the initial connection has been made, it is nil if the client was connected
+	// when it was created.
+	connecting chan struct{}
+
+	// refs is guarded by the connectionsLock of the cluster.
+	refs int32
+
+	lock    sync.RWMutex
+	cli     client
+	evicted bool
+	closed  bool
+}
+
+// newBucketClient creates a bucket client which has not yet connected, connectInitial must be called once it has
+// been added to the connections of the cluster.
+func newBucketClient(cluster *Cluster, sb *clientStateBlock) *bucketClient {
+	bc := &bucketClient{
+		cluster:    cluster,
+		state:      *sb,
+		connecting: make(chan struct{}),
+	}
+	bc.connectFn = bc.connectStd
+	bc.touch()
+
+	return bc
+}
+
+// connectInitial makes the first connection for the client. It blocks until the client has bootstrapped so must
+// not be called while holding the connectionsLock, users of the client wait for it to complete.
+func (bc *bucketClient) connectInitial() {
+	cli := bc.connectFn()
+
+	bc.lock.Lock()
+	bc.cli = cli
+	bc.lock.Unlock()
+
+	bc.touch()
+	close(bc.connecting)
+}
+
+func (bc *bucketClient) waitConnected() {
+	if bc.connecting != nil {
+		<-bc.connecting
+	}
+}
+
+func (bc *bucketClient) isConnecting() bool {
+	if bc.connecting == nil {
+		return false
+	}
+
+	select {
+	case <-bc.connecting:
+		return false
+	default:
+		return true
+	}
+}
+
+func (bc *bucketClient) connectStd() client {
+	cli := newClient(bc.cluster, &bc.state)
+	err := cli.buildConfig()
+	if err == nil {
+		err = cli.connect()
+		if err != nil {
+			cli.setBootstrapError(err)
+		}
+	} else {
+		cli.setBootstrapError(err)
+	}
+
+	return cli
+}
+
+func (bc *bucketClient) touch() {
+	atomic.StoreInt64(&bc.lastUsed, time.Now().UnixNano())
+}
+
+// current returns the underlying client for use by an operation, reconnecting it if it had been evicted.
+func (bc *bucketClient) current() client {
+	bc.waitConnected()
+	bc.touch()
+
+	bc.lock.RLock()
+	if !bc.evicted {
+		cli := bc.cli
+		bc.lock.RUnlock()
+		return cli
+	}
+	bc.lock.RUnlock()
+
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	if bc.evicted && !bc.closed {
+		logDebugf("Reconnecting idle bucket level connection for %s", bc.state.BucketName)
+		bc.cli = bc.connectFn()
+		bc.evicted = false
+	}
+
+	return bc.cli
+}
+
+// peek returns the underlying client without counting as a use, it returns nil if the client has been evicted
+// or is still making its initial connection.
+func (bc *bucketClient) peek() client {
+	if bc.isConnecting() {
+		return nil
+	}
+
+	bc.lock.RLock()
+	defer bc.lock.RUnlock()
+
+	if bc.evicted {
+		return nil
+	}
+	return bc.cli
+}
+
+// evictIfIdle closes the underlying client if it has not been used since before idleSince and has no KV
+// operations in flight or results streaming.
+func (bc *bucketClient) evictIfIdle(idleSince time.Time) bool {
+	if bc.isConnecting() || !bc.idleSince(idleSince) {
+		return false
+	}
+
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	if bc.evicted || bc.closed || !bc.idleSince(idleSince) {
+		return false
+	}
+
+	logDebugf("Closing idle bucket level connection for %s", bc.state.BucketName)
+	err := bc.cli.close()
+	if err != nil {
+		logWarnf("Failed to close idle bucket level connection for %s: %s", bc.state.BucketName, err)
+	}
+	bc.evicted = true
+
+	return true
+}
+
+func (bc *bucketClient) idleSince(idleSince time.Time) bool {
+	return atomic.LoadInt32(&bc.inUse) == 0 && atomic.LoadInt64(&bc.lastUsed) <= idleSince.UnixNano()
+}
+
+// trackOp keeps the client from being evicted until the returned function has been called, which may safely be
+// called more than once.
+func (bc *bucketClient) trackOp() func() {
+	atomic.AddInt32(&bc.inUse, 1)
+
+	var done int32
+	return func() {
+		if atomic.CompareAndSwapInt32(&done, 0, 1) {
+			bc.touch()
+			atomic.AddInt32(&bc.inUse, -1)
+		}
+	}
+}
+
+// trackStream keeps the client from being evicted until the rows of reader have been consumed.
+func (bc *bucketClient) trackStream(reader trackedStreamReader, err error) (trackedStreamReader, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	done := bc.trackOp()
+	return onRowsComplete(reader, func(error) { done() }), nil
+}
+
+func (bc *bucketClient) trackQueryStream(reader queryRowReader, err error) (queryRowReader, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	done := bc.trackOp()
+	return onQueryRowsComplete(reader, func(error) { done() }), nil
+}
+
+// bucketClientKvProvider keeps the client from being evicted whilst any of its KV operations are in flight.
+type bucketClientKvProvider struct {
+	provider kvProvider
+	bc       *bucketClient
+}
+
+func (p *bucketClientKvProvider) Add(opts gocbcore.AddOptions, cb gocbcore.StoreCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.Add(opts, func(res *gocbcore.StoreResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) Set(opts gocbcore.SetOptions, cb gocbcore.StoreCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.Set(opts, func(res *gocbcore.StoreResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) Replace(opts gocbcore.ReplaceOptions, cb gocbcore.StoreCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.Replace(opts, func(res *gocbcore.StoreResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) Get(opts gocbcore.GetOptions, cb gocbcore.GetCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.Get(opts, func(res *gocbcore.GetResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) GetOneReplica(opts gocbcore.GetOneReplicaOptions, cb gocbcore.GetReplicaCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.GetOneReplica(opts, func(res *gocbcore.GetReplicaResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) Observe(opts gocbcore.ObserveOptions, cb gocbcore.ObserveCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.Observe(opts, func(res *gocbcore.ObserveResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) ObserveVb(opts gocbcore.ObserveVbOptions, cb gocbcore.ObserveVbCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.ObserveVb(opts, func(res *gocbcore.ObserveVbResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) GetMeta(opts gocbcore.GetMetaOptions, cb gocbcore.GetMetaCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.GetMeta(opts, func(res *gocbcore.GetMetaResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) Delete(opts gocbcore.DeleteOptions, cb gocbcore.DeleteCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.Delete(opts, func(res *gocbcore.DeleteResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) LookupIn(opts gocbcore.LookupInOptions, cb gocbcore.LookupInCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.LookupIn(opts, func(res *gocbcore.LookupInResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) MutateIn(opts gocbcore.MutateInOptions, cb gocbcore.MutateInCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.MutateIn(opts, func(res *gocbcore.MutateInResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) GetAndTouch(opts gocbcore.GetAndTouchOptions, cb gocbcore.GetAndTouchCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.GetAndTouch(opts, func(res *gocbcore.GetAndTouchResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) GetAndLock(opts gocbcore.GetAndLockOptions, cb gocbcore.GetAndLockCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.GetAndLock(opts, func(res *gocbcore.GetAndLockResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) Unlock(opts gocbcore.UnlockOptions, cb gocbcore.UnlockCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.Unlock(opts, func(res *gocbcore.UnlockResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) Touch(opts gocbcore.TouchOptions, cb gocbcore.TouchCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.Touch(opts, func(res *gocbcore.TouchResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) Increment(opts gocbcore.CounterOptions, cb gocbcore.CounterCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.Increment(opts, func(res *gocbcore.CounterResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) Decrement(opts gocbcore.CounterOptions, cb gocbcore.CounterCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.Decrement(opts, func(res *gocbcore.CounterResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) Append(opts gocbcore.AdjoinOptions, cb gocbcore.AdjoinCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.Append(opts, func(res *gocbcore.AdjoinResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) Prepend(opts gocbcore.AdjoinOptions, cb gocbcore.AdjoinCallback) (gocbcore.PendingOp, error) {
+	done := p.bc.trackOp()
+	op, err := p.provider.Prepend(opts, func(res *gocbcore.AdjoinResult, err error) {
+		done()
+		cb(res, err)
+	})
+	if err != nil {
+		done()
+	}
+	return op, err
+}
+
+func (p *bucketClientKvProvider) ConfigSnapshot() (*gocbcore.ConfigSnapshot, error) {
+	return p.provider.ConfigSnapshot()
+}
+
+type bucketClientQueryProvider struct {
+	provider queryProvider
+	bc       *bucketClient
+}
+
+func (p *bucketClientQueryProvider) N1QLQuery(opts gocbcore.N1QLQueryOptions) (queryRowReader, error) {
+	return p.bc.trackQueryStream(p.provider.N1QLQuery(opts))
+}
+
+func (p *bucketClientQueryProvider) PreparedN1QLQuery(opts gocbcore.N1QLQueryOptions) (queryRowReader, error) {
+	return p.bc.trackQueryStream(p.provider.PreparedN1QLQuery(opts))
+}
+
+type bucketClientAnalyticsProvider struct {
+	provider analyticsProvider
+	bc       *bucketClient
+}
+
+func (p *bucketClientAnalyticsProvider) AnalyticsQuery(opts gocbcore.AnalyticsQueryOptions) (analyticsRowReader, error) {
+	return p.bc.trackStream(p.provider.AnalyticsQuery(opts))
+}
+
+type bucketClientSearchProvider struct {
+	provider searchProvider
+	bc       *bucketClient
+}
+
+func (p *bucketClientSearchProvider) SearchQuery(opts gocbcore.SearchQueryOptions) (searchRowReader, error) {
+	return p.bc.trackStream(p.provider.SearchQuery(opts))
+}
+
+type bucketClientViewProvider struct {
+	provider viewProvider
+	bc       *bucketClient
+}
+
+func (p *bucketClientViewProvider) ViewQuery(opts gocbcore.ViewQueryOptions) (viewRowReader, error) {
+	return p.bc.trackStream(p.provider.ViewQuery(opts))
+}
+
+func (bc *bucketClient) Hash() string {
+	return bc.state.Hash()
+}
+
+func (bc *bucketClient) connect() error {
+	return bc.current().connect()
+}
+
+func (bc *bucketClient) buildConfig() error {
+	return bc.current().buildConfig()
+}
+
+func (bc *bucketClient) getKvProvider() (kvProvider, error) {
+	provider, err := bc.current().getKvProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	return &bucketClientKvProvider{provider: provider, bc: bc}, nil
+}
+
+func (bc *bucketClient) getViewProvider() (viewProvider, error) {
+	provider, err := bc.current().getViewProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	return &bucketClientViewProvider{provider: provider, bc: bc}, nil
+}
+
+func (bc *bucketClient) getQueryProvider() (queryProvider, error) {
+	provider, err := bc.current().getQueryProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	return &bucketClientQueryProvider{provider: provider, bc: bc}, nil
+}
+
+func (bc *bucketClient) getAnalyticsProvider() (analyticsProvider, error) {
+	provider, err := bc.current().getAnalyticsProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	return &bucketClientAnalyticsProvider{provider: provider, bc: bc}, nil
+}
+
+func (bc *bucketClient) getSearchProvider() (searchProvider, error) {
+	provider, err := bc.current().getSearchProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	return &bucketClientSearchProvider{provider: provider, bc: bc}, nil
+}
+
+func (bc *bucketClient) getHTTPProvider() (httpProvider, error) {
+	return bc.current().getHTTPProvider()
+}
+
+func (bc *bucketClient) getDiagnosticsProvider() (diagnosticsProvider, error) {
+	return bc.current().getDiagnosticsProvider()
+}
+
+func (bc *bucketClient) getWaitUntilReadyProvider() (waitUntilReadyProvider, error) {
+	return bc.current().getWaitUntilReadyProvider()
+}
+
+func (bc *bucketClient) close() error {
+	bc.waitConnected()
+
+	bc.lock.Lock()
+	defer bc.lock.Unlock()
+
+	if bc.closed {
+		return nil
+	}
+	bc.closed = true
+
+	if bc.evicted {
+		return nil
+	}
+	return bc.cli.close()
+}
+
+func (bc *bucketClient) setBootstrapError(err error) {
+	if cli := bc.peek(); cli != nil {
+		cli.setBootstrapError(err)
+	}
+}
+
+func (bc *bucketClient) supportsGCCCP() bool {
+	if cli := bc.peek(); cli != nil {
+		return cli.supportsGCCCP()
+	}
+	return false
+}
+
+func (bc *bucketClient) connected() (bool, error) {
+	if cli := bc.peek(); cli != nil {
+		return cli.connected()
+	}
+	return false, nil
+}
+
+func (bc *bucketClient) getBootstrapError() error {
+	if cli := bc.peek(); cli != nil {
+		return cli.getBootstrapError()
+	}
+	return nil
+}
+
+// releaseBucketClient drops a reference to a bucket client, closing it once no Bucket references it.
+func (c *Cluster) releaseBucketClient(bc *bucketClient) error {
+	c.connectionsLock.Lock()
+	bc.refs--
+	if bc.refs > 0 {
+		c.connectionsLock.Unlock()
+		return nil
+	}
+	if c.connections[bc.Hash()] == bc {
+		delete(c.connections, bc.Hash())
+	}
+	c.connectionsLock.Unlock()
+
+	logDebugf("Closing bucket level connection for %s", bc.state.BucketName)
+	return bc.close()
+}
+
+func (c *Cluster) runIdleBucketEviction(timeout time.Duration, stopCh chan struct{}) {
+	interval := timeout / 2
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-stopCh:
+			return
+		}
+
+		c.evictIdleBucketClients(time.Now().Add(-timeout))
+	}
+}
+
+func (c *Cluster) evictIdleBucketClients(idleSince time.Time) {
+	c.connectionsLock.RLock()
+	clients := make([]*bucketClient, 0, len(c.connections))
+	for _, cli := range c.connections {
+		if bc, ok := cli.(*bucketClient); ok {
+			clients = append(clients, bc)
+		}
+	}
+	c.connectionsLock.RUnlock()
+
+	for _, bc := range clients {
+		bc.evictIfIdle(idleSince)
+	}
+}
@@ -0,0 +1,180 @@
+package gocb
+
+import (
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v9"
+	"github.com/stretchr/testify/mock"
+)
+
+// bucketClientTestCluster creates a cluster with a bucket client whose connections are recorded in clis.
+func (suite *UnitTestSuite) bucketClientTestCluster(clis *[]*mockClient) (*Cluster, *bucketClient) {
+	c := clusterFromOptions(ClusterOptions{})
+
+	bc := &bucketClient{
+		cluster: c,
+		state:   clientStateBlock{BucketName: "mock"},
+		connectFn: func() client {
+			kvProvider := new(mockKvProvider)
+			kvProvider.
+				On("Get", mock.AnythingOfType("gocbcore.GetOptions"), mock.AnythingOfType("gocbcore.GetCallback")).
+				Return(new(mockPendingOp), nil)
+			queryProvider := new(mockQueryProvider)
+			queryProvider.
+				On("N1QLQuery", mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+				Return(&testQueryRowsReader{rows: [][]byte{[]byte("1")}, meta: []byte(`{}`)}, nil)
+			cli := new(mockClient)
+			cli.On("getKvProvider").Return(kvProvider, nil)
+			cli.On("getQueryProvider").Return(queryProvider, nil)
+			cli.On("close").Return(nil)
+			*clis = append(*clis, cli)
+			return cli
+		},
+	}
+	bc.cli = bc.connectFn()
+	bc.touch()
+	c.connections[bc.Hash()] = bc
+
+	return c, bc
+}
+
+func (suite *UnitTestSuite) TestBucketCloseRefCounting() {
+	var clis []*mockClient
+	c, bc := suite.bucketClientTestCluster(&clis)
+
+	b1 := newBucket(&c.sb, "mock")
+	b2 := newBucket(&c.sb, "mock")
+	for _, b := range []*Bucket{b1, b2} {
+		bc.refs++
+		b.cacheBucketClient(bc)
+	}
+
+	suite.Require().Nil(b1.Close(nil))
+	// Closing the same instance twice only releases it once.
+	suite.Require().Nil(b1.Close(nil))
+	clis[0].AssertNotCalled(suite.T(), "close")
+	suite.Assert().Contains(c.connections, "mock")
+
+	_, err := b2.sb.getCachedClient().getKvProvider()
+	suite.Require().Nil(err, err)
+
+	suite.Require().Nil(b2.Close(nil))
+	clis[0].AssertNumberOfCalls(suite.T(), "close", 1)
+	suite.Assert().NotContains(c.connections, "mock")
+	suite.Assert().Len(clis, 1)
+}
+
+func (suite *UnitTestSuite) TestBucketIdleEviction() {
+	var clis []*mockClient
+	c, bc := suite.bucketClientTestCluster(&clis)
+
+	c.evictIdleBucketClients(time.Now().Add(-time.Hour))
+	clis[0].AssertNotCalled(suite.T(), "close")
+
+	c.evictIdleBucketClients(time.Now().Add(time.Millisecond))
+	clis[0].AssertNumberOfCalls(suite.T(), "close", 1)
+
+	connected, err := bc.connected()
+	suite.Require().Nil(err, err)
+	suite.Assert().False(connected)
+	suite.Assert().Len(clis, 1)
+
+	// Using the bucket again reconnects it.
+	_, err = bc.getKvProvider()
+	suite.Require().Nil(err, err)
+	suite.Assert().Len(clis, 2)
+
+	suite.Require().Nil(c.Close(nil))
+	clis[1].AssertNumberOfCalls(suite.T(), "close", 1)
+
+	// A closed client is never reconnected.
+	c.evictIdleBucketClients(time.Now().Add(time.Millisecond))
+	bc.current()
+	suite.Assert().Len(clis, 2)
+}
+
+func (suite *UnitTestSuite) TestBucketIdleEvictionWaitsForStreams() {
+	var clis []*mockClient
+	c, bc := suite.bucketClientTestCluster(&clis)
+
+	provider, err := bc.getQueryProvider()
+	suite.Require().Nil(err, err)
+	reader, err := provider.N1QLQuery(gocbcore.N1QLQueryOptions{})
+	suite.Require().Nil(err, err)
+
+	// The client is not evicted while the rows are still being read.
+	c.evictIdleBucketClients(time.Now().Add(time.Hour))
+	clis[0].AssertNotCalled(suite.T(), "close")
+
+	for reader.NextRow() != nil {
+	}
+
+	c.evictIdleBucketClients(time.Now().Add(time.Hour))
+	clis[0].AssertNumberOfCalls(suite.T(), "close", 1)
+}
+
+func (suite *UnitTestSuite) TestBucketIdleEvictionWaitsForKvOps() {
+	var clis []*mockClient
+	c, bc := suite.bucketClientTestCluster(&clis)
+
+	provider, err := bc.getKvProvider()
+	suite.Require().Nil(err, err)
+
+	var cb gocbcore.GetCallback
+	_, err = provider.Get(gocbcore.GetOptions{}, func(res *gocbcore.GetResult, err error) {})
+	suite.Require().Nil(err, err)
+	for _, call := range provider.(*bucketClientKvProvider).provider.(*mockKvProvider).Calls {
+		cb = call.Arguments.Get(1).(gocbcore.GetCallback)
+	}
+	suite.Require().NotNil(cb)
+
+	// The client is not evicted while the operation is in flight.
+	c.evictIdleBucketClients(time.Now().Add(time.Hour))
+	clis[0].AssertNotCalled(suite.T(), "close")
+
+	cb(&gocbcore.GetResult{}, nil)
+
+	c.evictIdleBucketClients(time.Now().Add(time.Hour))
+	clis[0].AssertNumberOfCalls(suite.T(), "close", 1)
+}
+
+func (suite *UnitTestSuite) TestBucketClientConnectingDoesNotBlock() {
+	c := clusterFromOptions(ClusterOptions{})
+
+	connectCh := make(chan struct{})
+	bc := newBucketClient(c, &clientStateBlock{BucketName: "mock"})
+	bc.connectFn = func() client {
+		<-connectCh
+		cli := new(mockClient)
+		cli.On("getKvProvider").Return(new(mockKvProvider), nil)
+		cli.On("connected").Return(true, nil)
+		cli.On("getBootstrapError").Return(nil)
+		return cli
+	}
+	c.connections[bc.Hash()] = bc
+	go bc.connectInitial()
+
+	// Other users of the connections are not held up by the client which is still connecting.
+	_, err := c.randomClient()
+	suite.Require().NotNil(err)
+	suite.Assert().False(bc.evictIfIdle(time.Now().Add(time.Hour)))
+
+	providerCh := make(chan error)
+	go func() {
+		_, err := bc.getKvProvider()
+		providerCh <- err
+	}()
+
+	select {
+	case <-providerCh:
+		suite.T().Fatalf("Expected the provider to wait for the client to connect")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(connectCh)
+	suite.Require().Nil(<-providerCh)
+
+	cli, err := c.randomClient()
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal(bc, cli)
+}
@@ -0,0 +1,209 @@
+package gocb
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// BulkheadLimits specifies the limits applied to the requests sent to a single service.
+// VOLATILE: This API is subject to change at any time.
+type BulkheadLimits struct {
+	// MaxConcurrent is the maximum number of requests which may be in flight at once, 0 indicates no limit.
+	MaxConcurrent uint32
+	// MaxQueued is the maximum number of requests which may wait for one of the in flight requests to complete.
+	// Once this many requests are waiting any further requests fail immediately with ErrRequestRejected.
+	MaxQueued uint32
+}
+
+// BulkheadConfig specifies the limits on the number of concurrent requests for each service, preventing one
+// type of workload from starving the others of resources within the SDK. Time spent waiting for a request to be
+// admitted counts toward the timeout of the operation. Any retries performed by the RetryStrategy of an operation
+// take place once it has been admitted, so a retrying request does not queue again. Requests which stream their
+// results hold their slot until all of the rows have been read or the result has been closed. The operations of a
+// single Collection.Do call wait for each other to complete rather than counting toward MaxQueued.
+// VOLATILE: This API is subject to change at any time.
+type BulkheadConfig struct {
+	KeyValue   BulkheadLimits
+	Query      BulkheadLimits
+	Search     BulkheadLimits
+	Analytics  BulkheadLimits
+	Views      BulkheadLimits
+	Management BulkheadLimits
+}
+
+type bulkhead struct {
+	service   ServiceType
+	maxQueued int32
+	slots     chan struct{}
+	queued    int32
+}
+
+func newBulkhead(service ServiceType, limits BulkheadLimits) *bulkhead {
+	if limits.MaxConcurrent == 0 {
+		return nil
+	}
+
+	return &bulkhead{
+		service:   service,
+		maxQueued: int32(limits.MaxQueued),
+		slots:     make(chan struct{}, limits.MaxConcurrent),
+	}
+}
+
+func (b *bulkhead) release() {
+	<-b.slots
+}
+
+func noopBulkheadRelease() {}
+
+// acquire waits for the request to be admitted, returning the function which must be called once the request
+// has completed.
+func (b *bulkhead) acquire(deadline time.Time, cancelCh chan struct{}) (func(), error) {
+	if b == nil {
+		return noopBulkheadRelease, nil
+	}
+
+	select {
+	case b.slots <- struct{}{}:
+		return b.release, nil
+	default:
+	}
+
+	if atomic.AddInt32(&b.queued, 1) > b.maxQueued {
+		atomic.AddInt32(&b.queued, -1)
+		return nil, wrapError(ErrRequestRejected,
+			fmt.Sprintf("%s request queue is full", serviceTypeToString(b.service)))
+	}
+	defer atomic.AddInt32(&b.queued, -1)
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case b.slots <- struct{}{}:
+		return b.release, nil
+	case <-timer.C:
+		return nil, wrapError(ErrTimeout,
+			fmt.Sprintf("timed out waiting for a %s request slot", serviceTypeToString(b.service)))
+	case <-cancelCh:
+		return nil, ErrRequestCanceled
+	}
+}
+
+// bulkheadBatch admits the requests of a single batch operation, such as Collection.Do, to a bulkhead. At most as
+// many requests as the bulkhead allows to be in flight are admitted at once, the remainder wait for one of the
+// batch's own requests to complete rather than counting toward the queue limit of the bulkhead.
+type bulkheadBatch struct {
+	bulkhead *bulkhead
+	slots    chan struct{}
+}
+
+func (b *bulkhead) batch() *bulkheadBatch {
+	if b == nil {
+		return &bulkheadBatch{}
+	}
+
+	return &bulkheadBatch{
+		bulkhead: b,
+		slots:    make(chan struct{}, cap(b.slots)),
+	}
+}
+
+// enabled returns whether the batch is admitted to a bulkhead, requests are always admitted immediately otherwise.
+func (bb *bulkheadBatch) enabled() bool {
+	return bb.bulkhead != nil
+}
+
+// acquire waits for one of the batch's requests to complete, if the batch already has as many requests in flight as
+// the bulkhead allows, before admitting the request to the bulkhead.
+func (bb *bulkheadBatch) acquire(deadline time.Time) (func(), error) {
+	if bb.bulkhead == nil {
+		return noopBulkheadRelease, nil
+	}
+
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	select {
+	case bb.slots <- struct{}{}:
+	case <-timer.C:
+		return nil, wrapError(ErrTimeout,
+			fmt.Sprintf("timed out waiting for a %s request slot", serviceTypeToString(bb.bulkhead.service)))
+	}
+
+	release, err := bb.bulkhead.acquire(deadline, nil)
+	if err != nil {
+		<-bb.slots
+		return nil, err
+	}
+
+	return func() {
+		release()
+		<-bb.slots
+	}, nil
+}
+
+// streamingBulkheadRelease holds the slot of a request which streams its results until the rows have been
+// consumed, or releases it straight away if the request fails before it starts streaming.
+type streamingBulkheadRelease struct {
+	release   func()
+	streaming bool
+}
+
+func (r *streamingBulkheadRelease) releaseUnlessStreaming() {
+	if r.streaming {
+		return
+	}
+
+	r.release()
+}
+
+// startStreaming returns the function which must be called once the rows have been consumed.
+func (r *streamingBulkheadRelease) startStreaming() func() {
+	r.streaming = true
+	return r.release
+}
+
+type bulkheads struct {
+	kv         *bulkhead
+	query      *bulkhead
+	search     *bulkhead
+	analytics  *bulkhead
+	views      *bulkhead
+	management *bulkhead
+}
+
+func newBulkheads(config BulkheadConfig) *bulkheads {
+	return &bulkheads{
+		kv:         newBulkhead(ServiceTypeKeyValue, config.KeyValue),
+		query:      newBulkhead(ServiceTypeQuery, config.Query),
+		search:     newBulkhead(ServiceTypeSearch, config.Search),
+		analytics:  newBulkhead(ServiceTypeAnalytics, config.Analytics),
+		views:      newBulkhead(ServiceTypeViews, config.Views),
+		management: newBulkhead(ServiceTypeManagement, config.Management),
+	}
+}
+
+func (bs *bulkheads) forService(service ServiceType) *bulkhead {
+	if bs == nil {
+		return nil
+	}
+
+	switch service {
+	case ServiceTypeKeyValue:
+		return bs.kv
+	case ServiceTypeQuery:
+		return bs.query
+	case ServiceTypeSearch:
+		return bs.search
+	case ServiceTypeAnalytics:
+		return bs.analytics
+	case ServiceTypeViews:
+		return bs.views
+	case ServiceTypeManagement:
+		return bs.management
+	}
+
+	return nil
+}
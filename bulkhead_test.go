@@ -0,0 +1,227 @@
+package gocb
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v9"
+	"github.com/stretchr/testify/mock"
+)
+
+func (suite *UnitTestSuite) TestBulkheadQueue() {
+	bh := newBulkhead(ServiceTypeAnalytics, BulkheadLimits{
+		MaxConcurrent: 1,
+		MaxQueued:     1,
+	})
+
+	release, err := bh.acquire(time.Now().Add(time.Second), nil)
+	suite.Require().Nil(err, err)
+
+	queuedErrCh := make(chan error, 1)
+	go func() {
+		queuedRelease, err := bh.acquire(time.Now().Add(time.Second), nil)
+		if err == nil {
+			queuedRelease()
+		}
+		queuedErrCh <- err
+	}()
+
+	suite.Require().Eventually(func() bool {
+		return atomic.LoadInt32(&bh.queued) == 1
+	}, time.Second, time.Millisecond)
+
+	_, err = bh.acquire(time.Now().Add(time.Second), nil)
+	suite.Require().True(errors.Is(err, ErrRequestRejected), err)
+
+	release()
+	suite.Require().Nil(<-queuedErrCh)
+
+	// Once the queue has drained requests are admitted again.
+	release, err = bh.acquire(time.Now().Add(time.Second), nil)
+	suite.Require().Nil(err, err)
+	release()
+}
+
+func (suite *UnitTestSuite) TestBulkheadTimeout() {
+	bh := newBulkhead(ServiceTypeKeyValue, BulkheadLimits{
+		MaxConcurrent: 1,
+		MaxQueued:     1,
+	})
+
+	release, err := bh.acquire(time.Now().Add(time.Second), nil)
+	suite.Require().Nil(err, err)
+	defer release()
+
+	_, err = bh.acquire(time.Now().Add(10*time.Millisecond), nil)
+	suite.Assert().True(errors.Is(err, ErrTimeout), err)
+
+	cancelCh := make(chan struct{})
+	close(cancelCh)
+	_, err = bh.acquire(time.Now().Add(time.Second), cancelCh)
+	suite.Assert().True(errors.Is(err, ErrRequestCanceled), err)
+}
+
+func (suite *UnitTestSuite) TestBulkheadUnlimited() {
+	bhs := newBulkheads(BulkheadConfig{
+		Query: BulkheadLimits{MaxConcurrent: 1},
+	})
+
+	suite.Assert().Nil(bhs.forService(ServiceTypeKeyValue))
+	for i := 0; i < 10; i++ {
+		_, err := bhs.forService(ServiceTypeKeyValue).acquire(time.Now(), nil)
+		suite.Require().Nil(err, err)
+	}
+
+	release, err := bhs.forService(ServiceTypeQuery).acquire(time.Now().Add(time.Second), nil)
+	suite.Require().Nil(err, err)
+	defer release()
+
+	_, err = bhs.forService(ServiceTypeQuery).acquire(time.Now().Add(time.Second), nil)
+	suite.Assert().True(errors.Is(err, ErrRequestRejected), err)
+}
+
+func (suite *UnitTestSuite) TestBulkheadRejectsQuery() {
+	cluster := clusterFromOptions(ClusterOptions{
+		IoConfig: IoConfig{
+			Bulkheads: BulkheadConfig{
+				Query: BulkheadLimits{MaxConcurrent: 1},
+			},
+		},
+	})
+
+	release, err := cluster.sb.Bulkheads.forService(ServiceTypeQuery).acquire(time.Now().Add(time.Second), nil)
+	suite.Require().Nil(err, err)
+	defer release()
+
+	cli := new(mockClient)
+	cli.On("getQueryProvider").Return(new(mockQueryProvider), nil)
+	cli.On("supportsGCCCP").Return(true)
+	cluster.clusterClient = cli
+
+	_, err = cluster.Query("SELECT 1=1", &QueryOptions{Adhoc: true})
+	suite.Require().True(errors.Is(err, ErrRequestRejected), err)
+
+	var qErr QueryError
+	suite.Require().True(errors.As(err, &qErr))
+	suite.Assert().Equal("SELECT 1=1", qErr.Statement)
+}
+
+func (suite *UnitTestSuite) TestBulkheadRejectsKeyValue() {
+	b := suite.bucket("mock", suite.defaultTimeoutConfig(), new(mockClient))
+	b.sb.Bulkheads = newBulkheads(BulkheadConfig{
+		KeyValue: BulkheadLimits{MaxConcurrent: 1},
+	})
+	col := b.DefaultCollection()
+
+	release, err := b.sb.Bulkheads.forService(ServiceTypeKeyValue).acquire(time.Now().Add(time.Second), nil)
+	suite.Require().Nil(err, err)
+	defer release()
+
+	_, err = col.Get("key", nil)
+	suite.Require().True(errors.Is(err, ErrRequestRejected), err)
+}
+
+func (suite *UnitTestSuite) TestBulkheadHoldsQuerySlotUntilRowsConsumed() {
+	reader := &testQueryRowsReader{rows: [][]byte{[]byte(`{"a": 1}`)}, meta: []byte(`{}`)}
+	queryProvider := new(mockQueryProvider)
+	queryProvider.
+		On("N1QLQuery", mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+		Return(reader, nil).
+		Once()
+
+	cli := new(mockClient)
+	cli.On("getQueryProvider").Return(queryProvider, nil)
+	cli.On("supportsGCCCP").Return(true)
+
+	cluster := clusterFromOptions(ClusterOptions{
+		IoConfig: IoConfig{
+			Bulkheads: BulkheadConfig{
+				Query: BulkheadLimits{MaxConcurrent: 1},
+			},
+		},
+	})
+	cluster.clusterClient = cli
+
+	result, err := cluster.Query("SELECT 1=1", &QueryOptions{Adhoc: true})
+	suite.Require().Nil(err, err)
+
+	_, err = cluster.sb.Bulkheads.forService(ServiceTypeQuery).acquire(time.Now().Add(time.Second), nil)
+	suite.Require().True(errors.Is(err, ErrRequestRejected), err)
+
+	suite.Require().Nil(result.Close())
+
+	release, err := cluster.sb.Bulkheads.forService(ServiceTypeQuery).acquire(time.Now().Add(time.Second), nil)
+	suite.Require().Nil(err, err)
+	release()
+}
+
+func (suite *UnitTestSuite) TestBulkheadLimitsBulkOps() {
+	var inFlight, maxInFlight int32
+	provider := new(mockKvProvider)
+	provider.
+		On("Get", mock.AnythingOfType("gocbcore.GetOptions"), mock.AnythingOfType("gocbcore.GetCallback")).
+		Run(func(args mock.Arguments) {
+			cb := args.Get(1).(gocbcore.GetCallback)
+			if n := atomic.AddInt32(&inFlight, 1); n > atomic.LoadInt32(&maxInFlight) {
+				atomic.StoreInt32(&maxInFlight, n)
+			}
+			go func() {
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&inFlight, -1)
+				cb(&gocbcore.GetResult{Cas: 1}, nil)
+			}()
+		}).
+		Return(new(mockPendingOp), nil)
+
+	cli := new(mockClient)
+	cli.On("getKvProvider").Return(provider, nil)
+
+	b := suite.bucket("mock", suite.defaultTimeoutConfig(), cli)
+	b.sb.Bulkheads = newBulkheads(BulkheadConfig{
+		KeyValue: BulkheadLimits{MaxConcurrent: 1},
+	})
+
+	// The ops of a single Do wait for each other rather than being rejected by the queue limit.
+	ops := []BulkOp{&GetOp{ID: "a"}, &GetOp{ID: "b"}, &GetOp{ID: "c"}}
+	suite.Require().Nil(b.DefaultCollection().Do(ops, nil))
+
+	for _, op := range ops {
+		suite.Assert().Nil(op.(*GetOp).Err)
+	}
+	suite.Assert().Equal(int32(1), atomic.LoadInt32(&maxInFlight))
+
+	release, err := b.sb.Bulkheads.forService(ServiceTypeKeyValue).acquire(time.Now().Add(time.Second), nil)
+	suite.Require().Nil(err, err)
+	release()
+}
+
+func (suite *UnitTestSuite) TestBulkheadBulkOpsCompletingBeforeDispatchReturns() {
+	pendop := new(mockPendingOp)
+	provider := new(mockKvProvider)
+	provider.
+		On("Get", mock.AnythingOfType("gocbcore.GetOptions"), mock.AnythingOfType("gocbcore.GetCallback")).
+		Run(func(args mock.Arguments) {
+			// The op completes before the provider has returned its pending op.
+			args.Get(1).(gocbcore.GetCallback)(&gocbcore.GetResult{Cas: 1}, nil)
+		}).
+		Return(pendop, nil)
+
+	cli := new(mockClient)
+	cli.On("getKvProvider").Return(provider, nil)
+
+	for _, limits := range []BulkheadLimits{{}, {MaxConcurrent: 1}} {
+		b := suite.bucket("mock", suite.defaultTimeoutConfig(), cli)
+		b.sb.Bulkheads = newBulkheads(BulkheadConfig{KeyValue: limits})
+
+		ops := []BulkOp{&GetOp{ID: "a"}, &GetOp{ID: "b"}}
+		suite.Require().Nil(b.DefaultCollection().Do(ops, nil))
+
+		// Every op has been dispatched by the time Do returns.
+		for _, op := range ops {
+			getOp := op.(*GetOp)
+			suite.Assert().Nil(getOp.Err)
+			suite.Assert().Equal(pendop, getOp.pendop)
+		}
+	}
+}
@@ -6,7 +6,54 @@ import "time"
 // the circuit breaker failure count.
 type CircuitBreakerCallback func(error) bool
 
+// CircuitBreakerState specifies the state of a circuit breaker.
+// VOLATILE: This API is subject to change at any time.
+type CircuitBreakerState uint32
+
+const (
+	// CircuitBreakerStateDisabled indicates that the circuit breaker is disabled.
+	CircuitBreakerStateDisabled = CircuitBreakerState(0)
+
+	// CircuitBreakerStateClosed indicates that the circuit breaker is allowing requests.
+	CircuitBreakerStateClosed = CircuitBreakerState(1)
+
+	// CircuitBreakerStateHalfOpen indicates that the circuit breaker is rejecting requests whilst a canary
+	// is in flight.
+	CircuitBreakerStateHalfOpen = CircuitBreakerState(2)
+
+	// CircuitBreakerStateOpen indicates that the circuit breaker is rejecting requests.
+	CircuitBreakerStateOpen = CircuitBreakerState(3)
+)
+
+// CircuitBreakerStateChange describes a circuit breaker moving from one state to another.
+// VOLATILE: This API is subject to change at any time.
+type CircuitBreakerStateChange struct {
+	Service ServiceType
+	// Endpoint is the node that the circuit breaker applies to, it is empty for the breaker of a service as a whole.
+	Endpoint string
+	From     CircuitBreakerState
+	To       CircuitBreakerState
+}
+
+// CircuitBreakerStateChangeCallback is the callback invoked whenever a circuit breaker changes state.
+// VOLATILE: This API is subject to change at any time.
+type CircuitBreakerStateChangeCallback func(CircuitBreakerStateChange)
+
+// CircuitBreakerDiagnostics represents the state of a single circuit breaker in a diagnostics report.
+// VOLATILE: This API is subject to change at any time.
+type CircuitBreakerDiagnostics struct {
+	Service ServiceType
+	// Endpoint is the node that the circuit breaker applies to, it is empty for the breaker of a service as a whole.
+	Endpoint string
+	State    CircuitBreakerState
+	Total    int64
+	Failed   int64
+}
+
 // CircuitBreakerConfig are the settings for configuring circuit breakers.
+// The same settings are used for the circuit breakers on each KV connection and for the circuit breakers for the
+// query, search, analytics and view services. The breakers for the HTTP based services reject requests with
+// ErrCircuitBreakerOpen whilst open, and use a ping of the service as their canary.
 type CircuitBreakerConfig struct {
 	Disabled                 bool
 	VolumeThreshold          int64
@@ -15,4 +62,8 @@ type CircuitBreakerConfig struct {
 	RollingWindow            time.Duration
 	CompletionCallback       CircuitBreakerCallback
 	CanaryTimeout            time.Duration
+
+	// StateChangeCallback is invoked whenever one of the circuit breakers for the HTTP based services changes state.
+	// VOLATILE: This API is subject to change at any time.
+	StateChangeCallback CircuitBreakerStateChangeCallback
 }
@@ -123,6 +123,9 @@ func (c *stdClient) connect() error {
 	}
 
 	c.agent = agent
+	if c.cluster.sb.OrphanLoggerEnabled {
+		addOrphanReportClient(agent.ClientID(), c.cluster)
+	}
 	return nil
 }
 
@@ -237,5 +240,6 @@ func (c *stdClient) close() error {
 		return errors.New("cluster not yet connected")
 	}
 	c.lock.Unlock()
+	removeOrphanReportClient(c.agent.ClientID())
 	return c.agent.Close()
 }
@@ -22,7 +22,13 @@ type Cluster struct {
 	clusterClient   client
 
 	clusterLock sync.RWMutex
-	queryCache  map[string]*queryCacheEntry
+	monitors    map[*Monitor]struct{}
+	closed      bool
+
+	queryCache       *queryCache
+	queryResultCache *queryResultCache
+
+	idleEvictionStopCh chan struct{}
 
 	sb stateBlock
 
@@ -35,6 +41,15 @@ type Cluster struct {
 type IoConfig struct {
 	DisableMutationTokens  bool
 	DisableServerDurations bool
+
+	// Bulkheads specifies the limits on the number of concurrent requests for each service.
+	// VOLATILE: This API is subject to change at any time.
+	Bulkheads BulkheadConfig
+
+	// BucketIdleTimeout, when non-zero, causes the connections of a bucket to be closed once no operation
+	// has used them for this long. They are reconnected the next time the bucket is used.
+	// VOLATILE: This API is subject to change at any time.
+	BucketIdleTimeout time.Duration
 }
 
 // TimeoutsConfig specifies options for various operation timeouts.
@@ -93,9 +108,18 @@ type ClusterOptions struct {
 	// RetryStrategy is used to automatically retry operations if they fail.
 	RetryStrategy RetryStrategy
 
+	// RetryObserver is invoked for every decision made by a RetryStrategy on whether to retry a request.
+	// VOLATILE: This API is subject to change at any time.
+	RetryObserver RetryObserver
+
 	// Tracer specifies the tracer to use for requests.
 	// VOLATILE: This API is subject to change at any time.
-	Tracer requestTracer
+	Tracer RequestTracer
+
+	// Meter specifies the meter to record metrics to, such as the duration of operations and the number of
+	// retries.
+	// VOLATILE: This API is subject to change at any time.
+	Meter Meter
 
 	// OrphanReporterConfig specifies options for the orphan reporter.
 	OrphanReporterConfig OrphanReporterConfig
@@ -106,6 +130,15 @@ type ClusterOptions struct {
 	// IoConfig specifies IO related configuration options.
 	IoConfig IoConfig
 
+	// QueryCacheConfig specifies options for the cache of prepared query statements.
+	// VOLATILE: This API is subject to change at any time.
+	QueryCacheConfig QueryCacheConfig
+
+	// QueryResultCacheConfig specifies options for the cache of query results used by queries with
+	// QueryOptions.Cache set.
+	// VOLATILE: This API is subject to change at any time.
+	QueryResultCacheConfig QueryResultCacheConfig
+
 	// SecurityConfig specifies security related configuration options.
 	SecurityConfig SecurityConfig
 
@@ -116,6 +149,11 @@ type ClusterOptions struct {
 // ClusterCloseOptions is the set of options available when
 // disconnecting from a Cluster.
 type ClusterCloseOptions struct {
+	// DrainTimeout is how long to wait for operations which are already in flight to complete before the
+	// connections to the cluster are closed. Any streaming results which are still open after this time are
+	// closed. New operations fail with ErrClusterClosed as soon as Close is called.
+	// VOLATILE: This API is subject to change at any time.
+	DrainTimeout time.Duration
 }
 
 func clusterFromOptions(opts ClusterOptions) *Cluster {
@@ -174,7 +212,7 @@ func clusterFromOptions(opts ClusterOptions) *Cluster {
 		useServerDurations = false
 	}
 
-	var initialTracer requestTracer
+	var initialTracer RequestTracer
 	if opts.Tracer != nil {
 		initialTracer = opts.Tracer
 	} else {
@@ -182,7 +220,9 @@ func clusterFromOptions(opts ClusterOptions) *Cluster {
 	}
 	tracerAddRef(initialTracer)
 
-	return &Cluster{
+	meter := newMeterWrapper(opts.Meter)
+
+	c := &Cluster{
 		auth:        opts.Authenticator,
 		connections: make(map[string]client),
 		sb: stateBlock{
@@ -197,19 +237,32 @@ func clusterFromOptions(opts ClusterOptions) *Cluster {
 			Transcoder:             opts.Transcoder,
 			UseMutationTokens:      useMutationTokens,
 			ManagementTimeout:      managementTimeout,
-			RetryStrategyWrapper:   newRetryStrategyWrapper(opts.RetryStrategy),
+			RetryStrategyWrapper:   &retryStrategyWrapper{wrapped: opts.RetryStrategy, observer: opts.RetryObserver, meter: meter},
 			OrphanLoggerEnabled:    !opts.OrphanReporterConfig.Disabled,
 			OrphanLoggerInterval:   opts.OrphanReporterConfig.ReportInterval,
 			OrphanLoggerSampleSize: opts.OrphanReporterConfig.SampleSize,
 			UseServerDurations:     useServerDurations,
 			Tracer:                 initialTracer,
+			Meter:                  meter,
 			CircuitBreakerConfig:   opts.CircuitBreakerConfig,
+			HTTPCircuitBreakers:    newHTTPCircuitBreakers(opts.CircuitBreakerConfig),
+			Bulkheads:              newBulkheads(opts.IoConfig.Bulkheads),
+			Ops:                    newOpTracker(),
+			KvLatencies:            newKvLatencySampler(),
 			SecurityConfig:         opts.SecurityConfig,
 			InternalConfig:         opts.InternalConfig,
 		},
 
-		queryCache: make(map[string]*queryCacheEntry),
+		queryCache:       newQueryCache(opts.QueryCacheConfig),
+		queryResultCache: newQueryResultCache(opts.QueryResultCacheConfig),
+	}
+
+	if opts.IoConfig.BucketIdleTimeout > 0 {
+		c.idleEvictionStopCh = make(chan struct{})
+		go c.runIdleBucketEviction(opts.IoConfig.BucketIdleTimeout, c.idleEvictionStopCh)
 	}
+
+	return c
 }
 
 // Connect creates and returns a Cluster instance created using the
@@ -295,7 +348,9 @@ func (c *Cluster) parseExtraConnStrOptions(spec gocbconnstr.ConnSpec) error {
 	return nil
 }
 
-// Bucket connects the cluster to server(s) and returns a new Bucket instance.
+// Bucket connects the cluster to server(s) and returns a new Bucket instance. Bucket instances for the same
+// bucket share their connections, which are closed once every instance has been closed with Bucket.Close or
+// the cluster is closed.
 func (c *Cluster) Bucket(bucketName string) *Bucket {
 	b := newBucket(&c.sb, bucketName)
 
@@ -310,48 +365,28 @@ func (c *Cluster) Bucket(bucketName string) *Bucket {
 		c.clusterClient = nil
 		logDebugf("Shut down cluster level client")
 	}
-	c.connectionsLock.Unlock()
 
 	// First we see if a connection already exists for a bucket with this name.
-	cli := c.getClient(&b.sb.clientStateBlock)
-	if cli != nil {
+	if cli, ok := c.connections[b.hash()].(*bucketClient); ok {
 		logDebugf("Sharing bucket level connection %p for %s", cli, bucketName)
-		b.cacheClient(cli)
+		cli.refs++
+		c.connectionsLock.Unlock()
+		b.cacheBucketClient(cli)
 		return b
 	}
 
 	logDebugf("Creating new bucket level connection for %s", bucketName)
-	// A connection doesn't already exist so we need to create a new one.
-	cli = newClient(c, &b.sb.clientStateBlock)
-	err := cli.buildConfig()
-	if err == nil {
-		err = cli.connect()
-		if err != nil {
-			cli.setBootstrapError(err)
-		}
-	} else {
-		cli.setBootstrapError(err)
-	}
-
-	c.connectionsLock.Lock()
+	// A connection doesn't already exist so we reserve one, it is connected once the lock has been released so
+	// that the other connections remain usable while it bootstraps.
+	cli := newBucketClient(c, &b.sb.clientStateBlock)
+	cli.refs++
 	c.connections[b.hash()] = cli
 	c.connectionsLock.Unlock()
-	b.cacheClient(cli)
-
-	return b
-}
-
-func (c *Cluster) getClient(sb *clientStateBlock) client {
-	c.connectionsLock.Lock()
 
-	hash := sb.Hash()
-	if cli, ok := c.connections[hash]; ok {
-		c.connectionsLock.Unlock()
-		return cli
-	}
-	c.connectionsLock.Unlock()
+	cli.connectInitial()
+	b.cacheBucketClient(cli)
 
-	return nil
+	return b
 }
 
 func (c *Cluster) randomClient() (client, error) {
@@ -442,10 +477,52 @@ func (c *Cluster) WaitUntilReady(timeout time.Duration, opts *WaitUntilReadyOpti
 }
 
 // Close shuts down all buckets in this cluster and invalidates any references this cluster has.
+// Operations which are in flight are given up to DrainTimeout to complete, and the final report of the
+// threshold logging tracer is logged, before the connections are closed.
 func (c *Cluster) Close(opts *ClusterCloseOptions) error {
+	if opts == nil {
+		opts = &ClusterCloseOptions{}
+	}
+
+	// Only the first call closes the cluster, the tracer may be shared with other clusters and must only be
+	// released once.
+	c.clusterLock.Lock()
+	if c.closed {
+		c.clusterLock.Unlock()
+		return nil
+	}
+	c.closed = true
+	idleEvictionStopCh := c.idleEvictionStopCh
+	c.idleEvictionStopCh = nil
+	c.clusterLock.Unlock()
+
 	var overallErr error
 
+	if !c.sb.Ops.drain(opts.DrainTimeout) {
+		logWarnf("Closing cluster before all in-flight operations completed")
+	}
+
+	c.clusterLock.RLock()
+	monitors := make([]*Monitor, 0, len(c.monitors))
+	for monitor := range c.monitors {
+		monitors = append(monitors, monitor)
+	}
+	c.clusterLock.RUnlock()
+
+	for _, monitor := range monitors {
+		monitor.Stop()
+	}
+
+	if idleEvictionStopCh != nil {
+		close(idleEvictionStopCh)
+	}
+
+	// The tracer and meter are left in place, operations attempted after close fail with ErrClusterClosed
+	// when they begin and nothing is reported once the op tracker has closed.
+	tracerDecRef(c.sb.Tracer)
+
 	c.clusterLock.Lock()
+	c.connectionsLock.Lock()
 	for key, conn := range c.connections {
 		err := conn.close()
 		if err != nil {
@@ -455,6 +532,7 @@ func (c *Cluster) Close(opts *ClusterCloseOptions) error {
 
 		delete(c.connections, key)
 	}
+	c.connectionsLock.Unlock()
 	if c.clusterClient != nil {
 		err := c.clusterClient.close()
 		if err != nil {
@@ -464,10 +542,7 @@ func (c *Cluster) Close(opts *ClusterCloseOptions) error {
 	}
 	c.clusterLock.Unlock()
 
-	if c.sb.Tracer != nil {
-		tracerDecRef(c.sb.Tracer)
-		c.sb.Tracer = nil
-	}
+	c.sb.Meter.close()
 
 	return overallErr
 }
@@ -495,6 +570,10 @@ func (c *Cluster) clusterOrRandomClient() (client, error) {
 	return cli, nil
 }
 
+func (c *Cluster) httpCircuitBreakerCanary(service ServiceType) httpCircuitBreakerCanaryFn {
+	return newHTTPCircuitBreakerCanary(c.getDiagnosticsProvider, service)
+}
+
 func (c *Cluster) getDiagnosticsProvider() (diagnosticsProvider, error) {
 	cli, err := c.clusterOrRandomClient()
 	if err != nil {
@@ -595,6 +674,7 @@ func (c *Cluster) AnalyticsIndexes() *AnalyticsIndexManager {
 func (c *Cluster) QueryIndexes() *QueryIndexManager {
 	return &QueryIndexManager{
 		provider:      c,
+		queryCache:    c.queryCache,
 		globalTimeout: c.sb.ManagementTimeout,
 		tracer:        c.sb.Tracer,
 	}
@@ -13,7 +13,7 @@ type AnalyticsIndexManager struct {
 	mgmtProvider mgmtProvider
 
 	globalTimeout time.Duration
-	tracer        requestTracer
+	tracer        RequestTracer
 }
 
 type analyticsIndexQueryProvider interface {
@@ -111,6 +111,8 @@ type CreateAnalyticsDataverseOptions struct {
 
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // CreateDataverse creates a new analytics dataset.
@@ -125,7 +127,7 @@ func (am *AnalyticsIndexManager) CreateDataverse(dataverseName string, opts *Cre
 		}
 	}
 
-	span := am.tracer.StartSpan("CreateDataverse", nil).
+	span := am.tracer.StartSpan("CreateDataverse", opts.ParentSpan).
 		SetTag("couchbase.service", "analytics")
 	defer span.Finish()
 
@@ -138,7 +140,7 @@ func (am *AnalyticsIndexManager) CreateDataverse(dataverseName string, opts *Cre
 	_, err := am.doAnalyticsQuery(q, &AnalyticsOptions{
 		Timeout:       opts.Timeout,
 		RetryStrategy: opts.RetryStrategy,
-		parentSpan:    span,
+		ParentSpan:    span.Context(),
 	})
 	if err != nil {
 		return err
@@ -153,6 +155,8 @@ type DropAnalyticsDataverseOptions struct {
 
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // DropDataverse drops an analytics dataset.
@@ -161,7 +165,7 @@ func (am *AnalyticsIndexManager) DropDataverse(dataverseName string, opts *DropA
 		opts = &DropAnalyticsDataverseOptions{}
 	}
 
-	span := am.tracer.StartSpan("DropDataverse", nil).
+	span := am.tracer.StartSpan("DropDataverse", opts.ParentSpan).
 		SetTag("couchbase.service", "analytics")
 	defer span.Finish()
 
@@ -174,7 +178,7 @@ func (am *AnalyticsIndexManager) DropDataverse(dataverseName string, opts *DropA
 	_, err := am.doAnalyticsQuery(q, &AnalyticsOptions{
 		Timeout:       opts.Timeout,
 		RetryStrategy: opts.RetryStrategy,
-		parentSpan:    span,
+		ParentSpan:    span.Context(),
 	})
 	if err != nil {
 		return err
@@ -191,6 +195,8 @@ type CreateAnalyticsDatasetOptions struct {
 
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // CreateDataset creates a new analytics dataset.
@@ -205,7 +211,7 @@ func (am *AnalyticsIndexManager) CreateDataset(datasetName, bucketName string, o
 		}
 	}
 
-	span := am.tracer.StartSpan("CreateDataset", nil).
+	span := am.tracer.StartSpan("CreateDataset", opts.ParentSpan).
 		SetTag("couchbase.service", "analytics")
 	defer span.Finish()
 
@@ -232,7 +238,7 @@ func (am *AnalyticsIndexManager) CreateDataset(datasetName, bucketName string, o
 	_, err := am.doAnalyticsQuery(q, &AnalyticsOptions{
 		Timeout:       opts.Timeout,
 		RetryStrategy: opts.RetryStrategy,
-		parentSpan:    span,
+		ParentSpan:    span.Context(),
 	})
 	if err != nil {
 		return err
@@ -248,6 +254,8 @@ type DropAnalyticsDatasetOptions struct {
 
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // DropDataset drops an analytics dataset.
@@ -256,7 +264,7 @@ func (am *AnalyticsIndexManager) DropDataset(datasetName string, opts *DropAnaly
 		opts = &DropAnalyticsDatasetOptions{}
 	}
 
-	span := am.tracer.StartSpan("DropDataset", nil).
+	span := am.tracer.StartSpan("DropDataset", opts.ParentSpan).
 		SetTag("couchbase.service", "analytics")
 	defer span.Finish()
 
@@ -275,7 +283,7 @@ func (am *AnalyticsIndexManager) DropDataset(datasetName string, opts *DropAnaly
 	_, err := am.doAnalyticsQuery(q, &AnalyticsOptions{
 		Timeout:       opts.Timeout,
 		RetryStrategy: opts.RetryStrategy,
-		parentSpan:    span,
+		ParentSpan:    span.Context(),
 	})
 	if err != nil {
 		return err
@@ -288,6 +296,8 @@ func (am *AnalyticsIndexManager) DropDataset(datasetName string, opts *DropAnaly
 type GetAllAnalyticsDatasetsOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // GetAllDatasets gets all analytics datasets.
@@ -296,7 +306,7 @@ func (am *AnalyticsIndexManager) GetAllDatasets(opts *GetAllAnalyticsDatasetsOpt
 		opts = &GetAllAnalyticsDatasetsOptions{}
 	}
 
-	span := am.tracer.StartSpan("GetAllDatasets", nil).
+	span := am.tracer.StartSpan("GetAllDatasets", opts.ParentSpan).
 		SetTag("couchbase.service", "analytics")
 	defer span.Finish()
 
@@ -304,7 +314,7 @@ func (am *AnalyticsIndexManager) GetAllDatasets(opts *GetAllAnalyticsDatasetsOpt
 	rows, err := am.doAnalyticsQuery(q, &AnalyticsOptions{
 		Timeout:       opts.Timeout,
 		RetryStrategy: opts.RetryStrategy,
-		parentSpan:    span,
+		ParentSpan:    span.Context(),
 	})
 	if err != nil {
 		return nil, err
@@ -334,6 +344,8 @@ type CreateAnalyticsIndexOptions struct {
 
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // CreateIndex creates a new analytics dataset.
@@ -353,7 +365,7 @@ func (am *AnalyticsIndexManager) CreateIndex(datasetName, indexName string, fiel
 		}
 	}
 
-	span := am.tracer.StartSpan("CreateIndex", nil).
+	span := am.tracer.StartSpan("CreateIndex", opts.ParentSpan).
 		SetTag("couchbase.service", "analytics")
 	defer span.Finish()
 
@@ -377,7 +389,7 @@ func (am *AnalyticsIndexManager) CreateIndex(datasetName, indexName string, fiel
 	_, err := am.doAnalyticsQuery(q, &AnalyticsOptions{
 		Timeout:       opts.Timeout,
 		RetryStrategy: opts.RetryStrategy,
-		parentSpan:    span,
+		ParentSpan:    span.Context(),
 	})
 	if err != nil {
 		return err
@@ -393,6 +405,8 @@ type DropAnalyticsIndexOptions struct {
 
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // DropIndex drops an analytics index.
@@ -401,7 +415,7 @@ func (am *AnalyticsIndexManager) DropIndex(datasetName, indexName string, opts *
 		opts = &DropAnalyticsIndexOptions{}
 	}
 
-	span := am.tracer.StartSpan("DropIndex", nil).
+	span := am.tracer.StartSpan("DropIndex", opts.ParentSpan).
 		SetTag("couchbase.service", "analytics")
 	defer span.Finish()
 
@@ -420,7 +434,7 @@ func (am *AnalyticsIndexManager) DropIndex(datasetName, indexName string, opts *
 	_, err := am.doAnalyticsQuery(q, &AnalyticsOptions{
 		Timeout:       opts.Timeout,
 		RetryStrategy: opts.RetryStrategy,
-		parentSpan:    span,
+		ParentSpan:    span.Context(),
 	})
 	if err != nil {
 		return err
@@ -433,6 +447,8 @@ func (am *AnalyticsIndexManager) DropIndex(datasetName, indexName string, opts *
 type GetAllAnalyticsIndexesOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // GetAllIndexes gets all analytics indexes.
@@ -441,7 +457,7 @@ func (am *AnalyticsIndexManager) GetAllIndexes(opts *GetAllAnalyticsIndexesOptio
 		opts = &GetAllAnalyticsIndexesOptions{}
 	}
 
-	span := am.tracer.StartSpan("GetAllIndexes", nil).
+	span := am.tracer.StartSpan("GetAllIndexes", opts.ParentSpan).
 		SetTag("couchbase.service", "analytics")
 	defer span.Finish()
 
@@ -449,7 +465,7 @@ func (am *AnalyticsIndexManager) GetAllIndexes(opts *GetAllAnalyticsIndexesOptio
 	rows, err := am.doAnalyticsQuery(q, &AnalyticsOptions{
 		Timeout:       opts.Timeout,
 		RetryStrategy: opts.RetryStrategy,
-		parentSpan:    span,
+		ParentSpan:    span.Context(),
 	})
 	if err != nil {
 		return nil, err
@@ -478,6 +494,8 @@ type ConnectAnalyticsLinkOptions struct {
 
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // ConnectLink connects an analytics link.
@@ -486,7 +504,7 @@ func (am *AnalyticsIndexManager) ConnectLink(opts *ConnectAnalyticsLinkOptions)
 		opts = &ConnectAnalyticsLinkOptions{}
 	}
 
-	span := am.tracer.StartSpan("ConnectLink", nil).
+	span := am.tracer.StartSpan("ConnectLink", opts.ParentSpan).
 		SetTag("couchbase.service", "analytics")
 	defer span.Finish()
 
@@ -498,7 +516,7 @@ func (am *AnalyticsIndexManager) ConnectLink(opts *ConnectAnalyticsLinkOptions)
 	_, err := am.doAnalyticsQuery(q, &AnalyticsOptions{
 		Timeout:       opts.Timeout,
 		RetryStrategy: opts.RetryStrategy,
-		parentSpan:    span,
+		ParentSpan:    span.Context(),
 	})
 	if err != nil {
 		return err
@@ -513,6 +531,8 @@ type DisconnectAnalyticsLinkOptions struct {
 
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // DisconnectLink disconnects an analytics link.
@@ -521,7 +541,7 @@ func (am *AnalyticsIndexManager) DisconnectLink(opts *DisconnectAnalyticsLinkOpt
 		opts = &DisconnectAnalyticsLinkOptions{}
 	}
 
-	span := am.tracer.StartSpan("DisconnectLink", nil).
+	span := am.tracer.StartSpan("DisconnectLink", opts.ParentSpan).
 		SetTag("couchbase.service", "analytics")
 	defer span.Finish()
 
@@ -533,7 +553,7 @@ func (am *AnalyticsIndexManager) DisconnectLink(opts *DisconnectAnalyticsLinkOpt
 	_, err := am.doAnalyticsQuery(q, &AnalyticsOptions{
 		Timeout:       opts.Timeout,
 		RetryStrategy: opts.RetryStrategy,
-		parentSpan:    span,
+		ParentSpan:    span.Context(),
 	})
 	if err != nil {
 		return err
@@ -546,6 +566,8 @@ func (am *AnalyticsIndexManager) DisconnectLink(opts *DisconnectAnalyticsLinkOpt
 type GetPendingMutationsAnalyticsOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // GetPendingMutations returns the number of pending mutations for all indexes in the form of dataverse.dataset:mutations.
@@ -554,7 +576,7 @@ func (am *AnalyticsIndexManager) GetPendingMutations(opts *GetPendingMutationsAn
 		opts = &GetPendingMutationsAnalyticsOptions{}
 	}
 
-	span := am.tracer.StartSpan("GetPendingMutations", nil).
+	span := am.tracer.StartSpan("GetPendingMutations", opts.ParentSpan).
 		SetTag("couchbase.service", "analytics")
 	defer span.Finish()
 
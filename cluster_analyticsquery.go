@@ -221,7 +221,7 @@ func (c *Cluster) AnalyticsQuery(statement string, opts *AnalyticsOptions) (*Ana
 		opts = &AnalyticsOptions{}
 	}
 
-	span := c.sb.Tracer.StartSpan("Query", opts.parentSpan).
+	span := c.sb.Tracer.StartSpan("Query", opts.ParentSpan).
 		SetTag("couchbase.service", "analytics")
 	defer span.Finish()
 
@@ -233,7 +233,7 @@ func (c *Cluster) AnalyticsQuery(statement string, opts *AnalyticsOptions) (*Ana
 
 	retryStrategy := c.sb.RetryStrategyWrapper
 	if opts.RetryStrategy != nil {
-		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
+		retryStrategy = c.sb.RetryStrategyWrapper.withStrategy(opts.RetryStrategy)
 	}
 
 	queryOpts, err := opts.toMap()
@@ -245,6 +245,12 @@ func (c *Cluster) AnalyticsQuery(statement string, opts *AnalyticsOptions) (*Ana
 		}
 	}
 
+	if opts.ClientContextID == "" && opts.ClientContextIDFromTrace {
+		if traceID := w3cTraceID(span.Context()); traceID != "" {
+			queryOpts["client_context_id"] = traceID
+		}
+	}
+
 	var priorityInt int32
 	if opts.Priority {
 		priorityInt = -1
@@ -252,7 +258,10 @@ func (c *Cluster) AnalyticsQuery(statement string, opts *AnalyticsOptions) (*Ana
 
 	queryOpts["statement"] = statement
 
-	return c.execAnalyticsQuery(span, queryOpts, priorityInt, deadline, retryStrategy)
+	start := time.Now()
+	res, err := c.execAnalyticsQuery(span, queryOpts, priorityInt, deadline, retryStrategy)
+	c.sb.Meter.recordOperation(meterValueServiceAnalytics, "analytics", start, err)
+	return res, err
 }
 
 func maybeGetAnalyticsOption(options map[string]interface{}, name string) string {
@@ -263,12 +272,22 @@ func maybeGetAnalyticsOption(options map[string]interface{}, name string) string
 }
 
 func (c *Cluster) execAnalyticsQuery(
-	span requestSpan,
+	span RequestSpan,
 	options map[string]interface{},
 	priority int32,
 	deadline time.Time,
 	retryStrategy *retryStrategyWrapper,
 ) (*AnalyticsResult, error) {
+	op, err := c.sb.Ops.begin()
+	if err != nil {
+		return nil, AnalyticsError{
+			InnerError:      err,
+			Statement:       maybeGetAnalyticsOption(options, "statement"),
+			ClientContextID: maybeGetAnalyticsOption(options, "client_context_id"),
+		}
+	}
+	defer op.finishUnlessStreaming()
+
 	provider, err := c.getAnalyticsProvider()
 	if err != nil {
 		return nil, AnalyticsError{
@@ -278,6 +297,25 @@ func (c *Cluster) execAnalyticsQuery(
 		}
 	}
 
+	if !c.sb.HTTPCircuitBreakers.allowsRequest(ServiceTypeAnalytics, c.httpCircuitBreakerCanary(ServiceTypeAnalytics)) {
+		return nil, AnalyticsError{
+			InnerError:      ErrCircuitBreakerOpen,
+			Statement:       maybeGetAnalyticsOption(options, "statement"),
+			ClientContextID: maybeGetAnalyticsOption(options, "client_context_id"),
+		}
+	}
+
+	release, err := c.sb.Bulkheads.forService(ServiceTypeAnalytics).acquire(deadline, nil)
+	if err != nil {
+		return nil, AnalyticsError{
+			InnerError:      err,
+			Statement:       maybeGetAnalyticsOption(options, "statement"),
+			ClientContextID: maybeGetAnalyticsOption(options, "client_context_id"),
+		}
+	}
+	slot := &streamingBulkheadRelease{release: release}
+	defer slot.releaseUnlessStreaming()
+
 	reqBytes, err := json.Marshal(options)
 	if err != nil {
 		return nil, AnalyticsError{
@@ -295,8 +333,15 @@ func (c *Cluster) execAnalyticsQuery(
 		TraceContext:  span.Context(),
 	})
 	if err != nil {
-		return nil, maybeEnhanceAnalyticsError(err)
+		err = maybeEnhanceAnalyticsError(err)
+		c.sb.HTTPCircuitBreakers.markCompletion(ServiceTypeAnalytics, err)
+		return nil, err
 	}
+	releaseSlot := slot.startStreaming()
+	rows := onRowsComplete(res, func(err error) {
+		c.sb.HTTPCircuitBreakers.markCompletion(ServiceTypeAnalytics, maybeEnhanceAnalyticsError(err))
+		releaseSlot()
+	})
 
-	return newAnalyticsResult(res), nil
+	return newAnalyticsResult(op.trackRows(rows)), nil
 }
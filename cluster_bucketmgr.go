@@ -187,13 +187,15 @@ func (bm *BucketManager) tryParseFlushErrorMessage(req *mgmtRequest, resp *mgmtR
 // See BucketManager for methods that allow creating and removing buckets themselves.
 type BucketManager struct {
 	provider mgmtProvider
-	tracer   requestTracer
+	tracer   RequestTracer
 }
 
 // GetBucketOptions is the set of options available to the bucket manager GetBucket operation.
 type GetBucketOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // GetBucket returns settings for a bucket on the cluster.
@@ -202,14 +204,14 @@ func (bm *BucketManager) GetBucket(bucketName string, opts *GetBucketOptions) (*
 		opts = &GetBucketOptions{}
 	}
 
-	span := bm.tracer.StartSpan("GetBucket", nil).
+	span := bm.tracer.StartSpan("GetBucket", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
 	return bm.get(span.Context(), bucketName, opts.RetryStrategy, opts.Timeout)
 }
 
-func (bm *BucketManager) get(tracectx requestSpanContext, bucketName string,
+func (bm *BucketManager) get(tracectx RequestSpanContext, bucketName string,
 	strategy RetryStrategy, timeout time.Duration) (*BucketSettings, error) {
 
 	req := mgmtRequest{
@@ -262,6 +264,8 @@ func (bm *BucketManager) get(tracectx requestSpanContext, bucketName string,
 type GetAllBucketsOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // GetAllBuckets returns a list of all active buckets on the cluster.
@@ -270,7 +274,7 @@ func (bm *BucketManager) GetAllBuckets(opts *GetAllBucketsOptions) (map[string]B
 		opts = &GetAllBucketsOptions{}
 	}
 
-	span := bm.tracer.StartSpan("GetAllBuckets", nil).
+	span := bm.tracer.StartSpan("GetAllBuckets", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -335,6 +339,8 @@ type CreateBucketSettings struct {
 type CreateBucketOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // CreateBucket creates a bucket on the cluster.
@@ -343,7 +349,7 @@ func (bm *BucketManager) CreateBucket(settings CreateBucketSettings, opts *Creat
 		opts = &CreateBucketOptions{}
 	}
 
-	span := bm.tracer.StartSpan("CreateBucket", nil).
+	span := bm.tracer.StartSpan("CreateBucket", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -394,6 +400,8 @@ func (bm *BucketManager) CreateBucket(settings CreateBucketSettings, opts *Creat
 type UpdateBucketOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // UpdateBucket updates a bucket on the cluster.
@@ -402,7 +410,7 @@ func (bm *BucketManager) UpdateBucket(settings BucketSettings, opts *UpdateBucke
 		opts = &UpdateBucketOptions{}
 	}
 
-	span := bm.tracer.StartSpan("UpdateBucket", nil).
+	span := bm.tracer.StartSpan("UpdateBucket", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -449,6 +457,8 @@ func (bm *BucketManager) UpdateBucket(settings BucketSettings, opts *UpdateBucke
 type DropBucketOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // DropBucket will delete a bucket from the cluster by name.
@@ -457,7 +467,7 @@ func (bm *BucketManager) DropBucket(name string, opts *DropBucketOptions) error
 		opts = &DropBucketOptions{}
 	}
 
-	span := bm.tracer.StartSpan("DropBucket", nil).
+	span := bm.tracer.StartSpan("DropBucket", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -497,6 +507,8 @@ func (bm *BucketManager) DropBucket(name string, opts *DropBucketOptions) error
 type FlushBucketOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // FlushBucket will delete all the of the data from a bucket.
@@ -506,7 +518,7 @@ func (bm *BucketManager) FlushBucket(name string, opts *FlushBucketOptions) erro
 		opts = &FlushBucketOptions{}
 	}
 
-	span := bm.tracer.StartSpan("FlushBucket", nil).
+	span := bm.tracer.StartSpan("FlushBucket", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
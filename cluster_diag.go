@@ -26,6 +26,10 @@ type DiagnosticsResult struct {
 	Services map[string][]EndPointDiagnostics
 	sdk      string
 	State    ClusterState
+
+	// CircuitBreakers contains the state of the circuit breakers for the HTTP based services.
+	// VOLATILE: This API is subject to change at any time.
+	CircuitBreakers []CircuitBreakerDiagnostics
 }
 
 type jsonDiagnosticEntry struct {
@@ -38,12 +42,21 @@ type jsonDiagnosticEntry struct {
 	Namespace      string `json:"namespace,omitempty"`
 }
 
+type jsonCircuitBreakerEntry struct {
+	Service  string `json:"service"`
+	Endpoint string `json:"endpoint,omitempty"`
+	State    string `json:"state"`
+	Total    int64  `json:"total"`
+	Failed   int64  `json:"failed"`
+}
+
 type jsonDiagnosticReport struct {
-	Version  int16                            `json:"version"`
-	SDK      string                           `json:"sdk,omitempty"`
-	ID       string                           `json:"id,omitempty"`
-	Services map[string][]jsonDiagnosticEntry `json:"services"`
-	State    string                           `json:"state"`
+	Version         int16                            `json:"version"`
+	SDK             string                           `json:"sdk,omitempty"`
+	ID              string                           `json:"id,omitempty"`
+	Services        map[string][]jsonDiagnosticEntry `json:"services"`
+	State           string                           `json:"state"`
+	CircuitBreakers []jsonCircuitBreakerEntry        `json:"circuit_breakers,omitempty"`
 }
 
 // MarshalJSON generates a JSON representation of this diagnostics report.
@@ -73,6 +86,16 @@ func (report *DiagnosticsResult) MarshalJSON() ([]byte, error) {
 		}
 	}
 
+	for _, breaker := range report.CircuitBreakers {
+		jsonReport.CircuitBreakers = append(jsonReport.CircuitBreakers, jsonCircuitBreakerEntry{
+			Service:  serviceTypeToString(breaker.Service),
+			Endpoint: breaker.Endpoint,
+			State:    circuitBreakerStateToString(breaker.State),
+			Total:    breaker.Total,
+			Failed:   breaker.Failed,
+		})
+	}
+
 	return json.Marshal(&jsonReport)
 }
 
@@ -106,6 +129,8 @@ func (c *Cluster) Diagnostics(opts *DiagnosticsOptions) (*DiagnosticsResult, err
 		Services: make(map[string][]EndPointDiagnostics),
 		sdk:      Identifier(),
 		State:    ClusterState(agentReport.State),
+
+		CircuitBreakers: c.sb.HTTPCircuitBreakers.diagnostics(),
 	}
 
 	report.Services["kv"] = make([]EndPointDiagnostics, 0)
@@ -0,0 +1,354 @@
+package gocb
+
+import (
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MonitorEvent describes an endpoint changing between being available and unavailable.
+// VOLATILE: This API is subject to change at any time.
+type MonitorEvent struct {
+	Service ServiceType
+	Remote  string
+	From    PingState
+	To      PingState
+	Error   string
+	Time    time.Time
+}
+
+// EndpointHealth is the health of a single endpoint as tracked by a Monitor.
+// VOLATILE: This API is subject to change at any time.
+type EndpointHealth struct {
+	Service ServiceType
+	Remote  string
+	// State is the current state of the endpoint. An endpoint only moves from PingStateOk once it has failed
+	// FailureThreshold consecutive pings.
+	State       PingState
+	LastState   PingState
+	LastError   string
+	LastLatency time.Duration
+	LastChecked time.Time
+	// SuccessRatio is the fraction of the pings within the history window which succeeded.
+	SuccessRatio float64
+}
+
+// MonitorOptions are the options available to the Monitor operation.
+// VOLATILE: This API is subject to change at any time.
+type MonitorOptions struct {
+	// ServiceTypes are the services to ping, defaulting to the same services as Ping.
+	ServiceTypes []ServiceType
+	// Interval is the time between pings, defaulting to 10 seconds.
+	Interval time.Duration
+	// Timeout is the timeout for each ping, defaulting to the timeout of each service.
+	Timeout time.Duration
+	// FailureThreshold is the number of consecutive failed pings before an endpoint is considered unavailable,
+	// defaulting to 1.
+	FailureThreshold uint32
+	// HistorySize is the number of pings used to calculate the SuccessRatio of each endpoint, defaulting to 10.
+	HistorySize uint32
+
+	// Callback is invoked, from the monitoring goroutine, whenever an endpoint changes state. The Callback may call
+	// Stop on the monitor.
+	Callback func(MonitorEvent)
+	// Events receives an event whenever an endpoint changes state. Events are dropped rather than blocking the
+	// monitor when the channel is full.
+	Events chan<- MonitorEvent
+}
+
+type monitorEndpoint struct {
+	health           EndpointHealth
+	history          []bool
+	historyIdx       int
+	historyLen       int
+	consecutiveFails uint32
+}
+
+// Monitor periodically pings services of the cluster, keeping track of the health of each endpoint.
+// VOLATILE: This API is subject to change at any time.
+type Monitor struct {
+	cluster *Cluster
+	opts    MonitorOptions
+
+	lock      sync.Mutex
+	endpoints map[string]*monitorEndpoint
+	lastPing  time.Time
+
+	stopCh   chan struct{}
+	stopOnce sync.Once
+	doneCh   chan struct{}
+
+	// inCallback is set whilst the Callback is running on the monitoring goroutine, which Stop must not wait for.
+	inCallback int32
+}
+
+// Monitor starts a background monitor which pings the requested services on an interval. The monitor runs until
+// Stop is called or the cluster is closed.
+// VOLATILE: This API is subject to change at any time.
+func (c *Cluster) Monitor(opts *MonitorOptions) (*Monitor, error) {
+	if opts == nil {
+		opts = &MonitorOptions{}
+	}
+
+	for _, svc := range opts.ServiceTypes {
+		if svc == ServiceTypeKeyValue || svc == ServiceTypeViews {
+			return nil, makeInvalidArgumentsError("keyvalue and view services are not valid service types for monitoring")
+		}
+	}
+
+	monitorOpts := *opts
+	if monitorOpts.Interval == 0 {
+		monitorOpts.Interval = 10 * time.Second
+	}
+	if monitorOpts.FailureThreshold == 0 {
+		monitorOpts.FailureThreshold = 1
+	}
+	if monitorOpts.HistorySize == 0 {
+		monitorOpts.HistorySize = 10
+	}
+
+	m := &Monitor{
+		cluster:   c,
+		opts:      monitorOpts,
+		endpoints: make(map[string]*monitorEndpoint),
+		stopCh:    make(chan struct{}),
+		doneCh:    make(chan struct{}),
+	}
+
+	c.clusterLock.Lock()
+	if c.monitors == nil {
+		c.monitors = make(map[*Monitor]struct{})
+	}
+	c.monitors[m] = struct{}{}
+	c.clusterLock.Unlock()
+
+	go m.run()
+
+	return m, nil
+}
+
+func (m *Monitor) run() {
+	defer close(m.doneCh)
+
+	ticker := time.NewTicker(m.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.check()
+
+		select {
+		case <-ticker.C:
+		case <-m.stopCh:
+			return
+		}
+	}
+}
+
+func (m *Monitor) check() {
+	result, err := m.cluster.Ping(&PingOptions{
+		ServiceTypes: m.opts.ServiceTypes,
+		Timeout:      m.opts.Timeout,
+	})
+	now := time.Now()
+
+	var events []MonitorEvent
+	m.lock.Lock()
+	m.lastPing = now
+	if err != nil {
+		logDebugf("Monitor failed to ping cluster: %v", err)
+
+		// Without a ping result every known endpoint must be assumed to have failed.
+		for _, endpoint := range m.endpoints {
+			if event := m.recordLocked(endpoint, PingStateError, err.Error(), 0, now); event != nil {
+				events = append(events, *event)
+			}
+		}
+	} else {
+		seen := make(map[string]struct{})
+		for service, reports := range result.Services {
+			for _, report := range reports {
+				key := serviceTypeToString(service) + "/" + report.Remote
+				seen[key] = struct{}{}
+
+				endpoint, ok := m.endpoints[key]
+				if !ok {
+					endpoint = &monitorEndpoint{
+						health: EndpointHealth{
+							Service: service,
+							Remote:  report.Remote,
+							State:   report.State,
+						},
+						history: make([]bool, m.opts.HistorySize),
+					}
+					m.endpoints[key] = endpoint
+				}
+
+				event := m.recordLocked(endpoint, report.State, report.Error, report.Latency, now)
+				if event != nil {
+					events = append(events, *event)
+				}
+			}
+		}
+
+		// Endpoints which are no longer part of the cluster are forgotten.
+		for key := range m.endpoints {
+			if _, ok := seen[key]; !ok {
+				delete(m.endpoints, key)
+			}
+		}
+	}
+	m.lock.Unlock()
+
+	for _, event := range events {
+		m.dispatch(event)
+	}
+}
+
+func (m *Monitor) recordLocked(
+	endpoint *monitorEndpoint,
+	state PingState,
+	errStr string,
+	latency time.Duration,
+	now time.Time,
+) *MonitorEvent {
+	ok := state == PingStateOk
+
+	endpoint.history[endpoint.historyIdx] = ok
+	endpoint.historyIdx = (endpoint.historyIdx + 1) % len(endpoint.history)
+	if endpoint.historyLen < len(endpoint.history) {
+		endpoint.historyLen++
+	}
+	numOk := 0
+	for i := 0; i < endpoint.historyLen; i++ {
+		if endpoint.history[i] {
+			numOk++
+		}
+	}
+
+	health := &endpoint.health
+	health.LastState = state
+	health.LastError = errStr
+	health.LastLatency = latency
+	health.LastChecked = now
+	health.SuccessRatio = float64(numOk) / float64(endpoint.historyLen)
+
+	if ok {
+		endpoint.consecutiveFails = 0
+	} else {
+		endpoint.consecutiveFails++
+		if health.State == PingStateOk && endpoint.consecutiveFails < m.opts.FailureThreshold {
+			return nil
+		}
+	}
+
+	if health.State == state {
+		return nil
+	}
+	if (health.State == PingStateOk) == ok {
+		// Moving between timeout and error does not change whether the endpoint is available.
+		health.State = state
+		return nil
+	}
+
+	event := &MonitorEvent{
+		Service: health.Service,
+		Remote:  health.Remote,
+		From:    health.State,
+		To:      state,
+		Error:   errStr,
+		Time:    now,
+	}
+	health.State = state
+
+	return event
+}
+
+func (m *Monitor) dispatch(event MonitorEvent) {
+	// No further events are delivered once the monitor has been stopped, which may have happened within a callback.
+	select {
+	case <-m.stopCh:
+		return
+	default:
+	}
+
+	if m.opts.Callback != nil {
+		atomic.StoreInt32(&m.inCallback, 1)
+		m.opts.Callback(event)
+		atomic.StoreInt32(&m.inCallback, 0)
+	}
+
+	if m.opts.Events != nil {
+		select {
+		case m.opts.Events <- event:
+		default:
+			logWarnf("Dropping monitor event for %s as the events channel is full", event.Remote)
+		}
+	}
+}
+
+// Endpoints returns the current health of every endpoint being monitored.
+func (m *Monitor) Endpoints() []EndpointHealth {
+	m.lock.Lock()
+	endpoints := make([]EndpointHealth, 0, len(m.endpoints))
+	for _, endpoint := range m.endpoints {
+		endpoints = append(endpoints, endpoint.health)
+	}
+	m.lock.Unlock()
+
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Service != endpoints[j].Service {
+			return endpoints[i].Service < endpoints[j].Service
+		}
+		return endpoints[i].Remote < endpoints[j].Remote
+	})
+
+	return endpoints
+}
+
+// State returns the aggregated state of the monitored endpoints. The cluster is online when every endpoint is
+// available, degraded when only some are and offline when none are, or no ping has completed yet.
+func (m *Monitor) State() ClusterState {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	numOk := 0
+	for _, endpoint := range m.endpoints {
+		if endpoint.health.State == PingStateOk {
+			numOk++
+		}
+	}
+
+	if numOk == 0 {
+		return ClusterStateOffline
+	} else if numOk < len(m.endpoints) {
+		return ClusterStateDegraded
+	}
+	return ClusterStateOnline
+}
+
+// LastChecked returns the time at which the most recent ping completed.
+func (m *Monitor) LastChecked() time.Time {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.lastPing
+}
+
+// Stop stops the monitor, waiting for any in progress ping to complete. No events are delivered once Stop has
+// returned, other than to a Callback which is already running.
+func (m *Monitor) Stop() {
+	m.stopOnce.Do(func() {
+		close(m.stopCh)
+	})
+
+	// The monitoring goroutine cannot exit whilst it is running the Callback, which may be what called Stop. The
+	// ping has already completed by the time the Callback runs so there is nothing left to wait for.
+	if atomic.LoadInt32(&m.inCallback) == 0 {
+		<-m.doneCh
+	}
+
+	m.cluster.clusterLock.Lock()
+	delete(m.cluster.monitors, m)
+	m.cluster.clusterLock.Unlock()
+}
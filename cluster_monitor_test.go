@@ -0,0 +1,183 @@
+package gocb
+
+import (
+	"errors"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v9"
+	"github.com/stretchr/testify/mock"
+)
+
+func monitorTestPingResult(server2State gocbcore.PingState) *gocbcore.PingResult {
+	var server2Err error
+	if server2State != gocbcore.PingStateOK {
+		server2Err = errors.New("something")
+	}
+
+	return &gocbcore.PingResult{
+		Services: map[gocbcore.ServiceType][]gocbcore.EndpointPingResult{
+			gocbcore.N1qlService: {
+				{
+					Endpoint: "server1",
+					Latency:  10 * time.Millisecond,
+					State:    gocbcore.PingStateOK,
+				},
+				{
+					Endpoint: "server2",
+					Latency:  20 * time.Millisecond,
+					Error:    server2Err,
+					State:    server2State,
+				},
+			},
+		},
+	}
+}
+
+func (suite *UnitTestSuite) TestClusterMonitor() {
+	pingProvider := new(mockDiagnosticsProvider)
+	for _, state := range []gocbcore.PingState{
+		gocbcore.PingStateOK,
+		gocbcore.PingStateTimeout,
+		gocbcore.PingStateError,
+		gocbcore.PingStateError,
+	} {
+		pingProvider.
+			On("Ping", mock.AnythingOfType("gocbcore.PingOptions")).
+			Return(monitorTestPingResult(state), nil).
+			Once()
+	}
+	pingProvider.
+		On("Ping", mock.AnythingOfType("gocbcore.PingOptions")).
+		Return(monitorTestPingResult(gocbcore.PingStateOK), nil)
+
+	cli := new(mockClient)
+	cli.On("getDiagnosticsProvider").Return(pingProvider, nil)
+	cli.On("supportsGCCCP").Return(true)
+
+	c := clusterFromOptions(ClusterOptions{})
+	c.clusterClient = cli
+
+	var callbackEvents []MonitorEvent
+	events := make(chan MonitorEvent, 10)
+	monitor, err := c.Monitor(&MonitorOptions{
+		ServiceTypes:     []ServiceType{ServiceTypeQuery},
+		Interval:         5 * time.Millisecond,
+		FailureThreshold: 2,
+		HistorySize:      4,
+		Callback: func(event MonitorEvent) {
+			callbackEvents = append(callbackEvents, event)
+		},
+		Events: events,
+	})
+	suite.Require().Nil(err, err)
+
+	down := <-events
+	suite.Assert().Equal(ServiceTypeQuery, down.Service)
+	suite.Assert().Equal("server2", down.Remote)
+	suite.Assert().Equal(PingStateOk, down.From)
+	suite.Assert().Equal(PingStateError, down.To)
+	suite.Assert().Equal("something", down.Error)
+
+	up := <-events
+	suite.Assert().Equal("server2", up.Remote)
+	suite.Assert().Equal(PingStateError, up.From)
+	suite.Assert().Equal(PingStateOk, up.To)
+
+	suite.Require().Eventually(func() bool {
+		return monitor.State() == ClusterStateOnline
+	}, time.Second, time.Millisecond)
+
+	monitor.Stop()
+	suite.Assert().Equal([]MonitorEvent{down, up}, callbackEvents)
+
+	endpoints := monitor.Endpoints()
+	suite.Require().Len(endpoints, 2)
+	suite.Assert().Equal("server1", endpoints[0].Remote)
+	suite.Assert().Equal(float64(1), endpoints[0].SuccessRatio)
+	suite.Assert().Equal("server2", endpoints[1].Remote)
+	suite.Assert().Equal(PingStateOk, endpoints[1].State)
+	suite.Assert().Equal(20*time.Millisecond, endpoints[1].LastLatency)
+	suite.Assert().False(monitor.LastChecked().IsZero())
+
+	suite.Assert().Empty(c.monitors)
+}
+
+func (suite *UnitTestSuite) TestClusterMonitorState() {
+	m := &Monitor{
+		opts: MonitorOptions{
+			FailureThreshold: 1,
+		},
+		endpoints: make(map[string]*monitorEndpoint),
+	}
+	suite.Assert().Equal(ClusterStateOffline, m.State())
+
+	now := time.Now()
+	for _, remote := range []string{"server1", "server2"} {
+		m.endpoints[remote] = &monitorEndpoint{
+			health:  EndpointHealth{Remote: remote, State: PingStateOk},
+			history: make([]bool, 2),
+		}
+	}
+	suite.Assert().Equal(ClusterStateOnline, m.State())
+
+	event := m.recordLocked(m.endpoints["server1"], PingStateTimeout, "timeout", 0, now)
+	suite.Require().NotNil(event)
+	suite.Assert().Equal(ClusterStateDegraded, m.State())
+
+	// Moving from one failed state to another does not raise an event.
+	suite.Assert().Nil(m.recordLocked(m.endpoints["server1"], PingStateError, "error", 0, now))
+	suite.Assert().Equal(PingStateError, m.endpoints["server1"].health.State)
+	suite.Assert().Equal(float64(0), m.endpoints["server1"].health.SuccessRatio)
+
+	suite.Require().NotNil(m.recordLocked(m.endpoints["server2"], PingStateError, "error", 0, now))
+	suite.Assert().Equal(ClusterStateOffline, m.State())
+}
+
+func (suite *UnitTestSuite) TestClusterMonitorInvalidService() {
+	c := clusterFromOptions(ClusterOptions{})
+
+	_, err := c.Monitor(&MonitorOptions{
+		ServiceTypes: []ServiceType{ServiceTypeKeyValue},
+	})
+	suite.Assert().True(errors.Is(err, ErrInvalidArgument), err)
+}
+
+func (suite *UnitTestSuite) TestClusterMonitorStopFromCallback() {
+	pingProvider := new(mockDiagnosticsProvider)
+	pingProvider.
+		On("Ping", mock.AnythingOfType("gocbcore.PingOptions")).
+		Return(monitorTestPingResult(gocbcore.PingStateOK), nil).
+		Once()
+	pingProvider.
+		On("Ping", mock.AnythingOfType("gocbcore.PingOptions")).
+		Return(monitorTestPingResult(gocbcore.PingStateError), nil)
+
+	cli := new(mockClient)
+	cli.On("getDiagnosticsProvider").Return(pingProvider, nil)
+	cli.On("supportsGCCCP").Return(true)
+
+	c := clusterFromOptions(ClusterOptions{})
+	c.clusterClient = cli
+
+	monitorCh := make(chan *Monitor, 1)
+	stoppedCh := make(chan struct{})
+	monitor, err := c.Monitor(&MonitorOptions{
+		ServiceTypes: []ServiceType{ServiceTypeQuery},
+		Interval:     time.Millisecond,
+		Callback: func(event MonitorEvent) {
+			(<-monitorCh).Stop()
+			close(stoppedCh)
+		},
+	})
+	suite.Require().Nil(err, err)
+	monitorCh <- monitor
+
+	select {
+	case <-stoppedCh:
+	case <-time.After(time.Second):
+		suite.T().Fatalf("Stop did not return when called from the callback")
+	}
+
+	<-monitor.doneCh
+	monitor.Stop()
+}
@@ -43,8 +43,12 @@ func (c *Cluster) Ping(opts *PingOptions) (*PingResult, error) {
 		gocbcoreServices[i] = gocbcore.ServiceType(svc)
 	}
 
+	span := c.sb.Tracer.StartSpan("Ping", opts.ParentSpan)
+	defer span.Finish()
+
 	coreopts := gocbcore.PingOptions{
 		ServiceTypes: gocbcoreServices,
+		TraceContext: span.Context(),
 	}
 	now := time.Now()
 	timeout := opts.Timeout
@@ -113,6 +113,7 @@ func (suite *UnitTestSuite) TestClusterPingAll() {
 			AnalyticsTimeout: 1000 * time.Second,
 			QueryTimeout:     1000 * time.Second,
 			SearchTimeout:    1000 * time.Second,
+			Tracer:           &noopTracer{},
 		},
 		clusterClient: cli,
 	}
@@ -202,6 +203,7 @@ func (suite *UnitTestSuite) TestClusterPingOne() {
 			AnalyticsTimeout: 1000 * time.Second,
 			QueryTimeout:     1000 * time.Second,
 			SearchTimeout:    1000 * time.Second,
+			Tracer:           &noopTracer{},
 		},
 		clusterClient: cli,
 	}
@@ -2,17 +2,13 @@ package gocb
 
 import (
 	"encoding/json"
+	"errors"
+	"sync/atomic"
 	"time"
 
 	gocbcore "github.com/couchbase/gocbcore/v9"
 )
 
-type queryCacheEntry struct {
-	enhanced    bool
-	name        string
-	encodedPlan string
-}
-
 type jsonQueryMetrics struct {
 	ElapsedTime   string `json:"elapsedTime"`
 	ExecutionTime string `json:"executionTime"`
@@ -237,10 +233,21 @@ func (c *Cluster) Query(statement string, opts *QueryOptions) (*QueryResult, err
 		opts = &QueryOptions{}
 	}
 
-	span := c.sb.Tracer.StartSpan("Query", opts.parentSpan).
+	span := c.sb.Tracer.StartSpan("Query", opts.ParentSpan).
 		SetTag("couchbase.service", "query")
 	defer span.Finish()
 
+	if opts.Cache && c.queryResultCache.enabled() {
+		if key, ok := queryResultCacheKey(statement, opts); ok {
+			return c.cachedQuery(span, key, statement, opts)
+		}
+	}
+
+	return c.query(span, statement, opts)
+}
+
+// query executes the query statement on the server without going through the result cache.
+func (c *Cluster) query(span RequestSpan, statement string, opts *QueryOptions) (*QueryResult, error) {
 	timeout := opts.Timeout
 	if timeout == 0 {
 		timeout = c.sb.QueryTimeout
@@ -249,7 +256,7 @@ func (c *Cluster) Query(statement string, opts *QueryOptions) (*QueryResult, err
 
 	retryStrategy := c.sb.RetryStrategyWrapper
 	if opts.RetryStrategy != nil {
-		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
+		retryStrategy = c.sb.RetryStrategyWrapper.withStrategy(opts.RetryStrategy)
 	}
 
 	queryOpts, err := opts.toMap()
@@ -261,9 +268,18 @@ func (c *Cluster) Query(statement string, opts *QueryOptions) (*QueryResult, err
 		}
 	}
 
+	if opts.ClientContextID == "" && opts.ClientContextIDFromTrace {
+		if traceID := w3cTraceID(span.Context()); traceID != "" {
+			queryOpts["client_context_id"] = traceID
+		}
+	}
+
 	queryOpts["statement"] = statement
 
-	return c.execN1qlQuery(span, queryOpts, deadline, retryStrategy, opts.Adhoc)
+	start := time.Now()
+	res, err := c.execN1qlQuery(span, queryOpts, deadline, retryStrategy, opts.Adhoc)
+	c.sb.Meter.recordOperation(meterValueServiceQuery, "query", start, err)
+	return res, err
 }
 
 func maybeGetQueryOption(options map[string]interface{}, name string) string {
@@ -274,12 +290,22 @@ func maybeGetQueryOption(options map[string]interface{}, name string) string {
 }
 
 func (c *Cluster) execN1qlQuery(
-	span requestSpan,
+	span RequestSpan,
 	options map[string]interface{},
 	deadline time.Time,
 	retryStrategy *retryStrategyWrapper,
 	adHoc bool,
 ) (*QueryResult, error) {
+	op, err := c.sb.Ops.begin()
+	if err != nil {
+		return nil, QueryError{
+			InnerError:      err,
+			Statement:       maybeGetQueryOption(options, "statement"),
+			ClientContextID: maybeGetQueryOption(options, "client_context_id"),
+		}
+	}
+	defer op.finishUnlessStreaming()
+
 	provider, err := c.getQueryProvider()
 	if err != nil {
 		return nil, QueryError{
@@ -289,6 +315,25 @@ func (c *Cluster) execN1qlQuery(
 		}
 	}
 
+	if !c.sb.HTTPCircuitBreakers.allowsRequest(ServiceTypeQuery, c.httpCircuitBreakerCanary(ServiceTypeQuery)) {
+		return nil, QueryError{
+			InnerError:      ErrCircuitBreakerOpen,
+			Statement:       maybeGetQueryOption(options, "statement"),
+			ClientContextID: maybeGetQueryOption(options, "client_context_id"),
+		}
+	}
+
+	release, err := c.sb.Bulkheads.forService(ServiceTypeQuery).acquire(deadline, nil)
+	if err != nil {
+		return nil, QueryError{
+			InnerError:      err,
+			Statement:       maybeGetQueryOption(options, "statement"),
+			ClientContextID: maybeGetQueryOption(options, "client_context_id"),
+		}
+	}
+	slot := &streamingBulkheadRelease{release: release}
+	defer slot.releaseUnlessStreaming()
+
 	eSpan := c.sb.Tracer.StartSpan("request_encoding", span.Context())
 	reqBytes, err := json.Marshal(options)
 	eSpan.Finish()
@@ -310,16 +355,226 @@ func (c *Cluster) execN1qlQuery(
 			TraceContext:  span.Context(),
 		})
 	} else {
-		res, qErr = provider.PreparedN1QLQuery(gocbcore.N1QLQueryOptions{
-			Payload:       reqBytes,
-			RetryStrategy: retryStrategy,
-			Deadline:      deadline,
-			TraceContext:  span.Context(),
-		})
+		res, qErr = c.execPreparedN1qlQuery(provider, span, options, deadline, retryStrategy)
 	}
 	if qErr != nil {
-		return nil, maybeEnhanceQueryError(qErr)
+		qErr = maybeEnhanceQueryError(qErr)
+		c.sb.HTTPCircuitBreakers.markCompletion(ServiceTypeQuery, qErr)
+		return nil, qErr
+	}
+	// Errors can occur part way through the stream so the outcome is only recorded, and the bulkhead slot is only
+	// freed, once the rows have been consumed.
+	releaseSlot := slot.startStreaming()
+	rows := onQueryRowsComplete(res, func(err error) {
+		c.sb.HTTPCircuitBreakers.markCompletion(ServiceTypeQuery, maybeEnhanceQueryError(err))
+		releaseSlot()
+	})
+
+	return newQueryResult(op.trackQueryRows(rows)), nil
+}
+
+// Values of Cluster.supportsEnhancedStatements, which is detected from the response to the first statement
+// which is prepared.
+const (
+	enhancedStatementsUnknown int32 = iota
+	enhancedStatementsSupported
+	enhancedStatementsUnsupported
+)
+
+// queryUnrecognizedParameterCode is returned by the query service for request parameters which it does not
+// support, servers without enhanced prepared statements return it for auto_execute.
+const queryUnrecognizedParameterCode = 1065
+
+// preparedStatementRetryStrategy does not retry requests which failed because the prepared statement was not
+// recognised, deferring to wrapped for any other reason.
+type preparedStatementRetryStrategy struct {
+	wrapped RetryStrategy
+}
+
+func (rs *preparedStatementRetryStrategy) RetryAfter(req RetryRequest, reason RetryReason) RetryAction {
+	if reason == QueryPreparedStatementFailureRetryReason {
+		return &NoRetryRetryAction{}
+	}
+
+	return rs.wrapped.RetryAfter(req, reason)
+}
+
+// execPreparedN1qlQuery executes a statement using the prepared statement cached for it, preparing the statement
+// if it is not cached or if the server no longer recognises the cached statement. Statements are prepared here
+// rather than by the underlying client so that the only cache of prepared statements is the bounded queryCache.
+func (c *Cluster) execPreparedN1qlQuery(
+	provider queryProvider,
+	span RequestSpan,
+	options map[string]interface{},
+	deadline time.Time,
+	retryStrategy *retryStrategyWrapper,
+) (queryRowReader, error) {
+	statement := maybeGetQueryOption(options, "statement")
+	if c.queryCache.enabled() {
+		if prepared, ok := c.queryCache.get(statement); ok {
+			// If the server no longer recognises the cached statement then it is prepared again rather than
+			// retried, any other failure is retried as normal.
+			res, err := c.execPreparedStatement(provider, span, options, prepared, deadline,
+				retryStrategy.withStrategy(&preparedStatementRetryStrategy{wrapped: retryStrategy.wrapped}))
+			if !errors.Is(err, ErrPreparedStatementFailure) {
+				return res, err
+			}
+
+			logDebugf("Failed to execute cached prepared statement %s, preparing again: %s", prepared.name, err)
+			c.queryCache.remove(statement)
+		}
+	}
+
+	if atomic.LoadInt32(&c.supportsEnhancedStatements) != enhancedStatementsUnsupported {
+		res, err := c.prepareN1qlQuery(provider, span, options, true, deadline, retryStrategy)
+		if err == nil {
+			atomic.StoreInt32(&c.supportsEnhancedStatements, enhancedStatementsSupported)
+
+			// Without a name the statement cannot be cached and is prepared again the next time it is queried.
+			if name, err := res.PreparedName(); err == nil && name != "" && c.queryCache.enabled() {
+				c.queryCache.put(statement, preparedStatement{name: name})
+			}
+
+			return res, nil
+		}
+
+		if !isQueryErrorCode(err, queryUnrecognizedParameterCode) {
+			return nil, err
+		}
+
+		logDebugf("Query service does not support enhanced prepared statements")
+		atomic.StoreInt32(&c.supportsEnhancedStatements, enhancedStatementsUnsupported)
+	}
+
+	res, err := c.prepareN1qlQuery(provider, span, options, false, deadline, retryStrategy)
+	if err != nil {
+		return nil, err
+	}
+
+	prepared, err := readPreparedStatement(res)
+	if err != nil {
+		return nil, QueryError{
+			InnerError:      wrapError(err, "failed to read prepared statement"),
+			Statement:       statement,
+			ClientContextID: maybeGetQueryOption(options, "client_context_id"),
+		}
+	}
+	if c.queryCache.enabled() {
+		c.queryCache.put(statement, prepared)
+	}
+
+	return c.execPreparedStatement(provider, span, options, prepared, deadline, retryStrategy)
+}
+
+// prepareN1qlQuery sends a PREPARE for the statement in options, when autoExecute is set the server also executes
+// the statement and returns its results.
+func (c *Cluster) prepareN1qlQuery(
+	provider queryProvider,
+	span RequestSpan,
+	options map[string]interface{},
+	autoExecute bool,
+	deadline time.Time,
+	retryStrategy *retryStrategyWrapper,
+) (queryRowReader, error) {
+	prepareOpts := make(map[string]interface{}, len(options)+1)
+	for key, value := range options {
+		prepareOpts[key] = value
+	}
+	prepareOpts["statement"] = "PREPARE " + maybeGetQueryOption(options, "statement")
+	if autoExecute {
+		prepareOpts["auto_execute"] = true
+	}
+
+	return c.execN1qlQueryPayload(provider, span, options, prepareOpts, deadline, retryStrategy)
+}
+
+// execPreparedStatement executes a statement which has already been prepared.
+func (c *Cluster) execPreparedStatement(
+	provider queryProvider,
+	span RequestSpan,
+	options map[string]interface{},
+	prepared preparedStatement,
+	deadline time.Time,
+	retryStrategy *retryStrategyWrapper,
+) (queryRowReader, error) {
+	preparedOpts := make(map[string]interface{}, len(options)+1)
+	for key, value := range options {
+		preparedOpts[key] = value
+	}
+	delete(preparedOpts, "statement")
+	preparedOpts["prepared"] = prepared.name
+	if prepared.encodedPlan != "" {
+		preparedOpts["encoded_plan"] = prepared.encodedPlan
+	}
+
+	return c.execN1qlQueryPayload(provider, span, options, preparedOpts, deadline, retryStrategy)
+}
+
+func (c *Cluster) execN1qlQueryPayload(
+	provider queryProvider,
+	span RequestSpan,
+	options map[string]interface{},
+	payload map[string]interface{},
+	deadline time.Time,
+	retryStrategy *retryStrategyWrapper,
+) (queryRowReader, error) {
+	reqBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, QueryError{
+			InnerError:      wrapError(err, "failed to marshall query body"),
+			Statement:       maybeGetQueryOption(options, "statement"),
+			ClientContextID: maybeGetQueryOption(options, "client_context_id"),
+		}
+	}
+
+	return provider.N1QLQuery(gocbcore.N1QLQueryOptions{
+		Payload:       reqBytes,
+		RetryStrategy: retryStrategy,
+		Deadline:      deadline,
+		TraceContext:  span.Context(),
+	})
+}
+
+// readPreparedStatement reads the prepared statement returned by a PREPARE which was not automatically executed.
+func readPreparedStatement(res queryRowReader) (preparedStatement, error) {
+	row := res.NextRow()
+	if row == nil {
+		err := res.Err()
+		if err == nil {
+			err = errors.New("no prepared statement was returned")
+		}
+		_ = res.Close()
+		return preparedStatement{}, err
+	}
+
+	var prepData struct {
+		Name        string `json:"name"`
+		EncodedPlan string `json:"encoded_plan"`
+	}
+	err := json.Unmarshal(row, &prepData)
+	_ = res.Close()
+	if err != nil {
+		return preparedStatement{}, err
+	}
+
+	return preparedStatement{
+		name:        prepData.Name,
+		encodedPlan: prepData.EncodedPlan,
+	}, nil
+}
+
+// isQueryErrorCode returns whether err was returned by the query service with the error code code.
+func isQueryErrorCode(err error, code uint32) bool {
+	var n1qlErr *gocbcore.N1QLError
+	if !errors.As(err, &n1qlErr) {
+		return false
+	}
+
+	for _, desc := range n1qlErr.Errors {
+		if desc.Code == code {
+			return true
+		}
 	}
 
-	return newQueryResult(res), nil
+	return false
 }
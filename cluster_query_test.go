@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/couchbase/gocbcore/v9"
@@ -188,12 +189,17 @@ func (arr *mockQueryRowReaderBase) PreparedName() (string, error) {
 
 func (suite *UnitTestSuite) newMockQueryProvider(prepared bool, reader queryRowReader) (*mockQueryProvider, *mock.Call) {
 	queryProvider := new(mockQueryProvider)
-	methodName := "N1QLQuery"
-	if prepared {
-		methodName = "PreparedN1QLQuery"
-	}
+	// Prepared statements are prepared and executed in a single request using auto_execute.
 	call := queryProvider.
-		On(methodName, mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+		On("N1QLQuery", mock.MatchedBy(func(opts gocbcore.N1QLQueryOptions) bool {
+			var payload map[string]interface{}
+			if err := json.Unmarshal(opts.Payload, &payload); err != nil {
+				return false
+			}
+
+			statement, _ := payload["statement"].(string)
+			return strings.HasPrefix(statement, "PREPARE ") == prepared && (payload["auto_execute"] == true) == prepared
+		})).
 		Return(reader, nil).
 		Once()
 
@@ -0,0 +1,173 @@
+package gocb
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const defaultQueryBatchParallelism = 4
+
+// QueryRequest is a single statement executed as part of a QueryBatch.
+type QueryRequest struct {
+	Statement string
+	Options   *QueryOptions
+}
+
+// QueryBatchResult is the result of a single statement executed as part of a QueryBatch.
+type QueryBatchResult struct {
+	// Rows are the raw JSON rows returned by the statement.
+	Rows []json.RawMessage
+	// MetaData is the metadata of the statement, it is nil if the statement failed.
+	MetaData *QueryMetaData
+	// Err is the error which the statement failed with. Statements which were not executed because the batch
+	// was stopped fail with ErrRequestCanceled, and those which were not executed before the batch timed out
+	// fail with ErrUnambiguousTimeout.
+	Err error
+}
+
+// QueryBatchOptions is the set of options available to the QueryBatch operation.
+type QueryBatchOptions struct {
+	// Parallelism is the maximum number of statements executed at the same time, defaulting to 4.
+	Parallelism int
+	// StopOnError stops executing statements once any statement has failed.
+	StopOnError bool
+	// Timeout is the time allowed for the whole batch, defaulting to the query timeout. Each statement uses the
+	// smaller of its own timeout and the time remaining in the batch.
+	Timeout time.Duration
+
+	ParentSpan RequestSpanContext
+}
+
+// QueryBatch executes independent statements concurrently, returning their results in the same order as
+// requests. Prepared statements share the prepared statement cache of the cluster. The error returned is the
+// first error which a statement failed with when StopOnError is set, otherwise the error of each statement is only
+// returned within its result.
+// VOLATILE: This API is subject to change at any time.
+func (c *Cluster) QueryBatch(requests []QueryRequest, opts *QueryBatchOptions) ([]QueryBatchResult, error) {
+	if opts == nil {
+		opts = &QueryBatchOptions{}
+	}
+
+	if opts.Parallelism < 0 {
+		return nil, makeInvalidArgumentsError("parallelism cannot be negative")
+	}
+	for _, request := range requests {
+		if request.Statement == "" {
+			return nil, makeInvalidArgumentsError("every request must have a statement")
+		}
+	}
+
+	span := c.sb.Tracer.StartSpan("QueryBatch", opts.ParentSpan).
+		SetTag("couchbase.service", "query")
+	defer span.Finish()
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = c.sb.QueryTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	parallelism := opts.Parallelism
+	if parallelism == 0 {
+		parallelism = defaultQueryBatchParallelism
+	}
+	if parallelism > len(requests) {
+		parallelism = len(requests)
+	}
+
+	results := make([]QueryBatchResult, len(requests))
+	indexes := make(chan int, len(requests))
+	for i := range requests {
+		indexes <- i
+	}
+	close(indexes)
+
+	var lock sync.Mutex
+	var firstErr error
+	stopped := func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return firstErr != nil
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(parallelism)
+	for i := 0; i < parallelism; i++ {
+		go func() {
+			defer wg.Done()
+
+			for idx := range indexes {
+				if opts.StopOnError && stopped() {
+					results[idx].Err = ErrRequestCanceled
+					continue
+				}
+
+				remaining := time.Until(deadline)
+				if remaining <= 0 {
+					results[idx].Err = ErrUnambiguousTimeout
+					continue
+				}
+
+				results[idx] = c.executeQueryBatchRequest(requests[idx], remaining, span.Context())
+				if results[idx].Err != nil {
+					lock.Lock()
+					if firstErr == nil {
+						firstErr = results[idx].Err
+					}
+					lock.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	if opts.StopOnError {
+		return results, firstErr
+	}
+
+	return results, nil
+}
+
+func (c *Cluster) executeQueryBatchRequest(request QueryRequest, remaining time.Duration, parentSpan RequestSpanContext) QueryBatchResult {
+	var queryOpts QueryOptions
+	if request.Options != nil {
+		queryOpts = *request.Options
+	}
+	if queryOpts.Timeout == 0 || queryOpts.Timeout > remaining {
+		queryOpts.Timeout = remaining
+	}
+	if queryOpts.ParentSpan == nil {
+		queryOpts.ParentSpan = parentSpan
+	}
+
+	result, err := c.Query(request.Statement, &queryOpts)
+	if err != nil {
+		return QueryBatchResult{Err: err}
+	}
+
+	var rows []json.RawMessage
+	for result.Next() {
+		var row json.RawMessage
+		if err := result.Row(&row); err != nil {
+			if closeErr := result.Close(); closeErr != nil {
+				logDebugf("Failed to close query results: %s", closeErr)
+			}
+			return QueryBatchResult{Rows: rows, Err: err}
+		}
+		rows = append(rows, row)
+	}
+	if err := result.Err(); err != nil {
+		return QueryBatchResult{Rows: rows, Err: err}
+	}
+
+	meta, err := result.MetaData()
+	if err != nil {
+		return QueryBatchResult{Rows: rows, Err: err}
+	}
+
+	return QueryBatchResult{
+		Rows:     rows,
+		MetaData: meta,
+	}
+}
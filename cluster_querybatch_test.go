@@ -0,0 +1,140 @@
+package gocb
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v9"
+	"github.com/stretchr/testify/mock"
+)
+
+func matchQueryStatement(statement string) interface{} {
+	return mock.MatchedBy(func(opts gocbcore.N1QLQueryOptions) bool {
+		var payload map[string]interface{}
+		if err := json.Unmarshal(opts.Payload, &payload); err != nil {
+			return false
+		}
+		return payload["statement"] == statement
+	})
+}
+
+func (suite *UnitTestSuite) TestQueryBatch() {
+	var inFlight, maxInFlight int32
+	trackInFlight := func(args mock.Arguments) {
+		current := atomic.AddInt32(&inFlight, 1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if current <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+	}
+
+	queryProvider := new(mockQueryProvider)
+	for i := 0; i < 5; i++ {
+		statement := "SELECT " + strings.Repeat("1", i+1)
+		queryProvider.
+			On("N1QLQuery", matchQueryStatement(statement)).
+			Run(trackInFlight).
+			Return(&testQueryRowsReader{
+				rows: [][]byte{[]byte(strings.Repeat("1", i+1))},
+				meta: []byte(`{"requestID": "` + statement + `"}`),
+			}, nil).
+			Once()
+	}
+	queryProvider.
+		On("N1QLQuery", matchQueryStatement("SELECT fail")).
+		Return(nil, errors.New("query failed")).
+		Once()
+
+	cli := new(mockClient)
+	cli.On("getQueryProvider").Return(queryProvider, nil)
+	cli.On("supportsGCCCP").Return(true)
+
+	cluster := clusterFromOptions(ClusterOptions{})
+	cluster.clusterClient = cli
+
+	requests := []QueryRequest{
+		{Statement: "SELECT 1", Options: &QueryOptions{Adhoc: true}},
+		{Statement: "SELECT fail", Options: &QueryOptions{Adhoc: true}},
+	}
+	for i := 1; i < 5; i++ {
+		requests = append(requests, QueryRequest{
+			Statement: "SELECT " + strings.Repeat("1", i+1),
+			Options:   &QueryOptions{Adhoc: true},
+		})
+	}
+
+	results, err := cluster.QueryBatch(requests, &QueryBatchOptions{Parallelism: 2})
+	suite.Require().Nil(err, err)
+	suite.Require().Len(results, len(requests))
+
+	for i, result := range results {
+		if requests[i].Statement == "SELECT fail" {
+			suite.Assert().NotNil(result.Err)
+			suite.Assert().Nil(result.MetaData)
+			continue
+		}
+
+		suite.Require().Nil(result.Err, result.Err)
+		suite.Require().Len(result.Rows, 1)
+		suite.Assert().Equal(strings.TrimPrefix(requests[i].Statement, "SELECT "), string(result.Rows[0]))
+		suite.Assert().Equal(requests[i].Statement, result.MetaData.RequestID)
+	}
+
+	suite.Assert().True(atomic.LoadInt32(&maxInFlight) <= 2, maxInFlight)
+	queryProvider.AssertExpectations(suite.T())
+}
+
+func (suite *UnitTestSuite) TestQueryBatchStopOnError() {
+	queryProvider := new(mockQueryProvider)
+	queryProvider.
+		On("N1QLQuery", matchQueryStatement("SELECT 1")).
+		Return(&testQueryRowsReader{rows: [][]byte{[]byte("1")}, meta: []byte(`{}`)}, nil).
+		Once()
+	queryProvider.
+		On("N1QLQuery", matchQueryStatement("SELECT fail")).
+		Return(nil, errors.New("query failed")).
+		Once()
+
+	cli := new(mockClient)
+	cli.On("getQueryProvider").Return(queryProvider, nil)
+	cli.On("supportsGCCCP").Return(true)
+
+	cluster := clusterFromOptions(ClusterOptions{})
+	cluster.clusterClient = cli
+
+	requests := []QueryRequest{
+		{Statement: "SELECT 1", Options: &QueryOptions{Adhoc: true}},
+		{Statement: "SELECT fail", Options: &QueryOptions{Adhoc: true}},
+		{Statement: "SELECT 2", Options: &QueryOptions{Adhoc: true}},
+	}
+
+	results, err := cluster.QueryBatch(requests, &QueryBatchOptions{Parallelism: 1, StopOnError: true})
+	suite.Require().NotNil(err)
+	suite.Require().Len(results, 3)
+
+	suite.Assert().Nil(results[0].Err)
+	suite.Assert().Equal(err, results[1].Err)
+	suite.Assert().True(errors.Is(results[2].Err, ErrRequestCanceled), results[2].Err)
+	queryProvider.AssertExpectations(suite.T())
+}
+
+func (suite *UnitTestSuite) TestQueryBatchInvalidArguments() {
+	cluster := clusterFromOptions(ClusterOptions{})
+
+	_, err := cluster.QueryBatch([]QueryRequest{{}}, nil)
+	suite.Assert().True(errors.Is(err, ErrInvalidArgument), err)
+
+	_, err = cluster.QueryBatch([]QueryRequest{{Statement: "SELECT 1"}}, &QueryBatchOptions{Parallelism: -1})
+	suite.Assert().True(errors.Is(err, ErrInvalidArgument), err)
+
+	results, err := cluster.QueryBatch(nil, nil)
+	suite.Require().Nil(err, err)
+	suite.Assert().Empty(results)
+}
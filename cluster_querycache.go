@@ -0,0 +1,178 @@
+package gocb
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const defaultQueryCacheMaxSize = 5000
+
+// QueryCacheConfig specifies options for the cache of prepared statements used by non-adhoc queries.
+// VOLATILE: This API is subject to change at any time.
+type QueryCacheConfig struct {
+	// MaxSize is the maximum number of prepared statements to cache, the least recently used statement is evicted
+	// once it is reached. It defaults to 5000, a negative value disables the cache.
+	MaxSize int
+	// TTL is how long a prepared statement is cached for before it is prepared again, zero means statements
+	// never expire.
+	TTL time.Duration
+}
+
+// QueryCacheStats are the statistics of the prepared statement cache.
+// VOLATILE: This API is subject to change at any time.
+type QueryCacheStats struct {
+	// Hits is the number of queries which used a cached prepared statement.
+	Hits uint64
+	// Misses is the number of queries which had to prepare their statement.
+	Misses uint64
+	// Evictions is the number of statements removed from the cache because it was full, they expired or
+	// they were no longer valid.
+	Evictions uint64
+	// Size is the number of statements currently cached.
+	Size int
+}
+
+// preparedStatement identifies a statement which has been prepared by the query service, encodedPlan is only
+// set by servers which do not support enhanced prepared statements.
+type preparedStatement struct {
+	name        string
+	encodedPlan string
+}
+
+type queryCacheEntry struct {
+	statement string
+	prepared  preparedStatement
+	expiresAt time.Time
+}
+
+// queryCache is a least recently used cache of prepared statements, keyed by statement.
+type queryCache struct {
+	lock    sync.Mutex
+	maxSize int
+	ttl     time.Duration
+	order   *list.List
+	entries map[string]*list.Element
+	stats   QueryCacheStats
+}
+
+func newQueryCache(config QueryCacheConfig) *queryCache {
+	maxSize := config.MaxSize
+	if maxSize == 0 {
+		maxSize = defaultQueryCacheMaxSize
+	}
+
+	return &queryCache{
+		maxSize: maxSize,
+		ttl:     config.TTL,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (qc *queryCache) enabled() bool {
+	return qc != nil && qc.maxSize > 0
+}
+
+// get returns the prepared statement for statement, recording a hit or a miss.
+func (qc *queryCache) get(statement string) (preparedStatement, bool) {
+	qc.lock.Lock()
+	defer qc.lock.Unlock()
+
+	elem, ok := qc.entries[statement]
+	if !ok {
+		qc.stats.Misses++
+		return preparedStatement{}, false
+	}
+
+	entry := elem.Value.(*queryCacheEntry)
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		qc.removeElement(elem)
+		qc.stats.Misses++
+		return preparedStatement{}, false
+	}
+
+	qc.order.MoveToFront(elem)
+	qc.stats.Hits++
+	return entry.prepared, true
+}
+
+func (qc *queryCache) put(statement string, prepared preparedStatement) {
+	qc.lock.Lock()
+	defer qc.lock.Unlock()
+
+	var expiresAt time.Time
+	if qc.ttl > 0 {
+		expiresAt = time.Now().Add(qc.ttl)
+	}
+
+	if elem, ok := qc.entries[statement]; ok {
+		entry := elem.Value.(*queryCacheEntry)
+		entry.prepared = prepared
+		entry.expiresAt = expiresAt
+		qc.order.MoveToFront(elem)
+		return
+	}
+
+	qc.entries[statement] = qc.order.PushFront(&queryCacheEntry{
+		statement: statement,
+		prepared:  prepared,
+		expiresAt: expiresAt,
+	})
+
+	for qc.order.Len() > qc.maxSize {
+		qc.removeElement(qc.order.Back())
+	}
+}
+
+// remove evicts statement from the cache, used when its prepared statement is no longer valid.
+func (qc *queryCache) remove(statement string) {
+	qc.lock.Lock()
+	defer qc.lock.Unlock()
+
+	if elem, ok := qc.entries[statement]; ok {
+		qc.removeElement(elem)
+	}
+}
+
+func (qc *queryCache) removeElement(elem *list.Element) {
+	entry := qc.order.Remove(elem).(*queryCacheEntry)
+	delete(qc.entries, entry.statement)
+	qc.stats.Evictions++
+}
+
+// clear evicts every statement from the cache, it is safe to call on a nil cache.
+func (qc *queryCache) clear() {
+	if qc == nil {
+		return
+	}
+
+	qc.lock.Lock()
+	defer qc.lock.Unlock()
+
+	qc.stats.Evictions += uint64(qc.order.Len())
+	qc.order.Init()
+	qc.entries = make(map[string]*list.Element)
+}
+
+func (qc *queryCache) snapshot() QueryCacheStats {
+	qc.lock.Lock()
+	defer qc.lock.Unlock()
+
+	stats := qc.stats
+	stats.Size = qc.order.Len()
+	return stats
+}
+
+// ClearQueryCache removes every prepared statement from the cache, causing statements to be prepared again the
+// next time they are queried.
+// VOLATILE: This API is subject to change at any time.
+func (c *Cluster) ClearQueryCache() {
+	c.queryCache.clear()
+}
+
+// QueryCacheStats returns the statistics of the prepared statement cache.
+// VOLATILE: This API is subject to change at any time.
+func (c *Cluster) QueryCacheStats() QueryCacheStats {
+	return c.queryCache.snapshot()
+}
@@ -0,0 +1,264 @@
+package gocb
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v9"
+)
+
+func (suite *UnitTestSuite) TestQueryCacheLRU() {
+	qc := newQueryCache(QueryCacheConfig{MaxSize: 2})
+
+	qc.put("a", preparedStatement{name: "name-a"})
+	qc.put("b", preparedStatement{name: "name-b"})
+
+	prepared, ok := qc.get("a")
+	suite.Require().True(ok)
+	suite.Assert().Equal(preparedStatement{name: "name-a"}, prepared)
+
+	// b is now the least recently used statement.
+	qc.put("c", preparedStatement{name: "name-c"})
+	_, ok = qc.get("b")
+	suite.Assert().False(ok)
+	_, ok = qc.get("c")
+	suite.Assert().True(ok)
+
+	suite.Assert().Equal(QueryCacheStats{
+		Hits:      2,
+		Misses:    1,
+		Evictions: 1,
+		Size:      2,
+	}, qc.snapshot())
+
+	qc.clear()
+	suite.Assert().Equal(QueryCacheStats{
+		Hits:      2,
+		Misses:    1,
+		Evictions: 3,
+	}, qc.snapshot())
+}
+
+func (suite *UnitTestSuite) TestQueryCacheTTL() {
+	qc := newQueryCache(QueryCacheConfig{TTL: time.Millisecond})
+	suite.Assert().Equal(defaultQueryCacheMaxSize, qc.maxSize)
+
+	qc.put("a", preparedStatement{name: "name-a"})
+	time.Sleep(5 * time.Millisecond)
+
+	_, ok := qc.get("a")
+	suite.Assert().False(ok)
+	suite.Assert().Equal(QueryCacheStats{
+		Misses:    1,
+		Evictions: 1,
+	}, qc.snapshot())
+}
+
+// testPreparingQueryProvider records the payload of each query and answers it using handler.
+type testPreparingQueryProvider struct {
+	payloads []map[string]interface{}
+	handler  func(payload map[string]interface{}, opts gocbcore.N1QLQueryOptions) (queryRowReader, error)
+}
+
+func (p *testPreparingQueryProvider) N1QLQuery(opts gocbcore.N1QLQueryOptions) (queryRowReader, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(opts.Payload, &payload); err != nil {
+		return nil, err
+	}
+	p.payloads = append(p.payloads, payload)
+
+	return p.handler(payload, opts)
+}
+
+func (p *testPreparingQueryProvider) PreparedN1QLQuery(opts gocbcore.N1QLQueryOptions) (queryRowReader, error) {
+	return nil, errors.New("statements should not be prepared by the underlying client")
+}
+
+func (suite *UnitTestSuite) preparingQueryCluster(provider *testPreparingQueryProvider) *Cluster {
+	cli := new(mockClient)
+	cli.On("getQueryProvider").Return(provider, nil)
+	cli.On("supportsGCCCP").Return(true)
+
+	cluster := clusterFromOptions(ClusterOptions{})
+	cluster.clusterClient = cli
+
+	return cluster
+}
+
+func (suite *UnitTestSuite) TestQueryPreparedCache() {
+	var cachedErr error
+	provider := &testPreparingQueryProvider{
+		handler: func(payload map[string]interface{}, opts gocbcore.N1QLQueryOptions) (queryRowReader, error) {
+			if _, ok := payload["prepared"]; ok && cachedErr != nil {
+				return nil, cachedErr
+			}
+
+			return &mockQueryRowReader{
+				mockQueryRowReaderBase: mockQueryRowReaderBase{
+					Suite: suite,
+					PName: "plan-1",
+				},
+			}, nil
+		},
+	}
+	cluster := suite.preparingQueryCluster(provider)
+
+	_, err := cluster.Query("SELECT 1", nil)
+	suite.Require().Nil(err, err)
+	suite.Require().Len(provider.payloads, 1)
+	suite.Assert().Equal("PREPARE SELECT 1", provider.payloads[0]["statement"])
+	suite.Assert().Equal(true, provider.payloads[0]["auto_execute"])
+
+	_, err = cluster.Query("SELECT 1", nil)
+	suite.Require().Nil(err, err)
+	suite.Require().Len(provider.payloads, 2)
+	suite.Assert().Equal("plan-1", provider.payloads[1]["prepared"])
+	suite.Assert().NotContains(provider.payloads[1], "statement")
+
+	// Failures which are not caused by the prepared statement are returned without preparing it again.
+	cachedErr = &gocbcore.N1QLError{InnerError: ErrTimeout}
+	_, err = cluster.Query("SELECT 1", nil)
+	suite.Require().True(errors.Is(err, ErrTimeout), err)
+	suite.Assert().Len(provider.payloads, 3)
+
+	// A cached statement which the server no longer recognises is prepared again.
+	cachedErr = &gocbcore.N1QLError{InnerError: ErrPreparedStatementFailure}
+	_, err = cluster.Query("SELECT 1", nil)
+	suite.Require().Nil(err, err)
+	suite.Require().Len(provider.payloads, 5)
+	suite.Assert().Equal("PREPARE SELECT 1", provider.payloads[4]["statement"])
+
+	suite.Assert().Equal(QueryCacheStats{
+		Hits:      3,
+		Misses:    1,
+		Evictions: 1,
+		Size:      1,
+	}, cluster.QueryCacheStats())
+
+	cachedErr = nil
+	cluster.ClearQueryCache()
+	suite.Assert().Equal(0, cluster.QueryCacheStats().Size)
+
+	_, err = cluster.Query("SELECT 1", nil)
+	suite.Require().Nil(err, err)
+	suite.Require().Len(provider.payloads, 6)
+	suite.Assert().Equal("PREPARE SELECT 1", provider.payloads[5]["statement"])
+}
+
+func (suite *UnitTestSuite) TestQueryPreparedCacheRetries() {
+	provider := &testPreparingQueryProvider{
+		handler: func(payload map[string]interface{}, opts gocbcore.N1QLQueryOptions) (queryRowReader, error) {
+			if _, ok := payload["prepared"]; ok {
+				req := &mockGocbcoreRequest{identifier: "cached", idempotent: true}
+
+				// The request to the cached statement is retried for transient failures, as it would be by the
+				// underlying client, but not when the statement is no longer recognised.
+				action := opts.RetryStrategy.RetryAfter(req, gocbcore.ServiceNotAvailableRetryReason)
+				suite.Require().NotNil(action)
+				suite.Assert().NotZero(action.Duration())
+
+				action = opts.RetryStrategy.RetryAfter(req, gocbcore.QueryPreparedStatementFailureRetryReason)
+				suite.Assert().Zero(action.Duration())
+			}
+
+			return &mockQueryRowReader{
+				mockQueryRowReaderBase: mockQueryRowReaderBase{
+					Suite: suite,
+					PName: "plan-1",
+				},
+			}, nil
+		},
+	}
+	cluster := suite.preparingQueryCluster(provider)
+
+	for i := 0; i < 2; i++ {
+		_, err := cluster.Query("SELECT 1", nil)
+		suite.Require().Nil(err, err)
+	}
+	suite.Require().Len(provider.payloads, 2)
+	suite.Assert().Equal("plan-1", provider.payloads[1]["prepared"])
+}
+
+func (suite *UnitTestSuite) TestQueryPreparedCacheDisabled() {
+	provider := &testPreparingQueryProvider{
+		handler: func(payload map[string]interface{}, opts gocbcore.N1QLQueryOptions) (queryRowReader, error) {
+			return &mockQueryRowReader{
+				mockQueryRowReaderBase: mockQueryRowReaderBase{
+					Suite: suite,
+					PName: "plan-1",
+				},
+			}, nil
+		},
+	}
+	cluster := suite.preparingQueryCluster(provider)
+	cluster.queryCache = newQueryCache(QueryCacheConfig{MaxSize: -1})
+
+	for i := 0; i < 2; i++ {
+		_, err := cluster.Query("SELECT 1", nil)
+		suite.Require().Nil(err, err)
+		suite.Assert().Equal("PREPARE SELECT 1", provider.payloads[i]["statement"])
+	}
+	suite.Assert().Equal(QueryCacheStats{}, cluster.QueryCacheStats())
+}
+
+func (suite *UnitTestSuite) TestQueryPreparedLegacy() {
+	provider := &testPreparingQueryProvider{
+		handler: func(payload map[string]interface{}, opts gocbcore.N1QLQueryOptions) (queryRowReader, error) {
+			if payload["auto_execute"] == true {
+				return nil, &gocbcore.N1QLError{
+					InnerError: errors.New("unrecognized parameter"),
+					Errors:     []gocbcore.N1QLErrorDesc{{Code: 1065, Message: "Unrecognized parameter in request"}},
+				}
+			}
+			if payload["statement"] == "PREPARE SELECT 1" {
+				return &testQueryRowsReader{
+					rows: [][]byte{[]byte(`{"name": "plan-1", "encoded_plan": "abc"}`)},
+					meta: []byte(`{}`),
+				}, nil
+			}
+
+			return &testQueryRowsReader{rows: [][]byte{[]byte("1")}, meta: []byte(`{}`)}, nil
+		},
+	}
+	cluster := suite.preparingQueryCluster(provider)
+
+	result, err := cluster.Query("SELECT 1", nil)
+	suite.Require().Nil(err, err)
+	var row int
+	suite.Require().Nil(result.One(&row))
+	suite.Assert().Equal(1, row)
+
+	suite.Require().Len(provider.payloads, 3)
+	suite.Assert().NotContains(provider.payloads[1], "auto_execute")
+	suite.Assert().Equal("plan-1", provider.payloads[2]["prepared"])
+	suite.Assert().Equal("abc", provider.payloads[2]["encoded_plan"])
+
+	// Once the server is known not to support enhanced prepared statements the cached plan is used directly.
+	_, err = cluster.Query("SELECT 1", nil)
+	suite.Require().Nil(err, err)
+	suite.Require().Len(provider.payloads, 4)
+	suite.Assert().Equal("abc", provider.payloads[3]["encoded_plan"])
+
+	cluster.ClearQueryCache()
+	_, err = cluster.Query("SELECT 1", nil)
+	suite.Require().Nil(err, err)
+	suite.Require().Len(provider.payloads, 6)
+	suite.Assert().NotContains(provider.payloads[4], "auto_execute")
+}
+
+func (suite *UnitTestSuite) TestQueryCacheClearedByIndexChange() {
+	cluster := clusterFromOptions(ClusterOptions{})
+	cluster.queryCache.put("SELECT 1", preparedStatement{name: "plan-1"})
+
+	qm := cluster.QueryIndexes()
+	qm.provider = &testQueryIndexProvider{
+		handler: func(statement string) [][]byte {
+			return nil
+		},
+	}
+
+	err := qm.CreateIndex("default", "idx", []string{"field"}, nil)
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal(0, cluster.QueryCacheStats().Size)
+}
@@ -4,16 +4,18 @@ import (
 	"encoding/json"
 	"errors"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
 
 // QueryIndexManager provides methods for performing Couchbase query index management.
 type QueryIndexManager struct {
-	provider queryIndexQueryProvider
+	provider   queryIndexQueryProvider
+	queryCache *queryCache
 
 	globalTimeout time.Duration
-	tracer        requestTracer
+	tracer        RequestTracer
 }
 
 type queryIndexQueryProvider interface {
@@ -83,15 +85,24 @@ func (qm *QueryIndexManager) doQuery(q string, opts *QueryOptions) ([][]byte, er
 	return rows, nil
 }
 
+type jsonQueryIndexMetadata struct {
+	NumReplicas   int `json:"num_replica"`
+	NumPartitions int `json:"num_partition"`
+}
+
 type jsonQueryIndex struct {
-	Name      string         `json:"name"`
-	IsPrimary bool           `json:"is_primary"`
-	Type      QueryIndexType `json:"using"`
-	State     string         `json:"state"`
-	Keyspace  string         `json:"keyspace_id"`
-	Namespace string         `json:"namespace_id"`
-	IndexKey  []string       `json:"index_key"`
-	Condition string         `json:"condition"`
+	Name      string                  `json:"name"`
+	IsPrimary bool                    `json:"is_primary"`
+	Type      QueryIndexType          `json:"using"`
+	State     string                  `json:"state"`
+	Keyspace  string                  `json:"keyspace_id"`
+	Namespace string                  `json:"namespace_id"`
+	Bucket    string                  `json:"bucket_id"`
+	Scope     string                  `json:"scope_id"`
+	IndexKey  []string                `json:"index_key"`
+	Condition string                  `json:"condition"`
+	Partition string                  `json:"partition"`
+	Metadata  *jsonQueryIndexMetadata `json:"metadata"`
 }
 
 // QueryIndex represents a Couchbase GSI index.
@@ -104,6 +115,17 @@ type QueryIndex struct {
 	Namespace string
 	IndexKey  []string
 	Condition string
+
+	// BucketName is the bucket that the index belongs to.
+	BucketName string
+	// ScopeName and CollectionName are set when the index is on a collection, rather than on a bucket.
+	ScopeName      string
+	CollectionName string
+	// Partition is the partitioning of a partitioned index, such as HASH(`city`).
+	Partition string
+	// NumReplicas and NumPartitions are only reported by servers which include index metadata in system:indexes.
+	NumReplicas   int
+	NumPartitions int
 }
 
 func (index *QueryIndex) fromData(data jsonQueryIndex) error {
@@ -115,27 +137,80 @@ func (index *QueryIndex) fromData(data jsonQueryIndex) error {
 	index.Namespace = data.Namespace
 	index.IndexKey = data.IndexKey
 	index.Condition = data.Condition
+	index.Partition = data.Partition
+
+	// Indexes on a collection report the collection as their keyspace, along with the bucket and scope.
+	if data.Bucket != "" {
+		index.BucketName = data.Bucket
+		index.ScopeName = data.Scope
+		index.CollectionName = data.Keyspace
+	} else {
+		index.BucketName = data.Keyspace
+	}
+
+	if data.Metadata != nil {
+		index.NumReplicas = data.Metadata.NumReplicas
+		index.NumPartitions = data.Metadata.NumPartitions
+	}
 
 	return nil
 }
 
-type createQueryIndexOptions struct {
-	IgnoreIfExists bool
-	Deferred       bool
+// queryIndexKeyspace returns the escaped keyspace of a bucket, or of a collection when either a scope or a
+// collection name is given, in which case the other defaults to _default.
+// quoteQueryIndexName returns the name of an index as an escaped identifier.
+func quoteQueryIndexName(name string) string {
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
 
-	Timeout       time.Duration
-	RetryStrategy RetryStrategy
+func queryIndexKeyspace(bucketName, scopeName, collectionName string) string {
+	if scopeName == "" && collectionName == "" {
+		return "`" + bucketName + "`"
+	}
+
+	if scopeName == "" {
+		scopeName = "_default"
+	}
+	if collectionName == "" {
+		collectionName = "_default"
+	}
+
+	return "`" + bucketName + "`.`" + scopeName + "`.`" + collectionName + "`"
 }
 
-func (qm *QueryIndexManager) createIndex(
-	tracectx requestSpanContext,
-	bucketName, indexName string,
-	fields []string,
-	opts createQueryIndexOptions,
-) error {
+// queryIndexWith returns the WITH clause for an index, or an empty string if none is needed.
+func queryIndexWith(deferred bool, numReplicas int, nodes []string) string {
+	var with []string
+	if deferred {
+		with = append(with, "\"defer_build\": true")
+	}
+	if numReplicas > 0 {
+		with = append(with, "\"num_replica\": "+strconv.Itoa(numReplicas))
+	}
+	if len(nodes) > 0 {
+		// Marshalling a slice of strings cannot fail.
+		nodesBytes, _ := json.Marshal(nodes)
+		with = append(with, "\"nodes\": "+string(nodesBytes))
+	}
+
+	if len(with) == 0 {
+		return ""
+	}
+	return " WITH {" + strings.Join(with, ", ") + "}"
+}
+
+// createQueryIndexStatement returns the statement which creates an index, keys and partitionBy are N1QL
+// expressions and are used as given. A primary index is created when there are no keys.
+func createQueryIndexStatement(
+	keyspace, indexName string,
+	keys []string,
+	condition string,
+	partitionBy []string,
+	with string,
+) string {
 	var qs string
 
-	if len(fields) == 0 {
+	if len(keys) == 0 {
 		qs += "CREATE PRIMARY INDEX"
 	} else {
 		qs += "CREATE INDEX"
@@ -143,30 +218,81 @@ func (qm *QueryIndexManager) createIndex(
 	if indexName != "" {
 		qs += " `" + indexName + "`"
 	}
-	qs += " ON `" + bucketName + "`"
-	if len(fields) > 0 {
-		qs += " ("
-		for i := 0; i < len(fields); i++ {
-			if i > 0 {
-				qs += ", "
-			}
-			qs += "`" + fields[i] + "`"
-		}
-		qs += ")"
+	qs += " ON " + keyspace
+	if len(keys) > 0 {
+		qs += " (" + strings.Join(keys, ", ") + ")"
+	}
+	if len(partitionBy) > 0 {
+		qs += " PARTITION BY HASH(" + strings.Join(partitionBy, ", ") + ")"
 	}
-	if opts.Deferred {
-		qs += " WITH {\"defer_build\": true}"
+	if condition != "" {
+		qs += " WHERE " + condition
 	}
+	qs += with
+
+	return qs
+}
+
+// dropQueryIndexStatement returns the statement which drops an index, an empty indexName drops the unnamed
+// primary index.
+func dropQueryIndexStatement(bucketName, scopeName, collectionName, indexName string) string {
+	keyspace := queryIndexKeyspace(bucketName, scopeName, collectionName)
+	if indexName == "" {
+		return "DROP PRIMARY INDEX ON " + keyspace
+	}
+	if scopeName == "" && collectionName == "" {
+		return "DROP INDEX `" + bucketName + "`.`" + indexName + "`"
+	}
+	return "DROP INDEX `" + indexName + "` ON " + keyspace
+}
+
+type createQueryIndexOptions struct {
+	IgnoreIfExists bool
+	Deferred       bool
+
+	ScopeName      string
+	CollectionName string
+	Condition      string
+	PartitionBy    []string
+	NumReplicas    int
+	Nodes          []string
+
+	Timeout       time.Duration
+	RetryStrategy RetryStrategy
+}
+
+func (qm *QueryIndexManager) createIndex(
+	tracectx RequestSpanContext,
+	bucketName, indexName string,
+	fields []string,
+	opts createQueryIndexOptions,
+) error {
+	keys := make([]string, len(fields))
+	for i, field := range fields {
+		keys[i] = "`" + field + "`"
+	}
+
+	qs := createQueryIndexStatement(
+		queryIndexKeyspace(bucketName, opts.ScopeName, opts.CollectionName),
+		indexName,
+		keys,
+		opts.Condition,
+		opts.PartitionBy,
+		queryIndexWith(opts.Deferred, opts.NumReplicas, opts.Nodes),
+	)
 
 	_, err := qm.doQuery(qs, &QueryOptions{
 		Timeout:       opts.Timeout,
 		RetryStrategy: opts.RetryStrategy,
-		parentSpan:    tracectx,
+		ParentSpan:    tracectx,
 	})
 	if err != nil {
 		return err
 	}
 
+	// Prepared statements cached before the index existed would not make use of it.
+	qm.queryCache.clear()
+
 	return nil
 }
 
@@ -175,8 +301,23 @@ type CreateQueryIndexOptions struct {
 	IgnoreIfExists bool
 	Deferred       bool
 
+	// ScopeName and CollectionName specify the collection to create the index on, rather than the bucket.
+	// If only one of them is set the other defaults to _default.
+	ScopeName      string
+	CollectionName string
+	// Condition is the WHERE clause of a partial index, as a N1QL expression.
+	Condition string
+	// PartitionBy are the N1QL expressions which the index is hash partitioned by.
+	PartitionBy []string
+	// NumReplicas is the number of replicas of the index to create.
+	NumReplicas int
+	// Nodes are the index service nodes to place the index on, such as "10.0.0.1:8091".
+	Nodes []string
+
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // CreateIndex creates an index over the specified fields.
@@ -196,13 +337,19 @@ func (qm *QueryIndexManager) CreateIndex(bucketName, indexName string, fields []
 		}
 	}
 
-	span := qm.tracer.StartSpan("CreateIndex", nil).
+	span := qm.tracer.StartSpan("CreateIndex", opts.ParentSpan).
 		SetTag("couchbase.service", "query")
 	defer span.Finish()
 
 	return qm.createIndex(span.Context(), bucketName, indexName, fields, createQueryIndexOptions{
 		IgnoreIfExists: opts.IgnoreIfExists,
 		Deferred:       opts.Deferred,
+		ScopeName:      opts.ScopeName,
+		CollectionName: opts.CollectionName,
+		Condition:      opts.Condition,
+		PartitionBy:    opts.PartitionBy,
+		NumReplicas:    opts.NumReplicas,
+		Nodes:          opts.Nodes,
 		Timeout:        opts.Timeout,
 		RetryStrategy:  opts.RetryStrategy,
 	})
@@ -214,8 +361,19 @@ type CreatePrimaryQueryIndexOptions struct {
 	Deferred       bool
 	CustomName     string
 
+	// ScopeName and CollectionName specify the collection to create the index on, rather than the bucket.
+	// If only one of them is set the other defaults to _default.
+	ScopeName      string
+	CollectionName string
+	// NumReplicas is the number of replicas of the index to create.
+	NumReplicas int
+	// Nodes are the index service nodes to place the index on, such as "10.0.0.1:8091".
+	Nodes []string
+
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // CreatePrimaryIndex creates a primary index.  An empty customName uses the default naming.
@@ -224,7 +382,7 @@ func (qm *QueryIndexManager) CreatePrimaryIndex(bucketName string, opts *CreateP
 		opts = &CreatePrimaryQueryIndexOptions{}
 	}
 
-	span := qm.tracer.StartSpan("CreatePrimaryIndex", nil).
+	span := qm.tracer.StartSpan("CreatePrimaryIndex", opts.ParentSpan).
 		SetTag("couchbase.service", "query")
 	defer span.Finish()
 
@@ -236,6 +394,10 @@ func (qm *QueryIndexManager) CreatePrimaryIndex(bucketName string, opts *CreateP
 		createQueryIndexOptions{
 			IgnoreIfExists: opts.IgnoreIfExists,
 			Deferred:       opts.Deferred,
+			ScopeName:      opts.ScopeName,
+			CollectionName: opts.CollectionName,
+			NumReplicas:    opts.NumReplicas,
+			Nodes:          opts.Nodes,
 			Timeout:        opts.Timeout,
 			RetryStrategy:  opts.RetryStrategy,
 		})
@@ -244,32 +406,31 @@ func (qm *QueryIndexManager) CreatePrimaryIndex(bucketName string, opts *CreateP
 type dropQueryIndexOptions struct {
 	IgnoreIfNotExists bool
 
+	ScopeName      string
+	CollectionName string
+
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
 }
 
 func (qm *QueryIndexManager) dropIndex(
-	tracectx requestSpanContext,
+	tracectx RequestSpanContext,
 	bucketName, indexName string,
 	opts dropQueryIndexOptions,
 ) error {
-	var qs string
-
-	if indexName == "" {
-		qs += "DROP PRIMARY INDEX ON `" + bucketName + "`"
-	} else {
-		qs += "DROP INDEX `" + bucketName + "`.`" + indexName + "`"
-	}
+	qs := dropQueryIndexStatement(bucketName, opts.ScopeName, opts.CollectionName, indexName)
 
 	_, err := qm.doQuery(qs, &QueryOptions{
 		Timeout:       opts.Timeout,
 		RetryStrategy: opts.RetryStrategy,
-		parentSpan:    tracectx,
+		ParentSpan:    tracectx,
 	})
 	if err != nil {
 		return err
 	}
 
+	qm.queryCache.clear()
+
 	return nil
 }
 
@@ -277,8 +438,15 @@ func (qm *QueryIndexManager) dropIndex(
 type DropQueryIndexOptions struct {
 	IgnoreIfNotExists bool
 
+	// ScopeName and CollectionName specify the collection that the index is on, rather than the bucket.
+	// If only one of them is set the other defaults to _default.
+	ScopeName      string
+	CollectionName string
+
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // DropIndex drops a specific index by name.
@@ -293,7 +461,7 @@ func (qm *QueryIndexManager) DropIndex(bucketName, indexName string, opts *DropQ
 		}
 	}
 
-	span := qm.tracer.StartSpan("DropIndex", nil).
+	span := qm.tracer.StartSpan("DropIndex", opts.ParentSpan).
 		SetTag("couchbase.service", "query")
 	defer span.Finish()
 
@@ -303,6 +471,8 @@ func (qm *QueryIndexManager) DropIndex(bucketName, indexName string, opts *DropQ
 		indexName,
 		dropQueryIndexOptions{
 			IgnoreIfNotExists: opts.IgnoreIfNotExists,
+			ScopeName:         opts.ScopeName,
+			CollectionName:    opts.CollectionName,
 			Timeout:           opts.Timeout,
 			RetryStrategy:     opts.RetryStrategy,
 		})
@@ -313,8 +483,15 @@ type DropPrimaryQueryIndexOptions struct {
 	IgnoreIfNotExists bool
 	CustomName        string
 
+	// ScopeName and CollectionName specify the collection that the index is on, rather than the bucket.
+	// If only one of them is set the other defaults to _default.
+	ScopeName      string
+	CollectionName string
+
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // DropPrimaryIndex drops the primary index.  Pass an empty customName for unnamed primary indexes.
@@ -323,7 +500,7 @@ func (qm *QueryIndexManager) DropPrimaryIndex(bucketName string, opts *DropPrima
 		opts = &DropPrimaryQueryIndexOptions{}
 	}
 
-	span := qm.tracer.StartSpan("DropPrimaryIndex", nil).
+	span := qm.tracer.StartSpan("DropPrimaryIndex", opts.ParentSpan).
 		SetTag("couchbase.service", "query")
 	defer span.Finish()
 
@@ -333,6 +510,8 @@ func (qm *QueryIndexManager) DropPrimaryIndex(bucketName string, opts *DropPrima
 		opts.CustomName,
 		dropQueryIndexOptions{
 			IgnoreIfNotExists: opts.IgnoreIfNotExists,
+			ScopeName:         opts.ScopeName,
+			CollectionName:    opts.CollectionName,
 			Timeout:           opts.Timeout,
 			RetryStrategy:     opts.RetryStrategy,
 		})
@@ -340,8 +519,15 @@ func (qm *QueryIndexManager) DropPrimaryIndex(bucketName string, opts *DropPrima
 
 // GetAllQueryIndexesOptions is the set of options available to the query indexes GetAllIndexes operation.
 type GetAllQueryIndexesOptions struct {
+	// ScopeName and CollectionName specify the collection to list the indexes of, rather than the bucket.
+	// If only one of them is set the other defaults to _default.
+	ScopeName      string
+	CollectionName string
+
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // GetAllIndexes returns a list of all currently registered indexes.
@@ -350,7 +536,7 @@ func (qm *QueryIndexManager) GetAllIndexes(bucketName string, opts *GetAllQueryI
 		opts = &GetAllQueryIndexesOptions{}
 	}
 
-	span := qm.tracer.StartSpan("GetAllIndexes", nil).
+	span := qm.tracer.StartSpan("GetAllIndexes", opts.ParentSpan).
 		SetTag("couchbase.service", "query")
 	defer span.Finish()
 
@@ -358,17 +544,37 @@ func (qm *QueryIndexManager) GetAllIndexes(bucketName string, opts *GetAllQueryI
 }
 
 func (qm *QueryIndexManager) getAllIndexes(
-	tracectx requestSpanContext,
+	tracectx RequestSpanContext,
 	bucketName string,
 	opts *GetAllQueryIndexesOptions,
 ) ([]QueryIndex, error) {
-	q := "SELECT `indexes`.* FROM system:indexes WHERE keyspace_id=?"
+	// Indexes on a collection whose name matches the bucket, in any bucket, are excluded.
+	q := "SELECT `indexes`.* FROM system:indexes WHERE keyspace_id=? AND bucket_id IS MISSING"
+	params := []interface{}{bucketName}
+	if opts.ScopeName != "" || opts.CollectionName != "" {
+		scopeName, collectionName := opts.ScopeName, opts.CollectionName
+		if scopeName == "" {
+			scopeName = "_default"
+		}
+		if collectionName == "" {
+			collectionName = "_default"
+		}
+
+		q = "SELECT `indexes`.* FROM system:indexes WHERE (bucket_id=? AND scope_id=? AND keyspace_id=?)"
+		params = []interface{}{bucketName, scopeName, collectionName}
+		if scopeName == "_default" && collectionName == "_default" {
+			// Indexes created on the bucket itself are on the default collection.
+			q += " OR (bucket_id IS MISSING AND keyspace_id=?)"
+			params = append(params, bucketName)
+		}
+	}
+
 	rows, err := qm.doQuery(q, &QueryOptions{
-		PositionalParameters: []interface{}{bucketName},
+		PositionalParameters: params,
 		Readonly:             true,
 		Timeout:              opts.Timeout,
 		RetryStrategy:        opts.RetryStrategy,
-		parentSpan:           tracectx,
+		ParentSpan:           tracectx,
 	})
 	if err != nil {
 		return nil, err
@@ -396,8 +602,15 @@ func (qm *QueryIndexManager) getAllIndexes(
 
 // BuildDeferredQueryIndexOptions is the set of options available to the query indexes BuildDeferredIndexes operation.
 type BuildDeferredQueryIndexOptions struct {
+	// ScopeName and CollectionName specify the collection to build the indexes of, rather than the bucket.
+	// If only one of them is set the other defaults to _default.
+	ScopeName      string
+	CollectionName string
+
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // BuildDeferredIndexes builds all indexes which are currently in deferred state.
@@ -406,7 +619,7 @@ func (qm *QueryIndexManager) BuildDeferredIndexes(bucketName string, opts *Build
 		opts = &BuildDeferredQueryIndexOptions{}
 	}
 
-	span := qm.tracer.StartSpan("BuildDeferredIndexes", nil).
+	span := qm.tracer.StartSpan("BuildDeferredIndexes", opts.ParentSpan).
 		SetTag("couchbase.service", "query")
 	defer span.Finish()
 
@@ -414,8 +627,10 @@ func (qm *QueryIndexManager) BuildDeferredIndexes(bucketName string, opts *Build
 		span.Context(),
 		bucketName,
 		&GetAllQueryIndexesOptions{
-			Timeout:       opts.Timeout,
-			RetryStrategy: opts.RetryStrategy,
+			ScopeName:      opts.ScopeName,
+			CollectionName: opts.CollectionName,
+			Timeout:        opts.Timeout,
+			RetryStrategy:  opts.RetryStrategy,
 		})
 	if err != nil {
 		return nil, err
@@ -435,24 +650,26 @@ func (qm *QueryIndexManager) BuildDeferredIndexes(bucketName string, opts *Build
 	}
 
 	var qs string
-	qs += "BUILD INDEX ON `" + bucketName + "`("
+	qs += "BUILD INDEX ON " + queryIndexKeyspace(bucketName, opts.ScopeName, opts.CollectionName) + "("
 	for i := 0; i < len(deferredList); i++ {
 		if i > 0 {
 			qs += ", "
 		}
-		qs += "`" + deferredList[i] + "`"
+		qs += quoteQueryIndexName(deferredList[i])
 	}
 	qs += ")"
 
 	_, err = qm.doQuery(qs, &QueryOptions{
 		Timeout:       opts.Timeout,
 		RetryStrategy: opts.RetryStrategy,
-		parentSpan:    span,
+		ParentSpan:    span.Context(),
 	})
 	if err != nil {
 		return nil, err
 	}
 
+	qm.queryCache.clear()
+
 	return deferredList, nil
 }
 
@@ -485,7 +702,14 @@ func checkIndexesActive(indexes []QueryIndex, checkList []string) (bool, error)
 type WatchQueryIndexOptions struct {
 	WatchPrimary bool
 
+	// ScopeName and CollectionName specify the collection that the indexes are on, rather than the bucket.
+	// If only one of them is set the other defaults to _default.
+	ScopeName      string
+	CollectionName string
+
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // WatchIndexes waits for a set of indexes to come online.
@@ -494,7 +718,7 @@ func (qm *QueryIndexManager) WatchIndexes(bucketName string, watchList []string,
 		opts = &WatchQueryIndexOptions{}
 	}
 
-	span := qm.tracer.StartSpan("WatchIndexes", nil).
+	span := qm.tracer.StartSpan("WatchIndexes", opts.ParentSpan).
 		SetTag("couchbase.service", "query")
 	defer span.Finish()
 
@@ -514,8 +738,10 @@ func (qm *QueryIndexManager) WatchIndexes(bucketName string, watchList []string,
 			span.Context(),
 			bucketName,
 			&GetAllQueryIndexesOptions{
-				Timeout:       deadline.Sub(time.Now()),
-				RetryStrategy: opts.RetryStrategy,
+				ScopeName:      opts.ScopeName,
+				CollectionName: opts.CollectionName,
+				Timeout:        deadline.Sub(time.Now()),
+				RetryStrategy:  opts.RetryStrategy,
 			})
 		if err != nil {
 			return err
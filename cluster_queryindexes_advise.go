@@ -0,0 +1,354 @@
+package gocb
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// QueryIndexRecommendation is a single index recommended by the query index advisor.
+type QueryIndexRecommendation struct {
+	// IndexStatement is the CREATE INDEX statement which creates the index.
+	IndexStatement string
+	// Keyspace is the keyspace, or keyspace alias, that the index is recommended for.
+	Keyspace string
+	// Reason is the rule which led the advisor to recommend the index, it is not set for covering indexes.
+	Reason string
+	// Property describes any additional capability of the index, such as FULL GROUPBY for covering indexes.
+	Property string
+}
+
+// QueryIndexAdvice is the result of running the query index advisor over a statement.
+type QueryIndexAdvice struct {
+	// Statement is the statement which was advised on.
+	Statement string
+	// CurrentIndexes are the existing indexes which the statement would use.
+	CurrentIndexes []QueryIndexRecommendation
+	// RecommendedIndexes are the indexes which the advisor recommends creating for the statement.
+	RecommendedIndexes []QueryIndexRecommendation
+	// CoveringIndexes are indexes which would cover the statement entirely, avoiding the need to fetch documents.
+	CoveringIndexes []QueryIndexRecommendation
+	// Message is set by the advisor when it has no recommendations, describing why.
+	Message string
+	// AppliedIndexes are the names of the indexes which were created, when AdviseQueryIndexOptions.Apply is set.
+	AppliedIndexes []string
+}
+
+// HasRecommendations returns whether the advisor recommended creating any indexes.
+func (advice *QueryIndexAdvice) HasRecommendations() bool {
+	return len(advice.RecommendedIndexes) > 0 || len(advice.CoveringIndexes) > 0
+}
+
+type jsonQueryIndexRecommendation struct {
+	IndexStatement   string `json:"index_statement"`
+	KeyspaceAlias    string `json:"keyspace_alias"`
+	RecommendingRule string `json:"recommending_rule"`
+	IndexProperty    string `json:"index_property"`
+}
+
+func (rec *QueryIndexRecommendation) fromData(data jsonQueryIndexRecommendation) {
+	rec.IndexStatement = data.IndexStatement
+	rec.Keyspace = data.KeyspaceAlias
+	rec.Reason = data.RecommendingRule
+	rec.Property = data.IndexProperty
+}
+
+type jsonQueryIndexAdviseInfo struct {
+	CurrentIndexes     json.RawMessage `json:"current_indexes"`
+	RecommendedIndexes json.RawMessage `json:"recommended_indexes"`
+}
+
+type jsonQueryIndexRecommendations struct {
+	Indexes         []jsonQueryIndexRecommendation `json:"indexes"`
+	CoveringIndexes []jsonQueryIndexRecommendation `json:"covering_indexes"`
+}
+
+type jsonQueryIndexAdvice struct {
+	Advice struct {
+		AdviseInfo json.RawMessage `json:"adviseinfo"`
+	} `json:"advice"`
+	Query string `json:"query"`
+}
+
+func parseQueryIndexRecommendations(data []jsonQueryIndexRecommendation) []QueryIndexRecommendation {
+	if len(data) == 0 {
+		return nil
+	}
+
+	recs := make([]QueryIndexRecommendation, len(data))
+	for i, recData := range data {
+		recs[i].fromData(recData)
+	}
+	return recs
+}
+
+func (advice *QueryIndexAdvice) fromData(data jsonQueryIndexAdvice) error {
+	advice.Statement = data.Query
+
+	// Depending on the server version the advise info is either a single object or an array of objects.
+	var infos []jsonQueryIndexAdviseInfo
+	if len(data.Advice.AdviseInfo) > 0 && data.Advice.AdviseInfo[0] == '[' {
+		if err := json.Unmarshal(data.Advice.AdviseInfo, &infos); err != nil {
+			return wrapError(err, "failed to parse index advice")
+		}
+	} else if len(data.Advice.AdviseInfo) > 0 {
+		var info jsonQueryIndexAdviseInfo
+		if err := json.Unmarshal(data.Advice.AdviseInfo, &info); err != nil {
+			return wrapError(err, "failed to parse index advice")
+		}
+		infos = append(infos, info)
+	}
+
+	for _, info := range infos {
+		if len(info.CurrentIndexes) > 0 && info.CurrentIndexes[0] == '[' {
+			var current []jsonQueryIndexRecommendation
+			if err := json.Unmarshal(info.CurrentIndexes, &current); err != nil {
+				return wrapError(err, "failed to parse current indexes")
+			}
+			advice.CurrentIndexes = append(advice.CurrentIndexes, parseQueryIndexRecommendations(current)...)
+		}
+
+		if len(info.RecommendedIndexes) == 0 {
+			continue
+		}
+		switch info.RecommendedIndexes[0] {
+		case '"':
+			// When there is nothing to recommend the server returns a message in place of the indexes.
+			if err := json.Unmarshal(info.RecommendedIndexes, &advice.Message); err != nil {
+				return wrapError(err, "failed to parse index advice message")
+			}
+		case '[':
+			var recommended []jsonQueryIndexRecommendation
+			if err := json.Unmarshal(info.RecommendedIndexes, &recommended); err != nil {
+				return wrapError(err, "failed to parse recommended indexes")
+			}
+			advice.RecommendedIndexes = append(advice.RecommendedIndexes, parseQueryIndexRecommendations(recommended)...)
+		default:
+			var recommended jsonQueryIndexRecommendations
+			if err := json.Unmarshal(info.RecommendedIndexes, &recommended); err != nil {
+				return wrapError(err, "failed to parse recommended indexes")
+			}
+			advice.RecommendedIndexes = append(advice.RecommendedIndexes,
+				parseQueryIndexRecommendations(recommended.Indexes)...)
+			advice.CoveringIndexes = append(advice.CoveringIndexes,
+				parseQueryIndexRecommendations(recommended.CoveringIndexes)...)
+		}
+	}
+
+	return nil
+}
+
+// adviseIndexStatementRegexp extracts the index name and keyspace from a recommended CREATE INDEX statement, the
+// keyspace is either a bucket or a bucket, scope and collection path.
+var adviseIndexStatementRegexp = regexp.MustCompile(
+	"(?i)^\\s*CREATE\\s+INDEX\\s+`?([^`\\s]+)`?\\s+ON\\s+(?:`?default`?:)?((?:`[^`]+`|[\\w%-]+)(?:\\.(?:`[^`]+`|[\\w%-]+)){0,2})")
+
+// adviseKeyspacePartRegexp matches each of the parts of a keyspace path.
+var adviseKeyspacePartRegexp = regexp.MustCompile("`[^`]+`|[\\w%-]+")
+
+// adviseWithClauseRegexp matches a WITH clause at the end of a recommended CREATE INDEX statement.
+var adviseWithClauseRegexp = regexp.MustCompile("(?is)\\sWITH\\s*(\\{.*\\})\\s*$")
+
+type adviseKeyspace struct {
+	bucketName     string
+	scopeName      string
+	collectionName string
+}
+
+// parseAdviseIndexStatement returns the index name and keyspace of a recommended CREATE INDEX statement.
+func parseAdviseIndexStatement(statement string) (string, adviseKeyspace, bool) {
+	matches := adviseIndexStatementRegexp.FindStringSubmatch(statement)
+	if matches == nil {
+		return "", adviseKeyspace{}, false
+	}
+
+	parts := adviseKeyspacePartRegexp.FindAllString(matches[2], -1)
+	for i, part := range parts {
+		parts[i] = strings.Trim(part, "`")
+	}
+
+	switch len(parts) {
+	case 1:
+		return matches[1], adviseKeyspace{bucketName: parts[0]}, true
+	case 3:
+		return matches[1], adviseKeyspace{bucketName: parts[0], scopeName: parts[1], collectionName: parts[2]}, true
+	}
+
+	return "", adviseKeyspace{}, false
+}
+
+// deferIndexStatement adds defer_build to the WITH clause of a CREATE INDEX statement, adding the clause if the
+// statement does not already have one.
+func deferIndexStatement(statement string) (string, error) {
+	loc := adviseWithClauseRegexp.FindStringSubmatchIndex(statement)
+	if loc == nil {
+		return statement + " WITH {\"defer_build\": true}", nil
+	}
+
+	var with map[string]interface{}
+	if err := json.Unmarshal([]byte(statement[loc[2]:loc[3]]), &with); err != nil {
+		return "", makeInvalidArgumentsError("unable to parse WITH clause of recommended index statement: " + statement)
+	}
+	with["defer_build"] = true
+
+	withBytes, err := json.Marshal(with)
+	if err != nil {
+		return "", err
+	}
+
+	return statement[:loc[2]] + string(withBytes) + statement[loc[3]:], nil
+}
+
+// AdviseQueryIndexOptions is the set of options available to the query indexes Advise operation.
+type AdviseQueryIndexOptions struct {
+	// Apply creates the recommended indexes in deferred mode, then builds them and waits for them to come online.
+	Apply bool
+	// ApplyCovering applies the covering indexes instead of the recommended indexes, when any are recommended.
+	ApplyCovering bool
+	// WatchTimeout is how long to wait for applied indexes to come online, defaulting to Timeout.
+	WatchTimeout time.Duration
+
+	Timeout       time.Duration
+	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
+}
+
+// Advise runs the query index advisor over a statement, returning the indexes which it recommends. If Apply is
+// set then the recommended indexes are also created and built.
+// UNCOMMITTED: This API may change in the future.
+func (qm *QueryIndexManager) Advise(statement string, opts *AdviseQueryIndexOptions) (*QueryIndexAdvice, error) {
+	if opts == nil {
+		opts = &AdviseQueryIndexOptions{}
+	}
+
+	if statement == "" {
+		return nil, makeInvalidArgumentsError("a statement must be specified")
+	}
+
+	span := qm.tracer.StartSpan("Advise", opts.ParentSpan).
+		SetTag("couchbase.service", "query")
+	defer span.Finish()
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = qm.globalTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	rows, err := qm.doQuery("ADVISE "+statement, &QueryOptions{
+		Timeout:       timeout,
+		RetryStrategy: opts.RetryStrategy,
+		ParentSpan:    span.Context(),
+		Adhoc:         true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, ErrNoResult
+	}
+
+	var data jsonQueryIndexAdvice
+	if err := json.Unmarshal(rows[0], &data); err != nil {
+		return nil, wrapError(err, "failed to parse index advice")
+	}
+
+	advice := &QueryIndexAdvice{}
+	if err := advice.fromData(data); err != nil {
+		return nil, err
+	}
+	if advice.Statement == "" {
+		advice.Statement = statement
+	}
+
+	if !opts.Apply {
+		return advice, nil
+	}
+
+	toApply := advice.RecommendedIndexes
+	if opts.ApplyCovering && len(advice.CoveringIndexes) > 0 {
+		toApply = advice.CoveringIndexes
+	}
+
+	applied, err := qm.applyIndexRecommendations(span.Context(), toApply, deadline, opts)
+	advice.AppliedIndexes = applied
+	if err != nil {
+		return advice, err
+	}
+
+	return advice, nil
+}
+
+func (qm *QueryIndexManager) applyIndexRecommendations(
+	tracectx RequestSpanContext,
+	recs []QueryIndexRecommendation,
+	deadline time.Time,
+	opts *AdviseQueryIndexOptions,
+) ([]string, error) {
+	var applied []string
+	var keyspaces []adviseKeyspace
+	keyspaceIndexes := make(map[adviseKeyspace][]string)
+	for _, rec := range recs {
+		indexName, keyspace, ok := parseAdviseIndexStatement(rec.IndexStatement)
+		if !ok {
+			return applied, makeInvalidArgumentsError("unable to parse recommended index statement: " + rec.IndexStatement)
+		}
+
+		// The statement is used as returned by the advisor as it may contain expressions and conditions which
+		// cannot be expressed as a list of fields.
+		statement, err := deferIndexStatement(rec.IndexStatement)
+		if err != nil {
+			return applied, err
+		}
+
+		_, err = qm.doQuery(statement, &QueryOptions{
+			Timeout:       time.Until(deadline),
+			RetryStrategy: opts.RetryStrategy,
+			ParentSpan:    tracectx,
+		})
+		if err != nil && !errors.Is(err, ErrIndexExists) {
+			return applied, err
+		}
+
+		if _, ok := keyspaceIndexes[keyspace]; !ok {
+			keyspaces = append(keyspaces, keyspace)
+		}
+		keyspaceIndexes[keyspace] = append(keyspaceIndexes[keyspace], indexName)
+		applied = append(applied, indexName)
+	}
+
+	watchTimeout := opts.WatchTimeout
+	if watchTimeout == 0 {
+		watchTimeout = time.Until(deadline)
+	}
+
+	for _, keyspace := range keyspaces {
+		_, err := qm.BuildDeferredIndexes(keyspace.bucketName, &BuildDeferredQueryIndexOptions{
+			ScopeName:      keyspace.scopeName,
+			CollectionName: keyspace.collectionName,
+			Timeout:        time.Until(deadline),
+			RetryStrategy:  opts.RetryStrategy,
+			ParentSpan:     tracectx,
+		})
+		if err != nil {
+			return applied, err
+		}
+	}
+
+	for _, keyspace := range keyspaces {
+		err := qm.WatchIndexes(keyspace.bucketName, keyspaceIndexes[keyspace], watchTimeout, &WatchQueryIndexOptions{
+			ScopeName:      keyspace.scopeName,
+			CollectionName: keyspace.collectionName,
+			RetryStrategy:  opts.RetryStrategy,
+			ParentSpan:     tracectx,
+		})
+		if err != nil {
+			return applied, err
+		}
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,182 @@
+package gocb
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// testQueryIndexProvider is a queryIndexQueryProvider which answers statements using handler.
+type testQueryIndexProvider struct {
+	statements []string
+	handler    func(statement string) [][]byte
+}
+
+func (p *testQueryIndexProvider) Query(statement string, opts *QueryOptions) (*QueryResult, error) {
+	p.statements = append(p.statements, statement)
+	return newQueryResult(&testQueryRowsReader{rows: p.handler(statement)}), nil
+}
+
+func (suite *UnitTestSuite) TestQueryIndexAdvise() {
+	advice := `{
+		"#operator": "Advise",
+		"advice": {
+			"#operator": "IndexAdvice",
+			"adviseinfo": {
+				"current_indexes": [
+					{"index_statement": "CREATE PRIMARY INDEX def_primary ON ` + "`travel-sample`" + `", "keyspace_alias": "travel-sample"}
+				],
+				"recommended_indexes": {
+					"covering_indexes": [
+						{"index_property": "FULL GROUPBY", "index_statement": "CREATE INDEX adv_city_type_name ON ` + "`travel-sample`(`city`,`name`)" + ` WHERE ` + "`type`" + ` = 'hotel'", "keyspace_alias": "travel-sample"}
+					],
+					"indexes": [
+						{"index_statement": "CREATE INDEX adv_city_type ON ` + "`travel-sample`(`city`)" + ` WHERE ` + "`type`" + ` = 'hotel'", "keyspace_alias": "travel-sample", "recommending_rule": "Index keys follow order of predicate types: 2. equality/null/missing."}
+					]
+				}
+			}
+		},
+		"query": "SELECT name FROM ` + "`travel-sample`" + ` WHERE type = 'hotel' AND city = 'Paris'"
+	}`
+
+	indexState := "deferred"
+	provider := &testQueryIndexProvider{
+		handler: func(statement string) [][]byte {
+			switch {
+			case strings.HasPrefix(statement, "ADVISE "):
+				return [][]byte{[]byte(advice)}
+			case strings.HasPrefix(statement, "SELECT `indexes`.* FROM system:indexes"):
+				return [][]byte{
+					[]byte(`{"name": "adv_city_type", "state": "` + indexState + `", "keyspace_id": "travel-sample"}`),
+				}
+			case strings.HasPrefix(statement, "BUILD INDEX"):
+				indexState = "online"
+			}
+			return nil
+		},
+	}
+	qm := &QueryIndexManager{
+		provider:      provider,
+		globalTimeout: time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	result, err := qm.Advise("SELECT name FROM `travel-sample` WHERE type = 'hotel' AND city = 'Paris'", nil)
+	suite.Require().Nil(err, err)
+
+	suite.Assert().Equal([]string{"ADVISE SELECT name FROM `travel-sample` WHERE type = 'hotel' AND city = 'Paris'"},
+		provider.statements)
+	suite.Assert().True(result.HasRecommendations())
+	suite.Assert().Equal("SELECT name FROM `travel-sample` WHERE type = 'hotel' AND city = 'Paris'", result.Statement)
+	suite.Require().Len(result.CurrentIndexes, 1)
+	suite.Assert().Equal("travel-sample", result.CurrentIndexes[0].Keyspace)
+	suite.Require().Len(result.RecommendedIndexes, 1)
+	suite.Assert().Equal(QueryIndexRecommendation{
+		IndexStatement: "CREATE INDEX adv_city_type ON `travel-sample`(`city`) WHERE `type` = 'hotel'",
+		Keyspace:       "travel-sample",
+		Reason:         "Index keys follow order of predicate types: 2. equality/null/missing.",
+	}, result.RecommendedIndexes[0])
+	suite.Require().Len(result.CoveringIndexes, 1)
+	suite.Assert().Equal("FULL GROUPBY", result.CoveringIndexes[0].Property)
+	suite.Assert().Empty(result.AppliedIndexes)
+
+	provider.statements = nil
+	result, err = qm.Advise("SELECT name FROM `travel-sample` WHERE type = 'hotel' AND city = 'Paris'",
+		&AdviseQueryIndexOptions{Apply: true})
+	suite.Require().Nil(err, err)
+
+	suite.Assert().Equal([]string{"adv_city_type"}, result.AppliedIndexes)
+	suite.Assert().Equal([]string{
+		"ADVISE SELECT name FROM `travel-sample` WHERE type = 'hotel' AND city = 'Paris'",
+		"CREATE INDEX adv_city_type ON `travel-sample`(`city`) WHERE `type` = 'hotel' WITH {\"defer_build\": true}",
+		"SELECT `indexes`.* FROM system:indexes WHERE keyspace_id=? AND bucket_id IS MISSING",
+		"BUILD INDEX ON `travel-sample`(`adv_city_type`)",
+		"SELECT `indexes`.* FROM system:indexes WHERE keyspace_id=? AND bucket_id IS MISSING",
+	}, provider.statements)
+}
+
+func (suite *UnitTestSuite) TestQueryIndexAdviseNoRecommendations() {
+	provider := &testQueryIndexProvider{
+		handler: func(statement string) [][]byte {
+			return [][]byte{[]byte(`{
+				"#operator": "Advise",
+				"advice": {
+					"#operator": "IndexAdvice",
+					"adviseinfo": [
+						{"recommended_indexes": "No index recommendation at this time."}
+					]
+				}
+			}`)}
+		},
+	}
+	qm := &QueryIndexManager{
+		provider:      provider,
+		globalTimeout: time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	result, err := qm.Advise("SELECT 1", &AdviseQueryIndexOptions{Apply: true})
+	suite.Require().Nil(err, err)
+
+	suite.Assert().False(result.HasRecommendations())
+	suite.Assert().Equal("SELECT 1", result.Statement)
+	suite.Assert().Equal("No index recommendation at this time.", result.Message)
+	suite.Assert().Empty(result.AppliedIndexes)
+	suite.Assert().Len(provider.statements, 1)
+
+	_, err = qm.Advise("", nil)
+	suite.Assert().True(errors.Is(err, ErrInvalidArgument), err)
+}
+
+func (suite *UnitTestSuite) TestQueryIndexAdviseApplyCollection() {
+	advice := `{
+		"advice": {
+			"adviseinfo": [
+				{
+					"recommended_indexes": {
+						"indexes": [
+							{"index_statement": "CREATE INDEX adv_city ON ` + "`default`:`travel-sample`.`inventory`.`hotel`(`city`)" + ` WITH {\"num_replica\": 1}", "keyspace_alias": "hotel"}
+						]
+					}
+				}
+			]
+		}
+	}`
+
+	indexState := "deferred"
+	provider := &testQueryIndexProvider{
+		handler: func(statement string) [][]byte {
+			switch {
+			case strings.HasPrefix(statement, "ADVISE "):
+				return [][]byte{[]byte(advice)}
+			case strings.HasPrefix(statement, "SELECT `indexes`.* FROM system:indexes"):
+				return [][]byte{
+					[]byte(`{"name": "adv_city", "state": "` + indexState + `", "bucket_id": "travel-sample", ` +
+						`"scope_id": "inventory", "keyspace_id": "hotel"}`),
+				}
+			case strings.HasPrefix(statement, "BUILD INDEX"):
+				indexState = "online"
+			}
+			return nil
+		},
+	}
+	qm := &QueryIndexManager{
+		provider:      provider,
+		globalTimeout: time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	result, err := qm.Advise("SELECT name FROM `travel-sample`.inventory.hotel WHERE city = 'Paris'",
+		&AdviseQueryIndexOptions{Apply: true})
+	suite.Require().Nil(err, err)
+
+	suite.Assert().Equal([]string{"adv_city"}, result.AppliedIndexes)
+	suite.Assert().Equal([]string{
+		"ADVISE SELECT name FROM `travel-sample`.inventory.hotel WHERE city = 'Paris'",
+		"CREATE INDEX adv_city ON `default`:`travel-sample`.`inventory`.`hotel`(`city`) " +
+			"WITH {\"defer_build\":true,\"num_replica\":1}",
+		"SELECT `indexes`.* FROM system:indexes WHERE (bucket_id=? AND scope_id=? AND keyspace_id=?)",
+		"BUILD INDEX ON `travel-sample`.`inventory`.`hotel`(`adv_city`)",
+		"SELECT `indexes`.* FROM system:indexes WHERE (bucket_id=? AND scope_id=? AND keyspace_id=?)",
+	}, provider.statements)
+}
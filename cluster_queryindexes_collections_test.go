@@ -0,0 +1,88 @@
+package gocb
+
+import (
+	"strings"
+	"time"
+)
+
+func (suite *UnitTestSuite) TestQueryIndexesCollectionStatements() {
+	provider := &testQueryIndexProvider{
+		handler: func(statement string) [][]byte {
+			if strings.HasPrefix(statement, "SELECT") {
+				return [][]byte{
+					[]byte(`{"name": "idx_city", "state": "deferred", "bucket_id": "travel-sample", "scope_id": "inventory", ` +
+						`"keyspace_id": "hotel", "index_key": ["` + "`city`" + `"], "condition": "(` + "`type`" + ` = \"hotel\")", ` +
+						`"partition": "HASH(` + "`city`" + `)", "metadata": {"num_replica": 1, "num_partition": 8}}`),
+				}
+			}
+			return nil
+		},
+	}
+	qm := &QueryIndexManager{
+		provider:      provider,
+		globalTimeout: time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	err := qm.CreateIndex("travel-sample", "idx_city", []string{"city"}, &CreateQueryIndexOptions{
+		ScopeName:      "inventory",
+		CollectionName: "hotel",
+		Condition:      "`type` = \"hotel\"",
+		PartitionBy:    []string{"`city`"},
+		NumReplicas:    1,
+		Nodes:          []string{"10.0.0.1:8091", "10.0.0.2:8091"},
+		Deferred:       true,
+	})
+	suite.Require().Nil(err, err)
+
+	err = qm.CreatePrimaryIndex("travel-sample", &CreatePrimaryQueryIndexOptions{CollectionName: "hotel"})
+	suite.Require().Nil(err, err)
+
+	err = qm.DropIndex("travel-sample", "idx_city", &DropQueryIndexOptions{ScopeName: "inventory", CollectionName: "hotel"})
+	suite.Require().Nil(err, err)
+
+	err = qm.DropPrimaryIndex("travel-sample", nil)
+	suite.Require().Nil(err, err)
+
+	indexes, err := qm.GetAllIndexes("travel-sample", &GetAllQueryIndexesOptions{ScopeName: "inventory", CollectionName: "hotel"})
+	suite.Require().Nil(err, err)
+
+	_, err = qm.BuildDeferredIndexes("travel-sample", &BuildDeferredQueryIndexOptions{ScopeName: "inventory", CollectionName: "hotel"})
+	suite.Require().Nil(err, err)
+
+	_, err = qm.GetAllIndexes("travel-sample", &GetAllQueryIndexesOptions{ScopeName: "_default"})
+	suite.Require().Nil(err, err)
+
+	_, err = qm.GetAllIndexes("travel-sample", nil)
+	suite.Require().Nil(err, err)
+
+	suite.Assert().Equal([]string{
+		"CREATE INDEX `idx_city` ON `travel-sample`.`inventory`.`hotel` (`city`) PARTITION BY HASH(`city`) " +
+			"WHERE `type` = \"hotel\" WITH {\"defer_build\": true, \"num_replica\": 1, " +
+			"\"nodes\": [\"10.0.0.1:8091\",\"10.0.0.2:8091\"]}",
+		"CREATE PRIMARY INDEX ON `travel-sample`.`_default`.`hotel`",
+		"DROP INDEX `idx_city` ON `travel-sample`.`inventory`.`hotel`",
+		"DROP PRIMARY INDEX ON `travel-sample`",
+		"SELECT `indexes`.* FROM system:indexes WHERE (bucket_id=? AND scope_id=? AND keyspace_id=?)",
+		"SELECT `indexes`.* FROM system:indexes WHERE (bucket_id=? AND scope_id=? AND keyspace_id=?)",
+		"BUILD INDEX ON `travel-sample`.`inventory`.`hotel`(`idx_city`)",
+		"SELECT `indexes`.* FROM system:indexes WHERE (bucket_id=? AND scope_id=? AND keyspace_id=?) " +
+			"OR (bucket_id IS MISSING AND keyspace_id=?)",
+		"SELECT `indexes`.* FROM system:indexes WHERE keyspace_id=? AND bucket_id IS MISSING",
+	}, provider.statements)
+
+	suite.Require().Len(indexes, 1)
+	suite.Assert().Equal(QueryIndex{
+		Name:           "idx_city",
+		State:          "deferred",
+		Keyspace:       "hotel",
+		IndexKey:       []string{"`city`"},
+		Condition:      "(`type` = \"hotel\")",
+		BucketName:     "travel-sample",
+		ScopeName:      "inventory",
+		CollectionName: "hotel",
+		Partition:      "HASH(`city`)",
+		NumReplicas:    1,
+		NumPartitions:  8,
+	}, indexes[0])
+}
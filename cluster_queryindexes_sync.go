@@ -0,0 +1,470 @@
+package gocb
+
+import (
+	"strings"
+	"time"
+	"unicode"
+)
+
+// QueryIndexSpec is the desired definition of a query index, as used by Sync.
+type QueryIndexSpec struct {
+	// Name is the name of the index, it may be left empty for a primary index which is then named #primary.
+	Name string
+	// IsPrimary specifies that the index is a primary index, in which case Keys must be empty.
+	IsPrimary bool
+	// Keys are the N1QL expressions which are indexed, such as "`city`" or "LOWER(`name`)".
+	Keys []string
+	// Where is the N1QL condition of a partial index.
+	Where string
+	// PartitionBy are the N1QL expressions which the index is hash partitioned by.
+	PartitionBy []string
+	// NumReplicas is the number of replicas of the index to create.
+	NumReplicas int
+	// Nodes are the index service nodes to place the index on, such as "10.0.0.1:8091".
+	Nodes []string
+}
+
+func (spec QueryIndexSpec) indexName() string {
+	if spec.Name == "" && spec.IsPrimary {
+		return "#primary"
+	}
+	return spec.Name
+}
+
+// createStatement returns the statement which creates the index described by spec in deferred mode.
+func (spec QueryIndexSpec) createStatement(keyspace string) string {
+	return createQueryIndexStatement(
+		keyspace,
+		spec.Name,
+		spec.Keys,
+		spec.Where,
+		spec.PartitionBy,
+		queryIndexWith(true, spec.NumReplicas, spec.Nodes),
+	)
+}
+
+// normalizeIndexExpression strips the quoting, spacing and redundant parentheses which the query service adds to
+// expressions when it stores an index definition, so that they can be compared with those in a QueryIndexSpec.
+// Identifiers and keywords are compared case insensitively, string literals are compared exactly.
+func normalizeIndexExpression(expr string) string {
+	return strings.Join(removeRedundantParens(tokenizeIndexExpression(expr)), "")
+}
+
+func isIndexExpressionWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$' || r == '.' || r == '#'
+}
+
+func isIndexExpressionWord(token string) bool {
+	for _, r := range token {
+		return isIndexExpressionWordRune(r)
+	}
+	return false
+}
+
+// tokenizeIndexExpression splits an expression into words, string literals and single character symbols.
+// Escaped identifiers are unquoted and joined to any adjoining word, and string literals are always written
+// within double quotes.
+func tokenizeIndexExpression(expr string) []string {
+	var tokens []string
+	var word strings.Builder
+	flushWord := func() {
+		if word.Len() > 0 {
+			tokens = append(tokens, word.String())
+			word.Reset()
+		}
+	}
+
+	runes := []rune(expr)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case r == '`':
+			for i++; i < len(runes) && runes[i] != '`'; i++ {
+				word.WriteRune(unicode.ToLower(runes[i]))
+			}
+		case r == '\'', r == '"':
+			flushWord()
+
+			var literal strings.Builder
+			literal.WriteRune('"')
+			for i++; i < len(runes); i++ {
+				c := runes[i]
+				if c == '\\' && i+1 < len(runes) {
+					i++
+					c = runes[i]
+				} else if c == r {
+					// A doubled quote is an escaped quote, otherwise it ends the literal.
+					if i+1 >= len(runes) || runes[i+1] != r {
+						break
+					}
+					i++
+				}
+				if c == '"' || c == '\\' {
+					literal.WriteRune('\\')
+				}
+				literal.WriteRune(c)
+			}
+			literal.WriteRune('"')
+			tokens = append(tokens, literal.String())
+		case unicode.IsSpace(r):
+			flushWord()
+		case isIndexExpressionWordRune(r):
+			word.WriteRune(unicode.ToLower(r))
+		default:
+			flushWord()
+			tokens = append(tokens, string(r))
+		}
+	}
+	flushWord()
+
+	return tokens
+}
+
+// matchParens returns the index of the closing parenthesis for each opening parenthesis in tokens, or nil if
+// they are not balanced.
+func matchParens(tokens []string) map[int]int {
+	matches := make(map[int]int)
+	var open []int
+	for i, token := range tokens {
+		switch token {
+		case "(":
+			open = append(open, i)
+		case ")":
+			if len(open) == 0 {
+				return nil
+			}
+			matches[open[len(open)-1]] = i
+			open = open[:len(open)-1]
+		}
+	}
+	if len(open) > 0 {
+		return nil
+	}
+
+	return matches
+}
+
+// removeRedundantParens removes parentheses which surround the whole expression, directly surround another pair
+// of parentheses or surround a single word or literal which is not the argument of a function.
+func removeRedundantParens(tokens []string) []string {
+	for {
+		matches := matchParens(tokens)
+		if matches == nil {
+			return tokens
+		}
+
+		open, close := -1, -1
+		for o, c := range matches {
+			wholeExpr := o == 0 && c == len(tokens)-1
+			doubled := o > 0 && c < len(tokens)-1 && matches[o-1] == c+1
+			single := c == o+2 && tokens[o+1] != "(" && (o == 0 || !isIndexExpressionWord(tokens[o-1])) &&
+				(isIndexExpressionWord(tokens[o+1]) || strings.HasPrefix(tokens[o+1], "\""))
+			if wholeExpr || doubled || single {
+				open, close = o, c
+				break
+			}
+		}
+		if open < 0 {
+			return tokens
+		}
+
+		stripped := make([]string, 0, len(tokens)-2)
+		stripped = append(stripped, tokens[:open]...)
+		stripped = append(stripped, tokens[open+1:close]...)
+		tokens = append(stripped, tokens[close+1:]...)
+	}
+}
+
+// matches returns whether the existing index has the keys, condition and partitioning described by spec. The
+// number of replicas is only compared when the server reports it.
+func (spec QueryIndexSpec) matches(index QueryIndex) bool {
+	if spec.IsPrimary != index.IsPrimary {
+		return false
+	}
+	if normalizeIndexExpression(spec.Where) != normalizeIndexExpression(index.Condition) {
+		return false
+	}
+	var partition string
+	if len(spec.PartitionBy) > 0 {
+		partition = "HASH(" + strings.Join(spec.PartitionBy, ",") + ")"
+	}
+	if normalizeIndexExpression(partition) != normalizeIndexExpression(index.Partition) {
+		return false
+	}
+	if index.NumReplicas > 0 && spec.NumReplicas != index.NumReplicas {
+		return false
+	}
+	if len(spec.Keys) != len(index.IndexKey) {
+		return false
+	}
+	for i, key := range spec.Keys {
+		if normalizeIndexExpression(key) != normalizeIndexExpression(index.IndexKey[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// QueryIndexSyncPlan is the set of changes which Sync makes, or would make when run as a dry run.
+type QueryIndexSyncPlan struct {
+	// Create are the indexes which do not exist yet.
+	Create []QueryIndexSpec
+	// Rebuild are the indexes whose definition differs from the desired definition, they are dropped and
+	// created again.
+	Rebuild []QueryIndexSpec
+	// Drop are the names of the existing indexes which are not desired, they are only dropped when
+	// SyncQueryIndexOptions.DropExtras is set.
+	Drop []string
+	// Unchanged are the names of the indexes which already match their desired definition.
+	Unchanged []string
+	// Build are the names of the indexes which are built and waited for, these are the created and rebuilt
+	// indexes along with any unchanged index which was left deferred, such as by an earlier Sync which failed.
+	Build []string
+	// Statements are the statements which Sync executes, in order, ending with the statement which builds the
+	// indexes in Build. The indexes are built using BuildDeferredIndexes, which also builds any other deferred
+	// index of the bucket or collection.
+	Statements []string
+}
+
+// HasChanges returns whether the plan changes any index.
+func (plan *QueryIndexSyncPlan) HasChanges() bool {
+	return len(plan.Create) > 0 || len(plan.Rebuild) > 0 || len(plan.Drop) > 0 || len(plan.Build) > 0
+}
+
+// SyncQueryIndexOptions is the set of options available to the query indexes Sync operation.
+type SyncQueryIndexOptions struct {
+	// DropExtras drops any existing index which is not in the desired set.
+	DropExtras bool
+	// DryRun computes the plan without changing any index.
+	DryRun bool
+	// WatchTimeout is how long to wait for created indexes to come online, defaulting to Timeout.
+	WatchTimeout time.Duration
+
+	// ScopeName and CollectionName specify the collection to synchronise the indexes of, rather than the bucket.
+	// If only one of them is set the other defaults to _default.
+	ScopeName      string
+	CollectionName string
+
+	Timeout       time.Duration
+	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
+}
+
+func validateQueryIndexSpecs(desired []QueryIndexSpec) error {
+	seen := make(map[string]struct{}, len(desired))
+	for _, spec := range desired {
+		if spec.IsPrimary && len(spec.Keys) > 0 {
+			return makeInvalidArgumentsError("a primary index cannot have keys")
+		}
+		if !spec.IsPrimary {
+			if spec.Name == "" {
+				return makeInvalidArgumentsError("an invalid index name was specified")
+			}
+			if len(spec.Keys) == 0 {
+				return makeInvalidArgumentsError("you must specify at least one key to index for " + spec.Name)
+			}
+		}
+
+		name := spec.indexName()
+		if _, ok := seen[name]; ok {
+			return makeInvalidArgumentsError("index " + name + " was specified more than once")
+		}
+		seen[name] = struct{}{}
+	}
+
+	return nil
+}
+
+// Sync makes the indexes of a bucket, or of a collection, match the desired set. Missing indexes are created, indexes whose
+// definition has changed are dropped and created again and, when DropExtras is set, indexes which are not
+// desired are dropped. Indexes are created in deferred mode and then built together, using BuildDeferredIndexes,
+// before waiting for them to come online.
+// UNCOMMITTED: This API may change in the future.
+func (qm *QueryIndexManager) Sync(bucketName string, desired []QueryIndexSpec, opts *SyncQueryIndexOptions) (*QueryIndexSyncPlan, error) {
+	if opts == nil {
+		opts = &SyncQueryIndexOptions{}
+	}
+
+	if err := validateQueryIndexSpecs(desired); err != nil {
+		return nil, err
+	}
+
+	span := qm.tracer.StartSpan("Sync", opts.ParentSpan).
+		SetTag("couchbase.service", "query")
+	defer span.Finish()
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = qm.globalTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	indexes, err := qm.getAllIndexes(span.Context(), bucketName, &GetAllQueryIndexesOptions{
+		ScopeName:      opts.ScopeName,
+		CollectionName: opts.CollectionName,
+		Timeout:        timeout,
+		RetryStrategy:  opts.RetryStrategy,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	plan := planQueryIndexSync(bucketName, opts.ScopeName, opts.CollectionName, indexes, desired, opts.DropExtras)
+	if opts.DryRun || !plan.HasChanges() {
+		return plan, nil
+	}
+
+	// The last statement builds the indexes, which is done through BuildDeferredIndexes instead.
+	statements := plan.Statements
+	if len(plan.Build) > 0 {
+		statements = statements[:len(statements)-1]
+	}
+	for _, statement := range statements {
+		_, err := qm.doQuery(statement, &QueryOptions{
+			Timeout:       time.Until(deadline),
+			RetryStrategy: opts.RetryStrategy,
+			ParentSpan:    span.Context(),
+		})
+		if err != nil {
+			return plan, err
+		}
+	}
+	qm.queryCache.clear()
+
+	if len(plan.Build) == 0 {
+		return plan, nil
+	}
+
+	_, err = qm.BuildDeferredIndexes(bucketName, &BuildDeferredQueryIndexOptions{
+		ScopeName:      opts.ScopeName,
+		CollectionName: opts.CollectionName,
+		Timeout:        time.Until(deadline),
+		RetryStrategy:  opts.RetryStrategy,
+		ParentSpan:     span.Context(),
+	})
+	if err != nil {
+		return plan, err
+	}
+
+	watchTimeout := opts.WatchTimeout
+	if watchTimeout == 0 {
+		watchTimeout = time.Until(deadline)
+	}
+
+	err = qm.WatchIndexes(bucketName, plan.Build, watchTimeout, &WatchQueryIndexOptions{
+		ScopeName:      opts.ScopeName,
+		CollectionName: opts.CollectionName,
+		RetryStrategy:  opts.RetryStrategy,
+		ParentSpan:     span.Context(),
+	})
+	if err != nil {
+		return plan, err
+	}
+
+	return plan, nil
+}
+
+// queryIndexInKeyspace returns whether index is on the bucket, or on the collection when a scope or collection is
+// given. Indexes on the bucket itself are on its default collection.
+func queryIndexInKeyspace(index QueryIndex, bucketName, scopeName, collectionName string) bool {
+	if scopeName == "" && collectionName == "" {
+		return index.CollectionName == ""
+	}
+
+	if scopeName == "" {
+		scopeName = "_default"
+	}
+	if collectionName == "" {
+		collectionName = "_default"
+	}
+
+	if index.CollectionName == "" {
+		return scopeName == "_default" && collectionName == "_default"
+	}
+	return index.BucketName == bucketName && index.ScopeName == scopeName && index.CollectionName == collectionName
+}
+
+func planQueryIndexSync(
+	bucketName, scopeName, collectionName string,
+	indexes []QueryIndex,
+	desired []QueryIndexSpec,
+	dropExtras bool,
+) *QueryIndexSyncPlan {
+	keyspace := queryIndexKeyspace(bucketName, scopeName, collectionName)
+	dropStatement := func(index QueryIndex) string {
+		if index.IsPrimary && index.Name == "#primary" {
+			return dropQueryIndexStatement(bucketName, scopeName, collectionName, "")
+		}
+		return dropQueryIndexStatement(bucketName, scopeName, collectionName, index.Name)
+	}
+
+	// Only indexes on the keyspace itself are synced, indexes on a collection which shares the name of the bucket
+	// are never dropped as extras.
+	var keyspaceIndexes []QueryIndex
+	for _, index := range indexes {
+		if queryIndexInKeyspace(index, bucketName, scopeName, collectionName) {
+			keyspaceIndexes = append(keyspaceIndexes, index)
+		}
+	}
+	indexes = keyspaceIndexes
+
+	existing := make(map[string]QueryIndex, len(indexes))
+	for _, index := range indexes {
+		existing[index.Name] = index
+	}
+
+	plan := &QueryIndexSyncPlan{}
+	var createStatements []string
+	wanted := make(map[string]struct{}, len(desired))
+	for _, spec := range desired {
+		name := spec.indexName()
+		wanted[name] = struct{}{}
+
+		index, ok := existing[name]
+		if !ok {
+			plan.Create = append(plan.Create, spec)
+			createStatements = append(createStatements, spec.createStatement(keyspace))
+			plan.Build = append(plan.Build, name)
+			continue
+		}
+
+		if spec.matches(index) {
+			plan.Unchanged = append(plan.Unchanged, name)
+			if index.State == "deferred" {
+				plan.Build = append(plan.Build, name)
+			}
+			continue
+		}
+
+		plan.Rebuild = append(plan.Rebuild, spec)
+		plan.Statements = append(plan.Statements, dropStatement(index))
+		createStatements = append(createStatements, spec.createStatement(keyspace))
+		plan.Build = append(plan.Build, name)
+	}
+
+	if dropExtras {
+		for _, index := range indexes {
+			if _, ok := wanted[index.Name]; ok {
+				continue
+			}
+
+			plan.Drop = append(plan.Drop, index.Name)
+			plan.Statements = append(plan.Statements, dropStatement(index))
+		}
+	}
+
+	// Indexes are dropped before any are created so that a rebuilt index can reuse its name, the created indexes
+	// are then built together.
+	plan.Statements = append(plan.Statements, createStatements...)
+	if len(plan.Build) > 0 {
+		buildNames := make([]string, len(plan.Build))
+		for i, name := range plan.Build {
+			buildNames[i] = quoteQueryIndexName(name)
+		}
+		plan.Statements = append(plan.Statements, "BUILD INDEX ON "+keyspace+"("+strings.Join(buildNames, ", ")+")")
+	}
+
+	return plan
+}
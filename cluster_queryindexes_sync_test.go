@@ -0,0 +1,239 @@
+package gocb
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+func (suite *UnitTestSuite) TestQueryIndexSync() {
+	created, built := false, false
+	provider := &testQueryIndexProvider{
+		handler: func(statement string) [][]byte {
+			switch {
+			case strings.HasPrefix(statement, "CREATE INDEX"):
+				created = true
+			case strings.HasPrefix(statement, "BUILD INDEX"):
+				built = true
+			}
+			if !strings.HasPrefix(statement, "SELECT `indexes`.* FROM system:indexes") {
+				return nil
+			}
+
+			if created {
+				state := "deferred"
+				if built {
+					state = "online"
+				}
+				return [][]byte{
+					[]byte(`{"name": "idx_city", "state": "online", "index_key": ["` + "`city`" + `"], "condition": "(` + "`type`" + ` = \"hotel\")"}`),
+					[]byte(`{"name": "idx_name", "state": "` + state + `", "index_key": ["` + "`name`" + `", "` + "`city`" + `"]}`),
+					[]byte(`{"name": "idx_country", "state": "` + state + `", "index_key": ["` + "`country`" + `"]}`),
+				}
+			}
+			return [][]byte{
+				[]byte(`{"name": "idx_city", "state": "online", "index_key": ["` + "`city`" + `"], "condition": "(` + "`type`" + ` = \"hotel\")"}`),
+				[]byte(`{"name": "idx_name", "state": "online", "index_key": ["` + "`name`" + `"]}`),
+				[]byte(`{"name": "idx_old", "state": "online", "index_key": ["` + "`old`" + `"]}`),
+			}
+		},
+	}
+	qm := &QueryIndexManager{
+		provider:      provider,
+		globalTimeout: time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	desired := []QueryIndexSpec{
+		{Name: "idx_city", Keys: []string{"city"}, Where: "type = 'hotel'"},
+		{Name: "idx_name", Keys: []string{"`name`", "`city`"}},
+		{Name: "idx_country", Keys: []string{"`country`"}, PartitionBy: []string{"META().id"}, NumReplicas: 1},
+	}
+	expectedStatements := []string{
+		"DROP INDEX `travel-sample`.`idx_name`",
+		"DROP INDEX `travel-sample`.`idx_old`",
+		"CREATE INDEX `idx_name` ON `travel-sample` (`name`, `city`) WITH {\"defer_build\": true}",
+		"CREATE INDEX `idx_country` ON `travel-sample` (`country`) PARTITION BY HASH(META().id) " +
+			"WITH {\"defer_build\": true, \"num_replica\": 1}",
+		"BUILD INDEX ON `travel-sample`(`idx_name`, `idx_country`)",
+	}
+
+	plan, err := qm.Sync("travel-sample", desired, &SyncQueryIndexOptions{DropExtras: true, DryRun: true})
+	suite.Require().Nil(err, err)
+
+	suite.Assert().Len(provider.statements, 1)
+	suite.Assert().True(plan.HasChanges())
+	suite.Assert().Equal([]string{"idx_city"}, plan.Unchanged)
+	suite.Assert().Equal([]string{"idx_old"}, plan.Drop)
+	suite.Require().Len(plan.Create, 1)
+	suite.Assert().Equal("idx_country", plan.Create[0].Name)
+	suite.Require().Len(plan.Rebuild, 1)
+	suite.Assert().Equal("idx_name", plan.Rebuild[0].Name)
+	suite.Assert().Equal(expectedStatements, plan.Statements)
+
+	provider.statements = nil
+	_, err = qm.Sync("travel-sample", desired, &SyncQueryIndexOptions{DropExtras: true})
+	suite.Require().Nil(err, err)
+
+	// The indexes are built through BuildDeferredIndexes, which looks up the deferred indexes first.
+	executed := append(append([]string{}, expectedStatements[:len(expectedStatements)-1]...),
+		"SELECT `indexes`.* FROM system:indexes WHERE keyspace_id=? AND bucket_id IS MISSING",
+		expectedStatements[len(expectedStatements)-1])
+	suite.Require().True(len(provider.statements) > len(executed)+1, provider.statements)
+	suite.Assert().Equal(executed, provider.statements[1:len(executed)+1])
+}
+
+func (suite *UnitTestSuite) TestQueryIndexSyncBuildsDeferred() {
+	built := false
+	provider := &testQueryIndexProvider{
+		handler: func(statement string) [][]byte {
+			if strings.HasPrefix(statement, "BUILD INDEX") {
+				built = true
+			}
+			if !strings.HasPrefix(statement, "SELECT `indexes`.* FROM system:indexes") {
+				return nil
+			}
+
+			state := "deferred"
+			if built {
+				state = "online"
+			}
+			return [][]byte{
+				[]byte(`{"name": "idx_city", "state": "online", "index_key": ["` + "`city`" + `"]}`),
+				[]byte(`{"name": "idx_name", "state": "` + state + `", "index_key": ["` + "`name`" + `"]}`),
+			}
+		},
+	}
+	qm := &QueryIndexManager{
+		provider:      provider,
+		globalTimeout: time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	desired := []QueryIndexSpec{
+		{Name: "idx_city", Keys: []string{"`city`"}},
+		{Name: "idx_name", Keys: []string{"`name`"}},
+	}
+
+	// An index which matches its definition but was never built, such as by a Sync which failed part way
+	// through, is still built and waited for.
+	plan, err := qm.Sync("travel-sample", desired, &SyncQueryIndexOptions{DryRun: true})
+	suite.Require().Nil(err, err)
+	suite.Assert().True(plan.HasChanges())
+	suite.Assert().Equal([]string{"idx_city", "idx_name"}, plan.Unchanged)
+	suite.Assert().Equal([]string{"idx_name"}, plan.Build)
+	suite.Assert().Equal([]string{"BUILD INDEX ON `travel-sample`(`idx_name`)"}, plan.Statements)
+
+	provider.statements = nil
+	_, err = qm.Sync("travel-sample", desired, nil)
+	suite.Require().Nil(err, err)
+	suite.Assert().True(built)
+	suite.Assert().Contains(provider.statements, "BUILD INDEX ON `travel-sample`(`idx_name`)")
+
+	plan, err = qm.Sync("travel-sample", desired, &SyncQueryIndexOptions{DryRun: true})
+	suite.Require().Nil(err, err)
+	suite.Assert().False(plan.HasChanges())
+}
+
+func (suite *UnitTestSuite) TestQueryIndexSyncInvalidSpecs() {
+	qm := &QueryIndexManager{
+		provider:      &testQueryIndexProvider{handler: func(string) [][]byte { return nil }},
+		globalTimeout: time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	specs := [][]QueryIndexSpec{
+		{{Name: "idx"}},
+		{{Keys: []string{"city"}}},
+		{{IsPrimary: true, Keys: []string{"city"}}},
+		{{Name: "idx", Keys: []string{"city"}}, {Name: "idx", Keys: []string{"name"}}},
+	}
+	for _, spec := range specs {
+		_, err := qm.Sync("travel-sample", spec, nil)
+		suite.Assert().True(errors.Is(err, ErrInvalidArgument), err)
+	}
+
+	plan, err := qm.Sync("travel-sample", []QueryIndexSpec{{IsPrimary: true}}, &SyncQueryIndexOptions{DryRun: true})
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal([]string{
+		"CREATE PRIMARY INDEX ON `travel-sample` WITH {\"defer_build\": true}",
+		"BUILD INDEX ON `travel-sample`(`#primary`)",
+	}, plan.Statements)
+}
+
+func (suite *UnitTestSuite) TestQueryIndexSyncCollection() {
+	provider := &testQueryIndexProvider{
+		handler: func(statement string) [][]byte {
+			return [][]byte{
+				[]byte(`{"name": "idx_city", "state": "online", "bucket_id": "travel-sample", "scope_id": "inventory", ` +
+					`"keyspace_id": "hotel", "index_key": ["` + "`city`" + `"], "partition": "HASH(` + "`city`" + `)"}`),
+				[]byte(`{"name": "#primary", "is_primary": true, "state": "online", "bucket_id": "travel-sample", ` +
+					`"scope_id": "inventory", "keyspace_id": "hotel"}`),
+			}
+		},
+	}
+	qm := &QueryIndexManager{
+		provider:      provider,
+		globalTimeout: time.Second,
+		tracer:        &noopTracer{},
+	}
+
+	plan, err := qm.Sync("travel-sample", []QueryIndexSpec{
+		{Name: "idx_city", Keys: []string{"`city`"}},
+	}, &SyncQueryIndexOptions{
+		ScopeName:      "inventory",
+		CollectionName: "hotel",
+		DropExtras:     true,
+		DryRun:         true,
+	})
+	suite.Require().Nil(err, err)
+
+	suite.Assert().Equal([]string{
+		"SELECT `indexes`.* FROM system:indexes WHERE (bucket_id=? AND scope_id=? AND keyspace_id=?)",
+	}, provider.statements)
+	suite.Assert().Equal([]string{"#primary"}, plan.Drop)
+	suite.Assert().Equal([]string{
+		"DROP INDEX `idx_city` ON `travel-sample`.`inventory`.`hotel`",
+		"DROP PRIMARY INDEX ON `travel-sample`.`inventory`.`hotel`",
+		"CREATE INDEX `idx_city` ON `travel-sample`.`inventory`.`hotel` (`city`) WITH {\"defer_build\": true}",
+		"BUILD INDEX ON `travel-sample`.`inventory`.`hotel`(`idx_city`)",
+	}, plan.Statements)
+}
+
+func (suite *UnitTestSuite) TestNormalizeIndexExpression() {
+	equal := [][2]string{
+		{"(`type` = \"hotel\")", "type = 'hotel'"},
+		{"lower(`name`)", "LOWER((name))"},
+		{"((`a` + `b`) * `c`)", "(a + b) * c"},
+		{"(`name` = \"it's\")", "name = 'it''s'"},
+		{"HASH((meta().`id`))", "HASH(META().id)"},
+	}
+	for _, exprs := range equal {
+		suite.Assert().Equal(normalizeIndexExpression(exprs[0]), normalizeIndexExpression(exprs[1]), exprs)
+	}
+
+	notEqual := [][2]string{
+		{"(`type` = \"Hotel\")", "type = 'hotel'"},
+		{"(`a` + `b`) * `c`", "a + (b * c)"},
+		{"lower(`name`)", "lower(`name `)"},
+	}
+	for _, exprs := range notEqual {
+		suite.Assert().NotEqual(normalizeIndexExpression(exprs[0]), normalizeIndexExpression(exprs[1]), exprs)
+	}
+}
+
+func (suite *UnitTestSuite) TestQueryIndexSyncPlanKeyspace() {
+	indexes := []QueryIndex{
+		{Name: "idx_old", State: "online", Keyspace: "travel-sample", BucketName: "travel-sample", IndexKey: []string{"`old`"}},
+		// An index on a collection of another bucket which shares the name of the bucket.
+		{Name: "idx_other", State: "online", Keyspace: "travel-sample", BucketName: "other", ScopeName: "inventory",
+			CollectionName: "travel-sample", IndexKey: []string{"`other`"}},
+	}
+	desired := []QueryIndexSpec{
+		{Name: "idx_`quoted`", Keys: []string{"`name`"}},
+	}
+
+	plan := planQueryIndexSync("travel-sample", "", "", indexes, desired, true)
+	suite.Assert().Equal([]string{"idx_old"}, plan.Drop)
+	suite.Assert().Equal("BUILD INDEX ON `travel-sample`(`idx_``quoted```)", plan.Statements[len(plan.Statements)-1])
+}
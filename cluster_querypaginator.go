@@ -0,0 +1,351 @@
+package gocb
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+)
+
+// QueryPaginatorKeysetPlaceholder is the placeholder within a paginated statement which is replaced by the keyset
+// predicate that seeks past the previous page.
+const QueryPaginatorKeysetPlaceholder = "{{keyset}}"
+
+// QueryPaginatorKey is one of the keys which a paginated query is ordered by. Together the keys must uniquely
+// identify each row, such as by ending with META().id.
+type QueryPaginatorKey struct {
+	// Expression is the N1QL expression to order by, such as "`name`" or "META(b).id".
+	Expression string
+	// Field is the name of the top level field in each row which holds the value of Expression.
+	Field string
+	// Descending orders the results in descending order of this key.
+	Descending bool
+}
+
+// QueryPaginatorOptions is the set of options available when creating a QueryPaginator.
+type QueryPaginatorOptions struct {
+	// Cursor resumes pagination after the page which the cursor was returned with.
+	Cursor string
+	// QueryOptions are the options used to execute each page, they must not contain PositionalParameters.
+	QueryOptions *QueryOptions
+}
+
+// QueryPage is a single page of results returned by a QueryPaginator.
+type QueryPage struct {
+	// Rows are the raw JSON rows of the page.
+	Rows []json.RawMessage
+	// Cursor can be passed to QueryPaginatorOptions to resume pagination after this page.
+	Cursor string
+	// MetaData is the metadata of the query which returned the page.
+	MetaData *QueryMetaData
+}
+
+// QueryPaginator pages through the results of a query using keyset pagination. Rather than using OFFSET, which
+// gets slower as the offset grows, each page seeks past the keys of the last row of the previous page.
+// VOLATILE: This API is subject to change at any time.
+type QueryPaginator struct {
+	cluster     *Cluster
+	statement   string
+	keys        []QueryPaginatorKey
+	pageSize    int
+	opts        QueryOptions
+	fingerprint string
+
+	lastKeys []json.RawMessage
+	hasMore  bool
+}
+
+type jsonQueryPaginatorCursor struct {
+	Fingerprint string            `json:"f"`
+	Keys        []json.RawMessage `json:"k"`
+}
+
+// NewQueryPaginator creates a paginator over statement, which must contain QueryPaginatorKeysetPlaceholder
+// within its WHERE clause and must not contain an ORDER BY, LIMIT or OFFSET clause as these are added by the
+// paginator. For example:
+//
+//	SELECT META(b).id, b.name FROM `beer-sample` AS b WHERE b.type = "beer" AND {{keyset}}
+//
+// VOLATILE: This API is subject to change at any time.
+func (c *Cluster) NewQueryPaginator(
+	statement string,
+	keys []QueryPaginatorKey,
+	pageSize int,
+	opts *QueryPaginatorOptions,
+) (*QueryPaginator, error) {
+	if opts == nil {
+		opts = &QueryPaginatorOptions{}
+	}
+
+	if !strings.Contains(statement, QueryPaginatorKeysetPlaceholder) {
+		return nil, makeInvalidArgumentsError("statement must contain the " + QueryPaginatorKeysetPlaceholder + " placeholder")
+	}
+	if len(keys) == 0 {
+		return nil, makeInvalidArgumentsError("at least one key must be specified")
+	}
+	for _, key := range keys {
+		if key.Expression == "" || key.Field == "" {
+			return nil, makeInvalidArgumentsError("keys must have both an expression and a field")
+		}
+	}
+	if pageSize <= 0 {
+		return nil, makeInvalidArgumentsError("page size must be greater than zero")
+	}
+
+	var queryOpts QueryOptions
+	if opts.QueryOptions != nil {
+		queryOpts = *opts.QueryOptions
+	}
+	if len(queryOpts.PositionalParameters) > 0 {
+		return nil, makeInvalidArgumentsError("paginated queries must use named parameters")
+	}
+
+	p := &QueryPaginator{
+		cluster:   c,
+		statement: statement,
+		keys:      keys,
+		pageSize:  pageSize,
+		opts:      queryOpts,
+		hasMore:   true,
+	}
+	fingerprint, err := p.makeFingerprint()
+	if err != nil {
+		return nil, err
+	}
+	p.fingerprint = fingerprint
+
+	if opts.Cursor != "" {
+		lastKeys, err := p.decodeCursor(opts.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		p.lastKeys = lastKeys
+	}
+
+	return p, nil
+}
+
+// makeFingerprint identifies the statement, keys and named parameters so that a cursor cannot be used with a
+// different query.
+func (p *QueryPaginator) makeFingerprint() (string, error) {
+	h := sha256.New()
+	h.Write([]byte(p.statement))
+	for _, key := range p.keys {
+		h.Write([]byte{0})
+		h.Write([]byte(key.Expression))
+		if key.Descending {
+			h.Write([]byte(" DESC"))
+		}
+	}
+
+	if len(p.opts.NamedParameters) > 0 {
+		// Maps are marshalled with their keys sorted, so the same parameters always give the same fingerprint.
+		params, err := json.Marshal(p.opts.NamedParameters)
+		if err != nil {
+			return "", wrapError(err, "failed to marshal named parameters")
+		}
+		h.Write([]byte{0})
+		h.Write(params)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))[:16], nil
+}
+
+func (p *QueryPaginator) encodeCursor(keys []json.RawMessage) (string, error) {
+	cursorBytes, err := json.Marshal(jsonQueryPaginatorCursor{
+		Fingerprint: p.fingerprint,
+		Keys:        keys,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(cursorBytes), nil
+}
+
+func (p *QueryPaginator) decodeCursor(cursor string) ([]json.RawMessage, error) {
+	cursorBytes, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, makeInvalidArgumentsError("invalid cursor")
+	}
+
+	var data jsonQueryPaginatorCursor
+	if err := json.Unmarshal(cursorBytes, &data); err != nil {
+		return nil, makeInvalidArgumentsError("invalid cursor")
+	}
+	if data.Fingerprint != p.fingerprint || len(data.Keys) != len(p.keys) {
+		return nil, makeInvalidArgumentsError("cursor does not belong to this query")
+	}
+
+	return data.Keys, nil
+}
+
+// HasMore returns whether there may be another page of results.
+func (p *QueryPaginator) HasMore() bool {
+	return p.hasMore
+}
+
+// Cursor returns a cursor which resumes pagination after the most recently returned page.
+func (p *QueryPaginator) Cursor() string {
+	if p.lastKeys == nil {
+		return ""
+	}
+
+	cursor, err := p.encodeCursor(p.lastKeys)
+	if err != nil {
+		logDebugf("Failed to encode paginator cursor: %s", err)
+		return ""
+	}
+	return cursor
+}
+
+func queryPaginatorParam(i int) string {
+	return "gocbPageKey" + strconv.Itoa(i)
+}
+
+func isNullQueryPaginatorKey(value json.RawMessage) bool {
+	return string(bytes.TrimSpace(value)) == "null"
+}
+
+// keysetPredicate returns the predicate which matches the rows after lastKeys, for keys (a, b) this is
+// a > $a OR (a = $a AND b > $b). Comparisons with NULL never match so a key whose last value was null is
+// compared using IS NULL, and values which sort after it using IS VALUED or IS MISSING. Likewise null and
+// missing values sort after every other value in descending order, so they are included using IS NOT VALUED.
+func (p *QueryPaginator) keysetPredicate() string {
+	if p.lastKeys == nil {
+		return "TRUE"
+	}
+
+	var clauses []string
+	for i, key := range p.keys {
+		var clause []string
+		for j := 0; j < i; j++ {
+			if isNullQueryPaginatorKey(p.lastKeys[j]) {
+				clause = append(clause, p.keys[j].Expression+" IS NULL")
+			} else {
+				clause = append(clause, p.keys[j].Expression+" = $"+queryPaginatorParam(j))
+			}
+		}
+
+		param := "$" + queryPaginatorParam(i)
+		switch {
+		case isNullQueryPaginatorKey(p.lastKeys[i]) && key.Descending:
+			clause = append(clause, key.Expression+" IS MISSING")
+		case isNullQueryPaginatorKey(p.lastKeys[i]):
+			clause = append(clause, key.Expression+" IS VALUED")
+		case key.Descending:
+			clause = append(clause, "("+key.Expression+" < "+param+" OR "+key.Expression+" IS NOT VALUED)")
+		default:
+			clause = append(clause, key.Expression+" > "+param)
+		}
+		clauses = append(clauses, "("+strings.Join(clause, " AND ")+")")
+	}
+
+	return "(" + strings.Join(clauses, " OR ") + ")"
+}
+
+func (p *QueryPaginator) pageStatement() string {
+	var orderBy []string
+	for _, key := range p.keys {
+		if key.Descending {
+			orderBy = append(orderBy, key.Expression+" DESC")
+		} else {
+			orderBy = append(orderBy, key.Expression)
+		}
+	}
+
+	statement := strings.Replace(p.statement, QueryPaginatorKeysetPlaceholder, p.keysetPredicate(), -1)
+	// One extra row is requested to find out whether there is another page.
+	return statement + " ORDER BY " + strings.Join(orderBy, ", ") + " LIMIT " + strconv.Itoa(p.pageSize+1)
+}
+
+// NextPage executes the query for the next page of results. Once HasMore returns false an empty page is returned.
+func (p *QueryPaginator) NextPage() (*QueryPage, error) {
+	if !p.hasMore {
+		return &QueryPage{
+			Cursor: p.Cursor(),
+		}, nil
+	}
+
+	opts := p.opts
+	opts.NamedParameters = make(map[string]interface{}, len(p.opts.NamedParameters)+len(p.lastKeys))
+	for name, value := range p.opts.NamedParameters {
+		opts.NamedParameters[name] = value
+	}
+	for i, value := range p.lastKeys {
+		opts.NamedParameters[queryPaginatorParam(i)] = value
+	}
+
+	result, err := p.cluster.Query(p.pageStatement(), &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	// The result must be closed on every error path so that the stream, and anything held for it, is released.
+	closeResult := func() {
+		if err := result.Close(); err != nil {
+			logDebugf("Failed to close paginated query results: %s", err)
+		}
+	}
+
+	var rows []json.RawMessage
+	for result.Next() {
+		var row json.RawMessage
+		if err := result.Row(&row); err != nil {
+			closeResult()
+			return nil, err
+		}
+		rows = append(rows, row)
+	}
+	if err := result.Err(); err != nil {
+		closeResult()
+		return nil, err
+	}
+
+	meta, err := result.MetaData()
+	if err != nil {
+		closeResult()
+		return nil, err
+	}
+
+	p.hasMore = len(rows) > p.pageSize
+	if p.hasMore {
+		rows = rows[:p.pageSize]
+	}
+
+	if len(rows) > 0 {
+		lastKeys, err := p.rowKeys(rows[len(rows)-1])
+		if err != nil {
+			closeResult()
+			return nil, err
+		}
+		p.lastKeys = lastKeys
+	}
+
+	return &QueryPage{
+		Rows:     rows,
+		Cursor:   p.Cursor(),
+		MetaData: meta,
+	}, nil
+}
+
+func (p *QueryPaginator) rowKeys(row json.RawMessage) ([]json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(row, &fields); err != nil {
+		return nil, wrapError(err, "paginated rows must be JSON objects")
+	}
+
+	keys := make([]json.RawMessage, len(p.keys))
+	for i, key := range p.keys {
+		value, ok := fields[key.Field]
+		if !ok {
+			return nil, makeInvalidArgumentsError("paginated rows must contain the key field " + key.Field)
+		}
+		keys[i] = value
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,167 @@
+package gocb
+
+import (
+	"encoding/json"
+	"errors"
+
+	gocbcore "github.com/couchbase/gocbcore/v9"
+	"github.com/stretchr/testify/mock"
+)
+
+func (suite *UnitTestSuite) TestQueryPaginator() {
+	pages := [][][]byte{
+		{[]byte(`{"id": "a", "name": "x"}`), []byte(`{"id": "b", "name": "x"}`), []byte(`{"id": "c", "name": "y"}`)},
+		{[]byte(`{"id": "c", "name": "y"}`), []byte(`{"id": "d", "name": "z"}`)},
+	}
+
+	var payloads []map[string]interface{}
+	queryProvider := new(mockQueryProvider)
+	for _, rows := range pages {
+		queryProvider.
+			On("N1QLQuery", mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+			Run(func(args mock.Arguments) {
+				opts := args.Get(0).(gocbcore.N1QLQueryOptions)
+
+				var payload map[string]interface{}
+				suite.Require().Nil(json.Unmarshal(opts.Payload, &payload))
+				payloads = append(payloads, payload)
+			}).
+			Return(&testQueryRowsReader{rows: rows, meta: []byte(`{"requestID": "req"}`)}, nil).
+			Once()
+	}
+
+	cli := new(mockClient)
+	cli.On("getQueryProvider").Return(queryProvider, nil)
+	cli.On("supportsGCCCP").Return(true)
+
+	cluster := clusterFromOptions(ClusterOptions{})
+	cluster.clusterClient = cli
+
+	statement := "SELECT META(b).id, b.name FROM `beer-sample` AS b WHERE b.type = $type AND {{keyset}}"
+	keys := []QueryPaginatorKey{
+		{Expression: "b.name", Field: "name"},
+		{Expression: "META(b).id", Field: "id"},
+	}
+	opts := &QueryPaginatorOptions{
+		QueryOptions: &QueryOptions{
+			Adhoc:           true,
+			NamedParameters: map[string]interface{}{"type": "beer"},
+		},
+	}
+
+	paginator, err := cluster.NewQueryPaginator(statement, keys, 2, opts)
+	suite.Require().Nil(err, err)
+	suite.Assert().True(paginator.HasMore())
+	suite.Assert().Equal("", paginator.Cursor())
+
+	page, err := paginator.NextPage()
+	suite.Require().Nil(err, err)
+	suite.Assert().Len(page.Rows, 2)
+	suite.Assert().True(paginator.HasMore())
+	suite.Assert().Equal("req", page.MetaData.RequestID)
+
+	suite.Require().Len(payloads, 1)
+	suite.Assert().Equal("SELECT META(b).id, b.name FROM `beer-sample` AS b WHERE b.type = $type AND TRUE "+
+		"ORDER BY b.name, META(b).id LIMIT 3", payloads[0]["statement"])
+	suite.Assert().Equal("beer", payloads[0]["$type"])
+
+	// The cursor round trips into a new paginator which continues after the first page.
+	opts.Cursor = page.Cursor
+	paginator, err = cluster.NewQueryPaginator(statement, keys, 2, opts)
+	suite.Require().Nil(err, err)
+
+	page, err = paginator.NextPage()
+	suite.Require().Nil(err, err)
+	suite.Assert().Len(page.Rows, 2)
+	suite.Assert().False(paginator.HasMore())
+
+	suite.Require().Len(payloads, 2)
+	suite.Assert().Equal("SELECT META(b).id, b.name FROM `beer-sample` AS b WHERE b.type = $type AND "+
+		"((b.name > $gocbPageKey0) OR (b.name = $gocbPageKey0 AND META(b).id > $gocbPageKey1)) "+
+		"ORDER BY b.name, META(b).id LIMIT 3", payloads[1]["statement"])
+	suite.Assert().Equal("x", payloads[1]["$gocbPageKey0"])
+	suite.Assert().Equal("b", payloads[1]["$gocbPageKey1"])
+	suite.Assert().Equal("beer", payloads[1]["$type"])
+	suite.Assert().NotContains(opts.QueryOptions.NamedParameters, "gocbPageKey0")
+
+	page, err = paginator.NextPage()
+	suite.Require().Nil(err, err)
+	suite.Assert().Empty(page.Rows)
+	suite.Assert().Len(payloads, 2)
+
+	// A cursor cannot be used with a different query.
+	_, err = cluster.NewQueryPaginator(statement, keys[1:], 2, &QueryPaginatorOptions{Cursor: page.Cursor})
+	suite.Assert().True(errors.Is(err, ErrInvalidArgument), err)
+}
+
+func (suite *UnitTestSuite) TestQueryPaginatorInvalidArguments() {
+	cluster := clusterFromOptions(ClusterOptions{})
+	keys := []QueryPaginatorKey{{Expression: "META().id", Field: "id"}}
+
+	_, err := cluster.NewQueryPaginator("SELECT META().id FROM default", keys, 10, nil)
+	suite.Assert().True(errors.Is(err, ErrInvalidArgument), err)
+
+	_, err = cluster.NewQueryPaginator("SELECT META().id FROM default WHERE {{keyset}}", nil, 10, nil)
+	suite.Assert().True(errors.Is(err, ErrInvalidArgument), err)
+
+	_, err = cluster.NewQueryPaginator("SELECT META().id FROM default WHERE {{keyset}}", keys, 0, nil)
+	suite.Assert().True(errors.Is(err, ErrInvalidArgument), err)
+
+	_, err = cluster.NewQueryPaginator("SELECT META().id FROM default WHERE {{keyset}}", keys, 10, &QueryPaginatorOptions{
+		QueryOptions: &QueryOptions{PositionalParameters: []interface{}{1}},
+	})
+	suite.Assert().True(errors.Is(err, ErrInvalidArgument), err)
+
+	_, err = cluster.NewQueryPaginator("SELECT META().id FROM default WHERE {{keyset}}", keys, 10, &QueryPaginatorOptions{
+		Cursor: "not a cursor",
+	})
+	suite.Assert().True(errors.Is(err, ErrInvalidArgument), err)
+}
+
+func (suite *UnitTestSuite) TestQueryPaginatorNullKeys() {
+	cluster := clusterFromOptions(ClusterOptions{})
+	keys := []QueryPaginatorKey{
+		{Expression: "b.name", Field: "name"},
+		{Expression: "b.abv", Field: "abv", Descending: true},
+		{Expression: "META(b).id", Field: "id"},
+	}
+
+	paginator, err := cluster.NewQueryPaginator("SELECT META(b).id, b.name, b.abv FROM b WHERE {{keyset}}", keys, 2, nil)
+	suite.Require().Nil(err, err)
+
+	paginator.lastKeys = []json.RawMessage{json.RawMessage("null"), json.RawMessage("null"), json.RawMessage(`"a"`)}
+	suite.Assert().Equal("((b.name IS VALUED) OR (b.name IS NULL AND b.abv IS MISSING) OR "+
+		"(b.name IS NULL AND b.abv IS NULL AND META(b).id > $gocbPageKey2))", paginator.keysetPredicate())
+
+	paginator.lastKeys = []json.RawMessage{json.RawMessage(`"x"`), json.RawMessage("5"), json.RawMessage(`"a"`)}
+	suite.Assert().Equal("((b.name > $gocbPageKey0) OR "+
+		"(b.name = $gocbPageKey0 AND (b.abv < $gocbPageKey1 OR b.abv IS NOT VALUED)) OR "+
+		"(b.name = $gocbPageKey0 AND b.abv = $gocbPageKey1 AND META(b).id > $gocbPageKey2))",
+		paginator.keysetPredicate())
+}
+
+func (suite *UnitTestSuite) TestQueryPaginatorCursorParameters() {
+	cluster := clusterFromOptions(ClusterOptions{})
+	statement := "SELECT META().id FROM default WHERE type = $type AND {{keyset}}"
+	keys := []QueryPaginatorKey{{Expression: "META().id", Field: "id"}}
+
+	paginator, err := cluster.NewQueryPaginator(statement, keys, 10, &QueryPaginatorOptions{
+		QueryOptions: &QueryOptions{NamedParameters: map[string]interface{}{"type": "beer"}},
+	})
+	suite.Require().Nil(err, err)
+	paginator.lastKeys = []json.RawMessage{json.RawMessage(`"a"`)}
+	cursor := paginator.Cursor()
+
+	_, err = cluster.NewQueryPaginator(statement, keys, 10, &QueryPaginatorOptions{
+		Cursor:       cursor,
+		QueryOptions: &QueryOptions{NamedParameters: map[string]interface{}{"type": "beer"}},
+	})
+	suite.Assert().Nil(err, err)
+
+	// A cursor cannot be used with different parameters.
+	_, err = cluster.NewQueryPaginator(statement, keys, 10, &QueryPaginatorOptions{
+		Cursor:       cursor,
+		QueryOptions: &QueryOptions{NamedParameters: map[string]interface{}{"type": "brewery"}},
+	})
+	suite.Assert().True(errors.Is(err, ErrInvalidArgument), err)
+}
@@ -0,0 +1,294 @@
+package gocb
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+)
+
+// QueryOperatorStats are the statistics recorded for a single operator when a query is profiled.
+type QueryOperatorStats struct {
+	ExecTime      time.Duration
+	KernTime      time.Duration
+	ServTime      time.Duration
+	ItemsIn       uint64
+	ItemsOut      uint64
+	PhaseSwitches uint64
+}
+
+// QueryPlanOperator is a single operator within a query plan, such as an index scan or a fetch.
+type QueryPlanOperator struct {
+	// Operator is the name of the operator, such as IndexScan3 or Fetch.
+	Operator string
+	// Keyspace is the keyspace that the operator reads from, if any.
+	Keyspace string
+	// Index is the name of the index that the operator scans, if any.
+	Index string
+	// Stats are the statistics recorded for the operator, they are only available when profiling a query
+	// with QueryProfileModeTimings.
+	Stats *QueryOperatorStats
+	// Properties contains every other property of the operator, as returned by the query service.
+	Properties map[string]interface{}
+	Children   []*QueryPlanOperator
+}
+
+// Walk calls fn for this operator and every operator beneath it, in depth first order.
+func (op *QueryPlanOperator) Walk(fn func(op *QueryPlanOperator)) {
+	if op == nil {
+		return
+	}
+
+	fn(op)
+	for _, child := range op.Children {
+		child.Walk(fn)
+	}
+}
+
+// IsPrimaryScan returns whether the operator scans a primary index, which reads every document in the keyspace.
+func (op *QueryPlanOperator) IsPrimaryScan() bool {
+	return strings.HasPrefix(op.Operator, "PrimaryScan")
+}
+
+func (op *QueryPlanOperator) isIndexScan() bool {
+	return strings.HasPrefix(op.Operator, "IndexScan") ||
+		strings.HasPrefix(op.Operator, "IndexCountScan") ||
+		strings.HasPrefix(op.Operator, "IndexCountDistinctScan") ||
+		strings.HasPrefix(op.Operator, "IndexFtsSearch")
+}
+
+type jsonQueryOperatorStats struct {
+	ExecTime      string `json:"execTime"`
+	KernTime      string `json:"kernTime"`
+	ServTime      string `json:"servTime"`
+	ItemsIn       uint64 `json:"#itemsIn"`
+	ItemsOut      uint64 `json:"#itemsOut"`
+	PhaseSwitches uint64 `json:"#phaseSwitches"`
+}
+
+func parseQueryDuration(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+
+	duration, err := time.ParseDuration(value)
+	if err != nil {
+		logDebugf("Failed to parse query duration %s: %s", value, err)
+	}
+	return duration
+}
+
+func (stats *QueryOperatorStats) fromData(data jsonQueryOperatorStats) {
+	stats.ExecTime = parseQueryDuration(data.ExecTime)
+	stats.KernTime = parseQueryDuration(data.KernTime)
+	stats.ServTime = parseQueryDuration(data.ServTime)
+	stats.ItemsIn = data.ItemsIn
+	stats.ItemsOut = data.ItemsOut
+	stats.PhaseSwitches = data.PhaseSwitches
+}
+
+func parseQueryPlanOperator(data json.RawMessage) (*QueryPlanOperator, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	op := &QueryPlanOperator{
+		Properties: make(map[string]interface{}),
+	}
+	for key, value := range fields {
+		var err error
+		switch key {
+		case "#operator":
+			err = json.Unmarshal(value, &op.Operator)
+		case "#stats":
+			var stats jsonQueryOperatorStats
+			err = json.Unmarshal(value, &stats)
+			if err == nil {
+				op.Stats = &QueryOperatorStats{}
+				op.Stats.fromData(stats)
+			}
+		case "~children", "children", "scans":
+			var children []json.RawMessage
+			err = json.Unmarshal(value, &children)
+			for _, childData := range children {
+				if err != nil {
+					break
+				}
+				var child *QueryPlanOperator
+				child, err = parseQueryPlanOperator(childData)
+				if err == nil {
+					op.Children = append(op.Children, child)
+				}
+			}
+		case "~child", "scan":
+			var child *QueryPlanOperator
+			child, err = parseQueryPlanOperator(value)
+			if err == nil {
+				op.Children = append(op.Children, child)
+			}
+		default:
+			var property interface{}
+			err = json.Unmarshal(value, &property)
+			op.Properties[key] = property
+
+			if str, ok := property.(string); ok {
+				if key == "keyspace" {
+					op.Keyspace = str
+				} else if key == "index" {
+					op.Index = str
+				}
+			}
+		}
+		if err != nil {
+			return nil, wrapError(err, "failed to parse query plan operator "+key)
+		}
+	}
+
+	return op, nil
+}
+
+// QueryProfile is the profile of a query, returned when a query is executed with a QueryProfileMode.
+type QueryProfile struct {
+	PhaseTimes     map[string]time.Duration
+	PhaseCounts    map[string]uint64
+	PhaseOperators map[string]uint64
+	// ExecutionTimings is the tree of operators executed by the query along with their statistics, it is only
+	// available when using QueryProfileModeTimings.
+	ExecutionTimings *QueryPlanOperator
+}
+
+type jsonQueryProfile struct {
+	PhaseTimes       map[string]string `json:"phaseTimes"`
+	PhaseCounts      map[string]uint64 `json:"phaseCounts"`
+	PhaseOperators   map[string]uint64 `json:"phaseOperators"`
+	ExecutionTimings json.RawMessage   `json:"executionTimings"`
+}
+
+func (profile *QueryProfile) fromData(data jsonQueryProfile) error {
+	profile.PhaseTimes = make(map[string]time.Duration, len(data.PhaseTimes))
+	for phase, value := range data.PhaseTimes {
+		profile.PhaseTimes[phase] = parseQueryDuration(value)
+	}
+	profile.PhaseCounts = data.PhaseCounts
+	profile.PhaseOperators = data.PhaseOperators
+
+	if len(data.ExecutionTimings) > 0 {
+		timings, err := parseQueryPlanOperator(data.ExecutionTimings)
+		if err != nil {
+			return err
+		}
+		profile.ExecutionTimings = timings
+	}
+
+	return nil
+}
+
+// ParsedProfile parses the Profile of the query into a QueryProfile, returning ErrNoResult if the query was
+// not profiled.
+func (meta *QueryMetaData) ParsedProfile() (*QueryProfile, error) {
+	if meta.Profile == nil {
+		return nil, ErrNoResult
+	}
+
+	profileBytes, err := json.Marshal(meta.Profile)
+	if err != nil {
+		return nil, err
+	}
+
+	var data jsonQueryProfile
+	if err := json.Unmarshal(profileBytes, &data); err != nil {
+		return nil, err
+	}
+
+	profile := &QueryProfile{}
+	if err := profile.fromData(data); err != nil {
+		return nil, err
+	}
+
+	return profile, nil
+}
+
+// QueryPlan is the execution plan of a query, as returned by ExplainQuery.
+type QueryPlan struct {
+	// Root is the top level operator of the plan.
+	Root *QueryPlanOperator
+	// Text is the statement which was explained.
+	Text string
+}
+
+// QueryPlanIssues are the potential performance problems found in a query plan.
+type QueryPlanIssues struct {
+	// PrimaryScans are the operators which scan a primary index, reading every document in their keyspace.
+	PrimaryScans []*QueryPlanOperator
+	// IndexMisses are the keyspaces which are scanned without the use of any secondary index.
+	IndexMisses []string
+}
+
+// HasIssues returns whether any issues were found.
+func (issues QueryPlanIssues) HasIssues() bool {
+	return len(issues.PrimaryScans) > 0 || len(issues.IndexMisses) > 0
+}
+
+// Issues finds the primary scans and index misses within the plan.
+func (plan *QueryPlan) Issues() QueryPlanIssues {
+	var issues QueryPlanIssues
+	primaryScanned := make(map[string]struct{})
+	indexScanned := make(map[string]struct{})
+
+	plan.Root.Walk(func(op *QueryPlanOperator) {
+		if op.IsPrimaryScan() {
+			issues.PrimaryScans = append(issues.PrimaryScans, op)
+			primaryScanned[op.Keyspace] = struct{}{}
+		} else if op.isIndexScan() {
+			indexScanned[op.Keyspace] = struct{}{}
+		}
+	})
+
+	for keyspace := range primaryScanned {
+		if _, ok := indexScanned[keyspace]; !ok {
+			issues.IndexMisses = append(issues.IndexMisses, keyspace)
+		}
+	}
+	sort.Strings(issues.IndexMisses)
+
+	return issues
+}
+
+type jsonQueryPlan struct {
+	Plan json.RawMessage `json:"plan"`
+	Text string          `json:"text"`
+}
+
+// ExplainQuery returns the execution plan which the query service would use for the statement, without
+// executing it.
+func (c *Cluster) ExplainQuery(statement string, opts *QueryOptions) (*QueryPlan, error) {
+	if opts == nil {
+		opts = &QueryOptions{}
+	}
+
+	explainOpts := *opts
+	explainOpts.Adhoc = true
+	explainOpts.Profile = ""
+
+	result, err := c.Query("EXPLAIN "+statement, &explainOpts)
+	if err != nil {
+		return nil, err
+	}
+
+	var data jsonQueryPlan
+	err = result.One(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := parseQueryPlanOperator(data.Plan)
+	if err != nil {
+		return nil, err
+	}
+
+	return &QueryPlan{
+		Root: root,
+		Text: data.Text,
+	}, nil
+}
@@ -0,0 +1,215 @@
+package gocb
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v9"
+	"github.com/stretchr/testify/mock"
+)
+
+// testQueryRowsReader is a queryRowReader over a fixed set of raw rows.
+type testQueryRowsReader struct {
+	rows [][]byte
+	meta []byte
+	err  error
+}
+
+func (r *testQueryRowsReader) NextRow() []byte {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	row := r.rows[0]
+	r.rows = r.rows[1:]
+	return row
+}
+
+func (r *testQueryRowsReader) Err() error {
+	return r.err
+}
+
+func (r *testQueryRowsReader) MetaData() ([]byte, error) {
+	return r.meta, nil
+}
+
+func (r *testQueryRowsReader) Close() error {
+	return nil
+}
+
+func (r *testQueryRowsReader) PreparedName() (string, error) {
+	return "", nil
+}
+
+func (suite *UnitTestSuite) TestQueryMetaDataParsedProfile() {
+	profile := `{
+		"phaseTimes": {"authorize": "12.34µs", "fetch": "1.5ms", "primaryScan": "2ms"},
+		"phaseCounts": {"fetch": 16, "primaryScan": 16},
+		"phaseOperators": {"authorize": 1, "fetch": 1, "primaryScan": 1},
+		"executionTimings": {
+			"#operator": "Sequence",
+			"#stats": {"#phaseSwitches": 1, "execTime": "1.2µs"},
+			"~children": [
+				{
+					"#operator": "PrimaryScan3",
+					"#stats": {"#itemsOut": 16, "#phaseSwitches": 67, "execTime": "23µs", "kernTime": "1ms", "servTime": "2ms"},
+					"index": "#primary",
+					"keyspace": "beer-sample",
+					"using": "gsi"
+				},
+				{
+					"#operator": "Parallel",
+					"~child": {
+						"#operator": "Fetch",
+						"#stats": {"#itemsIn": 16, "#itemsOut": 16},
+						"keyspace": "beer-sample"
+					}
+				}
+			]
+		}
+	}`
+
+	var jsonResp jsonQueryResponse
+	suite.Require().Nil(json.Unmarshal([]byte(`{"profile":`+profile+`}`), &jsonResp))
+
+	var meta QueryMetaData
+	suite.Require().Nil(meta.fromData(jsonResp))
+
+	parsed, err := meta.ParsedProfile()
+	suite.Require().Nil(err, err)
+
+	suite.Assert().Equal(1500*time.Microsecond, parsed.PhaseTimes["fetch"])
+	suite.Assert().Equal(12340*time.Nanosecond, parsed.PhaseTimes["authorize"])
+	suite.Assert().Equal(uint64(16), parsed.PhaseCounts["primaryScan"])
+	suite.Assert().Equal(uint64(1), parsed.PhaseOperators["fetch"])
+
+	root := parsed.ExecutionTimings
+	suite.Require().NotNil(root)
+	suite.Assert().Equal("Sequence", root.Operator)
+	suite.Require().Len(root.Children, 2)
+
+	scan := root.Children[0]
+	suite.Assert().Equal("PrimaryScan3", scan.Operator)
+	suite.Assert().Equal("#primary", scan.Index)
+	suite.Assert().Equal("beer-sample", scan.Keyspace)
+	suite.Assert().Equal("gsi", scan.Properties["using"])
+	suite.Assert().Equal(&QueryOperatorStats{
+		ExecTime:      23 * time.Microsecond,
+		KernTime:      time.Millisecond,
+		ServTime:      2 * time.Millisecond,
+		ItemsOut:      16,
+		PhaseSwitches: 67,
+	}, scan.Stats)
+
+	suite.Require().Len(root.Children[1].Children, 1)
+	fetch := root.Children[1].Children[0]
+	suite.Assert().Equal("Fetch", fetch.Operator)
+	suite.Assert().Equal(uint64(16), fetch.Stats.ItemsIn)
+
+	var operators []string
+	root.Walk(func(op *QueryPlanOperator) {
+		operators = append(operators, op.Operator)
+	})
+	suite.Assert().Equal([]string{"Sequence", "PrimaryScan3", "Parallel", "Fetch"}, operators)
+
+	_, err = (&QueryMetaData{}).ParsedProfile()
+	suite.Assert().True(errors.Is(err, ErrNoResult), err)
+}
+
+func (suite *UnitTestSuite) TestExplainQuery() {
+	plan := []byte(`{
+		"plan": {
+			"#operator": "Sequence",
+			"~children": [
+				{
+					"#operator": "UnionScan",
+					"scans": [
+						{"#operator": "IndexScan3", "index": "idx_type", "keyspace": "travel-sample"},
+						{"#operator": "PrimaryScan3", "index": "#primary", "keyspace": "travel-sample"}
+					]
+				},
+				{"#operator": "PrimaryScan3", "index": "#primary", "keyspace": "beer-sample"},
+				{"#operator": "Fetch", "keyspace": "beer-sample"}
+			]
+		},
+		"text": "SELECT * FROM ` + "`beer-sample`" + `"
+	}`)
+
+	reader := &testQueryRowsReader{rows: [][]byte{plan}}
+
+	cluster := suite.queryCluster(false, reader, func(args mock.Arguments) {
+		opts := args.Get(0).(gocbcore.N1QLQueryOptions)
+
+		var actualOptions map[string]interface{}
+		err := json.Unmarshal(opts.Payload, &actualOptions)
+		suite.Require().Nil(err)
+
+		suite.Assert().Equal("EXPLAIN SELECT * FROM `beer-sample`", actualOptions["statement"])
+		suite.Assert().NotContains(actualOptions, "profile")
+	})
+
+	queryPlan, err := cluster.ExplainQuery("SELECT * FROM `beer-sample`", &QueryOptions{
+		Profile: QueryProfileModeTimings,
+	})
+	suite.Require().Nil(err, err)
+
+	suite.Assert().Equal("SELECT * FROM `beer-sample`", queryPlan.Text)
+	suite.Assert().Equal("Sequence", queryPlan.Root.Operator)
+	suite.Require().Len(queryPlan.Root.Children, 3)
+	suite.Assert().Len(queryPlan.Root.Children[0].Children, 2)
+
+	issues := queryPlan.Issues()
+	suite.Assert().True(issues.HasIssues())
+	suite.Require().Len(issues.PrimaryScans, 2)
+	suite.Assert().Equal("travel-sample", issues.PrimaryScans[0].Keyspace)
+	suite.Assert().Equal("beer-sample", issues.PrimaryScans[1].Keyspace)
+	suite.Assert().Equal([]string{"beer-sample"}, issues.IndexMisses)
+}
+
+func (suite *UnitTestSuite) TestExplainQueryUnion() {
+	plan := []byte(`{
+		"plan": {
+			"#operator": "Sequence",
+			"~children": [
+				{
+					"#operator": "UnionAll",
+					"children": [
+						{
+							"#operator": "Sequence",
+							"~children": [
+								{"#operator": "PrimaryScan3", "index": "#primary", "keyspace": "travel-sample"},
+								{"#operator": "Fetch", "keyspace": "travel-sample"}
+							]
+						},
+						{
+							"#operator": "Sequence",
+							"~children": [
+								{"#operator": "IndexScan3", "index": "def_type", "keyspace": "beer-sample"},
+								{"#operator": "Fetch", "keyspace": "beer-sample"}
+							]
+						}
+					]
+				}
+			]
+		},
+		"text": "SELECT * FROM ` + "`travel-sample`" + ` UNION ALL SELECT * FROM ` + "`beer-sample`" + ` WHERE type = \"beer\""
+	}`)
+
+	reader := &testQueryRowsReader{rows: [][]byte{plan}}
+
+	cluster := suite.queryCluster(false, reader, func(args mock.Arguments) {})
+
+	queryPlan, err := cluster.ExplainQuery("SELECT * FROM `travel-sample` UNION ALL SELECT * FROM `beer-sample` WHERE type = \"beer\"", nil)
+	suite.Require().Nil(err, err)
+
+	suite.Require().Len(queryPlan.Root.Children, 1)
+	union := queryPlan.Root.Children[0]
+	suite.Assert().Equal("UnionAll", union.Operator)
+	suite.Require().Len(union.Children, 2)
+	suite.Assert().Len(union.Children[0].Children, 2)
+	suite.Assert().Equal("def_type", union.Children[1].Children[0].Index)
+
+	issues := queryPlan.Issues()
+	suite.Require().Len(issues.PrimaryScans, 1)
+	suite.Assert().Equal("travel-sample", issues.PrimaryScans[0].Keyspace)
+}
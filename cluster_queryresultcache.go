@@ -0,0 +1,473 @@
+package gocb
+
+import (
+	"container/list"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultQueryResultCacheMaxBytes = 64 * 1024 * 1024
+	defaultQueryResultCacheTTL      = time.Minute
+)
+
+// QueryResultCacheConfig specifies options for the client side cache of query results.
+// VOLATILE: This API is subject to change at any time.
+type QueryResultCacheConfig struct {
+	// MaxBytes is the maximum total size of the cached rows and metadata, the least recently used results are
+	// evicted once it is reached. It defaults to 64MiB, a negative value disables the cache.
+	MaxBytes int
+	// TTL is how long results are cached for, defaulting to one minute.
+	TTL time.Duration
+}
+
+// QueryResultCacheStats are the statistics of the query result cache.
+// VOLATILE: This API is subject to change at any time.
+type QueryResultCacheStats struct {
+	// Hits is the number of queries which were served from the cache.
+	Hits uint64
+	// Misses is the number of queries which were sent to the query service.
+	Misses uint64
+	// Collapsed is the number of queries which waited for an identical query that was already in flight.
+	Collapsed uint64
+	// Evictions is the number of results removed from the cache because it was full or they expired.
+	Evictions uint64
+	// Size is the number of results currently cached.
+	Size int
+	// Bytes is the total size of the results currently cached.
+	Bytes int
+}
+
+type queryResultCacheEntry struct {
+	key       string
+	rows      [][]byte
+	meta      []byte
+	size      int
+	expiresAt time.Time
+}
+
+// queryResultCall is a query which is in flight, identical queries wait for it rather than sending their own.
+// If the results were too large to be cached then entry and err are both nil once done is closed.
+type queryResultCall struct {
+	done  chan struct{}
+	entry *queryResultCacheEntry
+	err   error
+}
+
+// queryResultCache is a least recently used cache of buffered query results, bounded by their total size.
+type queryResultCache struct {
+	lock     sync.Mutex
+	maxBytes int
+	ttl      time.Duration
+	order    *list.List
+	entries  map[string]*list.Element
+	inFlight map[string]*queryResultCall
+	bytes    int
+	stats    QueryResultCacheStats
+}
+
+func newQueryResultCache(config QueryResultCacheConfig) *queryResultCache {
+	maxBytes := config.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = defaultQueryResultCacheMaxBytes
+	}
+	ttl := config.TTL
+	if ttl == 0 {
+		ttl = defaultQueryResultCacheTTL
+	}
+
+	return &queryResultCache{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		order:    list.New(),
+		entries:  make(map[string]*list.Element),
+		inFlight: make(map[string]*queryResultCall),
+	}
+}
+
+func (qc *queryResultCache) enabled() bool {
+	return qc != nil && qc.maxBytes > 0
+}
+
+type jsonQueryResultCacheKey struct {
+	Statement       string                 `json:"statement"`
+	ScanConsistency QueryScanConsistency   `json:"scan_consistency,omitempty"`
+	Positional      []interface{}          `json:"args,omitempty"`
+	Named           map[string]interface{} `json:"named,omitempty"`
+	Raw             map[string]interface{} `json:"raw,omitempty"`
+}
+
+// queryResultCacheKey returns the key which identifies the results of statement, or false if the query cannot be
+// cached because it may modify data or requires results which are consistent with recent mutations.
+func queryResultCacheKey(statement string, opts *QueryOptions) (string, bool) {
+	if !opts.Readonly && !isSelectStatement(statement) {
+		return "", false
+	}
+
+	if opts.ConsistentWith != nil || opts.ScanConsistency == QueryScanConsistencyRequestPlus {
+		return "", false
+	}
+
+	// Raw parameters are sent as they are, so they can also request consistency with recent mutations.
+	if _, ok := opts.Raw["scan_vectors"]; ok {
+		return "", false
+	}
+	if consistency, ok := opts.Raw["scan_consistency"]; ok && consistency != "not_bounded" {
+		return "", false
+	}
+
+	// Maps are marshalled with sorted keys, so the same parameters always produce the same key.
+	key, err := json.Marshal(jsonQueryResultCacheKey{
+		Statement:       statement,
+		ScanConsistency: opts.ScanConsistency,
+		Positional:      opts.PositionalParameters,
+		Named:           opts.NamedParameters,
+		Raw:             opts.Raw,
+	})
+	if err != nil {
+		logDebugf("Failed to generate query result cache key: %s", err)
+		return "", false
+	}
+
+	return string(key), true
+}
+
+// isSelectStatement returns whether statement is a SELECT, ignoring any leading whitespace, comments and
+// parentheses.
+func isSelectStatement(statement string) bool {
+	for {
+		trimmed := strings.TrimLeft(statement, " \t\r\n(")
+		switch {
+		case strings.HasPrefix(trimmed, "--"):
+			end := strings.Index(trimmed, "\n")
+			if end < 0 {
+				return false
+			}
+			statement = trimmed[end:]
+		case strings.HasPrefix(trimmed, "/*"):
+			end := strings.Index(trimmed, "*/")
+			if end < 0 {
+				return false
+			}
+			statement = trimmed[end+2:]
+		default:
+			const selectKeyword = "select"
+			if len(trimmed) < len(selectKeyword) || !strings.EqualFold(trimmed[:len(selectKeyword)], selectKeyword) {
+				return false
+			}
+			rest := trimmed[len(selectKeyword):]
+			return rest == "" || strings.IndexAny(rest[:1], " \t\r\n(*`\"") == 0
+		}
+	}
+}
+
+// get returns the cached results for key, or the in flight call which will produce them. If neither exist a new
+// call is registered and returned with leader set, and the caller must complete it with finish.
+func (qc *queryResultCache) get(key string) (entry *queryResultCacheEntry, call *queryResultCall, leader bool) {
+	qc.lock.Lock()
+	defer qc.lock.Unlock()
+
+	if elem, ok := qc.entries[key]; ok {
+		entry := elem.Value.(*queryResultCacheEntry)
+		if time.Now().Before(entry.expiresAt) {
+			qc.order.MoveToFront(elem)
+			qc.stats.Hits++
+			return entry, nil, false
+		}
+		qc.removeElement(elem)
+	}
+
+	if call, ok := qc.inFlight[key]; ok {
+		qc.stats.Collapsed++
+		return nil, call, false
+	}
+
+	call = &queryResultCall{done: make(chan struct{})}
+	qc.inFlight[key] = call
+	qc.stats.Misses++
+	return nil, call, true
+}
+
+// finish completes an in flight call, caching its results if it succeeded and they were buffered.
+func (qc *queryResultCache) finish(key string, call *queryResultCall) {
+	qc.lock.Lock()
+	delete(qc.inFlight, key)
+	if call.err == nil && call.entry != nil {
+		qc.put(call.entry)
+	}
+	qc.lock.Unlock()
+
+	close(call.done)
+}
+
+func (qc *queryResultCache) put(entry *queryResultCacheEntry) {
+	if entry.size > qc.maxBytes {
+		return
+	}
+
+	entry.expiresAt = time.Now().Add(qc.ttl)
+	if elem, ok := qc.entries[entry.key]; ok {
+		qc.removeElement(elem)
+	}
+	qc.entries[entry.key] = qc.order.PushFront(entry)
+	qc.bytes += entry.size
+
+	for qc.bytes > qc.maxBytes {
+		qc.removeElement(qc.order.Back())
+	}
+}
+
+func (qc *queryResultCache) removeElement(elem *list.Element) {
+	entry := qc.order.Remove(elem).(*queryResultCacheEntry)
+	delete(qc.entries, entry.key)
+	qc.bytes -= entry.size
+	qc.stats.Evictions++
+}
+
+// clear evicts every result from the cache, it is safe to call on a nil cache.
+func (qc *queryResultCache) clear() {
+	if qc == nil {
+		return
+	}
+
+	qc.lock.Lock()
+	defer qc.lock.Unlock()
+
+	qc.stats.Evictions += uint64(qc.order.Len())
+	qc.order.Init()
+	qc.entries = make(map[string]*list.Element)
+	qc.bytes = 0
+}
+
+func (qc *queryResultCache) snapshot() QueryResultCacheStats {
+	qc.lock.Lock()
+	defer qc.lock.Unlock()
+
+	stats := qc.stats
+	stats.Size = qc.order.Len()
+	stats.Bytes = qc.bytes
+	return stats
+}
+
+// bufferedQueryRowReader is a queryRowReader over a set of cached rows. The rows are shared between readers, so
+// each row is copied as it is read in case the caller modifies it.
+type bufferedQueryRowReader struct {
+	rows [][]byte
+	meta []byte
+	idx  int
+}
+
+func (r *bufferedQueryRowReader) NextRow() []byte {
+	if r.idx >= len(r.rows) {
+		return nil
+	}
+	row := make([]byte, len(r.rows[r.idx]))
+	copy(row, r.rows[r.idx])
+	r.idx++
+	return row
+}
+
+func (r *bufferedQueryRowReader) Err() error {
+	return nil
+}
+
+func (r *bufferedQueryRowReader) MetaData() ([]byte, error) {
+	return r.meta, nil
+}
+
+func (r *bufferedQueryRowReader) Close() error {
+	return nil
+}
+
+func (r *bufferedQueryRowReader) PreparedName() (string, error) {
+	return "", nil
+}
+
+func (r *bufferedQueryRowReader) EarlyMetadata(key string) json.RawMessage {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(r.meta, &fields); err != nil {
+		return nil
+	}
+	return fields[key]
+}
+
+// streamingQueryRowReader returns the rows which were buffered before the results became too large to cache,
+// followed by the rows which remain in the stream.
+type streamingQueryRowReader struct {
+	rows   [][]byte
+	reader queryRowReader
+}
+
+func (r *streamingQueryRowReader) NextRow() []byte {
+	if len(r.rows) > 0 {
+		row := r.rows[0]
+		r.rows = r.rows[1:]
+		return row
+	}
+	return r.reader.NextRow()
+}
+
+func (r *streamingQueryRowReader) Err() error {
+	return r.reader.Err()
+}
+
+func (r *streamingQueryRowReader) MetaData() ([]byte, error) {
+	return r.reader.MetaData()
+}
+
+func (r *streamingQueryRowReader) Close() error {
+	r.rows = nil
+	return r.reader.Close()
+}
+
+func (r *streamingQueryRowReader) PreparedName() (string, error) {
+	return r.reader.PreparedName()
+}
+
+func (r *streamingQueryRowReader) EarlyMetadata(key string) json.RawMessage {
+	return readerEarlyMetadata(r.reader, key)
+}
+
+// queryErrorFromOptions returns whether err may have been caused by the timeout or retry strategy of the query
+// which was sent, rather than by the query itself. Neither is part of the cache key, so a query which shared the
+// result is sent again on its own options instead.
+func queryErrorFromOptions(err error) bool {
+	return errors.Is(err, ErrTimeout) || errors.Is(err, ErrRequestCanceled)
+}
+
+// cachedQuery serves the query from the result cache, executing it once for any number of identical concurrent
+// queries when the results are not cached. Concurrent queries share the result of the query which was sent,
+// including its error, waiting for it for no longer than their own timeout. If the results were too large to
+// cache, or the query which was sent timed out or was cancelled, then each of the concurrent queries is sent
+// separately.
+func (c *Cluster) cachedQuery(span RequestSpan, key, statement string, opts *QueryOptions) (*QueryResult, error) {
+	op, err := c.sb.Ops.begin()
+	if err != nil {
+		return nil, QueryError{
+			InnerError:      err,
+			Statement:       statement,
+			ClientContextID: opts.ClientContextID,
+		}
+	}
+	defer op.finish()
+
+	start := time.Now()
+	entry, call, leader := c.queryResultCache.get(key)
+	if entry != nil {
+		c.sb.Meter.recordOperation(meterValueServiceQuery, "query", start, nil)
+		return newQueryResult(&bufferedQueryRowReader{rows: entry.rows, meta: entry.meta}), nil
+	}
+
+	if leader {
+		var reader queryRowReader
+		reader, call.entry, call.err = c.bufferQuery(span, key, statement, opts)
+		c.queryResultCache.finish(key, call)
+		if reader != nil {
+			return newQueryResult(reader), nil
+		}
+		if call.err != nil {
+			return nil, call.err
+		}
+
+		return newQueryResult(&bufferedQueryRowReader{rows: call.entry.rows, meta: call.entry.meta}), nil
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = c.sb.QueryTimeout
+	}
+
+	deadline := time.Now().Add(timeout)
+	timeoutErr := QueryError{
+		InnerError: wrapError(ErrTimeout, "timed out waiting for an identical query to complete"),
+		Statement:  statement,
+	}
+
+	timer := time.NewTimer(timeout)
+	select {
+	case <-call.done:
+		timer.Stop()
+	case <-timer.C:
+		c.sb.Meter.recordOperation(meterValueServiceQuery, "query", start, timeoutErr)
+		return nil, timeoutErr
+	}
+
+	if (call.entry == nil && call.err == nil) || queryErrorFromOptions(call.err) {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			c.sb.Meter.recordOperation(meterValueServiceQuery, "query", start, timeoutErr)
+			return nil, timeoutErr
+		}
+
+		followerOpts := *opts
+		followerOpts.Timeout = remaining
+		return c.query(span, statement, &followerOpts)
+	}
+
+	c.sb.Meter.recordOperation(meterValueServiceQuery, "query", start, call.err)
+	if call.err != nil {
+		return nil, call.err
+	}
+
+	return newQueryResult(&bufferedQueryRowReader{rows: call.entry.rows, meta: call.entry.meta}), nil
+}
+
+// bufferQuery executes the query and buffers its results to be cached. If the results become larger than the
+// cache then buffering stops and a reader which streams the rest of the results is returned instead.
+func (c *Cluster) bufferQuery(
+	span RequestSpan,
+	key, statement string,
+	opts *QueryOptions,
+) (queryRowReader, *queryResultCacheEntry, error) {
+	result, err := c.query(span, statement, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entry := &queryResultCacheEntry{
+		key:  key,
+		size: len(key),
+	}
+	for entry.size <= c.queryResultCache.maxBytes {
+		row := result.reader.NextRow()
+		if row == nil {
+			break
+		}
+
+		rowCopy := make([]byte, len(row))
+		copy(rowCopy, row)
+		entry.rows = append(entry.rows, rowCopy)
+		entry.size += len(rowCopy)
+	}
+	if entry.size > c.queryResultCache.maxBytes {
+		logDebugf("Query results are too large to cache, streaming the remaining results")
+		return &streamingQueryRowReader{rows: entry.rows, reader: result.reader}, nil, nil
+	}
+
+	if err := result.reader.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	entry.meta, err = result.reader.MetaData()
+	if err != nil {
+		return nil, nil, err
+	}
+	entry.size += len(entry.meta)
+
+	return nil, entry, nil
+}
+
+// ClearQueryResultCache removes every result from the query result cache.
+// VOLATILE: This API is subject to change at any time.
+func (c *Cluster) ClearQueryResultCache() {
+	c.queryResultCache.clear()
+}
+
+// QueryResultCacheStats returns the statistics of the query result cache.
+// VOLATILE: This API is subject to change at any time.
+func (c *Cluster) QueryResultCacheStats() QueryResultCacheStats {
+	return c.queryResultCache.snapshot()
+}
@@ -0,0 +1,350 @@
+package gocb
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+func (suite *UnitTestSuite) resultCacheCluster(config QueryResultCacheConfig, queryProvider *mockQueryProvider) *Cluster {
+	cli := new(mockClient)
+	cli.On("getQueryProvider").Return(queryProvider, nil)
+	cli.On("supportsGCCCP").Return(true)
+
+	cluster := clusterFromOptions(ClusterOptions{QueryResultCacheConfig: config})
+	cluster.clusterClient = cli
+
+	return cluster
+}
+
+func (suite *UnitTestSuite) queryCachedRows(cluster *Cluster, statement string, opts *QueryOptions) []string {
+	result, err := cluster.Query(statement, opts)
+	suite.Require().Nil(err, err)
+
+	var rows []string
+	for result.Next() {
+		var row string
+		suite.Require().Nil(result.Row(&row))
+		rows = append(rows, row)
+	}
+	suite.Require().Nil(result.Err())
+
+	meta, err := result.MetaData()
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal("req", meta.RequestID)
+
+	return rows
+}
+
+func (suite *UnitTestSuite) TestQueryResultCache() {
+	queryProvider := new(mockQueryProvider)
+	queryProvider.
+		On("N1QLQuery", matchQueryStatement("SELECT RAW name FROM default")).
+		Return(&testQueryRowsReader{rows: [][]byte{[]byte(`"a"`), []byte(`"b"`)}, meta: []byte(`{"requestID": "req"}`)}, nil).
+		Once()
+	queryProvider.
+		On("N1QLQuery", matchQueryStatement("SELECT RAW name FROM default")).
+		Return(&testQueryRowsReader{rows: [][]byte{[]byte(`"c"`)}, meta: []byte(`{"requestID": "req"}`)}, nil).
+		Once()
+	queryProvider.
+		On("N1QLQuery", matchQueryStatement("SELECT RAW name FROM default")).
+		Return(&testQueryRowsReader{rows: [][]byte{[]byte(`"d"`)}, meta: []byte(`{"requestID": "req"}`)}, nil).
+		Once()
+
+	cluster := suite.resultCacheCluster(QueryResultCacheConfig{}, queryProvider)
+
+	statement := "SELECT RAW name FROM default"
+	suite.Assert().Equal([]string{"a", "b"}, suite.queryCachedRows(cluster, statement, &QueryOptions{Adhoc: true, Cache: true}))
+	suite.Assert().Equal([]string{"a", "b"}, suite.queryCachedRows(cluster, statement, &QueryOptions{Adhoc: true, Cache: true}))
+
+	stats := cluster.QueryResultCacheStats()
+	suite.Assert().Equal(uint64(1), stats.Hits)
+	suite.Assert().Equal(uint64(1), stats.Misses)
+	suite.Assert().Equal(1, stats.Size)
+
+	// Queries which require consistency with recent mutations are never cached.
+	suite.Assert().Equal([]string{"c"}, suite.queryCachedRows(cluster, statement, &QueryOptions{
+		Adhoc:           true,
+		Cache:           true,
+		ScanConsistency: QueryScanConsistencyRequestPlus,
+	}))
+
+	// Different parameters are cached separately.
+	suite.Assert().Equal([]string{"d"}, suite.queryCachedRows(cluster, statement, &QueryOptions{
+		Adhoc:           true,
+		Cache:           true,
+		NamedParameters: map[string]interface{}{"type": "beer"},
+	}))
+
+	stats = cluster.QueryResultCacheStats()
+	suite.Assert().Equal(uint64(2), stats.Misses)
+	suite.Assert().Equal(2, stats.Size)
+
+	cluster.ClearQueryResultCache()
+	suite.Assert().Equal(0, cluster.QueryResultCacheStats().Size)
+	suite.Assert().Equal(0, cluster.QueryResultCacheStats().Bytes)
+	queryProvider.AssertExpectations(suite.T())
+}
+
+func (suite *UnitTestSuite) TestQueryResultCacheCollapsesConcurrentQueries() {
+	release := make(chan struct{})
+	queryProvider := new(mockQueryProvider)
+	queryProvider.
+		On("N1QLQuery", mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+		Run(func(args mock.Arguments) {
+			<-release
+		}).
+		Return(&testQueryRowsReader{rows: [][]byte{[]byte(`"a"`)}, meta: []byte(`{"requestID": "req"}`)}, nil).
+		Once()
+
+	cluster := suite.resultCacheCluster(QueryResultCacheConfig{}, queryProvider)
+
+	const numQueries = 5
+	results := make([][]string, numQueries)
+	var wg sync.WaitGroup
+	wg.Add(numQueries)
+	for i := 0; i < numQueries; i++ {
+		go func(i int) {
+			defer wg.Done()
+			results[i] = suite.queryCachedRows(cluster, "SELECT RAW name FROM default", &QueryOptions{Adhoc: true, Cache: true})
+		}(i)
+	}
+
+	suite.Require().Eventually(func() bool {
+		return cluster.QueryResultCacheStats().Collapsed == numQueries-1
+	}, time.Second, time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	for _, rows := range results {
+		suite.Assert().Equal([]string{"a"}, rows)
+	}
+	suite.Assert().Equal(uint64(1), cluster.QueryResultCacheStats().Misses)
+	queryProvider.AssertExpectations(suite.T())
+}
+
+func (suite *UnitTestSuite) TestQueryResultCacheEvictsBySize() {
+	queryProvider := new(mockQueryProvider)
+	for _, statement := range []string{"SELECT RAW 1", "SELECT RAW 2", "SELECT RAW 1"} {
+		queryProvider.
+			On("N1QLQuery", matchQueryStatement(statement)).
+			Return(&testQueryRowsReader{rows: [][]byte{[]byte(`"a"`)}, meta: []byte(`{"requestID": "req"}`)}, nil).
+			Once()
+	}
+
+	key, ok := queryResultCacheKey("SELECT RAW 1", &QueryOptions{})
+	suite.Require().True(ok)
+	entrySize := len(key) + len(`"a"`) + len(`{"requestID": "req"}`)
+
+	cluster := suite.resultCacheCluster(QueryResultCacheConfig{MaxBytes: entrySize + 1}, queryProvider)
+
+	for _, statement := range []string{"SELECT RAW 1", "SELECT RAW 2", "SELECT RAW 1"} {
+		suite.queryCachedRows(cluster, statement, &QueryOptions{Adhoc: true, Cache: true})
+	}
+
+	stats := cluster.QueryResultCacheStats()
+	suite.Assert().Equal(uint64(0), stats.Hits)
+	suite.Assert().Equal(uint64(3), stats.Misses)
+	suite.Assert().Equal(uint64(2), stats.Evictions)
+	suite.Assert().Equal(1, stats.Size)
+	suite.Assert().Equal(entrySize, stats.Bytes)
+	queryProvider.AssertExpectations(suite.T())
+}
+
+func (suite *UnitTestSuite) TestQueryResultCacheDoesNotCacheErrors() {
+	queryProvider := new(mockQueryProvider)
+	queryProvider.
+		On("N1QLQuery", mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+		Return(nil, errors.New("query failed")).
+		Once()
+	queryProvider.
+		On("N1QLQuery", mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+		Return(&testQueryRowsReader{rows: [][]byte{[]byte(`"a"`)}, meta: []byte(`{"requestID": "req"}`)}, nil).
+		Once()
+
+	cluster := suite.resultCacheCluster(QueryResultCacheConfig{}, queryProvider)
+
+	_, err := cluster.Query("SELECT RAW name FROM default", &QueryOptions{Adhoc: true, Cache: true})
+	suite.Require().NotNil(err)
+	suite.Assert().Equal(0, cluster.QueryResultCacheStats().Size)
+
+	suite.Assert().Equal([]string{"a"}, suite.queryCachedRows(cluster, "SELECT RAW name FROM default", &QueryOptions{Adhoc: true, Cache: true}))
+	queryProvider.AssertExpectations(suite.T())
+}
+
+func (suite *UnitTestSuite) TestQueryResultCacheFollowerTimeout() {
+	release := make(chan struct{})
+	queryProvider := new(mockQueryProvider)
+	queryProvider.
+		On("N1QLQuery", mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+		Run(func(args mock.Arguments) {
+			<-release
+		}).
+		Return(&testQueryRowsReader{rows: [][]byte{[]byte(`"a"`)}, meta: []byte(`{"requestID": "req"}`)}, nil).
+		Once()
+
+	cluster := suite.resultCacheCluster(QueryResultCacheConfig{}, queryProvider)
+
+	leaderDone := make(chan struct{})
+	go func() {
+		defer close(leaderDone)
+		suite.queryCachedRows(cluster, "SELECT RAW name FROM default", &QueryOptions{Adhoc: true, Cache: true})
+	}()
+	suite.Require().Eventually(func() bool {
+		return cluster.QueryResultCacheStats().Misses == 1
+	}, time.Second, time.Millisecond)
+
+	// A query waiting for an identical query gives up once its own timeout has passed.
+	_, err := cluster.Query("SELECT RAW name FROM default", &QueryOptions{
+		Adhoc:   true,
+		Cache:   true,
+		Timeout: 10 * time.Millisecond,
+	})
+	suite.Require().True(errors.Is(err, ErrTimeout), err)
+
+	close(release)
+	<-leaderDone
+	queryProvider.AssertExpectations(suite.T())
+}
+
+func (suite *UnitTestSuite) TestQueryResultCacheFollowerRetriesLeaderTimeout() {
+	release := make(chan struct{})
+	queryProvider := new(mockQueryProvider)
+	queryProvider.
+		On("N1QLQuery", mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+		Run(func(args mock.Arguments) {
+			<-release
+		}).
+		Return(nil, ErrTimeout).
+		Once()
+	queryProvider.
+		On("N1QLQuery", mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+		Return(&testQueryRowsReader{rows: [][]byte{[]byte(`"a"`)}, meta: []byte(`{"requestID": "req"}`)}, nil).
+		Once()
+
+	cluster := suite.resultCacheCluster(QueryResultCacheConfig{}, queryProvider)
+
+	leaderErrCh := make(chan error)
+	go func() {
+		_, err := cluster.Query("SELECT RAW name FROM default", &QueryOptions{
+			Adhoc:   true,
+			Cache:   true,
+			Timeout: 10 * time.Millisecond,
+		})
+		leaderErrCh <- err
+	}()
+	suite.Require().Eventually(func() bool {
+		return cluster.QueryResultCacheStats().Misses == 1
+	}, time.Second, time.Millisecond)
+
+	followerCh := make(chan []string)
+	go func() {
+		followerCh <- suite.queryCachedRows(cluster, "SELECT RAW name FROM default", &QueryOptions{Adhoc: true, Cache: true})
+	}()
+	suite.Require().Eventually(func() bool {
+		return cluster.QueryResultCacheStats().Collapsed == 1
+	}, time.Second, time.Millisecond)
+	close(release)
+
+	// The leader timed out on its own timeout, the follower sends the query again on its own options.
+	err := <-leaderErrCh
+	suite.Assert().True(errors.Is(err, ErrTimeout), err)
+	suite.Assert().Equal([]string{"a"}, <-followerCh)
+	queryProvider.AssertExpectations(suite.T())
+}
+
+func (suite *UnitTestSuite) TestQueryResultCacheHitsTrackedAndMetered() {
+	queryProvider := new(mockQueryProvider)
+	queryProvider.
+		On("N1QLQuery", mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+		Return(&testQueryRowsReader{rows: [][]byte{[]byte(`"a"`)}, meta: []byte(`{"requestID": "req"}`)}, nil).
+		Once()
+
+	meter := newTestMeter()
+	cluster := suite.resultCacheCluster(QueryResultCacheConfig{}, queryProvider)
+	cluster.sb.Meter = newMeterWrapper(meter)
+	cluster.clusterClient.(*mockClient).On("close").Return(nil)
+
+	suite.queryCachedRows(cluster, "SELECT RAW name FROM default", &QueryOptions{Adhoc: true, Cache: true})
+	suite.queryCachedRows(cluster, "SELECT RAW name FROM default", &QueryOptions{Adhoc: true, Cache: true})
+	suite.Assert().Equal(uint64(1), cluster.QueryResultCacheStats().Hits)
+
+	// The cache hit is recorded as well as the query which was sent.
+	suite.Assert().Len(meter.values(MeterNameOperations, map[string]string{
+		MeterAttribServiceKey:   meterValueServiceQuery,
+		MeterAttribOperationKey: "query",
+	}), 2)
+
+	suite.Require().Nil(cluster.Close(nil))
+	_, err := cluster.Query("SELECT RAW name FROM default", &QueryOptions{Adhoc: true, Cache: true})
+	suite.Assert().True(errors.Is(err, ErrClusterClosed), err)
+	queryProvider.AssertExpectations(suite.T())
+}
+
+func (suite *UnitTestSuite) TestQueryResultCacheRowsNotShared() {
+	queryProvider := new(mockQueryProvider)
+	queryProvider.
+		On("N1QLQuery", mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+		Return(&testQueryRowsReader{rows: [][]byte{[]byte(`"a"`)}, meta: []byte(`{"requestID": "req"}`)}, nil).
+		Once()
+
+	cluster := suite.resultCacheCluster(QueryResultCacheConfig{}, queryProvider)
+	suite.queryCachedRows(cluster, "SELECT RAW name FROM default", &QueryOptions{Adhoc: true, Cache: true})
+
+	result, err := cluster.Query("SELECT RAW name FROM default", &QueryOptions{Adhoc: true, Cache: true})
+	suite.Require().Nil(err, err)
+	suite.Require().True(result.Next())
+	var row json.RawMessage
+	suite.Require().Nil(result.Row(&row))
+	suite.Require().Nil(result.Close())
+
+	// Modifying a row which was served from the cache does not change the cached results.
+	row[1] = 'z'
+	suite.Assert().Equal([]string{"a"}, suite.queryCachedRows(cluster, "SELECT RAW name FROM default", &QueryOptions{Adhoc: true, Cache: true}))
+	queryProvider.AssertExpectations(suite.T())
+}
+
+func (suite *UnitTestSuite) TestQueryResultCacheStreamsLargeResults() {
+	rows := [][]byte{[]byte(`"aaaaaaaaaa"`), []byte(`"bbbbbbbbbb"`), []byte(`"cccccccccc"`)}
+	queryProvider := new(mockQueryProvider)
+	queryProvider.
+		On("N1QLQuery", mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+		Return(&testQueryRowsReader{rows: rows, meta: []byte(`{"requestID": "req"}`)}, nil).
+		Once()
+
+	key, ok := queryResultCacheKey("SELECT RAW name FROM default", &QueryOptions{})
+	suite.Require().True(ok)
+	cluster := suite.resultCacheCluster(QueryResultCacheConfig{MaxBytes: len(key) + len(rows[0])}, queryProvider)
+
+	suite.Assert().Equal([]string{"aaaaaaaaaa", "bbbbbbbbbb", "cccccccccc"},
+		suite.queryCachedRows(cluster, "SELECT RAW name FROM default", &QueryOptions{Adhoc: true, Cache: true}))
+
+	stats := cluster.QueryResultCacheStats()
+	suite.Assert().Equal(0, stats.Size)
+	suite.Assert().Equal(0, stats.Bytes)
+	queryProvider.AssertExpectations(suite.T())
+}
+
+func (suite *UnitTestSuite) TestQueryResultCacheKey() {
+	_, ok := queryResultCacheKey("  /* names */ (select RAW name FROM default)", &QueryOptions{})
+	suite.Assert().True(ok)
+
+	// Statements which may modify data are only cached when they are explicitly read only.
+	_, ok = queryResultCacheKey("UPDATE default SET a = 1 RETURNING RAW a", &QueryOptions{})
+	suite.Assert().False(ok)
+	_, ok = queryResultCacheKey("SELECTED", &QueryOptions{})
+	suite.Assert().False(ok)
+	_, ok = queryResultCacheKey("WITH a AS (SELECT 1) SELECT a", &QueryOptions{Readonly: true})
+	suite.Assert().True(ok)
+
+	// Consistency requirements passed through Raw are detected.
+	_, ok = queryResultCacheKey("SELECT 1", &QueryOptions{Raw: map[string]interface{}{"scan_consistency": "request_plus"}})
+	suite.Assert().False(ok)
+	_, ok = queryResultCacheKey("SELECT 1", &QueryOptions{Raw: map[string]interface{}{"scan_vectors": map[string]interface{}{}}})
+	suite.Assert().False(ok)
+	_, ok = queryResultCacheKey("SELECT 1", &QueryOptions{Raw: map[string]interface{}{"scan_consistency": "not_bounded"}})
+	suite.Assert().True(ok)
+}
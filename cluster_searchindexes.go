@@ -95,7 +95,7 @@ func (si *SearchIndex) toData() (jsonSearchIndex, error) {
 type SearchIndexManager struct {
 	mgmtProvider mgmtProvider
 
-	tracer requestTracer
+	tracer RequestTracer
 }
 
 func (sm *SearchIndexManager) tryParseErrorMessage(req *mgmtRequest, resp *mgmtResponse) error {
@@ -130,6 +130,8 @@ func (sm *SearchIndexManager) doMgmtRequest(req mgmtRequest) (*mgmtResponse, err
 type GetAllSearchIndexOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // GetAllIndexes retrieves all of the search indexes for the cluster.
@@ -138,7 +140,7 @@ func (sm *SearchIndexManager) GetAllIndexes(opts *GetAllSearchIndexOptions) ([]S
 		opts = &GetAllSearchIndexOptions{}
 	}
 
-	span := sm.tracer.StartSpan("GetAllIndexes", nil).
+	span := sm.tracer.StartSpan("GetAllIndexes", opts.ParentSpan).
 		SetTag("couchbase.service", "search")
 	defer span.Finish()
 
@@ -196,6 +198,8 @@ func (sm *SearchIndexManager) GetAllIndexes(opts *GetAllSearchIndexOptions) ([]S
 type GetSearchIndexOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // GetIndex retrieves a specific search index by name.
@@ -204,7 +208,7 @@ func (sm *SearchIndexManager) GetIndex(indexName string, opts *GetSearchIndexOpt
 		opts = &GetSearchIndexOptions{}
 	}
 
-	span := sm.tracer.StartSpan("GetIndex", nil).
+	span := sm.tracer.StartSpan("GetIndex", opts.ParentSpan).
 		SetTag("couchbase.service", "search")
 	defer span.Finish()
 
@@ -256,6 +260,8 @@ func (sm *SearchIndexManager) GetIndex(indexName string, opts *GetSearchIndexOpt
 type UpsertSearchIndexOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // UpsertIndex creates or updates a search index.
@@ -271,7 +277,7 @@ func (sm *SearchIndexManager) UpsertIndex(indexDefinition SearchIndex, opts *Ups
 		return invalidArgumentsError{"index type cannot be empty"}
 	}
 
-	span := sm.tracer.StartSpan("UpsertIndex", nil).
+	span := sm.tracer.StartSpan("UpsertIndex", opts.ParentSpan).
 		SetTag("couchbase.service", "search")
 	defer span.Finish()
 
@@ -318,6 +324,8 @@ func (sm *SearchIndexManager) UpsertIndex(indexDefinition SearchIndex, opts *Ups
 type DropSearchIndexOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // DropIndex removes the search index with the specific name.
@@ -330,7 +338,7 @@ func (sm *SearchIndexManager) DropIndex(indexName string, opts *DropSearchIndexO
 		return invalidArgumentsError{"indexName cannot be empty"}
 	}
 
-	span := sm.tracer.StartSpan("DropIndex", nil).
+	span := sm.tracer.StartSpan("DropIndex", opts.ParentSpan).
 		SetTag("couchbase.service", "search")
 	defer span.Finish()
 
@@ -358,6 +366,8 @@ func (sm *SearchIndexManager) DropIndex(indexName string, opts *DropSearchIndexO
 type AnalyzeDocumentOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // AnalyzeDocument returns how a doc is analyzed against a specific index.
@@ -370,7 +380,7 @@ func (sm *SearchIndexManager) AnalyzeDocument(indexName string, doc interface{},
 		return nil, invalidArgumentsError{"indexName cannot be empty"}
 	}
 
-	span := sm.tracer.StartSpan("AnalyzeDocument", nil).
+	span := sm.tracer.StartSpan("AnalyzeDocument", opts.ParentSpan).
 		SetTag("couchbase.service", "search")
 	defer span.Finish()
 
@@ -425,6 +435,8 @@ func (sm *SearchIndexManager) AnalyzeDocument(indexName string, doc interface{},
 type GetIndexedDocumentsCountOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // GetIndexedDocumentsCount retrieves the document count for a search index.
@@ -437,7 +449,7 @@ func (sm *SearchIndexManager) GetIndexedDocumentsCount(indexName string, opts *G
 		return 0, invalidArgumentsError{"indexName cannot be empty"}
 	}
 
-	span := sm.tracer.StartSpan("GetIndexedDocumentsCount", nil).
+	span := sm.tracer.StartSpan("GetIndexedDocumentsCount", opts.ParentSpan).
 		SetTag("couchbase.service", "search")
 	defer span.Finish()
 
@@ -482,7 +494,7 @@ func (sm *SearchIndexManager) GetIndexedDocumentsCount(indexName string, opts *G
 }
 
 func (sm *SearchIndexManager) performControlRequest(
-	tracectx requestSpanContext,
+	tracectx RequestSpanContext,
 	method, uri string,
 	timeout time.Duration,
 	retryStrategy RetryStrategy,
@@ -518,6 +530,8 @@ func (sm *SearchIndexManager) performControlRequest(
 type PauseIngestSearchIndexOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // PauseIngest pauses updates and maintenance for an index.
@@ -530,7 +544,7 @@ func (sm *SearchIndexManager) PauseIngest(indexName string, opts *PauseIngestSea
 		return invalidArgumentsError{"indexName cannot be empty"}
 	}
 
-	span := sm.tracer.StartSpan("PauseIngest", nil).
+	span := sm.tracer.StartSpan("PauseIngest", opts.ParentSpan).
 		SetTag("couchbase.service", "search")
 	defer span.Finish()
 
@@ -546,6 +560,8 @@ func (sm *SearchIndexManager) PauseIngest(indexName string, opts *PauseIngestSea
 type ResumeIngestSearchIndexOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // ResumeIngest resumes updates and maintenance for an index.
@@ -558,7 +574,7 @@ func (sm *SearchIndexManager) ResumeIngest(indexName string, opts *ResumeIngestS
 		return invalidArgumentsError{"indexName cannot be empty"}
 	}
 
-	span := sm.tracer.StartSpan("ResumeIngest", nil).
+	span := sm.tracer.StartSpan("ResumeIngest", opts.ParentSpan).
 		SetTag("couchbase.service", "search")
 	defer span.Finish()
 
@@ -574,6 +590,8 @@ func (sm *SearchIndexManager) ResumeIngest(indexName string, opts *ResumeIngestS
 type AllowQueryingSearchIndexOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // AllowQuerying allows querying against an index.
@@ -586,7 +604,7 @@ func (sm *SearchIndexManager) AllowQuerying(indexName string, opts *AllowQueryin
 		return invalidArgumentsError{"indexName cannot be empty"}
 	}
 
-	span := sm.tracer.StartSpan("AllowQuerying", nil).
+	span := sm.tracer.StartSpan("AllowQuerying", opts.ParentSpan).
 		SetTag("couchbase.service", "search")
 	defer span.Finish()
 
@@ -602,6 +620,8 @@ func (sm *SearchIndexManager) AllowQuerying(indexName string, opts *AllowQueryin
 type DisallowQueryingSearchIndexOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // DisallowQuerying disallows querying against an index.
@@ -614,7 +634,7 @@ func (sm *SearchIndexManager) DisallowQuerying(indexName string, opts *AllowQuer
 		return invalidArgumentsError{"indexName cannot be empty"}
 	}
 
-	span := sm.tracer.StartSpan("DisallowQuerying", nil).
+	span := sm.tracer.StartSpan("DisallowQuerying", opts.ParentSpan).
 		SetTag("couchbase.service", "search")
 	defer span.Finish()
 
@@ -630,6 +650,8 @@ func (sm *SearchIndexManager) DisallowQuerying(indexName string, opts *AllowQuer
 type FreezePlanSearchIndexOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // FreezePlan freezes the assignment of index partitions to nodes.
@@ -642,7 +664,7 @@ func (sm *SearchIndexManager) FreezePlan(indexName string, opts *AllowQueryingSe
 		return invalidArgumentsError{"indexName cannot be empty"}
 	}
 
-	span := sm.tracer.StartSpan("FreezePlan", nil).
+	span := sm.tracer.StartSpan("FreezePlan", opts.ParentSpan).
 		SetTag("couchbase.service", "search")
 	defer span.Finish()
 
@@ -658,6 +680,8 @@ func (sm *SearchIndexManager) FreezePlan(indexName string, opts *AllowQueryingSe
 type UnfreezePlanSearchIndexOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // UnfreezePlan unfreezes the assignment of index partitions to nodes.
@@ -670,7 +694,7 @@ func (sm *SearchIndexManager) UnfreezePlan(indexName string, opts *AllowQuerying
 		return invalidArgumentsError{"indexName cannot be empty"}
 	}
 
-	span := sm.tracer.StartSpan("UnfreezePlan", nil).
+	span := sm.tracer.StartSpan("UnfreezePlan", opts.ParentSpan).
 		SetTag("couchbase.service", "search")
 	defer span.Finish()
 
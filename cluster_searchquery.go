@@ -269,7 +269,7 @@ func (c *Cluster) SearchQuery(indexName string, query cbsearch.Query, opts *Sear
 		opts = &SearchOptions{}
 	}
 
-	span := c.sb.Tracer.StartSpan("SearchQuery", opts.parentSpan).
+	span := c.sb.Tracer.StartSpan("SearchQuery", opts.ParentSpan).
 		SetTag("couchbase.service", "search")
 	defer span.Finish()
 
@@ -281,7 +281,7 @@ func (c *Cluster) SearchQuery(indexName string, query cbsearch.Query, opts *Sear
 
 	retryStrategy := c.sb.RetryStrategyWrapper
 	if opts.RetryStrategy != nil {
-		retryStrategy = newRetryStrategyWrapper(opts.RetryStrategy)
+		retryStrategy = c.sb.RetryStrategyWrapper.withStrategy(opts.RetryStrategy)
 	}
 
 	searchOpts, err := opts.toMap()
@@ -294,7 +294,10 @@ func (c *Cluster) SearchQuery(indexName string, query cbsearch.Query, opts *Sear
 
 	searchOpts["query"] = query
 
-	return c.execSearchQuery(span, indexName, searchOpts, deadline, retryStrategy)
+	start := time.Now()
+	res, err := c.execSearchQuery(span, indexName, searchOpts, deadline, retryStrategy)
+	c.sb.Meter.recordOperation(meterValueServiceSearch, "search", start, err)
+	return res, err
 }
 
 func maybeGetSearchOptionQuery(options map[string]interface{}) interface{} {
@@ -305,12 +308,21 @@ func maybeGetSearchOptionQuery(options map[string]interface{}) interface{} {
 }
 
 func (c *Cluster) execSearchQuery(
-	span requestSpan,
+	span RequestSpan,
 	indexName string,
 	options map[string]interface{},
 	deadline time.Time,
 	retryStrategy *retryStrategyWrapper,
 ) (*SearchResult, error) {
+	op, err := c.sb.Ops.begin()
+	if err != nil {
+		return nil, SearchError{
+			InnerError: err,
+			Query:      maybeGetSearchOptionQuery(options),
+		}
+	}
+	defer op.finishUnlessStreaming()
+
 	provider, err := c.getSearchProvider()
 	if err != nil {
 		return nil, SearchError{
@@ -319,6 +331,23 @@ func (c *Cluster) execSearchQuery(
 		}
 	}
 
+	if !c.sb.HTTPCircuitBreakers.allowsRequest(ServiceTypeSearch, c.httpCircuitBreakerCanary(ServiceTypeSearch)) {
+		return nil, SearchError{
+			InnerError: ErrCircuitBreakerOpen,
+			Query:      maybeGetSearchOptionQuery(options),
+		}
+	}
+
+	release, err := c.sb.Bulkheads.forService(ServiceTypeSearch).acquire(deadline, nil)
+	if err != nil {
+		return nil, SearchError{
+			InnerError: err,
+			Query:      maybeGetSearchOptionQuery(options),
+		}
+	}
+	slot := &streamingBulkheadRelease{release: release}
+	defer slot.releaseUnlessStreaming()
+
 	reqBytes, err := json.Marshal(options)
 	if err != nil {
 		return nil, SearchError{
@@ -335,8 +364,15 @@ func (c *Cluster) execSearchQuery(
 		TraceContext:  span.Context(),
 	})
 	if err != nil {
-		return nil, maybeEnhanceSearchError(err)
+		err = maybeEnhanceSearchError(err)
+		c.sb.HTTPCircuitBreakers.markCompletion(ServiceTypeSearch, err)
+		return nil, err
 	}
+	releaseSlot := slot.startStreaming()
+	rows := onRowsComplete(res, func(err error) {
+		c.sb.HTTPCircuitBreakers.markCompletion(ServiceTypeSearch, maybeEnhanceSearchError(err))
+		releaseSlot()
+	})
 
-	return newSearchResult(res), nil
+	return newSearchResult(op.trackRows(rows)), nil
 }
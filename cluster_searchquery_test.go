@@ -256,3 +256,34 @@ func (suite *UnitTestSuite) TestSearchQuery() {
 
 	suite.Assert().Equal(expectedFacets, facets)
 }
+
+func (suite *UnitTestSuite) TestSearchQueryGocbcoreError() {
+	retErr := &gocbcore.SearchError{
+		Endpoint:      "http://localhost:8094",
+		Query:         "query",
+		RetryReasons:  []gocbcore.RetryReason{gocbcore.SearchTooManyRequestsRetryReason},
+		RetryAttempts: 3,
+	}
+
+	provider := new(mockSearchProvider)
+	provider.
+		On("SearchQuery", mock.AnythingOfType("gocbcore.SearchQueryOptions")).
+		Return(nil, retErr)
+
+	cli := new(mockClient)
+	cli.On("getSearchProvider").Return(provider, nil)
+	cli.On("supportsGCCCP").Return(true)
+
+	cluster := clusterFromOptions(ClusterOptions{})
+	cluster.clusterClient = cli
+
+	result, err := cluster.SearchQuery("index", search.NewMatchQuery("test"), nil)
+	suite.Require().IsType(&SearchError{}, err)
+	suite.Require().Equal(&SearchError{
+		Endpoint:      "http://localhost:8094",
+		Query:         "query",
+		RetryReasons:  []RetryReason{SearchTooManyRequestsRetryReason},
+		RetryAttempts: 3,
+	}, err)
+	suite.Require().Nil(result)
+}
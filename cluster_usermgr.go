@@ -227,7 +227,7 @@ func (g *Group) fromData(data jsonGroup) error {
 // UserManager provides methods for performing Couchbase user management.
 type UserManager struct {
 	provider mgmtProvider
-	tracer   requestTracer
+	tracer   RequestTracer
 }
 
 func (um *UserManager) tryParseErrorMessage(req *mgmtRequest, resp *mgmtResponse) error {
@@ -263,6 +263,8 @@ type GetAllUsersOptions struct {
 	RetryStrategy RetryStrategy
 
 	DomainName string
+
+	ParentSpan RequestSpanContext
 }
 
 // GetAllUsers returns a list of all the users from the cluster.
@@ -271,7 +273,7 @@ func (um *UserManager) GetAllUsers(opts *GetAllUsersOptions) ([]UserAndMetadata,
 		opts = &GetAllUsersOptions{}
 	}
 
-	span := um.tracer.StartSpan("GetAllUsers", nil).
+	span := um.tracer.StartSpan("GetAllUsers", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -332,6 +334,8 @@ type GetUserOptions struct {
 	RetryStrategy RetryStrategy
 
 	DomainName string
+
+	ParentSpan RequestSpanContext
 }
 
 // GetUser returns the data for a particular user
@@ -340,7 +344,7 @@ func (um *UserManager) GetUser(name string, opts *GetUserOptions) (*UserAndMetad
 		opts = &GetUserOptions{}
 	}
 
-	span := um.tracer.StartSpan("GetUser", nil).
+	span := um.tracer.StartSpan("GetUser", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -399,6 +403,8 @@ type UpsertUserOptions struct {
 	RetryStrategy RetryStrategy
 
 	DomainName string
+
+	ParentSpan RequestSpanContext
 }
 
 // UpsertUser updates a built-in RBAC user on the cluster.
@@ -407,7 +413,7 @@ func (um *UserManager) UpsertUser(user User, opts *UpsertUserOptions) error {
 		opts = &UpsertUserOptions{}
 	}
 
-	span := um.tracer.StartSpan("UpsertUser", nil).
+	span := um.tracer.StartSpan("UpsertUser", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -464,6 +470,8 @@ type DropUserOptions struct {
 	RetryStrategy RetryStrategy
 
 	DomainName string
+
+	ParentSpan RequestSpanContext
 }
 
 // DropUser removes a built-in RBAC user on the cluster.
@@ -472,7 +480,7 @@ func (um *UserManager) DropUser(name string, opts *DropUserOptions) error {
 		opts = &DropUserOptions{}
 	}
 
-	span := um.tracer.StartSpan("DropUser", nil).
+	span := um.tracer.StartSpan("DropUser", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -510,6 +518,8 @@ func (um *UserManager) DropUser(name string, opts *DropUserOptions) error {
 type GetRolesOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // GetRoles lists the roles supported by the cluster.
@@ -518,7 +528,7 @@ func (um *UserManager) GetRoles(opts *GetRolesOptions) ([]RoleAndDescription, er
 		opts = &GetRolesOptions{}
 	}
 
-	span := um.tracer.StartSpan("GetRoles", nil).
+	span := um.tracer.StartSpan("GetRoles", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -573,6 +583,8 @@ func (um *UserManager) GetRoles(opts *GetRolesOptions) ([]RoleAndDescription, er
 type GetGroupOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // GetGroup fetches a single group from the server.
@@ -584,7 +596,7 @@ func (um *UserManager) GetGroup(groupName string, opts *GetGroupOptions) (*Group
 		opts = &GetGroupOptions{}
 	}
 
-	span := um.tracer.StartSpan("GetGroup", nil).
+	span := um.tracer.StartSpan("GetGroup", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -637,6 +649,8 @@ func (um *UserManager) GetGroup(groupName string, opts *GetGroupOptions) (*Group
 type GetAllGroupsOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // GetAllGroups fetches all groups from the server.
@@ -645,7 +659,7 @@ func (um *UserManager) GetAllGroups(opts *GetAllGroupsOptions) ([]Group, error)
 		opts = &GetAllGroupsOptions{}
 	}
 
-	span := um.tracer.StartSpan("GetAllGroups", nil).
+	span := um.tracer.StartSpan("GetAllGroups", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -700,6 +714,8 @@ func (um *UserManager) GetAllGroups(opts *GetAllGroupsOptions) ([]Group, error)
 type UpsertGroupOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // UpsertGroup creates, or updates, a group on the server.
@@ -711,7 +727,7 @@ func (um *UserManager) UpsertGroup(group Group, opts *UpsertGroupOptions) error
 		opts = &UpsertGroupOptions{}
 	}
 
-	span := um.tracer.StartSpan("UpsertGroup", nil).
+	span := um.tracer.StartSpan("UpsertGroup", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -761,6 +777,8 @@ func (um *UserManager) UpsertGroup(group Group, opts *UpsertGroupOptions) error
 type DropGroupOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // DropGroup removes a group from the server.
@@ -773,7 +791,7 @@ func (um *UserManager) DropGroup(groupName string, opts *DropGroupOptions) error
 		opts = &DropGroupOptions{}
 	}
 
-	span := um.tracer.StartSpan("DropGroup", nil).
+	span := um.tracer.StartSpan("DropGroup", opts.ParentSpan).
 		SetTag("couchbase.service", "mgmt")
 	defer span.Finish()
 
@@ -42,7 +42,17 @@ func (c *Collection) Name() string {
 	return c.sb.CollectionName
 }
 
-func (c *Collection) startKvOpTrace(operationName string, tracectx requestSpanContext) requestSpan {
+// ScopeName returns the name of the scope which the collection belongs to.
+func (c *Collection) ScopeName() string {
+	return c.sb.ScopeName
+}
+
+// BucketName returns the name of the bucket which the collection belongs to.
+func (c *Collection) BucketName() string {
+	return c.sb.BucketName
+}
+
+func (c *Collection) startKvOpTrace(operationName string, tracectx RequestSpanContext) RequestSpan {
 	return c.sb.Tracer.StartSpan(operationName, tracectx).
 		SetTag("couchbase.bucket", c.sb.BucketName).
 		SetTag("couchbase.collection", c.sb.CollectionName).
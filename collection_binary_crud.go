@@ -19,6 +19,8 @@ type AppendOptions struct {
 	ReplicateTo     uint
 	Cas             Cas
 	RetryStrategy   RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 func (c *Collection) binaryAppend(id string, val []byte, opts *AppendOptions) (mutOut *MutationResult, errOut error) {
@@ -26,7 +28,7 @@ func (c *Collection) binaryAppend(id string, val []byte, opts *AppendOptions) (m
 		opts = &AppendOptions{}
 	}
 
-	opm := c.newKvOpManager("Append", nil)
+	opm := c.newKvOpManager("Append", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -51,7 +53,7 @@ func (c *Collection) binaryAppend(id string, val []byte, opts *AppendOptions) (m
 		DurabilityLevelTimeout: opm.DurabilityTimeout(),
 		Cas:                    gocbcore.Cas(opts.Cas),
 		RetryStrategy:          opm.RetryStrategy(),
-		TraceContext:           opm.TraceSpan(),
+		TraceContext:           opm.TraceSpan().Context(),
 		Deadline:               opm.Deadline(),
 	}, func(res *gocbcore.AdjoinResult, err error) {
 		if err != nil {
@@ -85,6 +87,8 @@ type PrependOptions struct {
 	ReplicateTo     uint
 	Cas             Cas
 	RetryStrategy   RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 func (c *Collection) binaryPrepend(id string, val []byte, opts *PrependOptions) (mutOut *MutationResult, errOut error) {
@@ -92,7 +96,7 @@ func (c *Collection) binaryPrepend(id string, val []byte, opts *PrependOptions)
 		opts = &PrependOptions{}
 	}
 
-	opm := c.newKvOpManager("Prepend", nil)
+	opm := c.newKvOpManager("Prepend", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -117,7 +121,7 @@ func (c *Collection) binaryPrepend(id string, val []byte, opts *PrependOptions)
 		DurabilityLevelTimeout: opm.DurabilityTimeout(),
 		Cas:                    gocbcore.Cas(opts.Cas),
 		RetryStrategy:          opm.RetryStrategy(),
-		TraceContext:           opm.TraceSpan(),
+		TraceContext:           opm.TraceSpan().Context(),
 		Deadline:               opm.Deadline(),
 	}, func(res *gocbcore.AdjoinResult, err error) {
 		if err != nil {
@@ -159,6 +163,8 @@ type IncrementOptions struct {
 	ReplicateTo     uint
 	Cas             Cas
 	RetryStrategy   RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 func (c *Collection) binaryIncrement(id string, opts *IncrementOptions) (countOut *CounterResult, errOut error) {
@@ -166,7 +172,7 @@ func (c *Collection) binaryIncrement(id string, opts *IncrementOptions) (countOu
 		opts = &IncrementOptions{}
 	}
 
-	opm := c.newKvOpManager("Increment", nil)
+	opm := c.newKvOpManager("Increment", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -198,7 +204,7 @@ func (c *Collection) binaryIncrement(id string, opts *IncrementOptions) (countOu
 		DurabilityLevelTimeout: opm.DurabilityTimeout(),
 		Cas:                    gocbcore.Cas(opts.Cas),
 		RetryStrategy:          opm.RetryStrategy(),
-		TraceContext:           opm.TraceSpan(),
+		TraceContext:           opm.TraceSpan().Context(),
 		Deadline:               opm.Deadline(),
 	}, func(res *gocbcore.CounterResult, err error) {
 		if err != nil {
@@ -243,6 +249,8 @@ type DecrementOptions struct {
 	ReplicateTo     uint
 	Cas             Cas
 	RetryStrategy   RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 func (c *Collection) binaryDecrement(id string, opts *DecrementOptions) (countOut *CounterResult, errOut error) {
@@ -250,7 +258,7 @@ func (c *Collection) binaryDecrement(id string, opts *DecrementOptions) (countOu
 		opts = &DecrementOptions{}
 	}
 
-	opm := c.newKvOpManager("Decrement", nil)
+	opm := c.newKvOpManager("Decrement", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -282,7 +290,7 @@ func (c *Collection) binaryDecrement(id string, opts *DecrementOptions) (countOu
 		DurabilityLevelTimeout: opm.DurabilityTimeout(),
 		Cas:                    gocbcore.Cas(opts.Cas),
 		RetryStrategy:          opm.RetryStrategy(),
-		TraceContext:           opm.TraceSpan(),
+		TraceContext:           opm.TraceSpan().Context(),
 		Deadline:               opm.Deadline(),
 	}, func(res *gocbcore.CounterResult, err error) {
 		if err != nil {
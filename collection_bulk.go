@@ -1,22 +1,49 @@
 package gocb
 
 import (
+	"sync"
 	"time"
 
 	"github.com/couchbase/gocbcore/v9"
 )
 
 type bulkOp struct {
-	pendop gocbcore.PendingOp
-	span   requestSpan
+	// pendopLock guards pendop, which is set by the dispatching goroutine once the op has been sent and may
+	// already have completed by then.
+	pendopLock sync.Mutex
+	pendop     gocbcore.PendingOp
+	span       RequestSpan
+	release    func()
+}
+
+func (op *bulkOp) setPendingOp(pendop gocbcore.PendingOp) {
+	op.pendopLock.Lock()
+	op.pendop = pendop
+	op.pendopLock.Unlock()
 }
 
 func (op *bulkOp) cancel() {
-	op.pendop.Cancel()
+	op.pendopLock.Lock()
+	pendop := op.pendop
+	op.pendopLock.Unlock()
+
+	if pendop != nil {
+		pendop.Cancel()
+	}
+}
+
+func (op *bulkOp) admit(release func()) {
+	op.release = release
 }
 
 func (op *bulkOp) finish() {
-	op.span.Finish()
+	if op.release != nil {
+		op.release()
+		op.release = nil
+	}
+	if op.span != nil {
+		op.span.Finish()
+	}
 }
 
 // BulkOp represents a single operation that can be submitted (within a list of more operations) to .Do()
@@ -24,9 +51,10 @@ func (op *bulkOp) finish() {
 // such as GetOp, UpsertOp, ReplaceOp, and more.
 // UNCOMMITTED: This API may change in the future.
 type BulkOp interface {
-	execute(tracectx requestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
-		retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, requestSpanContext) requestSpan)
+	execute(tracectx RequestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
+		retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, RequestSpanContext) RequestSpan)
 	markError(err error)
+	admit(release func())
 	cancel()
 	finish()
 }
@@ -36,6 +64,8 @@ type BulkOpOptions struct {
 	Timeout       time.Duration
 	Transcoder    Transcoder
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // Do execute one or more `BulkOp` items in parallel.
@@ -45,7 +75,7 @@ func (c *Collection) Do(ops []BulkOp, opts *BulkOpOptions) error {
 		opts = &BulkOpOptions{}
 	}
 
-	span := c.startKvOpTrace("Do", nil)
+	span := c.startKvOpTrace("Do", opts.ParentSpan)
 
 	timeout := opts.Timeout
 	if opts.Timeout == 0 {
@@ -54,13 +84,19 @@ func (c *Collection) Do(ops []BulkOp, opts *BulkOpOptions) error {
 
 	retryWrapper := c.sb.RetryStrategyWrapper
 	if opts.RetryStrategy != nil {
-		retryWrapper = newRetryStrategyWrapper(opts.RetryStrategy)
+		retryWrapper = c.sb.RetryStrategyWrapper.withStrategy(opts.RetryStrategy)
 	}
 
 	if opts.Transcoder == nil {
 		opts.Transcoder = c.sb.Transcoder
 	}
 
+	op, err := c.sb.Ops.begin()
+	if err != nil {
+		return err
+	}
+	defer op.finish()
+
 	agent, err := c.getKvProvider()
 	if err != nil {
 		return err
@@ -70,8 +106,35 @@ func (c *Collection) Do(ops []BulkOp, opts *BulkOpOptions) error {
 	//   we get delayed inside execute (don't want to block the
 	//   individual op handlers when they dispatch their signal).
 	signal := make(chan BulkOp, len(ops))
-	for _, item := range ops {
-		item.execute(span.Context(), c, agent, opts.Transcoder, signal, retryWrapper, time.Now().Add(timeout), c.startKvOpTrace)
+	deadline := time.Now().Add(timeout)
+	kvBulkhead := c.sb.Bulkheads.forService(ServiceTypeKeyValue).batch()
+
+	if !kvBulkhead.enabled() {
+		for _, item := range ops {
+			item.execute(span.Context(), c, agent, opts.Transcoder, signal, retryWrapper, deadline, c.startKvOpTrace)
+		}
+	} else {
+		// Each op takes a slot in the KV bulkhead which is freed once its completion is received below, so ops are
+		//   dispatched in the background to allow the earlier ones to complete while the later ones wait for them.
+		var dispatchWg sync.WaitGroup
+		dispatchWg.Add(1)
+		defer dispatchWg.Wait()
+
+		go func() {
+			defer dispatchWg.Done()
+
+			for _, item := range ops {
+				release, err := kvBulkhead.acquire(deadline)
+				if err != nil {
+					item.markError(err)
+					signal <- item
+					continue
+				}
+				item.admit(release)
+
+				item.execute(span.Context(), c, agent, opts.Transcoder, signal, retryWrapper, deadline, c.startKvOpTrace)
+			}
+		}()
 	}
 
 	for range ops {
@@ -99,8 +162,8 @@ func (item *GetOp) markError(err error) {
 	item.Err = err
 }
 
-func (item *GetOp) execute(tracectx requestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
-	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, requestSpanContext) requestSpan) {
+func (item *GetOp) execute(tracectx RequestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
+	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, RequestSpanContext) RequestSpan) {
 	span := startSpanFunc("GetOp", tracectx)
 	item.bulkOp.span = span
 
@@ -129,7 +192,7 @@ func (item *GetOp) execute(tracectx requestSpanContext, c *Collection, provider
 		item.Err = err
 		signal <- item
 	} else {
-		item.bulkOp.pendop = op
+		item.bulkOp.setPendingOp(op)
 	}
 }
 
@@ -148,8 +211,8 @@ func (item *GetAndTouchOp) markError(err error) {
 	item.Err = err
 }
 
-func (item *GetAndTouchOp) execute(tracectx requestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
-	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, requestSpanContext) requestSpan) {
+func (item *GetAndTouchOp) execute(tracectx RequestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
+	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, RequestSpanContext) RequestSpan) {
 	span := startSpanFunc("GetAndTouchOp", tracectx)
 	item.bulkOp.span = span
 
@@ -179,7 +242,7 @@ func (item *GetAndTouchOp) execute(tracectx requestSpanContext, c *Collection, p
 		item.Err = err
 		signal <- item
 	} else {
-		item.bulkOp.pendop = op
+		item.bulkOp.setPendingOp(op)
 	}
 }
 
@@ -198,8 +261,8 @@ func (item *TouchOp) markError(err error) {
 	item.Err = err
 }
 
-func (item *TouchOp) execute(tracectx requestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
-	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, requestSpanContext) requestSpan) {
+func (item *TouchOp) execute(tracectx RequestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
+	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, RequestSpanContext) RequestSpan) {
 	span := startSpanFunc("TouchOp", tracectx)
 	item.bulkOp.span = span
 
@@ -234,7 +297,7 @@ func (item *TouchOp) execute(tracectx requestSpanContext, c *Collection, provide
 		item.Err = err
 		signal <- item
 	} else {
-		item.bulkOp.pendop = op
+		item.bulkOp.setPendingOp(op)
 	}
 }
 
@@ -253,8 +316,8 @@ func (item *RemoveOp) markError(err error) {
 	item.Err = err
 }
 
-func (item *RemoveOp) execute(tracectx requestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
-	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, requestSpanContext) requestSpan) {
+func (item *RemoveOp) execute(tracectx RequestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
+	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, RequestSpanContext) RequestSpan) {
 	span := startSpanFunc("RemoveOp", tracectx)
 	item.bulkOp.span = span
 
@@ -289,7 +352,7 @@ func (item *RemoveOp) execute(tracectx requestSpanContext, c *Collection, provid
 		item.Err = err
 		signal <- item
 	} else {
-		item.bulkOp.pendop = op
+		item.bulkOp.setPendingOp(op)
 	}
 }
 
@@ -310,8 +373,8 @@ func (item *UpsertOp) markError(err error) {
 	item.Err = err
 }
 
-func (item *UpsertOp) execute(tracectx requestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder,
-	signal chan BulkOp, retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, requestSpanContext) requestSpan) {
+func (item *UpsertOp) execute(tracectx RequestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder,
+	signal chan BulkOp, retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, RequestSpanContext) RequestSpan) {
 	span := startSpanFunc("UpsertOp", tracectx)
 	item.bulkOp.span = span
 
@@ -358,7 +421,7 @@ func (item *UpsertOp) execute(tracectx requestSpanContext, c *Collection, provid
 		item.Err = err
 		signal <- item
 	} else {
-		item.bulkOp.pendop = op
+		item.bulkOp.setPendingOp(op)
 	}
 }
 
@@ -378,8 +441,8 @@ func (item *InsertOp) markError(err error) {
 	item.Err = err
 }
 
-func (item *InsertOp) execute(tracectx requestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
-	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, requestSpanContext) requestSpan) {
+func (item *InsertOp) execute(tracectx RequestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
+	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, RequestSpanContext) RequestSpan) {
 	span := startSpanFunc("InsertOp", tracectx)
 	item.bulkOp.span = span
 
@@ -426,7 +489,7 @@ func (item *InsertOp) execute(tracectx requestSpanContext, c *Collection, provid
 		item.Err = err
 		signal <- item
 	} else {
-		item.bulkOp.pendop = op
+		item.bulkOp.setPendingOp(op)
 	}
 }
 
@@ -447,8 +510,8 @@ func (item *ReplaceOp) markError(err error) {
 	item.Err = err
 }
 
-func (item *ReplaceOp) execute(tracectx requestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
-	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, requestSpanContext) requestSpan) {
+func (item *ReplaceOp) execute(tracectx RequestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
+	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, RequestSpanContext) RequestSpan) {
 	span := startSpanFunc("ReplaceOp", tracectx)
 	item.bulkOp.span = span
 
@@ -496,7 +559,7 @@ func (item *ReplaceOp) execute(tracectx requestSpanContext, c *Collection, provi
 		item.Err = err
 		signal <- item
 	} else {
-		item.bulkOp.pendop = op
+		item.bulkOp.setPendingOp(op)
 	}
 }
 
@@ -515,8 +578,8 @@ func (item *AppendOp) markError(err error) {
 	item.Err = err
 }
 
-func (item *AppendOp) execute(tracectx requestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
-	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, requestSpanContext) requestSpan) {
+func (item *AppendOp) execute(tracectx RequestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
+	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, RequestSpanContext) RequestSpan) {
 	span := startSpanFunc("AppendOp", tracectx)
 	item.bulkOp.span = span
 
@@ -551,7 +614,7 @@ func (item *AppendOp) execute(tracectx requestSpanContext, c *Collection, provid
 		item.Err = err
 		signal <- item
 	} else {
-		item.bulkOp.pendop = op
+		item.bulkOp.setPendingOp(op)
 	}
 }
 
@@ -570,8 +633,8 @@ func (item *PrependOp) markError(err error) {
 	item.Err = err
 }
 
-func (item *PrependOp) execute(tracectx requestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
-	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, requestSpanContext) requestSpan) {
+func (item *PrependOp) execute(tracectx RequestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
+	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, RequestSpanContext) RequestSpan) {
 	span := startSpanFunc("PrependOp", tracectx)
 	item.bulkOp.span = span
 
@@ -606,7 +669,7 @@ func (item *PrependOp) execute(tracectx requestSpanContext, c *Collection, provi
 		item.Err = err
 		signal <- item
 	} else {
-		item.bulkOp.pendop = op
+		item.bulkOp.setPendingOp(op)
 	}
 }
 
@@ -628,8 +691,8 @@ func (item *IncrementOp) markError(err error) {
 	item.Err = err
 }
 
-func (item *IncrementOp) execute(tracectx requestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
-	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, requestSpanContext) requestSpan) {
+func (item *IncrementOp) execute(tracectx RequestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
+	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, RequestSpanContext) RequestSpan) {
 	span := startSpanFunc("IncrementOp", tracectx)
 	item.bulkOp.span = span
 
@@ -674,7 +737,7 @@ func (item *IncrementOp) execute(tracectx requestSpanContext, c *Collection, pro
 		item.Err = err
 		signal <- item
 	} else {
-		item.bulkOp.pendop = op
+		item.bulkOp.setPendingOp(op)
 	}
 }
 
@@ -696,8 +759,8 @@ func (item *DecrementOp) markError(err error) {
 	item.Err = err
 }
 
-func (item *DecrementOp) execute(tracectx requestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
-	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, requestSpanContext) requestSpan) {
+func (item *DecrementOp) execute(tracectx RequestSpanContext, c *Collection, provider kvProvider, transcoder Transcoder, signal chan BulkOp,
+	retryWrapper *retryStrategyWrapper, deadline time.Time, startSpanFunc func(string, RequestSpanContext) RequestSpan) {
 	span := startSpanFunc("DecrementOp", tracectx)
 	item.bulkOp.span = span
 
@@ -742,6 +805,6 @@ func (item *DecrementOp) execute(tracectx requestSpanContext, c *Collection, pro
 		item.Err = err
 		signal <- item
 	} else {
-		item.bulkOp.pendop = op
+		item.bulkOp.setPendingOp(op)
 	}
 }
@@ -43,6 +43,8 @@ type InsertOptions struct {
 	Transcoder      Transcoder
 	Timeout         time.Duration
 	RetryStrategy   RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // Insert creates a new document in the Collection.
@@ -51,7 +53,7 @@ func (c *Collection) Insert(id string, val interface{}, opts *InsertOptions) (mu
 		opts = &InsertOptions{}
 	}
 
-	opm := c.newKvOpManager("Insert", nil)
+	opm := c.newKvOpManager("Insert", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -79,7 +81,7 @@ func (c *Collection) Insert(id string, val interface{}, opts *InsertOptions) (mu
 		DurabilityLevel:        opm.DurabilityLevel(),
 		DurabilityLevelTimeout: opm.DurabilityTimeout(),
 		RetryStrategy:          opm.RetryStrategy(),
-		TraceContext:           opm.TraceSpan(),
+		TraceContext:           opm.TraceSpan().Context(),
 		Deadline:               opm.Deadline(),
 	}, func(res *gocbcore.StoreResult, err error) {
 		if err != nil {
@@ -109,6 +111,8 @@ type UpsertOptions struct {
 	Transcoder      Transcoder
 	Timeout         time.Duration
 	RetryStrategy   RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // Upsert creates a new document in the Collection if it does not exist, if it does exist then it updates it.
@@ -117,7 +121,7 @@ func (c *Collection) Upsert(id string, val interface{}, opts *UpsertOptions) (mu
 		opts = &UpsertOptions{}
 	}
 
-	opm := c.newKvOpManager("Upsert", nil)
+	opm := c.newKvOpManager("Upsert", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -145,7 +149,7 @@ func (c *Collection) Upsert(id string, val interface{}, opts *UpsertOptions) (mu
 		DurabilityLevel:        opm.DurabilityLevel(),
 		DurabilityLevelTimeout: opm.DurabilityTimeout(),
 		RetryStrategy:          opm.RetryStrategy(),
-		TraceContext:           opm.TraceSpan(),
+		TraceContext:           opm.TraceSpan().Context(),
 		Deadline:               opm.Deadline(),
 	}, func(res *gocbcore.StoreResult, err error) {
 		if err != nil {
@@ -176,6 +180,8 @@ type ReplaceOptions struct {
 	Transcoder      Transcoder
 	Timeout         time.Duration
 	RetryStrategy   RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // Replace updates a document in the collection.
@@ -184,7 +190,7 @@ func (c *Collection) Replace(id string, val interface{}, opts *ReplaceOptions) (
 		opts = &ReplaceOptions{}
 	}
 
-	opm := c.newKvOpManager("Replace", nil)
+	opm := c.newKvOpManager("Replace", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -213,7 +219,7 @@ func (c *Collection) Replace(id string, val interface{}, opts *ReplaceOptions) (
 		DurabilityLevel:        opm.DurabilityLevel(),
 		DurabilityLevelTimeout: opm.DurabilityTimeout(),
 		RetryStrategy:          opm.RetryStrategy(),
-		TraceContext:           opm.TraceSpan(),
+		TraceContext:           opm.TraceSpan().Context(),
 		Deadline:               opm.Deadline(),
 	}, func(res *gocbcore.StoreResult, err error) {
 		if err != nil {
@@ -244,6 +250,13 @@ type GetOptions struct {
 	Transcoder    Transcoder
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	// Hedge causes the Get to also be sent to every replica when the active node has not responded within the
+	// hedge delay. It cannot be used with WithExpiry or Project.
+	// VOLATILE: This API is subject to change at any time.
+	Hedge *HedgedReadOptions
+
+	ParentSpan RequestSpanContext
 }
 
 // Get performs a fetch operation against the collection. This can take 3 paths, a standard full document
@@ -254,6 +267,14 @@ func (c *Collection) Get(id string, opts *GetOptions) (docOut *GetResult, errOut
 		opts = &GetOptions{}
 	}
 
+	if opts.Hedge != nil {
+		if len(opts.Project) > 0 || opts.WithExpiry {
+			return nil, makeInvalidArgumentsError("hedged reads cannot be used with WithExpiry or Project")
+		}
+
+		return c.getHedged(id, opts)
+	}
+
 	if len(opts.Project) == 0 && !opts.WithExpiry {
 		return c.getDirect(id, opts)
 	}
@@ -266,7 +287,7 @@ func (c *Collection) getDirect(id string, opts *GetOptions) (docOut *GetResult,
 		opts = &GetOptions{}
 	}
 
-	opm := c.newKvOpManager("Get", nil)
+	opm := c.newKvOpManager("Get", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -282,12 +303,13 @@ func (c *Collection) getDirect(id string, opts *GetOptions) (docOut *GetResult,
 	if err != nil {
 		return nil, err
 	}
+	start := time.Now()
 	err = opm.Wait(agent.Get(gocbcore.GetOptions{
 		Key:            opm.DocumentID(),
 		CollectionName: opm.CollectionName(),
 		ScopeName:      opm.ScopeName(),
 		RetryStrategy:  opm.RetryStrategy(),
-		TraceContext:   opm.TraceSpan(),
+		TraceContext:   opm.TraceSpan().Context(),
 		Deadline:       opm.Deadline(),
 	}, func(res *gocbcore.GetResult, err error) {
 		if err != nil {
@@ -306,6 +328,7 @@ func (c *Collection) getDirect(id string, opts *GetOptions) (docOut *GetResult,
 		}
 
 		docOut = doc
+		c.sb.KvLatencies.record(time.Since(start))
 
 		opm.Resolve(nil)
 	}))
@@ -320,7 +343,7 @@ func (c *Collection) getProjected(id string, opts *GetOptions) (docOut *GetResul
 		opts = &GetOptions{}
 	}
 
-	opm := c.newKvOpManager("Get", nil)
+	opm := c.newKvOpManager("Get", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -397,6 +420,8 @@ func (c *Collection) getProjected(id string, opts *GetOptions) (docOut *GetResul
 type ExistsOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // Exists checks if a document exists for the given id.
@@ -405,7 +430,7 @@ func (c *Collection) Exists(id string, opts *ExistsOptions) (docOut *ExistsResul
 		opts = &ExistsOptions{}
 	}
 
-	opm := c.newKvOpManager("Exists", nil)
+	opm := c.newKvOpManager("Exists", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -425,7 +450,7 @@ func (c *Collection) Exists(id string, opts *ExistsOptions) (docOut *ExistsResul
 		CollectionName: opm.CollectionName(),
 		ScopeName:      opm.ScopeName(),
 		RetryStrategy:  opm.RetryStrategy(),
-		TraceContext:   opm.TraceSpan,
+		TraceContext:   opm.TraceSpan().Context(),
 		Deadline:       opm.Deadline(),
 	}, func(res *gocbcore.GetMetaResult, err error) {
 		if errors.Is(err, ErrDocumentNotFound) {
@@ -463,7 +488,7 @@ func (c *Collection) Exists(id string, opts *ExistsOptions) (docOut *ExistsResul
 }
 
 func (c *Collection) getOneReplica(
-	span requestSpanContext,
+	span RequestSpanContext,
 	id string,
 	replicaIdx int,
 	transcoder Transcoder,
@@ -480,6 +505,10 @@ func (c *Collection) getOneReplica(
 	opm.SetTimeout(timeout)
 	opm.SetCancelCh(cancelCh)
 
+	if err := opm.CheckReadyForOp(); err != nil {
+		return nil, err
+	}
+
 	agent, err := c.getKvProvider()
 	if err != nil {
 		return nil, err
@@ -490,7 +519,7 @@ func (c *Collection) getOneReplica(
 			CollectionName: opm.CollectionName(),
 			ScopeName:      opm.ScopeName(),
 			RetryStrategy:  opm.RetryStrategy(),
-			TraceContext:   opm.TraceSpan(),
+			TraceContext:   opm.TraceSpan().Context(),
 			Deadline:       opm.Deadline(),
 		}, func(res *gocbcore.GetResult, err error) {
 			if err != nil {
@@ -520,7 +549,7 @@ func (c *Collection) getOneReplica(
 		CollectionName: opm.CollectionName(),
 		ScopeName:      opm.ScopeName(),
 		RetryStrategy:  opm.RetryStrategy(),
-		TraceContext:   opm.TraceSpan(),
+		TraceContext:   opm.TraceSpan().Context(),
 		Deadline:       opm.Deadline(),
 	}, func(res *gocbcore.GetReplicaResult, err error) {
 		if err != nil {
@@ -549,6 +578,8 @@ type GetAllReplicaOptions struct {
 	Transcoder    Transcoder
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // GetAllReplicasResult represents the results of a GetAllReplicas operation.
@@ -616,7 +647,7 @@ func (c *Collection) GetAllReplicas(id string, opts *GetAllReplicaOptions) (docO
 		opts = &GetAllReplicaOptions{}
 	}
 
-	span := c.startKvOpTrace("GetAllReplicas", nil)
+	span := c.startKvOpTrace("GetAllReplicas", opts.ParentSpan)
 	defer span.Finish()
 
 	// Timeout needs to be adjusted here, since we use it at the bottom of this
@@ -662,7 +693,7 @@ func (c *Collection) GetAllReplicas(id string, opts *GetAllReplicaOptions) (docO
 			// This timeout value will cause the getOneReplica operation to timeout after our deadline has expired,
 			// as the deadline has already begun. getOneReplica timing out before our deadline would cause inconsistent
 			// behaviour.
-			res, err := c.getOneReplica(span, id, replicaIdx, transcoder, retryStrategy, cancelCh, timeout)
+			res, err := c.getOneReplica(span.Context(), id, replicaIdx, transcoder, retryStrategy, cancelCh, timeout)
 			if err != nil {
 				logDebugf("Failed to fetch replica from replica %d: %s", replicaIdx, err)
 			} else {
@@ -695,6 +726,13 @@ type GetAnyReplicaOptions struct {
 	Transcoder    Transcoder
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	// Hedge causes the read to be sent to the active node first, and only to the replicas when the active node
+	// has not responded within the hedge delay.
+	// VOLATILE: This API is subject to change at any time.
+	Hedge *HedgedReadOptions
+
+	ParentSpan RequestSpanContext
 }
 
 // GetAnyReplica returns the value of a particular document from a replica server.
@@ -703,9 +741,13 @@ func (c *Collection) GetAnyReplica(id string, opts *GetAnyReplicaOptions) (docOu
 		opts = &GetAnyReplicaOptions{}
 	}
 
-	span := c.startKvOpTrace("GetAnyReplica", nil)
+	span := c.startKvOpTrace("GetAnyReplica", opts.ParentSpan)
 	defer span.Finish()
 
+	if opts.Hedge != nil {
+		return c.hedgedGet(span, id, opts.Hedge, opts.Transcoder, opts.RetryStrategy, opts.Timeout, false)
+	}
+
 	repRes, err := c.GetAllReplicas(id, &GetAllReplicaOptions{
 		Timeout:       opts.Timeout,
 		Transcoder:    opts.Transcoder,
@@ -744,6 +786,8 @@ type RemoveOptions struct {
 	DurabilityLevel DurabilityLevel
 	Timeout         time.Duration
 	RetryStrategy   RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // Remove removes a document from the collection.
@@ -752,7 +796,7 @@ func (c *Collection) Remove(id string, opts *RemoveOptions) (mutOut *MutationRes
 		opts = &RemoveOptions{}
 	}
 
-	opm := c.newKvOpManager("Remove", nil)
+	opm := c.newKvOpManager("Remove", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -776,7 +820,7 @@ func (c *Collection) Remove(id string, opts *RemoveOptions) (mutOut *MutationRes
 		DurabilityLevel:        opm.DurabilityLevel(),
 		DurabilityLevelTimeout: opm.DurabilityTimeout(),
 		RetryStrategy:          opm.RetryStrategy(),
-		TraceContext:           opm.TraceSpan(),
+		TraceContext:           opm.TraceSpan().Context(),
 		Deadline:               opm.Deadline(),
 	}, func(res *gocbcore.DeleteResult, err error) {
 		if err != nil {
@@ -802,6 +846,8 @@ type GetAndTouchOptions struct {
 	Transcoder    Transcoder
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // GetAndTouch retrieves a document and simultaneously updates its expiry time.
@@ -810,7 +856,7 @@ func (c *Collection) GetAndTouch(id string, expiry time.Duration, opts *GetAndTo
 		opts = &GetAndTouchOptions{}
 	}
 
-	opm := c.newKvOpManager("GetAndTouch", nil)
+	opm := c.newKvOpManager("GetAndTouch", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -832,7 +878,7 @@ func (c *Collection) GetAndTouch(id string, expiry time.Duration, opts *GetAndTo
 		CollectionName: opm.CollectionName(),
 		ScopeName:      opm.ScopeName(),
 		RetryStrategy:  opm.RetryStrategy(),
-		TraceContext:   opm.TraceSpan(),
+		TraceContext:   opm.TraceSpan().Context(),
 		Deadline:       opm.Deadline(),
 	}, func(res *gocbcore.GetAndTouchResult, err error) {
 		if err != nil {
@@ -867,6 +913,8 @@ type GetAndLockOptions struct {
 	Transcoder    Transcoder
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // GetAndLock locks a document for a period of time, providing exclusive RW access to it.
@@ -877,7 +925,7 @@ func (c *Collection) GetAndLock(id string, lockTime time.Duration, opts *GetAndL
 		opts = &GetAndLockOptions{}
 	}
 
-	opm := c.newKvOpManager("GetAndLock", nil)
+	opm := c.newKvOpManager("GetAndLock", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -899,7 +947,7 @@ func (c *Collection) GetAndLock(id string, lockTime time.Duration, opts *GetAndL
 		CollectionName: opm.CollectionName(),
 		ScopeName:      opm.ScopeName(),
 		RetryStrategy:  opm.RetryStrategy(),
-		TraceContext:   opm.TraceSpan(),
+		TraceContext:   opm.TraceSpan().Context(),
 		Deadline:       opm.Deadline(),
 	}, func(res *gocbcore.GetAndLockResult, err error) {
 		if err != nil {
@@ -933,6 +981,8 @@ func (c *Collection) GetAndLock(id string, lockTime time.Duration, opts *GetAndL
 type UnlockOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // Unlock unlocks a document which was locked with GetAndLock.
@@ -941,7 +991,7 @@ func (c *Collection) Unlock(id string, cas Cas, opts *UnlockOptions) (errOut err
 		opts = &UnlockOptions{}
 	}
 
-	opm := c.newKvOpManager("Unlock", nil)
+	opm := c.newKvOpManager("Unlock", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -962,7 +1012,7 @@ func (c *Collection) Unlock(id string, cas Cas, opts *UnlockOptions) (errOut err
 		CollectionName: opm.CollectionName(),
 		ScopeName:      opm.ScopeName(),
 		RetryStrategy:  opm.RetryStrategy(),
-		TraceContext:   opm.TraceSpan(),
+		TraceContext:   opm.TraceSpan().Context(),
 		Deadline:       opm.Deadline(),
 	}, func(res *gocbcore.UnlockResult, err error) {
 		if err != nil {
@@ -984,6 +1034,8 @@ func (c *Collection) Unlock(id string, cas Cas, opts *UnlockOptions) (errOut err
 type TouchOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // Touch touches a document, specifying a new expiry time for it.
@@ -992,7 +1044,7 @@ func (c *Collection) Touch(id string, expiry time.Duration, opts *TouchOptions)
 		opts = &TouchOptions{}
 	}
 
-	opm := c.newKvOpManager("Touch", nil)
+	opm := c.newKvOpManager("Touch", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -1013,7 +1065,7 @@ func (c *Collection) Touch(id string, expiry time.Duration, opts *TouchOptions)
 		CollectionName: opm.CollectionName(),
 		ScopeName:      opm.ScopeName(),
 		RetryStrategy:  opm.RetryStrategy(),
-		TraceContext:   opm.TraceSpan(),
+		TraceContext:   opm.TraceSpan().Context(),
 		Deadline:       opm.Deadline(),
 	}, func(res *gocbcore.TouchResult, err error) {
 		if err != nil {
@@ -1001,7 +1001,7 @@ func (suite *IntegrationTestSuite) TestGetAndLock() {
 	}
 
 	mutRes, err = globalCollection.Upsert("getAndLock", doc, &UpsertOptions{
-		RetryStrategy: newFailFastRetryStrategy(),
+		RetryStrategy: NewFailFastRetryStrategy(),
 	})
 	if err == nil {
 		suite.T().Fatalf("Expected error but was nil")
@@ -1105,7 +1105,7 @@ func (suite *IntegrationTestSuite) TestUnlockInvalidCas() {
 	}
 
 	err = globalCollection.Unlock("unlockInvalidCas", lockedDoc.Cas()+1, &UnlockOptions{
-		RetryStrategy: newFailFastRetryStrategy(),
+		RetryStrategy: NewFailFastRetryStrategy(),
 	})
 	if err == nil {
 		suite.T().Fatalf("Unlock should have failed")
@@ -1163,7 +1163,7 @@ func (suite *IntegrationTestSuite) TestDoubleLockFail() {
 	}
 
 	_, err = globalCollection.GetAndLock("doubleLock", 1, &GetAndLockOptions{
-		RetryStrategy: newFailFastRetryStrategy(),
+		RetryStrategy: NewFailFastRetryStrategy(),
 	})
 	if err == nil {
 		suite.T().Fatalf("Expected GetAndLock to fail")
@@ -1597,3 +1597,40 @@ func (suite *UnitTestSuite) TestGetErrorProperties() {
 
 	suite.Assert().Nil(res)
 }
+
+func (suite *IntegrationTestSuite) TestGetHedged() {
+	suite.skipIfUnsupported(KeyValueFeature)
+	suite.skipIfUnsupported(ReplicasFeature)
+
+	var doc testBeerDocument
+	err := loadJSONTestDataset("beer_sample_single", &doc)
+	if err != nil {
+		suite.T().Fatalf("Could not read test dataset: %v", err)
+	}
+
+	mutRes, err := globalCollection.Upsert("getHedgedDoc", doc, nil)
+	if err != nil {
+		suite.T().Fatalf("Upsert failed, error was %v", err)
+	}
+
+	getRes, err := globalCollection.Get("getHedgedDoc", &GetOptions{
+		Hedge: &HedgedReadOptions{},
+	})
+	if err != nil {
+		suite.T().Fatalf("Get failed, error was %v", err)
+	}
+
+	if !getRes.IsReplica() && getRes.Cas() != mutRes.Cas() {
+		suite.T().Fatalf("Expected cas of active result to be %d but was %d", mutRes.Cas(), getRes.Cas())
+	}
+
+	var insertedDoc testBeerDocument
+	err = getRes.Content(&insertedDoc)
+	if err != nil {
+		suite.T().Fatalf("Content failed, error was %v", err)
+	}
+
+	if insertedDoc != doc {
+		suite.T().Fatalf("Expected resulting doc to be %v but was %v", doc, insertedDoc)
+	}
+}
@@ -7,7 +7,7 @@ import (
 )
 
 func (c *Collection) observeOnceSeqNo(
-	tracectx requestSpanContext,
+	tracectx RequestSpanContext,
 	docID string,
 	mt gocbcore.MutationToken,
 	replicaIdx int,
@@ -21,6 +21,12 @@ func (c *Collection) observeOnceSeqNo(
 	opm.SetCancelCh(cancelCh)
 	opm.SetTimeout(timeout)
 
+	// The observe requests are part of the mutation which is being waited on, so they are not tracked as
+	// operations in their own right but are still subject to the KV bulkhead.
+	if err := opm.acquireBulkhead(); err != nil {
+		return false, false, err
+	}
+
 	agent, err := c.getKvProvider()
 	if err != nil {
 		return false, false, err
@@ -29,7 +35,7 @@ func (c *Collection) observeOnceSeqNo(
 		VbID:         mt.VbID,
 		VbUUID:       mt.VbUUID,
 		ReplicaIdx:   replicaIdx,
-		TraceContext: opm.TraceSpan(),
+		TraceContext: opm.TraceSpan().Context(),
 		Deadline:     opm.Deadline(),
 	}, func(res *gocbcore.ObserveVbResult, err error) {
 		if err != nil || res == nil {
@@ -49,7 +55,7 @@ func (c *Collection) observeOnceSeqNo(
 }
 
 func (c *Collection) observeOne(
-	tracectx requestSpanContext,
+	tracectx RequestSpanContext,
 	docID string,
 	mt gocbcore.MutationToken,
 	replicaIdx int,
@@ -101,7 +107,7 @@ ObserveLoop:
 }
 
 func (c *Collection) waitForDurability(
-	tracectx requestSpanContext,
+	tracectx RequestSpanContext,
 	docID string,
 	mt gocbcore.MutationToken,
 	replicateTo uint,
@@ -0,0 +1,241 @@
+package gocb
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// HedgedReadOptions are the options for hedging a read, where the read is sent to the active node and then also
+// sent to every replica if no response has been received within the hedge delay. The first successful response
+// is returned and the remaining requests are canceled.
+// VOLATILE: This API is subject to change at any time.
+type HedgedReadOptions struct {
+	// Delay is how long to wait for the active node before also sending the read to the replicas. It is also used
+	// when Percentile is set but not enough latencies have been observed yet, a Delay of 0 sends the read to every
+	// node immediately.
+	Delay time.Duration
+
+	// Percentile, when non-zero, uses the given percentile (e.g. 99) of the recently observed latencies of Get
+	// operations against active nodes as the hedge delay.
+	Percentile float64
+}
+
+const (
+	kvLatencySampleSize    = 1024
+	kvLatencyMinimumSample = 32
+)
+
+// kvLatencySampler keeps a ring of the most recently observed latencies of Get operations, which is used for
+// calculating percentile based hedge delays.
+type kvLatencySampler struct {
+	next    uint32
+	samples [kvLatencySampleSize]int64
+}
+
+func newKvLatencySampler() *kvLatencySampler {
+	return &kvLatencySampler{}
+}
+
+func (s *kvLatencySampler) record(latency time.Duration) {
+	if s == nil {
+		return
+	}
+
+	idx := atomic.AddUint32(&s.next, 1) - 1
+	atomic.StoreInt64(&s.samples[idx%kvLatencySampleSize], int64(latency))
+}
+
+func (s *kvLatencySampler) percentile(percentile float64) (time.Duration, bool) {
+	if s == nil {
+		return 0, false
+	}
+
+	numSamples := atomic.LoadUint32(&s.next)
+	if numSamples > kvLatencySampleSize {
+		numSamples = kvLatencySampleSize
+	}
+	if numSamples < kvLatencyMinimumSample {
+		return 0, false
+	}
+
+	samples := make([]int64, numSamples)
+	for i := range samples {
+		samples[i] = atomic.LoadInt64(&s.samples[i])
+	}
+	sort.Slice(samples, func(i, j int) bool {
+		return samples[i] < samples[j]
+	})
+
+	idx := int(math.Ceil(percentile/100*float64(numSamples))) - 1
+	if idx < 0 {
+		idx = 0
+	} else if idx >= len(samples) {
+		idx = len(samples) - 1
+	}
+
+	return time.Duration(samples[idx]), true
+}
+
+func (c *Collection) hedgeDelay(opts *HedgedReadOptions) time.Duration {
+	if opts.Percentile > 0 {
+		if delay, ok := c.sb.KvLatencies.percentile(opts.Percentile); ok {
+			return delay
+		}
+	}
+
+	return opts.Delay
+}
+
+func (c *Collection) getHedged(id string, opts *GetOptions) (*GetResult, error) {
+	span := c.startKvOpTrace("Get", opts.ParentSpan)
+	defer span.Finish()
+
+	res, err := c.hedgedGet(span, id, opts.Hedge, opts.Transcoder, opts.RetryStrategy, opts.Timeout, true)
+	if err != nil {
+		return nil, err
+	}
+
+	return &res.GetResult, nil
+}
+
+type hedgedReadResult struct {
+	replicaIdx int
+	res        *GetReplicaResult
+	err        error
+}
+
+// hedgedGet sends a get to the active node and, if no response has been received within the hedge delay, to
+// every replica. When activeAuthoritative is set then an error from the active node other than a timeout is
+// returned immediately, otherwise it is only returned once every node has failed.
+func (c *Collection) hedgedGet(
+	span RequestSpan,
+	id string,
+	hedge *HedgedReadOptions,
+	transcoder Transcoder,
+	retryStrategy RetryStrategy,
+	timeout time.Duration,
+	activeAuthoritative bool,
+) (*GetReplicaResult, error) {
+	if hedge.Delay < 0 || hedge.Percentile < 0 || hedge.Percentile > 100 {
+		return nil, makeInvalidArgumentsError("hedge delay and percentile must be positive, and percentile at most 100")
+	}
+
+	if timeout == 0 {
+		timeout = c.sb.KvTimeout
+	}
+	deadline := time.Now().Add(timeout)
+
+	agent, err := c.getKvProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot, err := agent.ConfigSnapshot()
+	if err != nil {
+		return nil, err
+	}
+
+	numReplicas, err := snapshot.NumReplicas()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.hedgedRead(span, numReplicas, c.hedgeDelay(hedge), activeAuthoritative,
+		func(replicaIdx int, cancelCh chan struct{}) (*GetReplicaResult, error) {
+			// The deadline has already begun so the timeout must be reduced to avoid the reads running past it.
+			return c.getOneReplica(span.Context(), id, replicaIdx, transcoder, retryStrategy, cancelCh,
+				time.Until(deadline))
+		})
+}
+
+func (c *Collection) hedgedRead(
+	span RequestSpan,
+	numReplicas int,
+	hedgeDelay time.Duration,
+	activeAuthoritative bool,
+	readFn func(replicaIdx int, cancelCh chan struct{}) (*GetReplicaResult, error),
+) (*GetReplicaResult, error) {
+	resultCh := make(chan hedgedReadResult, numReplicas+1)
+	cancelCh := make(chan struct{})
+	defer close(cancelCh)
+
+	dispatch := func(replicaIdx int) {
+		go func() {
+			res, err := readFn(replicaIdx, cancelCh)
+			resultCh <- hedgedReadResult{
+				replicaIdx: replicaIdx,
+				res:        res,
+				err:        err,
+			}
+		}()
+	}
+
+	start := time.Now()
+	dispatch(0)
+	inFlight := 1
+
+	hedgeTimer := time.NewTimer(hedgeDelay)
+	defer hedgeTimer.Stop()
+	hedged := false
+	sendHedge := func() {
+		hedged = true
+		span.SetTag("couchbase.hedged", true)
+		for replicaIdx := 1; replicaIdx <= numReplicas; replicaIdx++ {
+			dispatch(replicaIdx)
+		}
+		inFlight += numReplicas
+	}
+
+	var activeErr error
+	activeDone := false
+	for {
+		select {
+		case <-hedgeTimer.C:
+			if !hedged {
+				sendHedge()
+			}
+		case result := <-resultCh:
+			inFlight--
+
+			if result.err == nil {
+				// When a replica wins the active read is canceled or has timed out, so the time taken so far is
+				// recorded as a lower bound of its latency. Recording nothing would leave only the fast active reads in the sample,
+				// causing the percentile based hedge delay to drift downwards.
+				if !activeDone {
+					c.sb.KvLatencies.record(time.Since(start))
+				}
+				return result.res, nil
+			}
+
+			if result.replicaIdx == 0 {
+				activeDone = !errors.Is(result.err, ErrTimeout)
+				activeErr = result.err
+				if activeAuthoritative && !errors.Is(result.err, ErrTimeout) {
+					return nil, result.err
+				}
+				if !hedged {
+					sendHedge()
+				}
+			} else {
+				logDebugf("Failed to fetch replica from replica %d: %s", result.replicaIdx, result.err)
+			}
+
+			if inFlight > 0 {
+				continue
+			}
+
+			if activeAuthoritative && activeErr != nil {
+				return nil, activeErr
+			}
+			return nil, &KeyValueError{
+				InnerError:     ErrDocumentUnretrievable,
+				BucketName:     c.sb.BucketName,
+				ScopeName:      c.sb.ScopeName,
+				CollectionName: c.sb.CollectionName,
+			}
+		}
+	}
+}
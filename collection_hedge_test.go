@@ -0,0 +1,130 @@
+package gocb
+
+import (
+	"errors"
+	"time"
+)
+
+func (suite *UnitTestSuite) hedgeTestCollection() *Collection {
+	b := suite.bucket("mock", suite.defaultTimeoutConfig(), new(mockClient))
+	b.sb.KvLatencies = newKvLatencySampler()
+	return b.DefaultCollection()
+}
+
+func hedgeTestReadFn(delays []time.Duration, errs []error) func(int, chan struct{}) (*GetReplicaResult, error) {
+	return func(replicaIdx int, cancelCh chan struct{}) (*GetReplicaResult, error) {
+		select {
+		case <-time.After(delays[replicaIdx]):
+		case <-cancelCh:
+			return nil, ErrRequestCanceled
+		}
+
+		if errs != nil && errs[replicaIdx] != nil {
+			return nil, errs[replicaIdx]
+		}
+
+		return &GetReplicaResult{
+			GetResult: GetResult{
+				Result:    Result{cas: Cas(replicaIdx + 1)},
+				isReplica: replicaIdx > 0,
+			},
+		}, nil
+	}
+}
+
+func (suite *UnitTestSuite) TestHedgedReadActiveWins() {
+	col := suite.hedgeTestCollection()
+	span := &noopSpan{}
+
+	res, err := col.hedgedRead(span, 2, 50*time.Millisecond, true,
+		hedgeTestReadFn([]time.Duration{time.Millisecond, 0, 0}, nil))
+	suite.Require().Nil(err, err)
+	suite.Assert().False(res.IsReplica())
+	suite.Assert().Equal(Cas(1), res.Cas())
+
+	_, ok := col.sb.KvLatencies.percentile(50)
+	suite.Assert().False(ok, "percentile should not be available with a single sample")
+}
+
+func (suite *UnitTestSuite) TestHedgedReadReplicaWins() {
+	col := suite.hedgeTestCollection()
+
+	res, err := col.hedgedRead(&noopSpan{}, 2, 10*time.Millisecond, true,
+		hedgeTestReadFn([]time.Duration{time.Second, 500 * time.Millisecond, time.Millisecond}, nil))
+	suite.Require().Nil(err, err)
+	suite.Assert().True(res.IsReplica())
+	suite.Assert().Equal(Cas(3), res.Cas())
+
+	// The canceled active read is recorded with the time it had taken so far.
+	suite.Require().Equal(uint32(1), col.sb.KvLatencies.next)
+	suite.Assert().GreaterOrEqual(col.sb.KvLatencies.samples[0], int64(10*time.Millisecond))
+}
+
+func (suite *UnitTestSuite) TestHedgedReadActiveAuthoritative() {
+	col := suite.hedgeTestCollection()
+	errs := []error{ErrDocumentNotFound, nil, nil}
+
+	_, err := col.hedgedRead(&noopSpan{}, 2, 0, true,
+		hedgeTestReadFn([]time.Duration{time.Millisecond, 100 * time.Millisecond, 100 * time.Millisecond}, errs))
+	suite.Require().True(errors.Is(err, ErrDocumentNotFound), err)
+
+	// Any replica read ignores the active node failing.
+	res, err := col.hedgedRead(&noopSpan{}, 2, 0, false,
+		hedgeTestReadFn([]time.Duration{time.Millisecond, 5 * time.Millisecond, 5 * time.Millisecond}, errs))
+	suite.Require().Nil(err, err)
+	suite.Assert().True(res.IsReplica())
+}
+
+func (suite *UnitTestSuite) TestHedgedReadAllFail() {
+	col := suite.hedgeTestCollection()
+	errs := []error{ErrTimeout, ErrTimeout, ErrTimeout}
+	delays := []time.Duration{time.Millisecond, time.Millisecond, time.Millisecond}
+
+	_, err := col.hedgedRead(&noopSpan{}, 2, time.Second, true, hedgeTestReadFn(delays, errs))
+	suite.Require().True(errors.Is(err, ErrTimeout), err)
+
+	_, err = col.hedgedRead(&noopSpan{}, 2, time.Second, false, hedgeTestReadFn(delays, errs))
+	suite.Require().True(errors.Is(err, ErrDocumentUnretrievable), err)
+}
+
+func (suite *UnitTestSuite) TestHedgedGetInvalidOptions() {
+	col := suite.hedgeTestCollection()
+
+	_, err := col.Get("key", &GetOptions{
+		Hedge:      &HedgedReadOptions{},
+		WithExpiry: true,
+	})
+	suite.Assert().True(errors.Is(err, ErrInvalidArgument), err)
+
+	_, err = col.Get("key", &GetOptions{
+		Hedge: &HedgedReadOptions{Percentile: 101},
+	})
+	suite.Assert().True(errors.Is(err, ErrInvalidArgument), err)
+}
+
+func (suite *UnitTestSuite) TestKvLatencySamplerPercentile() {
+	sampler := newKvLatencySampler()
+	for i := 1; i <= 100; i++ {
+		sampler.record(time.Duration(i) * time.Millisecond)
+	}
+
+	p50, ok := sampler.percentile(50)
+	suite.Require().True(ok)
+	suite.Assert().Equal(50*time.Millisecond, p50)
+
+	p99, ok := sampler.percentile(99)
+	suite.Require().True(ok)
+	suite.Assert().Equal(99*time.Millisecond, p99)
+
+	col := suite.hedgeTestCollection()
+	col.sb.KvLatencies = sampler
+	suite.Assert().Equal(99*time.Millisecond, col.hedgeDelay(&HedgedReadOptions{Percentile: 99}))
+	suite.Assert().Equal(5*time.Millisecond, col.hedgeDelay(&HedgedReadOptions{Delay: 5 * time.Millisecond}))
+
+	// Only the most recent samples are used once the ring has wrapped.
+	for i := 0; i < kvLatencySampleSize; i++ {
+		sampler.record(time.Millisecond)
+	}
+	p99, _ = sampler.percentile(99)
+	suite.Assert().Equal(time.Millisecond, p99)
+}
@@ -14,6 +14,8 @@ import (
 type LookupInOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // LookupIn performs a set of subdocument lookup operations on the document identified by id.
@@ -22,7 +24,7 @@ func (c *Collection) LookupIn(id string, ops []LookupInSpec, opts *LookupInOptio
 		opts = &LookupInOptions{}
 	}
 
-	opm := c.newKvOpManager("LookupIn", nil)
+	opm := c.newKvOpManager("LookupIn", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -87,7 +89,7 @@ func (c *Collection) internalLookupIn(
 		CollectionName: opm.CollectionName(),
 		ScopeName:      opm.ScopeName(),
 		RetryStrategy:  opm.RetryStrategy(),
-		TraceContext:   opm.TraceSpan(),
+		TraceContext:   opm.TraceSpan().Context(),
 		Deadline:       opm.Deadline(),
 	}, func(res *gocbcore.LookupInResult, err error) {
 		if err != nil && res == nil {
@@ -141,6 +143,8 @@ type MutateInOptions struct {
 	StoreSemantic   StoreSemantics
 	Timeout         time.Duration
 	RetryStrategy   RetryStrategy
+
+	ParentSpan RequestSpanContext
 }
 
 // MutateIn performs a set of subdocument mutations on the document specified by id.
@@ -149,7 +153,7 @@ func (c *Collection) MutateIn(id string, ops []MutateInSpec, opts *MutateInOptio
 		opts = &MutateInOptions{}
 	}
 
-	opm := c.newKvOpManager("MutateIn", nil)
+	opm := c.newKvOpManager("MutateIn", opts.ParentSpan)
 	defer opm.Finish()
 
 	opm.SetDocumentID(id)
@@ -268,7 +272,7 @@ func (c *Collection) internalMutateIn(
 		DurabilityLevel:        opm.DurabilityLevel(),
 		DurabilityLevelTimeout: opm.DurabilityTimeout(),
 		RetryStrategy:          opm.RetryStrategy(),
-		TraceContext:           opm.TraceSpan(),
+		TraceContext:           opm.TraceSpan().Context(),
 		Deadline:               opm.Deadline(),
 	}, func(res *gocbcore.MutateInResult, err error) {
 		if err != nil {
@@ -36,6 +36,20 @@ func serviceTypeFromString(service string) ServiceType {
 	return ServiceType(0)
 }
 
+func circuitBreakerStateToString(state CircuitBreakerState) string {
+	switch state {
+	case CircuitBreakerStateDisabled:
+		return "disabled"
+	case CircuitBreakerStateClosed:
+		return "closed"
+	case CircuitBreakerStateHalfOpen:
+		return "half_open"
+	case CircuitBreakerStateOpen:
+		return "open"
+	}
+	return ""
+}
+
 func clusterStateToString(state ClusterState) string {
 	switch state {
 	case ClusterStateOnline:
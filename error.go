@@ -300,4 +300,15 @@ var (
 
 	// ErrNoResult occurs when no results are available to a query.
 	ErrNoResult = errors.New("no result was available")
+
+	// ErrRequestRejected occurs when a request is rejected because too many requests are already waiting to be
+	// sent to the service.
+	ErrRequestRejected = errors.New("request rejected")
+
+	// ErrCircuitBreakerOpen occurs when a request is rejected because the circuit breaker for the service is open.
+	ErrCircuitBreakerOpen = errors.New("circuit breaker open")
+
+	// ErrClusterClosed occurs when an operation is started on a cluster, or any of its buckets, which is closing
+	// or has been closed.
+	ErrClusterClosed = errors.New("cluster closed")
 )
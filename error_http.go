@@ -35,6 +35,13 @@ func makeGenericHTTPError(baseErr error, req *gocbcore.HTTPRequest, resp *gocbco
 		InnerError: baseErr,
 	}
 
+	var coreErr *gocbcore.HTTPError
+	if errors.As(baseErr, &coreErr) {
+		err.Endpoint = coreErr.Endpoint
+		err.RetryReasons = translateCoreRetryReasons(coreErr.RetryReasons)
+		err.RetryAttempts = coreErr.RetryAttempts
+	}
+
 	if req != nil {
 		err.UniqueID = req.UniqueID
 	}
@@ -68,6 +68,15 @@ func maybeEnhanceCoreErr(err error) error {
 			RetryAttempts:   analyticsErr.RetryAttempts,
 		}
 	}
+	if searchErr, ok := err.(*gocbcore.SearchError); ok {
+		return &SearchError{
+			InnerError:    searchErr.InnerError,
+			Query:         searchErr.Query,
+			Endpoint:      searchErr.Endpoint,
+			RetryReasons:  translateCoreRetryReasons(searchErr.RetryReasons),
+			RetryAttempts: searchErr.RetryAttempts,
+		}
+	}
 	if httpErr, ok := err.(*gocbcore.HTTPError); ok {
 		return &HTTPError{
 			InnerError:    httpErr.InnerError,
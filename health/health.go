@@ -0,0 +1,276 @@
+// Package health provides net/http handlers which expose the health of a cluster, for use as readiness and
+// liveness probes or for debugging.
+//
+// Each handler is created from a *gocb.Cluster, along with any buckets which should also be checked:
+//
+//	opts := &health.Options{Buckets: []health.Bucket{bucket}}
+//	http.Handle("/ready", health.NewReadinessHandler(cluster, opts))
+//	http.Handle("/live", health.NewLivenessHandler(cluster))
+//	http.Handle("/diagnostics", health.NewDiagnosticsHandler(cluster, opts))
+//	http.Handle("/ping", health.NewPingHandler(cluster, opts))
+//
+// Healthy responses use http.StatusOK and unhealthy responses use http.StatusServiceUnavailable. A degraded
+// cluster is reported as unhealthy unless Options.AllowDegraded is set.
+//
+// VOLATILE: This API is subject to change at any time.
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// Cluster is the set of cluster operations used by the handlers, it is implemented by *gocb.Cluster.
+type Cluster interface {
+	WaitUntilReady(timeout time.Duration, opts *gocb.WaitUntilReadyOptions) error
+	Diagnostics(opts *gocb.DiagnosticsOptions) (*gocb.DiagnosticsResult, error)
+	Ping(opts *gocb.PingOptions) (*gocb.PingResult, error)
+}
+
+// Bucket is the set of bucket operations used by the handlers, it is implemented by *gocb.Bucket.
+type Bucket interface {
+	Name() string
+	WaitUntilReady(timeout time.Duration, opts *gocb.WaitUntilReadyOptions) error
+	Ping(opts *gocb.PingOptions) (*gocb.PingResult, error)
+}
+
+// Options are the options used to configure the handlers.
+type Options struct {
+	// Buckets are the buckets which are checked in addition to the cluster.
+	Buckets []Bucket
+
+	// ReadyTimeout is how long the readiness handler waits for the cluster and buckets to become ready,
+	// defaulting to 2 seconds.
+	ReadyTimeout time.Duration
+	// DesiredState is the state the readiness handler waits for, defaulting to gocb.ClusterStateOnline.
+	DesiredState gocb.ClusterState
+
+	// ClusterServices are the services pinged at the cluster level, defaulting to the same services as
+	// Cluster.Ping.
+	ClusterServices []gocb.ServiceType
+	// BucketServices are the services pinged for each bucket, defaulting to the same services as Bucket.Ping.
+	BucketServices []gocb.ServiceType
+	// PingTimeout is the timeout used for each ping, defaulting to the timeout of each service.
+	PingTimeout time.Duration
+
+	// MaxLatency, when non-zero, causes endpoints which take longer than this to respond to a ping to be
+	// treated as failed.
+	MaxLatency time.Duration
+	// AllowDegraded causes a degraded cluster, or a ping in which only some endpoints failed, to be reported
+	// as healthy.
+	AllowDegraded bool
+}
+
+func (opts *Options) readyTimeout() time.Duration {
+	if opts.ReadyTimeout == 0 {
+		return 2 * time.Second
+	}
+	return opts.ReadyTimeout
+}
+
+func (opts *Options) desiredState() gocb.ClusterState {
+	if opts.DesiredState == 0 {
+		return gocb.ClusterStateOnline
+	}
+	return opts.DesiredState
+}
+
+func resolveOptions(opts *Options) *Options {
+	if opts == nil {
+		return &Options{}
+	}
+	return opts
+}
+
+type jsonError struct {
+	Error string `json:"error"`
+}
+
+type jsonReadiness struct {
+	Ready   bool              `json:"ready"`
+	Errors  map[string]string `json:"errors,omitempty"`
+	Timeout string            `json:"timeout"`
+}
+
+type jsonPingResults struct {
+	Cluster *gocb.PingResult            `json:"cluster,omitempty"`
+	Buckets map[string]*gocb.PingResult `json:"buckets,omitempty"`
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		status = http.StatusInternalServerError
+		body, _ = json.Marshal(jsonError{Error: err.Error()})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// ClusterStateStatusCode returns the HTTP status code used to report the given cluster state.
+func ClusterStateStatusCode(state gocb.ClusterState, allowDegraded bool) int {
+	switch state {
+	case gocb.ClusterStateOnline:
+		return http.StatusOK
+	case gocb.ClusterStateDegraded:
+		if allowDegraded {
+			return http.StatusOK
+		}
+	}
+	return http.StatusServiceUnavailable
+}
+
+// PingStatusCode returns the HTTP status code used to report the given ping results. Endpoints which did not
+// respond with gocb.PingStateOk, or which took longer than maxLatency when it is non-zero, are treated as failed.
+// When no endpoints were reported, such as when none of the pinged services run on the cluster, there is nothing
+// to report as unhealthy and http.StatusOK is returned.
+func PingStatusCode(results []*gocb.PingResult, maxLatency time.Duration, allowDegraded bool) int {
+	numOk := 0
+	numFailed := 0
+	for _, result := range results {
+		for _, endpoints := range result.Services {
+			for _, endpoint := range endpoints {
+				if endpoint.State == gocb.PingStateOk && (maxLatency == 0 || endpoint.Latency <= maxLatency) {
+					numOk++
+				} else {
+					numFailed++
+				}
+			}
+		}
+	}
+
+	if numOk == 0 && numFailed == 0 {
+		return http.StatusOK
+	}
+
+	state := gocb.ClusterStateOnline
+	if numOk == 0 {
+		state = gocb.ClusterStateOffline
+	} else if numFailed > 0 {
+		state = gocb.ClusterStateDegraded
+	}
+
+	return ClusterStateStatusCode(state, allowDegraded)
+}
+
+// NewReadinessHandler returns a handler which waits, for at most ReadyTimeout, for the cluster and every bucket
+// to reach DesiredState. The cluster and buckets are waited for concurrently.
+func NewReadinessHandler(cluster Cluster, opts *Options) http.Handler {
+	opts = resolveOptions(opts)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timeout := opts.readyTimeout()
+		waitOpts := &gocb.WaitUntilReadyOptions{
+			DesiredState: opts.desiredState(),
+		}
+
+		var lock sync.Mutex
+		var wg sync.WaitGroup
+		errs := make(map[string]string)
+		wait := func(name string, waitFn func(time.Duration, *gocb.WaitUntilReadyOptions) error) {
+			defer wg.Done()
+
+			if err := waitFn(timeout, waitOpts); err != nil {
+				lock.Lock()
+				errs[name] = err.Error()
+				lock.Unlock()
+			}
+		}
+
+		wg.Add(1 + len(opts.Buckets))
+		go wait("cluster", cluster.WaitUntilReady)
+		for _, bucket := range opts.Buckets {
+			go wait(bucket.Name(), bucket.WaitUntilReady)
+		}
+		wg.Wait()
+
+		status := http.StatusOK
+		if len(errs) > 0 {
+			status = http.StatusServiceUnavailable
+		}
+
+		writeJSON(w, status, jsonReadiness{
+			Ready:   len(errs) == 0,
+			Errors:  errs,
+			Timeout: timeout.String(),
+		})
+	})
+}
+
+// NewLivenessHandler returns a handler which reports whether the SDK is able to reach the cluster at all, using
+// the state reported by Diagnostics. A degraded cluster is always reported as alive, so it takes no options.
+func NewLivenessHandler(cluster Cluster) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report, err := cluster.Diagnostics(nil)
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, jsonError{Error: err.Error()})
+			return
+		}
+
+		writeJSON(w, ClusterStateStatusCode(report.State, true), report)
+	})
+}
+
+// NewDiagnosticsHandler returns a handler which writes the result of Diagnostics, with a status code based upon
+// the state of the cluster.
+func NewDiagnosticsHandler(cluster Cluster, opts *Options) http.Handler {
+	opts = resolveOptions(opts)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report, err := cluster.Diagnostics(nil)
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, jsonError{Error: err.Error()})
+			return
+		}
+
+		writeJSON(w, ClusterStateStatusCode(report.State, opts.AllowDegraded), report)
+	})
+}
+
+// NewPingHandler returns a handler which pings the cluster and every bucket, writing the results with a status
+// code based upon the state of every endpoint which was pinged.
+func NewPingHandler(cluster Cluster, opts *Options) http.Handler {
+	opts = resolveOptions(opts)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var results []*gocb.PingResult
+		out := jsonPingResults{}
+
+		clusterResult, err := cluster.Ping(&gocb.PingOptions{
+			ServiceTypes: opts.ClusterServices,
+			Timeout:      opts.PingTimeout,
+		})
+		if err != nil {
+			writeJSON(w, http.StatusServiceUnavailable, jsonError{Error: err.Error()})
+			return
+		}
+		out.Cluster = clusterResult
+		results = append(results, clusterResult)
+
+		for _, bucket := range opts.Buckets {
+			bucketResult, err := bucket.Ping(&gocb.PingOptions{
+				ServiceTypes: opts.BucketServices,
+				Timeout:      opts.PingTimeout,
+			})
+			if err != nil {
+				writeJSON(w, http.StatusServiceUnavailable, jsonError{Error: err.Error()})
+				return
+			}
+
+			if out.Buckets == nil {
+				out.Buckets = make(map[string]*gocb.PingResult)
+			}
+			out.Buckets[bucket.Name()] = bucketResult
+			results = append(results, bucketResult)
+		}
+
+		writeJSON(w, PingStatusCode(results, opts.MaxLatency, opts.AllowDegraded), out)
+	})
+}
@@ -0,0 +1,225 @@
+package health
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+type testCluster struct {
+	readyErr    error
+	readyFn     func()
+	diagnostics *gocb.DiagnosticsResult
+	ping        *gocb.PingResult
+	pingOpts    *gocb.PingOptions
+	waitTimeout time.Duration
+	waitOpts    *gocb.WaitUntilReadyOptions
+}
+
+func (c *testCluster) WaitUntilReady(timeout time.Duration, opts *gocb.WaitUntilReadyOptions) error {
+	c.waitTimeout = timeout
+	c.waitOpts = opts
+	if c.readyFn != nil {
+		c.readyFn()
+	}
+	return c.readyErr
+}
+
+func (c *testCluster) Diagnostics(opts *gocb.DiagnosticsOptions) (*gocb.DiagnosticsResult, error) {
+	return c.diagnostics, nil
+}
+
+func (c *testCluster) Ping(opts *gocb.PingOptions) (*gocb.PingResult, error) {
+	c.pingOpts = opts
+	return c.ping, nil
+}
+
+type testBucket struct {
+	testCluster
+	name string
+}
+
+func (b *testBucket) Name() string {
+	return b.name
+}
+
+func serve(t *testing.T, handler http.Handler) (int, map[string]interface{}) {
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Fatalf("Expected content type to be application/json but was %s", contentType)
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Failed to unmarshal body: %v", err)
+	}
+
+	return rec.Code, body
+}
+
+func TestReadinessHandler(t *testing.T) {
+	cluster := &testCluster{}
+	bucket := &testBucket{name: "default"}
+	handler := NewReadinessHandler(cluster, &Options{
+		Buckets:      []Bucket{bucket},
+		ReadyTimeout: 500 * time.Millisecond,
+	})
+
+	status, body := serve(t, handler)
+	if status != http.StatusOK || body["ready"] != true {
+		t.Fatalf("Expected ready response but was %d: %v", status, body)
+	}
+	if cluster.waitTimeout != 500*time.Millisecond || bucket.waitTimeout != 500*time.Millisecond {
+		t.Fatalf("Expected ready timeout to be used")
+	}
+	if cluster.waitOpts.DesiredState != gocb.ClusterStateOnline {
+		t.Fatalf("Expected desired state to default to online but was %d", cluster.waitOpts.DesiredState)
+	}
+
+	bucket.readyErr = errors.New("unambiguous timeout")
+	status, body = serve(t, handler)
+	if status != http.StatusServiceUnavailable || body["ready"] != false {
+		t.Fatalf("Expected not ready response but was %d: %v", status, body)
+	}
+	errs := body["errors"].(map[string]interface{})
+	if errs["default"] != "unambiguous timeout" || len(errs) != 1 {
+		t.Fatalf("Expected bucket error to be reported but was %v", errs)
+	}
+}
+
+func TestReadinessHandlerConcurrent(t *testing.T) {
+	// The cluster only becomes ready once the bucket has started waiting, which never happens if they are
+	// waited for one after the other.
+	bucketWaitingCh := make(chan struct{})
+	cluster := &testCluster{}
+	cluster.readyFn = func() {
+		select {
+		case <-bucketWaitingCh:
+		case <-time.After(time.Second):
+			cluster.readyErr = errors.New("unambiguous timeout")
+		}
+	}
+	bucket := &testBucket{name: "default"}
+	bucket.readyFn = func() {
+		close(bucketWaitingCh)
+	}
+
+	status, body := serve(t, NewReadinessHandler(cluster, &Options{Buckets: []Bucket{bucket}}))
+	if status != http.StatusOK || body["ready"] != true {
+		t.Fatalf("Expected ready response but was %d: %v", status, body)
+	}
+}
+
+func TestDiagnosticsHandlers(t *testing.T) {
+	cluster := &testCluster{
+		diagnostics: &gocb.DiagnosticsResult{
+			ID:       "report",
+			Services: map[string][]gocb.EndPointDiagnostics{},
+			State:    gocb.ClusterStateDegraded,
+		},
+	}
+
+	status, body := serve(t, NewDiagnosticsHandler(cluster, nil))
+	if status != http.StatusServiceUnavailable {
+		t.Fatalf("Expected degraded cluster to be unavailable but was %d", status)
+	}
+	if body["id"] != "report" || body["state"] != "degraded" {
+		t.Fatalf("Expected diagnostics report but was %v", body)
+	}
+
+	status, _ = serve(t, NewDiagnosticsHandler(cluster, &Options{AllowDegraded: true}))
+	if status != http.StatusOK {
+		t.Fatalf("Expected degraded cluster to be allowed but was %d", status)
+	}
+
+	status, _ = serve(t, NewLivenessHandler(cluster))
+	if status != http.StatusOK {
+		t.Fatalf("Expected degraded cluster to be alive but was %d", status)
+	}
+
+	cluster.diagnostics.State = gocb.ClusterStateOffline
+	status, _ = serve(t, NewLivenessHandler(cluster))
+	if status != http.StatusServiceUnavailable {
+		t.Fatalf("Expected offline cluster not to be alive but was %d", status)
+	}
+}
+
+func TestPingHandler(t *testing.T) {
+	cluster := &testCluster{
+		ping: &gocb.PingResult{
+			ID: "cluster",
+			Services: map[gocb.ServiceType][]gocb.EndpointPingReport{
+				gocb.ServiceTypeQuery: {
+					{Remote: "server1", State: gocb.PingStateOk, Latency: time.Millisecond},
+				},
+			},
+		},
+	}
+	bucket := &testBucket{
+		name: "default",
+		testCluster: testCluster{
+			ping: &gocb.PingResult{
+				ID: "bucket",
+				Services: map[gocb.ServiceType][]gocb.EndpointPingReport{
+					gocb.ServiceTypeKeyValue: {
+						{Remote: "server1", State: gocb.PingStateOk, Latency: 50 * time.Millisecond},
+						{Remote: "server2", State: gocb.PingStateTimeout},
+					},
+				},
+			},
+		},
+	}
+
+	opts := &Options{
+		Buckets:         []Bucket{bucket},
+		ClusterServices: []gocb.ServiceType{gocb.ServiceTypeQuery},
+		PingTimeout:     time.Second,
+	}
+	status, body := serve(t, NewPingHandler(cluster, opts))
+	if status != http.StatusServiceUnavailable {
+		t.Fatalf("Expected failed endpoint to be unavailable but was %d", status)
+	}
+	if body["cluster"].(map[string]interface{})["id"] != "cluster" {
+		t.Fatalf("Expected cluster ping report but was %v", body)
+	}
+	if body["buckets"].(map[string]interface{})["default"].(map[string]interface{})["id"] != "bucket" {
+		t.Fatalf("Expected bucket ping report but was %v", body)
+	}
+	if cluster.pingOpts.Timeout != time.Second || cluster.pingOpts.ServiceTypes[0] != gocb.ServiceTypeQuery {
+		t.Fatalf("Expected ping options to be used but was %v", cluster.pingOpts)
+	}
+
+	opts.AllowDegraded = true
+	status, _ = serve(t, NewPingHandler(cluster, opts))
+	if status != http.StatusOK {
+		t.Fatalf("Expected degraded ping to be allowed but was %d", status)
+	}
+
+	bucket.ping.Services[gocb.ServiceTypeKeyValue] = bucket.ping.Services[gocb.ServiceTypeKeyValue][:1]
+	opts.AllowDegraded = false
+	opts.MaxLatency = 10 * time.Millisecond
+	status, _ = serve(t, NewPingHandler(cluster, opts))
+	if status != http.StatusServiceUnavailable {
+		t.Fatalf("Expected slow endpoint to be unavailable but was %d", status)
+	}
+
+	opts.MaxLatency = 0
+	status, _ = serve(t, NewPingHandler(cluster, opts))
+	if status != http.StatusOK {
+		t.Fatalf("Expected healthy ping to be ok but was %d", status)
+	}
+}
+
+func TestPingStatusCodeNoEndpoints(t *testing.T) {
+	results := []*gocb.PingResult{{ID: "cluster", Services: map[gocb.ServiceType][]gocb.EndpointPingReport{}}}
+	if status := PingStatusCode(results, 0, false); status != http.StatusOK {
+		t.Fatalf("Expected ping without endpoints to be ok but was %d", status)
+	}
+}
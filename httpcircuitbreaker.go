@@ -0,0 +1,458 @@
+package gocb
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v9"
+)
+
+// httpCircuitBreakerServices are the services which requests are guarded by the HTTP circuit breakers for.
+var httpCircuitBreakerServices = []ServiceType{
+	ServiceTypeQuery,
+	ServiceTypeSearch,
+	ServiceTypeAnalytics,
+	ServiceTypeViews,
+}
+
+type httpCircuitBreakerCanaryFn func(deadline time.Time) (*gocbcore.PingResult, error)
+
+type httpCircuitBreakerConfig struct {
+	volumeThreshold          int64
+	errorThresholdPercentage float64
+	sleepWindow              time.Duration
+	rollingWindow            time.Duration
+	canaryTimeout            time.Duration
+	completionCallback       CircuitBreakerCallback
+	stateChangeCallback      CircuitBreakerStateChangeCallback
+	now                      func() time.Time
+}
+
+// httpCircuitBreaker is a rolling window circuit breaker, it behaves in the same way as the breakers used by
+// gocbcore for each KV connection.
+type httpCircuitBreaker struct {
+	service  ServiceType
+	endpoint string
+	config   *httpCircuitBreakerConfig
+
+	lock        sync.Mutex
+	state       CircuitBreakerState
+	windowStart time.Time
+	openedAt    time.Time
+	total       int64
+	failed      int64
+}
+
+func newHTTPCircuitBreaker(service ServiceType, endpoint string, config *httpCircuitBreakerConfig) *httpCircuitBreaker {
+	return &httpCircuitBreaker{
+		service:     service,
+		endpoint:    endpoint,
+		config:      config,
+		state:       CircuitBreakerStateClosed,
+		windowStart: config.now(),
+	}
+}
+
+// transitionLocked moves the breaker to a new state, returning the change which must be reported once the lock
+// has been released.
+func (cb *httpCircuitBreaker) transitionLocked(to CircuitBreakerState, now time.Time) *CircuitBreakerStateChange {
+	from := cb.state
+	cb.state = to
+
+	switch to {
+	case CircuitBreakerStateClosed:
+		cb.total = 0
+		cb.failed = 0
+		cb.windowStart = now
+	case CircuitBreakerStateOpen:
+		cb.openedAt = now
+	}
+
+	if from == to {
+		return nil
+	}
+
+	return &CircuitBreakerStateChange{
+		Service:  cb.service,
+		Endpoint: cb.endpoint,
+		From:     from,
+		To:       to,
+	}
+}
+
+func (cb *httpCircuitBreaker) setState(to CircuitBreakerState, now time.Time) *CircuitBreakerStateChange {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	return cb.transitionLocked(to, now)
+}
+
+// allowsRequest returns whether a request may be sent, along with whether a canary must be sent as the sleep
+// window has elapsed.
+func (cb *httpCircuitBreaker) allowsRequest(now time.Time) (bool, bool, *CircuitBreakerStateChange) {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	if cb.state == CircuitBreakerStateClosed {
+		return true, false, nil
+	}
+
+	if cb.state == CircuitBreakerStateOpen && now.Sub(cb.openedAt) > cb.config.sleepWindow {
+		return false, true, cb.transitionLocked(CircuitBreakerStateHalfOpen, now)
+	}
+
+	return false, false, nil
+}
+
+func (cb *httpCircuitBreaker) maybeResetRollingWindowLocked(now time.Time) {
+	if now.Sub(cb.windowStart) <= cb.config.rollingWindow {
+		return
+	}
+
+	cb.windowStart = now
+	cb.total = 0
+	cb.failed = 0
+}
+
+func (cb *httpCircuitBreaker) markSuccessful(now time.Time) *CircuitBreakerStateChange {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	if cb.state == CircuitBreakerStateHalfOpen {
+		logDebugf("Moving %s circuit breaker to closed", serviceTypeToString(cb.service))
+		return cb.transitionLocked(CircuitBreakerStateClosed, now)
+	}
+
+	cb.maybeResetRollingWindowLocked(now)
+	cb.total++
+	return nil
+}
+
+func (cb *httpCircuitBreaker) markFailure(now time.Time) *CircuitBreakerStateChange {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	if cb.state == CircuitBreakerStateHalfOpen {
+		logDebugf("Moving %s circuit breaker from half open to open", serviceTypeToString(cb.service))
+		return cb.transitionLocked(CircuitBreakerStateOpen, now)
+	}
+
+	cb.maybeResetRollingWindowLocked(now)
+	cb.total++
+	cb.failed++
+
+	if cb.state != CircuitBreakerStateClosed || cb.total < cb.config.volumeThreshold {
+		return nil
+	}
+
+	currentPercentage := (float64(cb.failed) / float64(cb.total)) * 100
+	if currentPercentage < cb.config.errorThresholdPercentage {
+		return nil
+	}
+
+	logDebugf("Moving %s circuit breaker to open", serviceTypeToString(cb.service))
+	return cb.transitionLocked(CircuitBreakerStateOpen, now)
+}
+
+func (cb *httpCircuitBreaker) diagnostics() CircuitBreakerDiagnostics {
+	cb.lock.Lock()
+	defer cb.lock.Unlock()
+
+	return CircuitBreakerDiagnostics{
+		Service:  cb.service,
+		Endpoint: cb.endpoint,
+		State:    cb.state,
+		Total:    cb.total,
+		Failed:   cb.failed,
+	}
+}
+
+type httpServiceCircuitBreakers struct {
+	service *httpCircuitBreaker
+	nodes   map[string]*httpCircuitBreaker
+
+	// nodesDiscovered is set once a canary has reported the endpoints for the service, until then the node
+	// breakers only cover the nodes which have failed and cannot be used to reject requests.
+	nodesDiscovered bool
+}
+
+// httpCircuitBreakers holds the circuit breakers for the HTTP based services. The nodes which requests are
+// dispatched to are selected by gocbcore so requests are allowed or rejected by the breaker for the service as a
+// whole and are only rejected by the breakers for individual nodes once every known node of the service is open.
+// The node breakers are driven by the outcomes of requests which report the endpoint they were sent to and by the
+// results of canaries.
+type httpCircuitBreakers struct {
+	config *httpCircuitBreakerConfig
+
+	lock     sync.Mutex
+	services map[ServiceType]*httpServiceCircuitBreakers
+}
+
+func newHTTPCircuitBreakers(config CircuitBreakerConfig) *httpCircuitBreakers {
+	if config.Disabled {
+		return nil
+	}
+
+	resolved := &httpCircuitBreakerConfig{
+		volumeThreshold:          config.VolumeThreshold,
+		errorThresholdPercentage: config.ErrorThresholdPercentage,
+		sleepWindow:              config.SleepWindow,
+		rollingWindow:            config.RollingWindow,
+		canaryTimeout:            config.CanaryTimeout,
+		completionCallback:       config.CompletionCallback,
+		stateChangeCallback:      config.StateChangeCallback,
+		now:                      time.Now,
+	}
+	if resolved.volumeThreshold == 0 {
+		resolved.volumeThreshold = 20
+	}
+	if resolved.errorThresholdPercentage == 0 {
+		resolved.errorThresholdPercentage = 50
+	}
+	if resolved.sleepWindow == 0 {
+		resolved.sleepWindow = 5 * time.Second
+	}
+	if resolved.rollingWindow == 0 {
+		resolved.rollingWindow = 1 * time.Minute
+	}
+	if resolved.canaryTimeout == 0 {
+		resolved.canaryTimeout = 5 * time.Second
+	}
+	if resolved.completionCallback == nil {
+		resolved.completionCallback = func(err error) bool {
+			return !errors.Is(err, ErrTimeout)
+		}
+	}
+
+	breakers := &httpCircuitBreakers{
+		config:   resolved,
+		services: make(map[ServiceType]*httpServiceCircuitBreakers),
+	}
+	for _, service := range httpCircuitBreakerServices {
+		breakers.services[service] = &httpServiceCircuitBreakers{
+			service: newHTTPCircuitBreaker(service, "", resolved),
+			nodes:   make(map[string]*httpCircuitBreaker),
+		}
+	}
+
+	return breakers
+}
+
+func (cbs *httpCircuitBreakers) notify(change *CircuitBreakerStateChange) {
+	if change == nil || cbs.config.stateChangeCallback == nil {
+		return
+	}
+
+	cbs.config.stateChangeCallback(*change)
+}
+
+func (cbs *httpCircuitBreakers) nodeBreaker(service ServiceType, endpoint string) *httpCircuitBreaker {
+	cbs.lock.Lock()
+	defer cbs.lock.Unlock()
+
+	serviceBreakers := cbs.services[service]
+	breaker, ok := serviceBreakers.nodes[endpoint]
+	if !ok {
+		breaker = newHTTPCircuitBreaker(service, endpoint, cbs.config)
+		serviceBreakers.nodes[endpoint] = breaker
+	}
+
+	return breaker
+}
+
+// discoveredNodeBreakers returns the breakers for the nodes of the service, or nil if the nodes have not yet been
+// discovered by a canary.
+func (cbs *httpCircuitBreakers) discoveredNodeBreakers(service ServiceType) []*httpCircuitBreaker {
+	cbs.lock.Lock()
+	defer cbs.lock.Unlock()
+
+	serviceBreakers := cbs.services[service]
+	if !serviceBreakers.nodesDiscovered {
+		return nil
+	}
+
+	breakers := make([]*httpCircuitBreaker, 0, len(serviceBreakers.nodes))
+	for _, breaker := range serviceBreakers.nodes {
+		breakers = append(breakers, breaker)
+	}
+
+	return breakers
+}
+
+// allowsRequest returns whether a request to the service may be sent. If the breaker for the service, or every
+// node breaker, has been open for longer than the sleep window then a canary is sent in the background using
+// canaryFn.
+func (cbs *httpCircuitBreakers) allowsRequest(service ServiceType, canaryFn httpCircuitBreakerCanaryFn) bool {
+	if cbs == nil {
+		return true
+	}
+
+	now := cbs.config.now()
+	allowed, sendCanary, change := cbs.services[service].service.allowsRequest(now)
+	cbs.notify(change)
+
+	if allowed {
+		if nodes := cbs.discoveredNodeBreakers(service); len(nodes) > 0 {
+			allowed = false
+			for _, node := range nodes {
+				nodeAllowed, nodeCanary, change := node.allowsRequest(now)
+				cbs.notify(change)
+				allowed = allowed || nodeAllowed
+				sendCanary = sendCanary || nodeCanary
+			}
+		}
+	}
+
+	if sendCanary {
+		go cbs.sendCanary(service, canaryFn)
+	}
+
+	return allowed
+}
+
+// markCompletion records the outcome of a request to the service, err should be the error as returned to the user.
+func (cbs *httpCircuitBreakers) markCompletion(service ServiceType, err error) {
+	if cbs == nil {
+		return
+	}
+
+	now := cbs.config.now()
+	endpoint := httpErrorEndpoint(err)
+	if err == nil || cbs.config.completionCallback(err) {
+		cbs.notify(cbs.services[service].service.markSuccessful(now))
+		if endpoint != "" {
+			cbs.notify(cbs.nodeBreaker(service, endpoint).markSuccessful(now))
+		}
+		return
+	}
+
+	cbs.notify(cbs.services[service].service.markFailure(now))
+	if endpoint != "" {
+		cbs.notify(cbs.nodeBreaker(service, endpoint).markFailure(now))
+	}
+}
+
+// reopenHalfOpenNodes moves any node breakers for the service which the canary did not close back to open so that
+// they wait out another sleep window.
+func (cbs *httpCircuitBreakers) reopenHalfOpenNodes(service ServiceType) {
+	cbs.lock.Lock()
+	var nodes []*httpCircuitBreaker
+	for _, breaker := range cbs.services[service].nodes {
+		nodes = append(nodes, breaker)
+	}
+	cbs.lock.Unlock()
+
+	for _, node := range nodes {
+		if node.diagnostics().State == CircuitBreakerStateHalfOpen {
+			cbs.notify(node.setState(CircuitBreakerStateOpen, cbs.config.now()))
+		}
+	}
+}
+
+func (cbs *httpCircuitBreakers) sendCanary(service ServiceType, canaryFn httpCircuitBreakerCanaryFn) {
+	serviceBreaker := cbs.services[service].service
+	defer cbs.reopenHalfOpenNodes(service)
+
+	result, err := canaryFn(cbs.config.now().Add(cbs.config.canaryTimeout))
+	if err != nil {
+		logDebugf("Circuit breaker canary for %s failed: %v", serviceTypeToString(service), err)
+		cbs.notify(serviceBreaker.markFailure(cbs.config.now()))
+		return
+	}
+
+	endpoints := result.Services[gocbcore.ServiceType(service)]
+	anyOk := false
+	for _, endpoint := range endpoints {
+		nodeState := CircuitBreakerStateOpen
+		if endpoint.State == gocbcore.PingStateOK {
+			anyOk = true
+			nodeState = CircuitBreakerStateClosed
+		}
+		cbs.notify(cbs.nodeBreaker(service, endpoint.Endpoint).setState(nodeState, cbs.config.now()))
+	}
+
+	if len(endpoints) > 0 {
+		cbs.lock.Lock()
+		cbs.services[service].nodesDiscovered = true
+		cbs.lock.Unlock()
+	}
+
+	if anyOk {
+		cbs.notify(serviceBreaker.markSuccessful(cbs.config.now()))
+	} else {
+		cbs.notify(serviceBreaker.markFailure(cbs.config.now()))
+	}
+}
+
+func (cbs *httpCircuitBreakers) diagnostics() []CircuitBreakerDiagnostics {
+	if cbs == nil {
+		return nil
+	}
+
+	var breakers []*httpCircuitBreaker
+	cbs.lock.Lock()
+	for _, service := range httpCircuitBreakerServices {
+		serviceBreakers := cbs.services[service]
+		breakers = append(breakers, serviceBreakers.service)
+		for _, breaker := range serviceBreakers.nodes {
+			breakers = append(breakers, breaker)
+		}
+	}
+	cbs.lock.Unlock()
+
+	diags := make([]CircuitBreakerDiagnostics, len(breakers))
+	for i, breaker := range breakers {
+		diags[i] = breaker.diagnostics()
+	}
+
+	sort.SliceStable(diags, func(i, j int) bool {
+		if diags[i].Service != diags[j].Service {
+			return diags[i].Service < diags[j].Service
+		}
+		return diags[i].Endpoint < diags[j].Endpoint
+	})
+
+	return diags
+}
+
+func httpErrorEndpoint(err error) string {
+	var queryErr *QueryError
+	if errors.As(err, &queryErr) {
+		return queryErr.Endpoint
+	}
+	var analyticsErr *AnalyticsError
+	if errors.As(err, &analyticsErr) {
+		return analyticsErr.Endpoint
+	}
+	var searchErr *SearchError
+	if errors.As(err, &searchErr) {
+		return searchErr.Endpoint
+	}
+	var viewErr *ViewError
+	if errors.As(err, &viewErr) {
+		return viewErr.Endpoint
+	}
+	return ""
+}
+
+func newHTTPCircuitBreakerCanary(
+	getProvider func() (diagnosticsProvider, error),
+	service ServiceType,
+) httpCircuitBreakerCanaryFn {
+	return func(deadline time.Time) (*gocbcore.PingResult, error) {
+		provider, err := getProvider()
+		if err != nil {
+			return nil, err
+		}
+
+		return provider.Ping(gocbcore.PingOptions{
+			ServiceTypes: []gocbcore.ServiceType{gocbcore.ServiceType(service)},
+			CbasDeadline: deadline,
+			N1QLDeadline: deadline,
+			FtsDeadline:  deadline,
+			CapiDeadline: deadline,
+		})
+	}
+}
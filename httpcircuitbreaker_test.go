@@ -0,0 +1,339 @@
+package gocb
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v9"
+	"github.com/stretchr/testify/mock"
+)
+
+type testCircuitBreakerClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func newTestCircuitBreakerClock(breakers *httpCircuitBreakers) *testCircuitBreakerClock {
+	clock := &testCircuitBreakerClock{now: time.Now()}
+	breakers.config.now = clock.time
+	return clock
+}
+
+func (c *testCircuitBreakerClock) time() time.Time {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.now
+}
+
+func (c *testCircuitBreakerClock) advance(d time.Duration) {
+	c.lock.Lock()
+	c.now = c.now.Add(d)
+	c.lock.Unlock()
+}
+
+func (suite *UnitTestSuite) TestHTTPCircuitBreakerQuery() {
+	var lock sync.Mutex
+	var changes []CircuitBreakerStateChange
+
+	retErr := &gocbcore.N1QLError{
+		InnerError: gocbcore.ErrTimeout,
+		Endpoint:   "http://10.0.0.1:8093",
+		Statement:  "SELECT 1=1",
+	}
+	queryProvider := new(mockQueryProvider)
+	queryProvider.
+		On("N1QLQuery", mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+		Return(nil, retErr).
+		Times(2)
+
+	pingResult := &gocbcore.PingResult{
+		Services: map[gocbcore.ServiceType][]gocbcore.EndpointPingResult{
+			gocbcore.N1qlService: {
+				{Endpoint: "http://10.0.0.1:8093", State: gocbcore.PingStateOK},
+			},
+		},
+	}
+	pingProvider := new(mockDiagnosticsProvider)
+	pingProvider.
+		On("Ping", mock.AnythingOfType("gocbcore.PingOptions")).
+		Return(pingResult, nil).
+		Once()
+
+	cli := new(mockClient)
+	cli.On("getQueryProvider").Return(queryProvider, nil)
+	cli.On("getDiagnosticsProvider").Return(pingProvider, nil)
+	cli.On("supportsGCCCP").Return(true)
+
+	cluster := clusterFromOptions(ClusterOptions{
+		CircuitBreakerConfig: CircuitBreakerConfig{
+			VolumeThreshold: 2,
+			SleepWindow:     50 * time.Millisecond,
+			StateChangeCallback: func(change CircuitBreakerStateChange) {
+				lock.Lock()
+				changes = append(changes, change)
+				lock.Unlock()
+			},
+		},
+	})
+	cluster.clusterClient = cli
+	clock := newTestCircuitBreakerClock(cluster.sb.HTTPCircuitBreakers)
+
+	for i := 0; i < 2; i++ {
+		_, err := cluster.Query("SELECT 1=1", &QueryOptions{Adhoc: true})
+		suite.Require().True(errors.Is(err, ErrTimeout), err)
+	}
+
+	_, err := cluster.Query("SELECT 1=1", &QueryOptions{Adhoc: true})
+	suite.Require().True(errors.Is(err, ErrCircuitBreakerOpen), err)
+
+	var qErr QueryError
+	suite.Require().True(errors.As(err, &qErr))
+	suite.Assert().Equal("SELECT 1=1", qErr.Statement)
+
+	diags := cluster.sb.HTTPCircuitBreakers.diagnostics()
+	suite.Assert().Contains(diags, CircuitBreakerDiagnostics{
+		Service: ServiceTypeQuery,
+		State:   CircuitBreakerStateOpen,
+		Total:   2,
+		Failed:  2,
+	})
+	suite.Assert().Contains(diags, CircuitBreakerDiagnostics{
+		Service:  ServiceTypeQuery,
+		Endpoint: "http://10.0.0.1:8093",
+		State:    CircuitBreakerStateOpen,
+		Total:    2,
+		Failed:   2,
+	})
+
+	clock.advance(60 * time.Millisecond)
+
+	// The first request after the sleep window is rejected and triggers the canary.
+	_, err = cluster.Query("SELECT 1=1", &QueryOptions{Adhoc: true})
+	suite.Require().True(errors.Is(err, ErrCircuitBreakerOpen), err)
+
+	suite.Require().Eventually(func() bool {
+		lock.Lock()
+		defer lock.Unlock()
+		return len(changes) == 5
+	}, time.Second, time.Millisecond)
+
+	lock.Lock()
+	suite.Assert().Equal([]CircuitBreakerStateChange{
+		{Service: ServiceTypeQuery, From: CircuitBreakerStateClosed, To: CircuitBreakerStateOpen},
+		{Service: ServiceTypeQuery, Endpoint: "http://10.0.0.1:8093", From: CircuitBreakerStateClosed,
+			To: CircuitBreakerStateOpen},
+		{Service: ServiceTypeQuery, From: CircuitBreakerStateOpen, To: CircuitBreakerStateHalfOpen},
+		{Service: ServiceTypeQuery, Endpoint: "http://10.0.0.1:8093", From: CircuitBreakerStateOpen,
+			To: CircuitBreakerStateClosed},
+		{Service: ServiceTypeQuery, From: CircuitBreakerStateHalfOpen, To: CircuitBreakerStateClosed},
+	}, changes)
+	lock.Unlock()
+
+	pingProvider.AssertExpectations(suite.T())
+	suite.Assert().True(cluster.sb.HTTPCircuitBreakers.allowsRequest(ServiceTypeQuery, nil))
+}
+
+func (suite *UnitTestSuite) TestHTTPCircuitBreakerCanaryFailure() {
+	breakers := newHTTPCircuitBreakers(CircuitBreakerConfig{
+		VolumeThreshold: 1,
+		SleepWindow:     50 * time.Millisecond,
+	})
+	clock := newTestCircuitBreakerClock(breakers)
+
+	breakers.markCompletion(ServiceTypeSearch, &SearchError{InnerError: ErrTimeout})
+	suite.Require().False(breakers.allowsRequest(ServiceTypeSearch, nil))
+
+	canaryDone := make(chan struct{})
+	clock.advance(60 * time.Millisecond)
+	suite.Require().False(breakers.allowsRequest(ServiceTypeSearch, func(deadline time.Time) (*gocbcore.PingResult, error) {
+		defer close(canaryDone)
+		return nil, ErrTimeout
+	}))
+	<-canaryDone
+
+	suite.Require().Eventually(func() bool {
+		for _, diag := range breakers.diagnostics() {
+			if diag.Service == ServiceTypeSearch && diag.Endpoint == "" {
+				return diag.State == CircuitBreakerStateOpen
+			}
+		}
+		return false
+	}, time.Second, time.Millisecond)
+}
+
+func (suite *UnitTestSuite) TestHTTPCircuitBreakerStreamError() {
+	reader := &testQueryRowsReader{
+		rows: [][]byte{[]byte(`{"a": 1}`)},
+		err: &gocbcore.N1QLError{
+			InnerError: gocbcore.ErrTimeout,
+			Endpoint:   "http://10.0.0.1:8093",
+		},
+	}
+	queryProvider := new(mockQueryProvider)
+	queryProvider.
+		On("N1QLQuery", mock.AnythingOfType("gocbcore.N1QLQueryOptions")).
+		Return(reader, nil).
+		Once()
+
+	cli := new(mockClient)
+	cli.On("getQueryProvider").Return(queryProvider, nil)
+	cli.On("supportsGCCCP").Return(true)
+
+	cluster := clusterFromOptions(ClusterOptions{
+		CircuitBreakerConfig: CircuitBreakerConfig{
+			VolumeThreshold: 1,
+		},
+	})
+	cluster.clusterClient = cli
+
+	result, err := cluster.Query("SELECT 1=1", &QueryOptions{Adhoc: true})
+	suite.Require().Nil(err, err)
+
+	// The request is only counted once the stream has failed.
+	suite.Assert().True(cluster.sb.HTTPCircuitBreakers.allowsRequest(ServiceTypeQuery, nil))
+
+	for result.Next() {
+	}
+	suite.Require().NotNil(result.Err())
+
+	suite.Assert().False(cluster.sb.HTTPCircuitBreakers.allowsRequest(ServiceTypeQuery, nil))
+	suite.Assert().Contains(cluster.sb.HTTPCircuitBreakers.diagnostics(), CircuitBreakerDiagnostics{
+		Service:  ServiceTypeQuery,
+		Endpoint: "http://10.0.0.1:8093",
+		State:    CircuitBreakerStateOpen,
+		Total:    1,
+		Failed:   1,
+	})
+}
+
+func (suite *UnitTestSuite) TestHTTPCircuitBreakerNodes() {
+	breakers := newHTTPCircuitBreakers(CircuitBreakerConfig{
+		VolumeThreshold: 2,
+		SleepWindow:     50 * time.Millisecond,
+		CompletionCallback: func(err error) bool {
+			return !errors.Is(err, ErrServiceNotAvailable)
+		},
+	})
+	clock := newTestCircuitBreakerClock(breakers)
+
+	canary := func(pings ...gocbcore.EndpointPingResult) httpCircuitBreakerCanaryFn {
+		return func(deadline time.Time) (*gocbcore.PingResult, error) {
+			return &gocbcore.PingResult{
+				Services: map[gocbcore.ServiceType][]gocbcore.EndpointPingResult{
+					gocbcore.CbasService: pings,
+				},
+			}, nil
+		}
+	}
+	nodeState := func(endpoint string) CircuitBreakerState {
+		for _, diag := range breakers.diagnostics() {
+			if diag.Service == ServiceTypeAnalytics && diag.Endpoint == endpoint {
+				return diag.State
+			}
+		}
+		return CircuitBreakerStateClosed
+	}
+
+	failed := &AnalyticsError{InnerError: ErrServiceNotAvailable, Endpoint: "http://10.0.0.1:8095"}
+	ignored := &AnalyticsError{InnerError: ErrTimeout, Endpoint: "http://10.0.0.1:8095"}
+
+	// Outcomes which are counted as successes reset the rolling window for the node.
+	breakers.markCompletion(ServiceTypeAnalytics, failed)
+	breakers.markCompletion(ServiceTypeAnalytics, ignored)
+	breakers.markCompletion(ServiceTypeAnalytics, ignored)
+	suite.Assert().Contains(breakers.diagnostics(), CircuitBreakerDiagnostics{
+		Service:  ServiceTypeAnalytics,
+		Endpoint: "http://10.0.0.1:8095",
+		State:    CircuitBreakerStateClosed,
+		Total:    3,
+		Failed:   1,
+	})
+
+	// Once the nodes are known requests are rejected when all of them are open.
+	breakers.sendCanary(ServiceTypeAnalytics, canary(
+		gocbcore.EndpointPingResult{Endpoint: "http://10.0.0.1:8095", State: gocbcore.PingStateOK},
+	))
+	breakers.markCompletion(ServiceTypeAnalytics, failed)
+	breakers.markCompletion(ServiceTypeAnalytics, failed)
+	suite.Require().Equal(CircuitBreakerStateOpen, nodeState("http://10.0.0.1:8095"))
+	breakers.markCompletion(ServiceTypeAnalytics, nil)
+	breakers.markCompletion(ServiceTypeAnalytics, nil)
+	breakers.markCompletion(ServiceTypeAnalytics, nil)
+	suite.Assert().False(breakers.allowsRequest(ServiceTypeAnalytics, nil))
+
+	// After the sleep window the node is probed by a canary which finds it healthy.
+	clock.advance(60 * time.Millisecond)
+	canaryDone := make(chan struct{})
+	healthy := canary(gocbcore.EndpointPingResult{Endpoint: "http://10.0.0.1:8095", State: gocbcore.PingStateOK})
+	suite.Require().False(breakers.allowsRequest(ServiceTypeAnalytics, func(deadline time.Time) (*gocbcore.PingResult, error) {
+		defer close(canaryDone)
+		return healthy(deadline)
+	}))
+	<-canaryDone
+
+	suite.Require().Eventually(func() bool {
+		return breakers.allowsRequest(ServiceTypeAnalytics, nil)
+	}, time.Second, time.Millisecond)
+}
+
+func (suite *UnitTestSuite) TestHTTPCircuitBreakerCompletionCallback() {
+	breakers := newHTTPCircuitBreakers(CircuitBreakerConfig{
+		VolumeThreshold: 1,
+		CompletionCallback: func(err error) bool {
+			return !errors.Is(err, ErrServiceNotAvailable)
+		},
+	})
+
+	// Timeouts are not counted as failures when a completion callback is provided which ignores them.
+	breakers.markCompletion(ServiceTypeAnalytics, &AnalyticsError{InnerError: ErrTimeout})
+	suite.Assert().True(breakers.allowsRequest(ServiceTypeAnalytics, nil))
+
+	breakers.markCompletion(ServiceTypeAnalytics, &AnalyticsError{InnerError: ErrServiceNotAvailable})
+	suite.Assert().False(breakers.allowsRequest(ServiceTypeAnalytics, nil))
+	suite.Assert().True(breakers.allowsRequest(ServiceTypeViews, nil))
+}
+
+func (suite *UnitTestSuite) TestHTTPCircuitBreakerDisabled() {
+	breakers := newHTTPCircuitBreakers(CircuitBreakerConfig{
+		Disabled:        true,
+		VolumeThreshold: 1,
+	})
+	suite.Require().Nil(breakers)
+
+	breakers.markCompletion(ServiceTypeQuery, &QueryError{InnerError: ErrTimeout})
+	suite.Assert().True(breakers.allowsRequest(ServiceTypeQuery, nil))
+	suite.Assert().Nil(breakers.diagnostics())
+}
+
+func (suite *UnitTestSuite) TestDiagnosticsCircuitBreakersJSON() {
+	report := &DiagnosticsResult{
+		ID:       "123",
+		Services: make(map[string][]EndPointDiagnostics),
+		CircuitBreakers: []CircuitBreakerDiagnostics{
+			{
+				Service:  ServiceTypeQuery,
+				Endpoint: "http://10.0.0.1:8093",
+				State:    CircuitBreakerStateHalfOpen,
+				Total:    10,
+				Failed:   6,
+			},
+		},
+	}
+
+	b, err := json.Marshal(report)
+	suite.Require().Nil(err, err)
+
+	var jsonReport jsonDiagnosticReport
+	suite.Require().Nil(json.Unmarshal(b, &jsonReport))
+	suite.Assert().Equal([]jsonCircuitBreakerEntry{
+		{
+			Service:  "query",
+			Endpoint: "http://10.0.0.1:8093",
+			State:    "half_open",
+			Total:    10,
+			Failed:   6,
+		},
+	}, jsonReport.CircuitBreakers)
+}
@@ -14,10 +14,13 @@ type kvOpManager struct {
 	signal chan struct{}
 
 	err           error
+	opErr         error
 	wasResolved   bool
 	mutationToken *MutationToken
 
-	span            requestSpan
+	span            RequestSpan
+	opName          string
+	start           time.Time
 	documentID      string
 	transcoder      Transcoder
 	timeout         time.Duration
@@ -29,6 +32,8 @@ type kvOpManager struct {
 	durabilityLevel DurabilityLevel
 	retryStrategy   *retryStrategyWrapper
 	cancelCh        chan struct{}
+	release         func()
+	op              *trackedOp
 }
 
 func (m *kvOpManager) getTimeout() time.Duration {
@@ -72,7 +77,7 @@ func (m *kvOpManager) SetValue(val interface{}) {
 		return
 	}
 
-	espan := m.parent.startKvOpTrace("encode", m.span)
+	espan := m.parent.startKvOpTrace("encode", m.span.Context())
 	defer espan.Finish()
 
 	bytes, flags, err := m.transcoder.Encode(val)
@@ -106,16 +111,19 @@ func (m *kvOpManager) SetDuraOptions(persistTo, replicateTo uint, level Durabili
 func (m *kvOpManager) SetRetryStrategy(retryStrategy RetryStrategy) {
 	wrapper := m.parent.sb.RetryStrategyWrapper
 	if retryStrategy != nil {
-		wrapper = newRetryStrategyWrapper(retryStrategy)
+		wrapper = wrapper.withStrategy(retryStrategy)
 	}
 	m.retryStrategy = wrapper
 }
 
 func (m *kvOpManager) Finish() {
+	m.releaseBulkhead()
+	m.op.finish()
+	m.parent.sb.Meter.recordOperation(meterValueServiceKV, m.opName, m.start, m.opErr)
 	m.span.Finish()
 }
 
-func (m *kvOpManager) TraceSpan() requestSpan {
+func (m *kvOpManager) TraceSpan() RequestSpan {
 	return m.span
 }
 
@@ -170,24 +178,58 @@ func (m *kvOpManager) RetryStrategy() *retryStrategyWrapper {
 	return m.retryStrategy
 }
 
+// setOpErr records the error that the operation failed with, so that it is recorded to the meter once the
+// operation finishes, and returns it.
+func (m *kvOpManager) setOpErr(err error) error {
+	if err != nil && m.opErr == nil {
+		m.opErr = err
+	}
+	return err
+}
+
 func (m *kvOpManager) CheckReadyForOp() error {
 	if m.err != nil {
-		return m.err
+		return m.setOpErr(m.err)
 	}
 
 	if m.getTimeout() == 0 {
 		return errors.New("op manager had no timeout specified")
 	}
 
+	op, err := m.parent.sb.Ops.begin()
+	if err != nil {
+		return m.setOpErr(err)
+	}
+	m.op = op
+
+	return m.acquireBulkhead()
+}
+
+// acquireBulkhead waits for the operation to be admitted by the KV bulkhead.
+func (m *kvOpManager) acquireBulkhead() error {
+	release, err := m.parent.sb.Bulkheads.forService(ServiceTypeKeyValue).acquire(m.Deadline(), m.cancelCh)
+	if err != nil {
+		return m.setOpErr(err)
+	}
+	m.release = release
+
 	return nil
 }
 
+// releaseBulkhead frees the slot held by the operation in the KV bulkhead.
+func (m *kvOpManager) releaseBulkhead() {
+	if m.release != nil {
+		m.release()
+		m.release = nil
+	}
+}
+
 func (m *kvOpManager) NeedsObserve() bool {
 	return m.persistTo > 0 || m.replicateTo > 0
 }
 
 func (m *kvOpManager) EnhanceErr(err error) error {
-	return maybeEnhanceCollKVErr(err, nil, m.parent, m.documentID)
+	return m.setOpErr(maybeEnhanceCollKVErr(err, nil, m.parent, m.documentID))
 }
 
 func (m *kvOpManager) EnhanceMt(token gocbcore.MutationToken) *MutationToken {
@@ -213,7 +255,7 @@ func (m *kvOpManager) Resolve(token *MutationToken) {
 
 func (m *kvOpManager) Wait(op gocbcore.PendingOp, err error) error {
 	if err != nil {
-		return err
+		return m.setOpErr(err)
 	}
 	if m.err != nil {
 		op.Cancel()
@@ -232,7 +274,10 @@ func (m *kvOpManager) Wait(op gocbcore.PendingOp, err error) error {
 			return errors.New("expected a mutation token")
 		}
 
-		return m.parent.waitForDurability(
+		// The observe requests each take their own slot, so the slot held by the mutation is freed first.
+		m.releaseBulkhead()
+
+		return m.setOpErr(m.parent.waitForDurability(
 			m.span,
 			m.documentID,
 			m.mutationToken.token,
@@ -240,19 +285,21 @@ func (m *kvOpManager) Wait(op gocbcore.PendingOp, err error) error {
 			m.persistTo,
 			m.Deadline(),
 			m.cancelCh,
-		)
+		))
 	}
 
 	return nil
 }
 
-func (c *Collection) newKvOpManager(opName string, tracectx requestSpanContext) *kvOpManager {
+func (c *Collection) newKvOpManager(opName string, tracectx RequestSpanContext) *kvOpManager {
 	span := c.startKvOpTrace(opName, tracectx)
 
 	return &kvOpManager{
 		parent: c,
 		signal: make(chan struct{}, 1),
 		span:   span,
+		opName: opName,
+		start:  time.Now(),
 	}
 }
 
@@ -104,12 +104,51 @@ func getCoreLogger(logger Logger) gocbcore.Logger {
 	}
 }
 
+// coreOrphanLogFormat is the format used by gocbcore when it logs the orphaned responses that it
+// has observed, gocbcore provides no other way to access these.
+const coreOrphanLogFormat = "Orphaned responses observed:\n %s"
+
+// coreInterceptLogger intercepts the orphaned response reports logged by gocbcore so that they can
+// be delivered to the cluster which owns the reporting agent, whether or not a logger has been set.
+type coreInterceptLogger struct {
+	wrapped gocbcore.Logger
+}
+
+func (wrapper *coreInterceptLogger) Log(level gocbcore.LogLevel, offset int, format string, v ...interface{}) error {
+	if format == coreOrphanLogFormat && len(v) == 1 {
+		if data, ok := v[0].([]byte); ok && dispatchOrphanReport(data) {
+			return nil
+		}
+	}
+
+	if wrapper.wrapped == nil {
+		return nil
+	}
+
+	return wrapper.wrapped.Log(level, offset+1, format, v...)
+}
+
+func updateCoreLogger() {
+	var logger gocbcore.Logger
+	if globalLogger != nil {
+		logger = getCoreLogger(globalLogger)
+	}
+
+	if hasOrphanReportClients() {
+		logger = &coreInterceptLogger{
+			wrapped: logger,
+		}
+	}
+
+	gocbcore.SetLogger(logger)
+}
+
 // SetLogger sets a logger to be used by the library. A logger can be obtained via
 // the DefaultStdioLogger() or VerboseStdioLogger() functions. You can also implement
 // your own logger using the Logger interface.
 func SetLogger(logger Logger) {
 	globalLogger = logger
-	gocbcore.SetLogger(getCoreLogger(logger))
+	updateCoreLogger()
 	// gocbcore.SetLogRedactionLevel(gocbcore.LogRedactLevel(globalLogRedactionLevel))
 }
 
@@ -0,0 +1,210 @@
+package gocb
+
+import (
+	"errors"
+	"sync/atomic"
+	"time"
+)
+
+// Meter creates the metrics which are recorded by the SDK. Implementations are expected to return the same metric
+// for repeated calls with the same name and tags.
+// VOLATILE: This API is subject to change at any time.
+type Meter interface {
+	Counter(name string, tags map[string]string) (Counter, error)
+	ValueRecorder(name string, tags map[string]string) (ValueRecorder, error)
+}
+
+// Counter is a metric which is only ever incremented.
+// VOLATILE: This API is subject to change at any time.
+type Counter interface {
+	IncrementBy(num uint64)
+}
+
+// ValueRecorder is a metric which records a distribution of values.
+// VOLATILE: This API is subject to change at any time.
+type ValueRecorder interface {
+	RecordValue(val uint64)
+}
+
+// The names of the metrics recorded by the SDK.
+const (
+	// MeterNameOperations is a ValueRecorder of the duration of operations, in microseconds, tagged by
+	// service and operation.
+	MeterNameOperations = "db.couchbase.operations"
+
+	// MeterNameErrors is a Counter of failed operations, tagged by service, operation and error.
+	MeterNameErrors = "db.couchbase.errors"
+
+	// MeterNameRetries is a Counter of the number of times requests have been retried, tagged by retry reason.
+	MeterNameRetries = "db.couchbase.retries"
+
+	// MeterNameOrphans is a Counter of the number of responses received after their request had already
+	// completed, tagged by service.
+	MeterNameOrphans = "db.couchbase.orphans"
+)
+
+// The tags applied to the metrics recorded by the SDK.
+const (
+	MeterAttribServiceKey     = "db.couchbase.service"
+	MeterAttribOperationKey   = "db.operation"
+	MeterAttribErrorKey       = "db.couchbase.error"
+	MeterAttribRetryReasonKey = "db.couchbase.retry_reason"
+)
+
+const (
+	meterValueServiceKV        = "kv"
+	meterValueServiceQuery     = "query"
+	meterValueServiceSearch    = "search"
+	meterValueServiceAnalytics = "analytics"
+	meterValueServiceViews     = "views"
+)
+
+type meterErrorMapping struct {
+	err  error
+	name string
+}
+
+// meterErrorNames are the errors which are counted individually by the errors metric, any other error is
+// counted as "other".
+var meterErrorNames = []meterErrorMapping{
+	{ErrTimeout, "timeout"},
+	{ErrRequestCanceled, "request_canceled"},
+	{ErrRequestRejected, "request_rejected"},
+	{ErrCircuitBreakerOpen, "circuit_breaker_open"},
+	{ErrClusterClosed, "cluster_closed"},
+	{ErrAuthenticationFailure, "authentication_failure"},
+	{ErrServiceNotAvailable, "service_not_available"},
+	{ErrTemporaryFailure, "temporary_failure"},
+	{ErrInternalServerFailure, "internal_server_failure"},
+	{ErrInvalidArgument, "invalid_argument"},
+	{ErrDocumentNotFound, "document_not_found"},
+	{ErrDocumentExists, "document_exists"},
+	{ErrCasMismatch, "cas_mismatch"},
+	{ErrDocumentLocked, "document_locked"},
+	{ErrValueTooLarge, "value_too_large"},
+	{ErrDurabilityImpossible, "durability_impossible"},
+	{ErrDurabilityAmbiguous, "durability_ambiguous"},
+	{ErrDurableWriteInProgress, "durable_write_in_progress"},
+	{ErrPathNotFound, "path_not_found"},
+	{ErrPathExists, "path_exists"},
+	{ErrParsingFailure, "parsing_failure"},
+	{ErrPlanningFailure, "planning_failure"},
+	{ErrIndexNotFound, "index_not_found"},
+	{ErrIndexFailure, "index_failure"},
+	{ErrPreparedStatementFailure, "prepared_statement_failure"},
+	{ErrCompilationFailure, "compilation_failure"},
+	{ErrViewNotFound, "view_not_found"},
+}
+
+func meterErrorName(err error) string {
+	for _, errName := range meterErrorNames {
+		if errors.Is(err, errName.err) {
+			return errName.name
+		}
+	}
+
+	return "other"
+}
+
+// meterWrapper records the metrics of the SDK to a Meter, it is nil when no Meter is configured. Nothing is
+// recorded once the cluster which owns it has been closed.
+type meterWrapper struct {
+	// closed is accessed atomically.
+	closed int32
+	meter  Meter
+}
+
+func newMeterWrapper(meter Meter) *meterWrapper {
+	if meter == nil {
+		return nil
+	}
+
+	return &meterWrapper{
+		meter: meter,
+	}
+}
+
+func (mw *meterWrapper) close() {
+	if mw == nil {
+		return
+	}
+
+	atomic.StoreInt32(&mw.closed, 1)
+}
+
+// enabled returns whether metrics should be recorded, mw may be nil.
+func (mw *meterWrapper) enabled() bool {
+	return mw != nil && atomic.LoadInt32(&mw.closed) == 0
+}
+
+func (mw *meterWrapper) counter(name string, tags map[string]string) Counter {
+	counter, err := mw.meter.Counter(name, tags)
+	if err != nil {
+		logDebugf("Failed to create %s counter: %v", name, err)
+		return nil
+	}
+
+	return counter
+}
+
+// recordOperation records the duration of an operation which started at start and, if it failed, the error.
+func (mw *meterWrapper) recordOperation(service, operation string, start time.Time, err error) {
+	if !mw.enabled() {
+		return
+	}
+
+	recorder, recErr := mw.meter.ValueRecorder(MeterNameOperations, map[string]string{
+		MeterAttribServiceKey:   service,
+		MeterAttribOperationKey: operation,
+	})
+	if recErr != nil {
+		logDebugf("Failed to create %s value recorder: %v", MeterNameOperations, recErr)
+	} else {
+		recorder.RecordValue(uint64(time.Since(start) / time.Microsecond))
+	}
+
+	if err != nil {
+		mw.recordError(service, operation, err)
+	}
+}
+
+func (mw *meterWrapper) recordError(service, operation string, err error) {
+	if !mw.enabled() {
+		return
+	}
+
+	counter := mw.counter(MeterNameErrors, map[string]string{
+		MeterAttribServiceKey:   service,
+		MeterAttribOperationKey: operation,
+		MeterAttribErrorKey:     meterErrorName(err),
+	})
+	if counter != nil {
+		counter.IncrementBy(1)
+	}
+}
+
+func (mw *meterWrapper) recordRetry(reason RetryReason) {
+	if !mw.enabled() {
+		return
+	}
+
+	counter := mw.counter(MeterNameRetries, map[string]string{
+		MeterAttribRetryReasonKey: reason.Description(),
+	})
+	if counter != nil {
+		counter.IncrementBy(1)
+	}
+}
+
+func (mw *meterWrapper) recordOrphans(service string, count uint64) {
+	if !mw.enabled() {
+		return
+	}
+
+	counter := mw.counter(MeterNameOrphans, map[string]string{
+		MeterAttribServiceKey: service,
+	})
+	if counter != nil {
+		counter.IncrementBy(count)
+	}
+}
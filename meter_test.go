@@ -0,0 +1,171 @@
+package gocb
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v9"
+)
+
+type testMeterMetric struct {
+	lock   sync.Mutex
+	values []uint64
+}
+
+func (m *testMeterMetric) IncrementBy(num uint64) {
+	m.lock.Lock()
+	m.values = append(m.values, num)
+	m.lock.Unlock()
+}
+
+func (m *testMeterMetric) RecordValue(val uint64) {
+	m.IncrementBy(val)
+}
+
+type testMeter struct {
+	lock    sync.Mutex
+	metrics map[string]*testMeterMetric
+}
+
+func newTestMeter() *testMeter {
+	return &testMeter{
+		metrics: make(map[string]*testMeterMetric),
+	}
+}
+
+func testMeterKey(name string, tags map[string]string) string {
+	var pairs []string
+	for key, value := range tags {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+func (m *testMeter) metric(name string, tags map[string]string) *testMeterMetric {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	key := testMeterKey(name, tags)
+	metric, ok := m.metrics[key]
+	if !ok {
+		metric = &testMeterMetric{}
+		m.metrics[key] = metric
+	}
+	return metric
+}
+
+func (m *testMeter) Counter(name string, tags map[string]string) (Counter, error) {
+	return m.metric(name, tags), nil
+}
+
+func (m *testMeter) ValueRecorder(name string, tags map[string]string) (ValueRecorder, error) {
+	return m.metric(name, tags), nil
+}
+
+func (m *testMeter) values(name string, tags map[string]string) []uint64 {
+	metric := m.metric(name, tags)
+	metric.lock.Lock()
+	defer metric.lock.Unlock()
+	return metric.values
+}
+
+func (suite *UnitTestSuite) TestMeterRecordOperation() {
+	meter := newTestMeter()
+	mw := newMeterWrapper(meter)
+
+	mw.recordOperation(meterValueServiceQuery, "query", time.Now().Add(-time.Millisecond), nil)
+	mw.recordOperation(meterValueServiceQuery, "query", time.Now(), QueryError{InnerError: ErrTimeout})
+	mw.recordError(meterValueServiceKV, "Get", errors.New("something"))
+
+	latencies := meter.values(MeterNameOperations, map[string]string{
+		MeterAttribServiceKey:   meterValueServiceQuery,
+		MeterAttribOperationKey: "query",
+	})
+	suite.Require().Len(latencies, 2)
+	suite.Assert().True(latencies[0] >= 1000, latencies[0])
+
+	suite.Assert().Equal([]uint64{1}, meter.values(MeterNameErrors, map[string]string{
+		MeterAttribServiceKey:   meterValueServiceQuery,
+		MeterAttribOperationKey: "query",
+		MeterAttribErrorKey:     "timeout",
+	}))
+	suite.Assert().Equal([]uint64{1}, meter.values(MeterNameErrors, map[string]string{
+		MeterAttribServiceKey:   meterValueServiceKV,
+		MeterAttribOperationKey: "Get",
+		MeterAttribErrorKey:     "other",
+	}))
+
+	// A nil wrapper, used when no meter is configured, records nothing.
+	var nilWrapper *meterWrapper
+	nilWrapper.recordOperation(meterValueServiceQuery, "query", time.Now(), ErrTimeout)
+}
+
+func (suite *UnitTestSuite) TestMeterRecordsRetriesAndOrphans() {
+	meter := newTestMeter()
+	c := clusterFromOptions(ClusterOptions{Meter: meter})
+	other := clusterFromOptions(ClusterOptions{})
+
+	strategy := c.sb.RetryStrategyWrapper.withStrategy(&mockRetryStrategy{action: &WithDurationRetryAction{WithDuration: time.Millisecond}})
+	strategy.RetryAfter(&mockGocbcoreRequest{}, gocbcore.KVLockedRetryReason)
+
+	// Retries of requests belonging to other clusters are not recorded.
+	other.sb.RetryStrategyWrapper.withStrategy(&mockRetryStrategy{action: &WithDurationRetryAction{WithDuration: time.Millisecond}}).
+		RetryAfter(&mockGocbcoreRequest{}, gocbcore.KVLockedRetryReason)
+
+	logger := &coreInterceptLogger{}
+	coreReport := []byte(`{"service":"kv","count":3,"top":[{"c":"client1/conn1","i":"0x12","r":"10.0.0.1:11210","d":1500,"s":"kv:Get"}]}`)
+	addOrphanReportClient("client1", c)
+	suite.Require().Nil(logger.Log(gocbcore.LogWarn, 0, coreOrphanLogFormat, coreReport))
+	removeOrphanReportClient("client1")
+
+	suite.Require().Nil(c.Close(nil))
+
+	// Nothing is recorded once the cluster has been closed.
+	strategy.RetryAfter(&mockGocbcoreRequest{}, gocbcore.KVLockedRetryReason)
+
+	suite.Assert().Equal([]uint64{1}, meter.values(MeterNameRetries, map[string]string{
+		MeterAttribRetryReasonKey: gocbcore.KVLockedRetryReason.Description(),
+	}))
+	suite.Assert().Equal([]uint64{3}, meter.values(MeterNameOrphans, map[string]string{
+		MeterAttribServiceKey: "kv",
+	}))
+}
+
+func (suite *UnitTestSuite) TestMeterRecordsRejectedKvOperations() {
+	meter := newTestMeter()
+	ops := newOpTracker()
+	ops.drain(0)
+
+	col := &Collection{
+		sb: stateBlock{
+			clientStateBlock: clientStateBlock{
+				BucketName: "mock",
+			},
+
+			KvTimeout:            2500 * time.Millisecond,
+			Transcoder:           NewJSONTranscoder(),
+			Tracer:               &noopTracer{},
+			Meter:                newMeterWrapper(meter),
+			Ops:                  ops,
+			RetryStrategyWrapper: newRetryStrategyWrapper(NewBestEffortRetryStrategy(nil)),
+		},
+	}
+
+	_, err := col.Get("key", nil)
+	suite.Require().True(errors.Is(err, ErrClusterClosed), err)
+
+	suite.Assert().Len(meter.values(MeterNameOperations, map[string]string{
+		MeterAttribServiceKey:   meterValueServiceKV,
+		MeterAttribOperationKey: "Get",
+	}), 1)
+	suite.Assert().Equal([]uint64{1}, meter.values(MeterNameErrors, map[string]string{
+		MeterAttribServiceKey:   meterValueServiceKV,
+		MeterAttribOperationKey: "Get",
+		MeterAttribErrorKey:     "cluster_closed",
+	}))
+}
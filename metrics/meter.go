@@ -0,0 +1,223 @@
+// Package metrics provides a gocb.Meter which aggregates the metrics recorded by the SDK in memory, along with a
+// net/http handler which exposes them in the Prometheus text exposition format without any further dependencies:
+//
+//	meter := metrics.NewMeter(nil)
+//	cluster, err := gocb.Connect(connStr, gocb.ClusterOptions{Meter: meter})
+//	...
+//	http.Handle("/metrics", metrics.NewPrometheusHandler(meter, &metrics.PrometheusOptions{Cluster: cluster}))
+//
+// VOLATILE: This API is subject to change at any time.
+package metrics
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// DefaultLatencyBuckets are the upper bounds of the histogram buckets used for value recorders when
+// MeterOptions.LatencyBuckets is not set.
+var DefaultLatencyBuckets = []time.Duration{
+	100 * time.Microsecond,
+	250 * time.Microsecond,
+	500 * time.Microsecond,
+	1 * time.Millisecond,
+	2500 * time.Microsecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// MeterOptions are the options used to create a Meter.
+type MeterOptions struct {
+	// LatencyBuckets are the upper bounds of the histogram buckets used for value recorders, which the SDK uses
+	// to record durations in microseconds. Defaults to DefaultLatencyBuckets.
+	LatencyBuckets []time.Duration
+}
+
+type tag struct {
+	key   string
+	value string
+}
+
+type series struct {
+	name string
+	tags []tag
+	key  string
+}
+
+func newSeries(name string, tags map[string]string) series {
+	s := series{
+		name: name,
+		tags: make([]tag, 0, len(tags)),
+	}
+	for key, value := range tags {
+		s.tags = append(s.tags, tag{key: key, value: value})
+	}
+	sort.Slice(s.tags, func(i, j int) bool {
+		return s.tags[i].key < s.tags[j].key
+	})
+
+	var key strings.Builder
+	key.WriteString(name)
+	for _, t := range s.tags {
+		key.WriteByte(0)
+		key.WriteString(t.key)
+		key.WriteByte(0)
+		key.WriteString(t.value)
+	}
+	s.key = key.String()
+
+	return s
+}
+
+type counter struct {
+	// value is accessed atomically so must remain 64-bit aligned.
+	value uint64
+	series
+}
+
+func (c *counter) IncrementBy(num uint64) {
+	atomic.AddUint64(&c.value, num)
+}
+
+type valueRecorder struct {
+	// sum and count are accessed atomically so must remain 64-bit aligned.
+	sum   uint64
+	count uint64
+	series
+
+	bounds []uint64
+	counts []uint64
+}
+
+func (r *valueRecorder) RecordValue(val uint64) {
+	idx := sort.Search(len(r.bounds), func(i int) bool {
+		return val <= r.bounds[i]
+	})
+	if idx < len(r.counts) {
+		atomic.AddUint64(&r.counts[idx], 1)
+	}
+	atomic.AddUint64(&r.sum, val)
+	atomic.AddUint64(&r.count, 1)
+}
+
+// Meter is a gocb.Meter which keeps a counter or histogram in memory for each combination of metric name and tags.
+type Meter struct {
+	bounds []uint64
+
+	lock      sync.RWMutex
+	counters  map[string]*counter
+	recorders map[string]*valueRecorder
+}
+
+var _ gocb.Meter = (*Meter)(nil)
+
+// NewMeter creates a new Meter.
+func NewMeter(opts *MeterOptions) *Meter {
+	if opts == nil {
+		opts = &MeterOptions{}
+	}
+
+	buckets := opts.LatencyBuckets
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	bounds := make([]uint64, len(buckets))
+	for i, bucket := range buckets {
+		bounds[i] = uint64(bucket / time.Microsecond)
+	}
+	sort.Slice(bounds, func(i, j int) bool {
+		return bounds[i] < bounds[j]
+	})
+
+	return &Meter{
+		bounds:    bounds,
+		counters:  make(map[string]*counter),
+		recorders: make(map[string]*valueRecorder),
+	}
+}
+
+// Counter returns the counter for the given name and tags, creating it if it does not exist.
+func (m *Meter) Counter(name string, tags map[string]string) (gocb.Counter, error) {
+	s := newSeries(name, tags)
+
+	m.lock.RLock()
+	c, ok := m.counters[s.key]
+	m.lock.RUnlock()
+	if ok {
+		return c, nil
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	c, ok = m.counters[s.key]
+	if !ok {
+		c = &counter{series: s}
+		m.counters[s.key] = c
+	}
+
+	return c, nil
+}
+
+// ValueRecorder returns the histogram for the given name and tags, creating it if it does not exist.
+func (m *Meter) ValueRecorder(name string, tags map[string]string) (gocb.ValueRecorder, error) {
+	s := newSeries(name, tags)
+
+	m.lock.RLock()
+	r, ok := m.recorders[s.key]
+	m.lock.RUnlock()
+	if ok {
+		return r, nil
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	r, ok = m.recorders[s.key]
+	if !ok {
+		r = &valueRecorder{
+			series: s,
+			bounds: m.bounds,
+			counts: make([]uint64, len(m.bounds)),
+		}
+		m.recorders[s.key] = r
+	}
+
+	return r, nil
+}
+
+func (m *Meter) snapshot() ([]*counter, []*valueRecorder) {
+	m.lock.RLock()
+	counters := make([]*counter, 0, len(m.counters))
+	for _, c := range m.counters {
+		counters = append(counters, c)
+	}
+	recorders := make([]*valueRecorder, 0, len(m.recorders))
+	for _, r := range m.recorders {
+		recorders = append(recorders, r)
+	}
+	m.lock.RUnlock()
+
+	sort.Slice(counters, func(i, j int) bool {
+		return counters[i].key < counters[j].key
+	})
+	sort.Slice(recorders, func(i, j int) bool {
+		return recorders[i].key < recorders[j].key
+	})
+
+	return counters, recorders
+}
@@ -0,0 +1,266 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// Diagnostician is the source of circuit breaker states, it is implemented by *gocb.Cluster.
+type Diagnostician interface {
+	Diagnostics(opts *gocb.DiagnosticsOptions) (*gocb.DiagnosticsResult, error)
+}
+
+// EndpointMonitor is the source of per-endpoint ping results, it is implemented by *gocb.Monitor.
+type EndpointMonitor interface {
+	Endpoints() []gocb.EndpointHealth
+}
+
+// PrometheusOptions are the options used to configure the Prometheus handler.
+type PrometheusOptions struct {
+	// Cluster, when set, is used to expose the state of each circuit breaker.
+	Cluster Diagnostician
+	// Monitor, when set, is used to expose the most recent ping result of each endpoint.
+	Monitor EndpointMonitor
+}
+
+const prometheusContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var metricHelp = map[string]string{
+	gocb.MeterNameOperations: "Duration of operations.",
+	gocb.MeterNameErrors:     "Number of failed operations.",
+	gocb.MeterNameRetries:    "Number of times requests have been retried.",
+	gocb.MeterNameOrphans:    "Number of responses received after their request had completed.",
+}
+
+// NewPrometheusHandler returns a handler which writes the metrics of meter in the Prometheus text exposition
+// format. Counters are suffixed with _total and value recorders, which the SDK uses for durations in
+// microseconds, are written as histograms in seconds suffixed with _seconds.
+func NewPrometheusHandler(meter *Meter, opts *PrometheusOptions) http.Handler {
+	if opts == nil {
+		opts = &PrometheusOptions{}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		writePrometheus(&buf, meter, opts)
+
+		w.Header().Set("Content-Type", prometheusContentType)
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buf.Bytes())
+	})
+}
+
+func writePrometheus(buf *bytes.Buffer, meter *Meter, opts *PrometheusOptions) {
+	if meter != nil {
+		counters, recorders := meter.snapshot()
+
+		lastFamily := ""
+		for _, c := range counters {
+			family := sanitizeName(c.name) + "_total"
+			if family != lastFamily {
+				writeHeader(buf, family, help(c.name), "counter")
+				lastFamily = family
+			}
+			writeSample(buf, family, c.tags, "", "", formatUint(atomic.LoadUint64(&c.value)))
+		}
+
+		lastFamily = ""
+		for _, r := range recorders {
+			family := sanitizeName(r.name) + "_seconds"
+			if family != lastFamily {
+				writeHeader(buf, family, help(r.name), "histogram")
+				lastFamily = family
+			}
+
+			var cumulative uint64
+			for i, bound := range r.bounds {
+				cumulative += atomic.LoadUint64(&r.counts[i])
+				writeSample(buf, family+"_bucket", r.tags, "le", formatMicros(bound), formatUint(cumulative))
+			}
+			// Values are recorded into their bucket before the count, so a value recorded whilst the buckets are
+			// being read may be included in the buckets but not the count.
+			count := atomic.LoadUint64(&r.count)
+			if count < cumulative {
+				count = cumulative
+			}
+			writeSample(buf, family+"_bucket", r.tags, "le", "+Inf", formatUint(count))
+			writeSample(buf, family+"_sum", r.tags, "", "", formatMicros(atomic.LoadUint64(&r.sum)))
+			writeSample(buf, family+"_count", r.tags, "", "", formatUint(count))
+		}
+	}
+
+	if opts.Cluster != nil {
+		writeCircuitBreakers(buf, opts.Cluster)
+	}
+	if opts.Monitor != nil {
+		writeEndpoints(buf, opts.Monitor.Endpoints())
+	}
+}
+
+func writeCircuitBreakers(buf *bytes.Buffer, cluster Diagnostician) {
+	report, err := cluster.Diagnostics(nil)
+	if err != nil || len(report.CircuitBreakers) == 0 {
+		return
+	}
+
+	breakers := make([]gocb.CircuitBreakerDiagnostics, len(report.CircuitBreakers))
+	copy(breakers, report.CircuitBreakers)
+	sort.Slice(breakers, func(i, j int) bool {
+		if breakers[i].Service != breakers[j].Service {
+			return breakers[i].Service < breakers[j].Service
+		}
+		return breakers[i].Endpoint < breakers[j].Endpoint
+	})
+
+	family := "db_couchbase_circuit_breaker_state"
+	writeHeader(buf, family,
+		"State of each circuit breaker, 0 is disabled, 1 is closed, 2 is half open and 3 is open.", "gauge")
+	for _, breaker := range breakers {
+		writeSample(buf, family, endpointTags(breaker.Service, breaker.Endpoint), "", "",
+			strconv.Itoa(int(breaker.State)))
+	}
+}
+
+func writeEndpoints(buf *bytes.Buffer, endpoints []gocb.EndpointHealth) {
+	if len(endpoints) == 0 {
+		return
+	}
+
+	family := "db_couchbase_endpoint_up"
+	writeHeader(buf, family, "Whether the most recent ping of each endpoint succeeded.", "gauge")
+	for _, endpoint := range endpoints {
+		up := "0"
+		if endpoint.LastState == gocb.PingStateOk {
+			up = "1"
+		}
+		writeSample(buf, family, endpointTags(endpoint.Service, endpoint.Remote), "", "", up)
+	}
+
+	family = "db_couchbase_endpoint_ping_latency_seconds"
+	writeHeader(buf, family, "Latency of the most recent ping of each endpoint.", "gauge")
+	for _, endpoint := range endpoints {
+		writeSample(buf, family, endpointTags(endpoint.Service, endpoint.Remote), "", "",
+			formatFloat(endpoint.LastLatency.Seconds()))
+	}
+
+	family = "db_couchbase_endpoint_ping_success_ratio"
+	writeHeader(buf, family, "Fraction of recent pings of each endpoint which succeeded.", "gauge")
+	for _, endpoint := range endpoints {
+		writeSample(buf, family, endpointTags(endpoint.Service, endpoint.Remote), "", "",
+			formatFloat(endpoint.SuccessRatio))
+	}
+}
+
+func endpointTags(service gocb.ServiceType, endpoint string) []tag {
+	return []tag{
+		{key: gocb.MeterAttribServiceKey, value: serviceName(service)},
+		{key: "endpoint", value: endpoint},
+	}
+}
+
+func serviceName(service gocb.ServiceType) string {
+	switch service {
+	case gocb.ServiceTypeManagement:
+		return "mgmt"
+	case gocb.ServiceTypeKeyValue:
+		return "kv"
+	case gocb.ServiceTypeViews:
+		return "views"
+	case gocb.ServiceTypeQuery:
+		return "query"
+	case gocb.ServiceTypeSearch:
+		return "search"
+	case gocb.ServiceTypeAnalytics:
+		return "analytics"
+	}
+	return ""
+}
+
+func help(name string) string {
+	if text, ok := metricHelp[name]; ok {
+		return text
+	}
+	return fmt.Sprintf("Couchbase SDK metric %s.", name)
+}
+
+func writeHeader(buf *bytes.Buffer, family, help, metricType string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n", family, escapeHelp(help))
+	fmt.Fprintf(buf, "# TYPE %s %s\n", family, metricType)
+}
+
+func writeSample(buf *bytes.Buffer, name string, tags []tag, extraKey, extraValue, value string) {
+	buf.WriteString(name)
+	if len(tags) > 0 || extraKey != "" {
+		buf.WriteByte('{')
+		for i, t := range tags {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeLabel(buf, t.key, t.value)
+		}
+		if extraKey != "" {
+			if len(tags) > 0 {
+				buf.WriteByte(',')
+			}
+			writeLabel(buf, extraKey, extraValue)
+		}
+		buf.WriteByte('}')
+	}
+	buf.WriteByte(' ')
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+func writeLabel(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(sanitizeName(key))
+	buf.WriteString(`="`)
+	buf.WriteString(escapeLabelValue(value))
+	buf.WriteByte('"')
+}
+
+// sanitizeName replaces any characters which are not valid in a Prometheus metric or label name with underscores.
+func sanitizeName(name string) string {
+	out := []byte(name)
+	for i, c := range out {
+		valid := c == '_' || c == ':' ||
+			(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(c >= '0' && c <= '9' && i > 0)
+		if !valid {
+			out[i] = '_'
+		}
+	}
+	return string(out)
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabelValue(value string) string {
+	return labelValueReplacer.Replace(value)
+}
+
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(help string) string {
+	return helpReplacer.Replace(help)
+}
+
+func formatUint(val uint64) string {
+	return strconv.FormatUint(val, 10)
+}
+
+func formatMicros(val uint64) string {
+	return formatFloat(float64(val) / float64(time.Second/time.Microsecond))
+}
+
+func formatFloat(val float64) string {
+	return strconv.FormatFloat(val, 'g', -1, 64)
+}
@@ -0,0 +1,154 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+type testCluster struct {
+	breakers []gocb.CircuitBreakerDiagnostics
+}
+
+func (c *testCluster) Diagnostics(opts *gocb.DiagnosticsOptions) (*gocb.DiagnosticsResult, error) {
+	return &gocb.DiagnosticsResult{CircuitBreakers: c.breakers}, nil
+}
+
+type testMonitor struct {
+	endpoints []gocb.EndpointHealth
+}
+
+func (m *testMonitor) Endpoints() []gocb.EndpointHealth {
+	return m.endpoints
+}
+
+func TestMeterReusesMetrics(t *testing.T) {
+	meter := NewMeter(nil)
+
+	c1, _ := meter.Counter("requests", map[string]string{"a": "1", "b": "2"})
+	c2, _ := meter.Counter("requests", map[string]string{"b": "2", "a": "1"})
+	if c1 != c2 {
+		t.Fatalf("Expected counters with the same tags to be the same")
+	}
+
+	c3, _ := meter.Counter("requests", map[string]string{"a": "2"})
+	if c1 == c3 {
+		t.Fatalf("Expected counters with different tags to be different")
+	}
+}
+
+func TestPrometheusHandler(t *testing.T) {
+	meter := NewMeter(&MeterOptions{
+		LatencyBuckets: []time.Duration{10 * time.Millisecond, time.Millisecond},
+	})
+
+	recorder, _ := meter.ValueRecorder(gocb.MeterNameOperations, map[string]string{
+		gocb.MeterAttribServiceKey:   "kv",
+		gocb.MeterAttribOperationKey: "Get",
+	})
+	recorder.RecordValue(500)
+	recorder.RecordValue(5000)
+	recorder.RecordValue(50000)
+
+	errCounter, _ := meter.Counter(gocb.MeterNameErrors, map[string]string{
+		gocb.MeterAttribServiceKey:   "kv",
+		gocb.MeterAttribOperationKey: "Get",
+		gocb.MeterAttribErrorKey:     "timeout",
+	})
+	errCounter.IncrementBy(2)
+
+	retryCounter, _ := meter.Counter(gocb.MeterNameRetries, map[string]string{
+		gocb.MeterAttribRetryReasonKey: "KV_\"LOCKED\"",
+	})
+	retryCounter.IncrementBy(1)
+
+	handler := NewPrometheusHandler(meter, &PrometheusOptions{
+		Cluster: &testCluster{
+			breakers: []gocb.CircuitBreakerDiagnostics{
+				{Service: gocb.ServiceTypeQuery, Endpoint: "server1:8093", State: gocb.CircuitBreakerStateOpen},
+				{Service: gocb.ServiceTypeQuery, State: gocb.CircuitBreakerStateClosed},
+			},
+		},
+		Monitor: &testMonitor{
+			endpoints: []gocb.EndpointHealth{
+				{
+					Service:      gocb.ServiceTypeQuery,
+					Remote:       "server1:8093",
+					LastState:    gocb.PingStateOk,
+					LastLatency:  2 * time.Millisecond,
+					SuccessRatio: 0.5,
+				},
+			},
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 but was %d", rec.Code)
+	}
+	if contentType := rec.Header().Get("Content-Type"); !strings.HasPrefix(contentType, "text/plain; version=0.0.4") {
+		t.Fatalf("Expected prometheus content type but was %s", contentType)
+	}
+
+	expected := []string{
+		"# TYPE db_couchbase_errors_total counter",
+		`db_couchbase_errors_total{db_couchbase_error="timeout",db_couchbase_service="kv",db_operation="Get"} 2`,
+		`db_couchbase_retries_total{db_couchbase_retry_reason="KV_\"LOCKED\""} 1`,
+		"# TYPE db_couchbase_operations_seconds histogram",
+		`db_couchbase_operations_seconds_bucket{db_couchbase_service="kv",db_operation="Get",le="0.001"} 1`,
+		`db_couchbase_operations_seconds_bucket{db_couchbase_service="kv",db_operation="Get",le="0.01"} 2`,
+		`db_couchbase_operations_seconds_bucket{db_couchbase_service="kv",db_operation="Get",le="+Inf"} 3`,
+		`db_couchbase_operations_seconds_sum{db_couchbase_service="kv",db_operation="Get"} 0.0555`,
+		`db_couchbase_operations_seconds_count{db_couchbase_service="kv",db_operation="Get"} 3`,
+		"# TYPE db_couchbase_circuit_breaker_state gauge",
+		`db_couchbase_circuit_breaker_state{db_couchbase_service="query",endpoint=""} 1`,
+		`db_couchbase_circuit_breaker_state{db_couchbase_service="query",endpoint="server1:8093"} 3`,
+		`db_couchbase_endpoint_up{db_couchbase_service="query",endpoint="server1:8093"} 1`,
+		`db_couchbase_endpoint_ping_latency_seconds{db_couchbase_service="query",endpoint="server1:8093"} 0.002`,
+		`db_couchbase_endpoint_ping_success_ratio{db_couchbase_service="query",endpoint="server1:8093"} 0.5`,
+	}
+
+	lines := strings.Split(rec.Body.String(), "\n")
+	lineIdx := 0
+	for _, line := range expected {
+		for lineIdx < len(lines) && lines[lineIdx] != line {
+			lineIdx++
+		}
+		if lineIdx == len(lines) {
+			t.Fatalf("Expected output to contain %s in order but was:\n%s", line, rec.Body.String())
+		}
+	}
+}
+
+func TestPrometheusHistogramCountIncludesBuckets(t *testing.T) {
+	meter := NewMeter(&MeterOptions{
+		LatencyBuckets: []time.Duration{time.Millisecond},
+	})
+
+	recorder, _ := meter.ValueRecorder(gocb.MeterNameOperations, nil)
+	recorder.RecordValue(500)
+
+	// Simulate a value which has been recorded into its bucket but not yet into the count.
+	recorder.(*valueRecorder).counts[0]++
+
+	var buf bytes.Buffer
+	writePrometheus(&buf, meter, &PrometheusOptions{})
+	out := buf.String()
+
+	for _, sample := range []string{
+		`db_couchbase_operations_seconds_bucket{le="0.001"} 2`,
+		`db_couchbase_operations_seconds_bucket{le="+Inf"} 2`,
+		`db_couchbase_operations_seconds_count 2`,
+	} {
+		if !strings.Contains(out, sample) {
+			t.Fatalf("Expected %s in output but was:\n%s", sample, out)
+		}
+	}
+}
@@ -20,7 +20,7 @@ type mgmtRequest struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
 
-	parentSpan requestSpanContext
+	parentSpan RequestSpanContext
 }
 
 type mgmtResponse struct {
@@ -34,6 +34,12 @@ type mgmtProvider interface {
 }
 
 func (c *Cluster) executeMgmtRequest(req mgmtRequest) (mgmtRespOut *mgmtResponse, errOut error) {
+	op, err := c.sb.Ops.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer op.finish()
+
 	provider, err := c.getHTTPProvider()
 	if err != nil {
 		return nil, err
@@ -46,19 +52,26 @@ func (c *Cluster) executeMgmtRequest(req mgmtRequest) (mgmtRespOut *mgmtResponse
 
 	retryStrategy := c.sb.RetryStrategyWrapper
 	if req.RetryStrategy != nil {
-		retryStrategy = newRetryStrategyWrapper(req.RetryStrategy)
+		retryStrategy = c.sb.RetryStrategyWrapper.withStrategy(req.RetryStrategy)
 	}
 
+	deadline := time.Now().Add(timeout)
+	release, err := c.sb.Bulkheads.forService(ServiceTypeManagement).acquire(deadline, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
 	corereq := &gocbcore.HTTPRequest{
 		Service:       gocbcore.ServiceType(req.Service),
 		Method:        req.Method,
 		Path:          req.Path,
 		Body:          req.Body,
-		Headers:       req.Headers,
+		Headers:       addTraceContextHeaders(req.Headers, req.parentSpan),
 		ContentType:   req.ContentType,
 		IsIdempotent:  req.IsIdempotent,
 		UniqueID:      req.UniqueID,
-		Deadline:      time.Now().Add(timeout),
+		Deadline:      deadline,
 		RetryStrategy: retryStrategy,
 		TraceContext:  req.parentSpan,
 	}
@@ -77,6 +90,12 @@ func (c *Cluster) executeMgmtRequest(req mgmtRequest) (mgmtRespOut *mgmtResponse
 }
 
 func (b *Bucket) executeMgmtRequest(req mgmtRequest) (mgmtRespOut *mgmtResponse, errOut error) {
+	op, err := b.sb.Ops.begin()
+	if err != nil {
+		return nil, err
+	}
+	defer op.finish()
+
 	provider, err := b.sb.getCachedClient().getHTTPProvider()
 	if err != nil {
 		return nil, err
@@ -89,20 +108,28 @@ func (b *Bucket) executeMgmtRequest(req mgmtRequest) (mgmtRespOut *mgmtResponse,
 
 	retryStrategy := b.sb.RetryStrategyWrapper
 	if req.RetryStrategy != nil {
-		retryStrategy = newRetryStrategyWrapper(req.RetryStrategy)
+		retryStrategy = b.sb.RetryStrategyWrapper.withStrategy(req.RetryStrategy)
+	}
+
+	deadline := time.Now().Add(timeout)
+	release, err := b.sb.Bulkheads.forService(ServiceTypeManagement).acquire(deadline, nil)
+	if err != nil {
+		return nil, err
 	}
+	defer release()
 
 	corereq := &gocbcore.HTTPRequest{
 		Service:       gocbcore.ServiceType(req.Service),
 		Method:        req.Method,
 		Path:          req.Path,
 		Body:          req.Body,
-		Headers:       req.Headers,
+		Headers:       addTraceContextHeaders(req.Headers, req.parentSpan),
 		ContentType:   req.ContentType,
 		IsIdempotent:  req.IsIdempotent,
 		UniqueID:      req.UniqueID,
-		Deadline:      time.Now().Add(timeout),
+		Deadline:      deadline,
 		RetryStrategy: retryStrategy,
+		TraceContext:  req.parentSpan,
 	}
 
 	coreresp, err := provider.DoHTTPRequest(corereq)
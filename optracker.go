@@ -0,0 +1,302 @@
+package gocb
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// opTracker keeps count of the operations in flight for a cluster so that closing the cluster can wait for them
+// to complete. Once the cluster has started closing no new operations can begin.
+type opTracker struct {
+	lock      sync.Mutex
+	closed    bool
+	inflight  int
+	drainedCh chan struct{}
+	streams   map[*trackedOp]func() error
+}
+
+func newOpTracker() *opTracker {
+	return &opTracker{
+		streams: make(map[*trackedOp]func() error),
+	}
+}
+
+// trackedOp is a single operation being tracked by an opTracker, it is nil when there is no tracker.
+type trackedOp struct {
+	tracker   *opTracker
+	once      sync.Once
+	streaming bool
+}
+
+// begin records the start of an operation, returning ErrClusterClosed if the cluster has started closing.
+func (t *opTracker) begin() (*trackedOp, error) {
+	if t == nil {
+		return nil, nil
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if t.closed {
+		return nil, ErrClusterClosed
+	}
+	t.inflight++
+
+	return &trackedOp{
+		tracker: t,
+	}, nil
+}
+
+func (t *opTracker) isClosed() bool {
+	if t == nil {
+		return false
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return t.closed
+}
+
+// drain stops any new operations from beginning and waits for up to timeout for those in flight to complete.
+// Any streaming results which are still open once the timeout is reached are closed. It returns whether every
+// operation completed.
+func (t *opTracker) drain(timeout time.Duration) bool {
+	if t == nil {
+		return true
+	}
+
+	t.lock.Lock()
+	t.closed = true
+	if t.inflight == 0 {
+		t.lock.Unlock()
+		return true
+	}
+	if t.drainedCh == nil {
+		t.drainedCh = make(chan struct{})
+	}
+	drainedCh := t.drainedCh
+	t.lock.Unlock()
+
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+
+		select {
+		case <-drainedCh:
+			return true
+		case <-timer.C:
+		}
+	}
+
+	t.lock.Lock()
+	closeFns := make([]func() error, 0, len(t.streams))
+	for _, closeFn := range t.streams {
+		closeFns = append(closeFns, closeFn)
+	}
+	inflight := t.inflight
+	t.lock.Unlock()
+
+	if inflight == 0 {
+		return true
+	}
+
+	logDebugf("Closing cluster with %d operations still in flight", inflight)
+	for _, closeFn := range closeFns {
+		if err := closeFn(); err != nil {
+			logDebugf("Failed to close streaming result during cluster close: %s", err)
+		}
+	}
+
+	return false
+}
+
+// finish records that the operation has completed, it is safe to call more than once.
+func (op *trackedOp) finish() {
+	if op == nil {
+		return
+	}
+
+	op.once.Do(func() {
+		t := op.tracker
+		t.lock.Lock()
+		t.inflight--
+		delete(t.streams, op)
+		if t.inflight == 0 && t.drainedCh != nil {
+			close(t.drainedCh)
+			t.drainedCh = nil
+		}
+		t.lock.Unlock()
+	})
+}
+
+// finishUnlessStreaming finishes the operation unless its results have been handed off to a streaming reader,
+// in which case the reader finishes it.
+func (op *trackedOp) finishUnlessStreaming() {
+	if op == nil || op.streaming {
+		return
+	}
+
+	op.finish()
+}
+
+type trackedStreamReader interface {
+	NextRow() []byte
+	Err() error
+	MetaData() ([]byte, error)
+	Close() error
+}
+
+// trackedRowReader keeps an operation in flight until all of its rows have been read or it has been closed.
+type trackedRowReader struct {
+	reader trackedStreamReader
+	op     *trackedOp
+}
+
+func (r *trackedRowReader) NextRow() []byte {
+	row := r.reader.NextRow()
+	if row == nil {
+		r.op.finish()
+	}
+	return row
+}
+
+func (r *trackedRowReader) Err() error {
+	return r.reader.Err()
+}
+
+func (r *trackedRowReader) MetaData() ([]byte, error) {
+	return r.reader.MetaData()
+}
+
+func (r *trackedRowReader) Close() error {
+	err := r.reader.Close()
+	r.op.finish()
+	return err
+}
+
+type trackedQueryRowReader struct {
+	trackedRowReader
+	reader queryRowReader
+}
+
+func (r *trackedQueryRowReader) PreparedName() (string, error) {
+	return r.reader.PreparedName()
+}
+
+func (r *trackedQueryRowReader) EarlyMetadata(key string) json.RawMessage {
+	return readerEarlyMetadata(r.reader, key)
+}
+
+// startStreaming hands the operation off to reader, which is closed if the cluster is closed before the rows have
+// been consumed. Closing the outermost reader ensures that any hooks wrapped around the stream are still called.
+func (op *trackedOp) startStreaming(reader trackedStreamReader) {
+	op.streaming = true
+
+	t := op.tracker
+	t.lock.Lock()
+	t.streams[op] = reader.Close
+	t.lock.Unlock()
+}
+
+// trackQueryRows returns a reader which keeps op in flight until the rows of reader have been consumed.
+func (op *trackedOp) trackQueryRows(reader queryRowReader) queryRowReader {
+	if op == nil {
+		return reader
+	}
+
+	tracked := &trackedQueryRowReader{
+		trackedRowReader: trackedRowReader{
+			reader: reader,
+			op:     op,
+		},
+		reader: reader,
+	}
+	op.startStreaming(tracked)
+
+	return tracked
+}
+
+// trackRows returns a reader which keeps op in flight until the rows of reader have been consumed.
+func (op *trackedOp) trackRows(reader trackedStreamReader) trackedStreamReader {
+	if op == nil {
+		return reader
+	}
+
+	tracked := &trackedRowReader{
+		reader: reader,
+		op:     op,
+	}
+	op.startStreaming(tracked)
+
+	return tracked
+}
+
+// completionRowReader calls onComplete once all of the rows of a streaming result have been read or it has been
+// closed, with the error which the stream failed with if any.
+type completionRowReader struct {
+	reader     trackedStreamReader
+	once       sync.Once
+	onComplete func(err error)
+}
+
+func (r *completionRowReader) complete(err error) {
+	r.once.Do(func() {
+		r.onComplete(err)
+	})
+}
+
+func (r *completionRowReader) NextRow() []byte {
+	row := r.reader.NextRow()
+	if row == nil {
+		r.complete(r.reader.Err())
+	}
+	return row
+}
+
+func (r *completionRowReader) Err() error {
+	return r.reader.Err()
+}
+
+func (r *completionRowReader) MetaData() ([]byte, error) {
+	return r.reader.MetaData()
+}
+
+func (r *completionRowReader) Close() error {
+	err := r.reader.Close()
+	r.complete(err)
+	return err
+}
+
+type completionQueryRowReader struct {
+	*completionRowReader
+	reader queryRowReader
+}
+
+func (r *completionQueryRowReader) PreparedName() (string, error) {
+	return r.reader.PreparedName()
+}
+
+func (r *completionQueryRowReader) EarlyMetadata(key string) json.RawMessage {
+	return readerEarlyMetadata(r.reader, key)
+}
+
+// onRowsComplete returns a reader which calls onComplete once the rows of reader have been consumed.
+func onRowsComplete(reader trackedStreamReader, onComplete func(err error)) trackedStreamReader {
+	return &completionRowReader{
+		reader:     reader,
+		onComplete: onComplete,
+	}
+}
+
+// onQueryRowsComplete returns a reader which calls onComplete once the rows of reader have been consumed.
+func onQueryRowsComplete(reader queryRowReader, onComplete func(err error)) queryRowReader {
+	return &completionQueryRowReader{
+		completionRowReader: &completionRowReader{
+			reader:     reader,
+			onComplete: onComplete,
+		},
+		reader: reader,
+	}
+}
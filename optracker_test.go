@@ -0,0 +1,166 @@
+package gocb
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/stretchr/testify/mock"
+)
+
+type trackerTestRowReader struct {
+	rows   [][]byte
+	closed chan struct{}
+}
+
+func (r *trackerTestRowReader) NextRow() []byte {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	row := r.rows[0]
+	r.rows = r.rows[1:]
+	return row
+}
+
+func (r *trackerTestRowReader) Err() error {
+	return nil
+}
+
+func (r *trackerTestRowReader) MetaData() ([]byte, error) {
+	return nil, nil
+}
+
+func (r *trackerTestRowReader) Close() error {
+	close(r.closed)
+	return nil
+}
+
+func (suite *UnitTestSuite) TestOpTrackerDrain() {
+	tracker := newOpTracker()
+
+	op, err := tracker.begin()
+	suite.Require().Nil(err, err)
+
+	drainedCh := make(chan bool)
+	go func() {
+		drainedCh <- tracker.drain(time.Second)
+	}()
+
+	suite.Require().Eventually(tracker.isClosed, time.Second, time.Millisecond)
+	_, err = tracker.begin()
+	suite.Assert().True(errors.Is(err, ErrClusterClosed), err)
+
+	op.finish()
+	// Finishing twice must not release another operation.
+	op.finish()
+	suite.Assert().True(<-drainedCh)
+	suite.Assert().Equal(0, tracker.inflight)
+}
+
+func (suite *UnitTestSuite) TestOpTrackerDrainClosesStreams() {
+	tracker := newOpTracker()
+
+	op, err := tracker.begin()
+	suite.Require().Nil(err, err)
+
+	reader := &trackerTestRowReader{
+		rows:   [][]byte{[]byte("{}")},
+		closed: make(chan struct{}),
+	}
+	completedCh := make(chan struct{})
+	tracked := op.trackRows(onRowsComplete(reader, func(err error) {
+		close(completedCh)
+	}))
+	op.finishUnlessStreaming()
+	suite.Assert().Equal(1, tracker.inflight)
+
+	// Closing the stream calls the hooks wrapped around it and finishes the operation.
+	suite.Assert().False(tracker.drain(10 * time.Millisecond))
+	<-reader.closed
+	<-completedCh
+	suite.Assert().Equal(0, tracker.inflight)
+
+	suite.Assert().NotNil(tracked.NextRow())
+	suite.Assert().Nil(tracked.NextRow())
+	suite.Assert().Equal(0, tracker.inflight)
+}
+
+func (suite *UnitTestSuite) TestClusterCloseDrainsQuery() {
+	var dataset testQueryDataset
+	err := loadJSONTestDataset("beer_sample_query_dataset", &dataset)
+	suite.Require().Nil(err, err)
+
+	reader := &mockQueryRowReader{
+		Dataset: dataset.Results,
+		mockQueryRowReaderBase: mockQueryRowReaderBase{
+			Meta:  suite.mustConvertToBytes(dataset.jsonQueryResponse),
+			Suite: suite,
+		},
+	}
+
+	cluster := suite.queryCluster(false, reader, func(args mock.Arguments) {})
+	cluster.clusterClient.(*mockClient).On("close").Return(nil)
+
+	result, err := cluster.Query("SELECT * FROM dataset", &QueryOptions{Adhoc: true})
+	suite.Require().Nil(err, err)
+
+	closedCh := make(chan error)
+	go func() {
+		closedCh <- cluster.Close(&ClusterCloseOptions{DrainTimeout: 5 * time.Second})
+	}()
+
+	suite.Require().Eventually(cluster.sb.Ops.isClosed, time.Second, time.Millisecond)
+	_, err = cluster.Query("SELECT * FROM dataset", &QueryOptions{Adhoc: true})
+	suite.Assert().True(errors.Is(err, ErrClusterClosed), err)
+
+	select {
+	case <-closedCh:
+		suite.Fail("Close should wait for the query results to be read")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	suite.assertQueryBeerResult(dataset, result)
+	suite.Require().Nil(<-closedCh)
+
+	// The tracer and meter are left in place by close, operations still fail once it has completed.
+	_, err = cluster.Query("SELECT * FROM dataset", &QueryOptions{Adhoc: true})
+	suite.Assert().True(errors.Is(err, ErrClusterClosed), err)
+}
+
+type refCountTestTracer struct {
+	noopTracer
+	refs int32
+}
+
+func (t *refCountTestTracer) AddRef() int32 {
+	return atomic.AddInt32(&t.refs, 1)
+}
+
+func (t *refCountTestTracer) DecRef() int32 {
+	return atomic.AddInt32(&t.refs, -1)
+}
+
+func (suite *UnitTestSuite) TestClusterCloseTwice() {
+	tracer := &refCountTestTracer{refs: 2}
+	cluster := clusterFromOptions(ClusterOptions{})
+	cluster.sb.Tracer = tracer
+	cli := new(mockClient)
+	cli.On("close").Return(nil).Once()
+	cluster.clusterClient = cli
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			suite.Assert().Nil(cluster.Close(nil))
+		}()
+	}
+	wg.Wait()
+	suite.Assert().Nil(cluster.Close(nil))
+
+	// The tracer is shared with another cluster, only this cluster's reference is released.
+	suite.Assert().Equal(int32(1), atomic.LoadInt32(&tracer.refs))
+	cli.AssertExpectations(suite.T())
+}
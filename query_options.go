@@ -42,7 +42,14 @@ type QueryOptions struct {
 
 	// ClientContextID provides a unique ID for this query which can be used matching up requests between client and
 	// server. If not provided will be assigned a uuid value.
-	ClientContextID      string
+	ClientContextID string
+
+	// ClientContextIDFromTrace specifies that, when ClientContextID is not provided and the span context of this
+	// query implements W3CTraceContext, the client context ID should be the trace ID of the trace. This allows
+	// server side logs to be joined with the traces of the application.
+	// VOLATILE: This API is subject to change at any time.
+	ClientContextIDFromTrace bool
+
 	PositionalParameters []interface{}
 	NamedParameters      map[string]interface{}
 	Metrics              bool
@@ -50,11 +57,22 @@ type QueryOptions struct {
 	// Raw provides a way to provide extra parameters in the request body for the query.
 	Raw map[string]interface{}
 
-	Adhoc         bool
+	Adhoc bool
+
+	// Cache specifies that the results of the query can be served from, and stored in, the client side query
+	// result cache configured by ClusterOptions.QueryResultCacheConfig. Identical queries which are executed
+	// concurrently are collapsed into a single request. Only SELECT statements, or queries with Readonly set, are
+	// cached and queries which require consistency with recent mutations, whether through ConsistentWith,
+	// QueryScanConsistencyRequestPlus or Raw, are never cached.
+	// VOLATILE: This API is subject to change at any time.
+	Cache bool
+
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
 
-	parentSpan requestSpanContext
+	// ParentSpan is the span context which the spans of this query are created under. The trace is only
+	// propagated to the query service as the client context ID, see ClientContextIDFromTrace.
+	ParentSpan RequestSpanContext
 }
 
 func (opts *QueryOptions) toMap() (map[string]interface{}, error) {
@@ -0,0 +1,229 @@
+package querybuilder
+
+import (
+	"strings"
+)
+
+// Expression is a N1QL expression, such as a field reference or a condition.
+type Expression struct {
+	render func(p *params) string
+}
+
+func (e Expression) build(p *params) string {
+	if e.render == nil {
+		p.fail("expressions cannot be empty")
+		return ""
+	}
+	return e.render(p)
+}
+
+// operand returns value as an expression, binding it as a named parameter unless it is already an Expression.
+func operand(value interface{}) Expression {
+	if expr, ok := value.(Expression); ok {
+		return expr
+	}
+	return Value(value)
+}
+
+// Field returns a reference to a field, where each element of path is a nested field name. For example
+// Field("h", "address", "city") is `h`.`address`.`city`.
+func Field(path ...string) Expression {
+	return Expression{
+		render: func(p *params) string {
+			if len(path) == 0 {
+				p.fail("fields must have a path")
+				return ""
+			}
+
+			parts := make([]string, len(path))
+			for i, name := range path {
+				parts[i] = escapeIdentifier(p, name)
+			}
+			return strings.Join(parts, ".")
+		},
+	}
+}
+
+// Value returns an expression which binds value as a named parameter.
+func Value(value interface{}) Expression {
+	return Expression{
+		render: func(p *params) string {
+			return p.bind(value)
+		},
+	}
+}
+
+// MetaID returns the document ID of the keyspace aliased as alias, or of the only keyspace if alias is empty.
+func MetaID(alias string) Expression {
+	return Expression{
+		render: func(p *params) string {
+			if alias == "" {
+				return "META().id"
+			}
+			return "META(" + escapeIdentifier(p, alias) + ").id"
+		},
+	}
+}
+
+// As returns the expression aliased as alias, for use as a projection.
+func (e Expression) As(alias string) Expression {
+	return Expression{
+		render: func(p *params) string {
+			return e.build(p) + " AS " + escapeIdentifier(p, alias)
+		},
+	}
+}
+
+func (e Expression) binary(op string, value interface{}) Expression {
+	right := operand(value)
+	return Expression{
+		render: func(p *params) string {
+			return e.build(p) + " " + op + " " + right.build(p)
+		},
+	}
+}
+
+func (e Expression) suffix(op string) Expression {
+	return Expression{
+		render: func(p *params) string {
+			return e.build(p) + " " + op
+		},
+	}
+}
+
+// Eq returns the condition that the expression equals value, which is either an Expression or is bound as a
+// named parameter. The same applies to the value of every other comparison.
+func (e Expression) Eq(value interface{}) Expression {
+	return e.binary("=", value)
+}
+
+// Ne returns the condition that the expression does not equal value.
+func (e Expression) Ne(value interface{}) Expression {
+	return e.binary("!=", value)
+}
+
+// Lt returns the condition that the expression is less than value.
+func (e Expression) Lt(value interface{}) Expression {
+	return e.binary("<", value)
+}
+
+// Lte returns the condition that the expression is less than or equal to value.
+func (e Expression) Lte(value interface{}) Expression {
+	return e.binary("<=", value)
+}
+
+// Gt returns the condition that the expression is greater than value.
+func (e Expression) Gt(value interface{}) Expression {
+	return e.binary(">", value)
+}
+
+// Gte returns the condition that the expression is greater than or equal to value.
+func (e Expression) Gte(value interface{}) Expression {
+	return e.binary(">=", value)
+}
+
+// Like returns the condition that the expression matches the LIKE pattern.
+func (e Expression) Like(pattern interface{}) Expression {
+	return e.binary("LIKE", pattern)
+}
+
+// In returns the condition that the expression is an element of values, such as a slice which is bound as a
+// single named parameter.
+func (e Expression) In(values interface{}) Expression {
+	return e.binary("IN", values)
+}
+
+// IsNull returns the condition that the expression is null.
+func (e Expression) IsNull() Expression {
+	return e.suffix("IS NULL")
+}
+
+// IsNotNull returns the condition that the expression is not null.
+func (e Expression) IsNotNull() Expression {
+	return e.suffix("IS NOT NULL")
+}
+
+// IsMissing returns the condition that the expression is missing.
+func (e Expression) IsMissing() Expression {
+	return e.suffix("IS MISSING")
+}
+
+// IsValued returns the condition that the expression is neither null nor missing.
+func (e Expression) IsValued() Expression {
+	return e.suffix("IS VALUED")
+}
+
+func join(op string, conds []Expression) Expression {
+	return Expression{
+		render: func(p *params) string {
+			if len(conds) == 0 {
+				p.fail(op + " requires at least one condition")
+				return ""
+			}
+
+			parts := make([]string, len(conds))
+			for i, cond := range conds {
+				parts[i] = cond.build(p)
+			}
+			return "(" + strings.Join(parts, " "+op+" ") + ")"
+		},
+	}
+}
+
+// And returns the condition that every one of conds is true.
+func And(conds ...Expression) Expression {
+	return join("AND", conds)
+}
+
+// Or returns the condition that at least one of conds is true.
+func Or(conds ...Expression) Expression {
+	return join("OR", conds)
+}
+
+// Not returns the condition that cond is false.
+func Not(cond Expression) Expression {
+	return Expression{
+		render: func(p *params) string {
+			return "NOT (" + cond.build(p) + ")"
+		},
+	}
+}
+
+func rangePredicate(quantifier, variable string, array, satisfies Expression) Expression {
+	return Expression{
+		render: func(p *params) string {
+			return quantifier + " " + escapeIdentifier(p, variable) + " IN " + array.build(p) +
+				" SATISFIES " + satisfies.build(p) + " END"
+		},
+	}
+}
+
+// Any returns the condition that satisfies is true for at least one element of array, where each element is
+// referenced within satisfies as Field(variable). For example:
+//
+//	Any("r", Field("reviews"), Field("r", "rating").Gte(4))
+func Any(variable string, array, satisfies Expression) Expression {
+	return rangePredicate("ANY", variable, array, satisfies)
+}
+
+// Every returns the condition that satisfies is true for every element of array, where each element is
+// referenced within satisfies as Field(variable).
+func Every(variable string, array, satisfies Expression) Expression {
+	return rangePredicate("EVERY", variable, array, satisfies)
+}
+
+// Ordering is a single term of an ORDER BY clause.
+type Ordering struct {
+	expr       Expression
+	descending bool
+}
+
+// Asc orders by expr in ascending order.
+func Asc(expr Expression) Ordering {
+	return Ordering{expr: expr}
+}
+
+// Desc orders by expr in descending order.
+func Desc(expr Expression) Ordering {
+	return Ordering{expr: expr, descending: true}
+}
@@ -0,0 +1,163 @@
+// Package querybuilder builds N1QL statements whose values are always bound as named parameters, rather than
+// being concatenated into the statement text.
+//
+// Identifiers, such as field and keyspace names, are escaped and every other value is bound to a generated
+// named parameter:
+//
+//	stmt, err := querybuilder.Select(querybuilder.Field("name"), querybuilder.MetaID("h")).
+//		From(querybuilder.CollectionKeyspace(collection).As("h")).
+//		Where(querybuilder.Field("h", "city").Eq(city)).
+//		OrderBy(querybuilder.Asc(querybuilder.Field("name"))).
+//		Limit(10).
+//		Build()
+//	if err != nil {
+//		return err
+//	}
+//
+//	result, err := cluster.Query(stmt.Statement, stmt.QueryOptions(&gocb.QueryOptions{Adhoc: true}))
+//
+// VOLATILE: This API is subject to change at any time.
+package querybuilder
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+// Statement is a built N1QL statement along with the named parameters which it references.
+type Statement struct {
+	Statement       string
+	NamedParameters map[string]interface{}
+}
+
+// QueryOptions returns a copy of opts with the named parameters of the statement added to it.
+func (s *Statement) QueryOptions(opts *gocb.QueryOptions) *gocb.QueryOptions {
+	var queryOpts gocb.QueryOptions
+	if opts != nil {
+		queryOpts = *opts
+	}
+
+	params := make(map[string]interface{}, len(queryOpts.NamedParameters)+len(s.NamedParameters))
+	for name, value := range queryOpts.NamedParameters {
+		params[name] = value
+	}
+	for name, value := range s.NamedParameters {
+		params[name] = value
+	}
+	queryOpts.NamedParameters = params
+
+	return &queryOpts
+}
+
+// params collects the named parameters of a statement as it is rendered, along with the first error found.
+type params struct {
+	values map[string]interface{}
+	err    error
+}
+
+func newParams() *params {
+	return &params{
+		values: make(map[string]interface{}),
+	}
+}
+
+// bind adds value as a named parameter, returning the placeholder which references it.
+func (p *params) bind(value interface{}) string {
+	name := "qb" + strconv.Itoa(len(p.values)+1)
+	p.values[name] = value
+	return "$" + name
+}
+
+func (p *params) fail(msg string) {
+	if p.err == nil {
+		p.err = fmt.Errorf("%w: %s", gocb.ErrInvalidArgument, msg)
+	}
+}
+
+func (p *params) statement(statement string) (*Statement, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+
+	return &Statement{
+		Statement:       statement,
+		NamedParameters: p.values,
+	}, nil
+}
+
+// escapeIdentifier quotes name with backticks so that it is always treated as an identifier.
+func escapeIdentifier(p *params, name string) string {
+	if name == "" {
+		p.fail("identifiers cannot be empty")
+		return ""
+	}
+	return "`" + strings.Replace(name, "`", "``", -1) + "`"
+}
+
+// Keyspace is the bucket, or collection, which a statement operates on.
+type Keyspace struct {
+	bucket     string
+	scope      string
+	collection string
+	alias      string
+}
+
+type keyspaceCollection interface {
+	BucketName() string
+	ScopeName() string
+	Name() string
+}
+
+func collectionKeyspace(collection keyspaceCollection) Keyspace {
+	return Keyspace{
+		bucket:     collection.BucketName(),
+		scope:      collection.ScopeName(),
+		collection: collection.Name(),
+	}
+}
+
+// CollectionKeyspace returns the keyspace of collection. The default collection is referenced by its bucket
+// name so that the statement can also be used against servers which do not support collections.
+func CollectionKeyspace(collection *gocb.Collection) Keyspace {
+	return collectionKeyspace(collection)
+}
+
+// BucketKeyspace returns the keyspace of a bucket.
+func BucketKeyspace(bucketName string) Keyspace {
+	return Keyspace{
+		bucket: bucketName,
+	}
+}
+
+// As returns a copy of the keyspace which is aliased as alias.
+func (k Keyspace) As(alias string) Keyspace {
+	k.alias = alias
+	return k
+}
+
+func isDefaultName(name string) bool {
+	return name == "" || name == "_default"
+}
+
+func (k Keyspace) render(p *params) string {
+	keyspace := escapeIdentifier(p, k.bucket)
+	if !isDefaultName(k.scope) || !isDefaultName(k.collection) {
+		scope, collection := k.scope, k.collection
+		if scope == "" {
+			scope = "_default"
+		}
+		if collection == "" {
+			collection = "_default"
+		}
+		keyspace += "." + escapeIdentifier(p, scope) + "." + escapeIdentifier(p, collection)
+	}
+
+	if k.alias != "" {
+		keyspace += " AS " + escapeIdentifier(p, k.alias)
+	}
+
+	return keyspace
+}
@@ -0,0 +1,155 @@
+package querybuilder
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/couchbase/gocb/v2"
+)
+
+type testCollection struct {
+	bucket, scope, name string
+}
+
+func (c testCollection) BucketName() string { return c.bucket }
+func (c testCollection) ScopeName() string  { return c.scope }
+func (c testCollection) Name() string       { return c.name }
+
+func assertStatement(t *testing.T, stmt *Statement, err error, expected string, params map[string]interface{}) {
+	t.Helper()
+
+	if err != nil {
+		t.Fatalf("Expected no error but was %v", err)
+	}
+	if stmt.Statement != expected {
+		t.Fatalf("Expected statement to be %s but was %s", expected, stmt.Statement)
+	}
+	if !reflect.DeepEqual(stmt.NamedParameters, params) {
+		t.Fatalf("Expected named parameters to be %v but were %v", params, stmt.NamedParameters)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	keyspace := collectionKeyspace(testCollection{"travel-sample", "inventory", "hotel"}).As("h")
+
+	stmt, err := Select(Field("name"), MetaID("h").As("id")).
+		From(keyspace).
+		Where(Field("h", "city").Eq("San Francisco")).
+		Where(Or(Field("free_breakfast").Eq(true), Not(Field("price").IsValued()))).
+		Where(Any("r", Field("reviews"), Field("r", "ratings", "Overall").Gte(4))).
+		OrderBy(Desc(Field("name")), Asc(MetaID("h"))).
+		Limit(10).
+		Offset(20).
+		Build()
+
+	assertStatement(t, stmt, err, "SELECT `name`, META(`h`).id AS `id` FROM `travel-sample`.`inventory`.`hotel` AS `h` "+
+		"WHERE (`h`.`city` = $qb1 AND (`free_breakfast` = $qb2 OR NOT (`price` IS VALUED)) AND "+
+		"ANY `r` IN `reviews` SATISFIES `r`.`ratings`.`Overall` >= $qb3 END) "+
+		"ORDER BY `name` DESC, META(`h`).id LIMIT 10 OFFSET 20",
+		map[string]interface{}{"qb1": "San Francisco", "qb2": true, "qb3": 4})
+}
+
+func TestSelectInjection(t *testing.T) {
+	input := "x` = 1 OR `y"
+
+	stmt, err := SelectRaw(Field(input)).
+		From(BucketKeyspace("default")).
+		UseKeys("a", "b").
+		Where(Every("v", Field("tags"), Field("v").In([]string{"' OR 1=1 --"}))).
+		Build()
+
+	assertStatement(t, stmt, err, "SELECT RAW `x`` = 1 OR ``y` FROM `default` USE KEYS $qb1 "+
+		"WHERE EVERY `v` IN `tags` SATISFIES `v` IN $qb2 END",
+		map[string]interface{}{"qb1": []string{"a", "b"}, "qb2": []string{"' OR 1=1 --"}})
+}
+
+func TestDefaultCollectionKeyspace(t *testing.T) {
+	stmt, err := Select().From(collectionKeyspace(testCollection{"default", "_default", "_default"})).Build()
+	assertStatement(t, stmt, err, "SELECT * FROM `default`", map[string]interface{}{})
+}
+
+func TestUpdate(t *testing.T) {
+	stmt, err := Update(BucketKeyspace("default")).
+		UseKeys("k").
+		Set(Field("count"), 1).
+		Set(Field("copy"), Field("name")).
+		Unset(Field("old")).
+		Where(Field("type").Ne("deleted")).
+		Limit(5).
+		Returning(MetaID("")).
+		Build()
+
+	assertStatement(t, stmt, err, "UPDATE `default` USE KEYS $qb1 SET `count` = $qb2, `copy` = `name` UNSET `old` "+
+		"WHERE `type` != $qb3 LIMIT 5 RETURNING META().id",
+		map[string]interface{}{"qb1": []string{"k"}, "qb2": 1, "qb3": "deleted"})
+}
+
+func TestDeleteFrom(t *testing.T) {
+	stmt, err := DeleteFrom(BucketKeyspace("default")).
+		Where(Field("expires").Lt(100)).
+		Where(Field("name").Like("tmp%")).
+		Build()
+
+	assertStatement(t, stmt, err, "DELETE FROM `default` WHERE (`expires` < $qb1 AND `name` LIKE $qb2)",
+		map[string]interface{}{"qb1": 100, "qb2": "tmp%"})
+}
+
+func TestUpsertInto(t *testing.T) {
+	doc := map[string]interface{}{"name": "a"}
+
+	stmt, err := UpsertInto(BucketKeyspace("default")).
+		Value("k1", doc).
+		Value("k2", "raw").
+		Returning(Field("name")).
+		Build()
+
+	assertStatement(t, stmt, err, "UPSERT INTO `default` (KEY, VALUE) VALUES ($qb1, $qb2), ($qb3, $qb4) RETURNING `name`",
+		map[string]interface{}{"qb1": "k1", "qb2": doc, "qb3": "k2", "qb4": "raw"})
+}
+
+func TestInvalidStatements(t *testing.T) {
+	builders := []interface {
+		Build() (*Statement, error)
+	}{
+		Select(),
+		Select(Field()).From(BucketKeyspace("default")),
+		Select(Field("")).From(BucketKeyspace("default")),
+		Select().From(BucketKeyspace("")),
+		Select().From(BucketKeyspace("default")).UseKeys(),
+		Select().From(BucketKeyspace("default")).Limit(-1),
+		Select().From(BucketKeyspace("default")).Where(Expression{}),
+		Select().From(BucketKeyspace("default")).Where(And()),
+		Update(BucketKeyspace("default")),
+		UpsertInto(BucketKeyspace("default")),
+	}
+
+	for i, builder := range builders {
+		_, err := builder.Build()
+		if !errors.Is(err, gocb.ErrInvalidArgument) {
+			t.Fatalf("Expected builder %d to fail with invalid argument but was %v", i, err)
+		}
+	}
+}
+
+func TestStatementQueryOptions(t *testing.T) {
+	stmt := &Statement{
+		Statement:       "SELECT 1",
+		NamedParameters: map[string]interface{}{"qb1": 1},
+	}
+	opts := &gocb.QueryOptions{
+		Adhoc:           true,
+		NamedParameters: map[string]interface{}{"user": "a"},
+	}
+
+	queryOpts := stmt.QueryOptions(opts)
+	if !queryOpts.Adhoc {
+		t.Fatalf("Expected options to be copied")
+	}
+	if !reflect.DeepEqual(queryOpts.NamedParameters, map[string]interface{}{"user": "a", "qb1": 1}) {
+		t.Fatalf("Expected named parameters to be merged but were %v", queryOpts.NamedParameters)
+	}
+	if len(opts.NamedParameters) != 1 {
+		t.Fatalf("Expected original options to be unchanged but were %v", opts.NamedParameters)
+	}
+}
@@ -0,0 +1,361 @@
+package querybuilder
+
+import (
+	"strconv"
+	"strings"
+)
+
+// clauses holds the clauses shared by the statement builders.
+type clauses struct {
+	keyspace  *Keyspace
+	keys      []string
+	where     []Expression
+	limit     int
+	returning []Expression
+}
+
+func (c *clauses) renderKeyspace(p *params) string {
+	if c.keyspace == nil {
+		p.fail("a keyspace must be specified")
+		return ""
+	}
+	return " " + c.keyspace.render(p)
+}
+
+func (c *clauses) renderUseKeys(p *params) string {
+	if c.keys == nil {
+		return ""
+	}
+	if len(c.keys) == 0 {
+		p.fail("USE KEYS requires at least one key")
+		return ""
+	}
+	return " USE KEYS " + p.bind(c.keys)
+}
+
+func (c *clauses) renderWhere(p *params) string {
+	switch len(c.where) {
+	case 0:
+		return ""
+	case 1:
+		return " WHERE " + c.where[0].build(p)
+	default:
+		return " WHERE " + And(c.where...).build(p)
+	}
+}
+
+func renderLimit(p *params, clause string, n int) string {
+	if n < 0 {
+		p.fail(clause + " cannot be negative")
+		return ""
+	}
+	if n == 0 {
+		return ""
+	}
+	return " " + clause + " " + strconv.Itoa(n)
+}
+
+func renderExpressions(p *params, exprs []Expression) string {
+	parts := make([]string, len(exprs))
+	for i, expr := range exprs {
+		parts[i] = expr.build(p)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (c *clauses) renderReturning(p *params) string {
+	if len(c.returning) == 0 {
+		return ""
+	}
+	return " RETURNING " + renderExpressions(p, c.returning)
+}
+
+// SelectBuilder builds a SELECT statement.
+type SelectBuilder struct {
+	clauses
+	raw      bool
+	fields   []Expression
+	ordering []Ordering
+	offset   int
+}
+
+// Select starts a SELECT statement projecting fields, or every field when none are specified.
+func Select(fields ...Expression) *SelectBuilder {
+	return &SelectBuilder{
+		fields: fields,
+	}
+}
+
+// SelectRaw starts a SELECT RAW statement projecting the value of field.
+func SelectRaw(field Expression) *SelectBuilder {
+	return &SelectBuilder{
+		raw:    true,
+		fields: []Expression{field},
+	}
+}
+
+// From sets the keyspace to select from.
+func (b *SelectBuilder) From(keyspace Keyspace) *SelectBuilder {
+	b.keyspace = &keyspace
+	return b
+}
+
+// UseKeys restricts the statement to the documents with the given IDs.
+func (b *SelectBuilder) UseKeys(keys ...string) *SelectBuilder {
+	b.keys = append(make([]string, 0, len(keys)), keys...)
+	return b
+}
+
+// Where adds a condition which documents must match, multiple conditions are combined with AND.
+func (b *SelectBuilder) Where(cond Expression) *SelectBuilder {
+	b.where = append(b.where, cond)
+	return b
+}
+
+// OrderBy adds terms to order the results by.
+func (b *SelectBuilder) OrderBy(ordering ...Ordering) *SelectBuilder {
+	b.ordering = append(b.ordering, ordering...)
+	return b
+}
+
+// Limit sets the maximum number of results.
+func (b *SelectBuilder) Limit(n int) *SelectBuilder {
+	b.limit = n
+	return b
+}
+
+// Offset sets the number of results to skip.
+func (b *SelectBuilder) Offset(n int) *SelectBuilder {
+	b.offset = n
+	return b
+}
+
+// Build returns the statement and its named parameters.
+func (b *SelectBuilder) Build() (*Statement, error) {
+	p := newParams()
+
+	var sb strings.Builder
+	sb.WriteString("SELECT ")
+	if b.raw {
+		sb.WriteString("RAW ")
+	}
+	if len(b.fields) == 0 {
+		sb.WriteString("*")
+	} else {
+		sb.WriteString(renderExpressions(p, b.fields))
+	}
+	sb.WriteString(" FROM")
+	sb.WriteString(b.renderKeyspace(p))
+	sb.WriteString(b.renderUseKeys(p))
+	sb.WriteString(b.renderWhere(p))
+
+	if len(b.ordering) > 0 {
+		terms := make([]string, len(b.ordering))
+		for i, ordering := range b.ordering {
+			terms[i] = ordering.expr.build(p)
+			if ordering.descending {
+				terms[i] += " DESC"
+			}
+		}
+		sb.WriteString(" ORDER BY " + strings.Join(terms, ", "))
+	}
+
+	sb.WriteString(renderLimit(p, "LIMIT", b.limit))
+	sb.WriteString(renderLimit(p, "OFFSET", b.offset))
+
+	return p.statement(sb.String())
+}
+
+type setClause struct {
+	field Expression
+	value Expression
+}
+
+// UpdateBuilder builds an UPDATE statement.
+type UpdateBuilder struct {
+	clauses
+	set   []setClause
+	unset []Expression
+}
+
+// Update starts an UPDATE statement on keyspace.
+func Update(keyspace Keyspace) *UpdateBuilder {
+	return &UpdateBuilder{
+		clauses: clauses{
+			keyspace: &keyspace,
+		},
+	}
+}
+
+// UseKeys restricts the statement to the documents with the given IDs.
+func (b *UpdateBuilder) UseKeys(keys ...string) *UpdateBuilder {
+	b.keys = append(make([]string, 0, len(keys)), keys...)
+	return b
+}
+
+// Set sets field to value, which is either an Expression or is bound as a named parameter.
+func (b *UpdateBuilder) Set(field Expression, value interface{}) *UpdateBuilder {
+	b.set = append(b.set, setClause{field: field, value: operand(value)})
+	return b
+}
+
+// Unset removes field.
+func (b *UpdateBuilder) Unset(field Expression) *UpdateBuilder {
+	b.unset = append(b.unset, field)
+	return b
+}
+
+// Where adds a condition which documents must match, multiple conditions are combined with AND.
+func (b *UpdateBuilder) Where(cond Expression) *UpdateBuilder {
+	b.where = append(b.where, cond)
+	return b
+}
+
+// Limit sets the maximum number of documents to update.
+func (b *UpdateBuilder) Limit(n int) *UpdateBuilder {
+	b.limit = n
+	return b
+}
+
+// Returning sets the expressions returned for each updated document.
+func (b *UpdateBuilder) Returning(fields ...Expression) *UpdateBuilder {
+	b.returning = append(b.returning, fields...)
+	return b
+}
+
+// Build returns the statement and its named parameters.
+func (b *UpdateBuilder) Build() (*Statement, error) {
+	p := newParams()
+	if len(b.set) == 0 && len(b.unset) == 0 {
+		p.fail("an update must set or unset at least one field")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("UPDATE")
+	sb.WriteString(b.renderKeyspace(p))
+	sb.WriteString(b.renderUseKeys(p))
+
+	if len(b.set) > 0 {
+		assignments := make([]string, len(b.set))
+		for i, set := range b.set {
+			assignments[i] = set.field.build(p) + " = " + set.value.build(p)
+		}
+		sb.WriteString(" SET " + strings.Join(assignments, ", "))
+	}
+	if len(b.unset) > 0 {
+		sb.WriteString(" UNSET " + renderExpressions(p, b.unset))
+	}
+
+	sb.WriteString(b.renderWhere(p))
+	sb.WriteString(renderLimit(p, "LIMIT", b.limit))
+	sb.WriteString(b.renderReturning(p))
+
+	return p.statement(sb.String())
+}
+
+// DeleteBuilder builds a DELETE statement.
+type DeleteBuilder struct {
+	clauses
+}
+
+// DeleteFrom starts a DELETE statement on keyspace.
+func DeleteFrom(keyspace Keyspace) *DeleteBuilder {
+	return &DeleteBuilder{
+		clauses: clauses{
+			keyspace: &keyspace,
+		},
+	}
+}
+
+// UseKeys restricts the statement to the documents with the given IDs.
+func (b *DeleteBuilder) UseKeys(keys ...string) *DeleteBuilder {
+	b.keys = append(make([]string, 0, len(keys)), keys...)
+	return b
+}
+
+// Where adds a condition which documents must match, multiple conditions are combined with AND.
+func (b *DeleteBuilder) Where(cond Expression) *DeleteBuilder {
+	b.where = append(b.where, cond)
+	return b
+}
+
+// Limit sets the maximum number of documents to delete.
+func (b *DeleteBuilder) Limit(n int) *DeleteBuilder {
+	b.limit = n
+	return b
+}
+
+// Returning sets the expressions returned for each deleted document.
+func (b *DeleteBuilder) Returning(fields ...Expression) *DeleteBuilder {
+	b.returning = append(b.returning, fields...)
+	return b
+}
+
+// Build returns the statement and its named parameters.
+func (b *DeleteBuilder) Build() (*Statement, error) {
+	p := newParams()
+
+	var sb strings.Builder
+	sb.WriteString("DELETE FROM")
+	sb.WriteString(b.renderKeyspace(p))
+	sb.WriteString(b.renderUseKeys(p))
+	sb.WriteString(b.renderWhere(p))
+	sb.WriteString(renderLimit(p, "LIMIT", b.limit))
+	sb.WriteString(b.renderReturning(p))
+
+	return p.statement(sb.String())
+}
+
+type upsertValue struct {
+	key   string
+	value interface{}
+}
+
+// UpsertBuilder builds an UPSERT statement.
+type UpsertBuilder struct {
+	clauses
+	values []upsertValue
+}
+
+// UpsertInto starts an UPSERT statement on keyspace.
+func UpsertInto(keyspace Keyspace) *UpsertBuilder {
+	return &UpsertBuilder{
+		clauses: clauses{
+			keyspace: &keyspace,
+		},
+	}
+}
+
+// Value adds a document to upsert with the given ID.
+func (b *UpsertBuilder) Value(key string, value interface{}) *UpsertBuilder {
+	b.values = append(b.values, upsertValue{key: key, value: value})
+	return b
+}
+
+// Returning sets the expressions returned for each upserted document.
+func (b *UpsertBuilder) Returning(fields ...Expression) *UpsertBuilder {
+	b.returning = append(b.returning, fields...)
+	return b
+}
+
+// Build returns the statement and its named parameters.
+func (b *UpsertBuilder) Build() (*Statement, error) {
+	p := newParams()
+	if len(b.values) == 0 {
+		p.fail("an upsert must have at least one value")
+	}
+
+	var sb strings.Builder
+	sb.WriteString("UPSERT INTO")
+	sb.WriteString(b.renderKeyspace(p))
+	sb.WriteString(" (KEY, VALUE) VALUES ")
+
+	values := make([]string, len(b.values))
+	for i, value := range b.values {
+		values[i] = "(" + p.bind(value.key) + ", " + p.bind(value.value) + ")"
+	}
+	sb.WriteString(strings.Join(values, ", "))
+	sb.WriteString(b.renderReturning(p))
+
+	return p.statement(sb.String())
+}
@@ -24,6 +24,13 @@ type GetResult struct {
 	flags      uint32
 	contents   []byte
 	expiry     *time.Duration
+	isReplica  bool
+}
+
+// IsReplica returns whether or not this result came from a replica server. A GetResult returned by Get can only
+// have come from a replica when the read was hedged.
+func (d *GetResult) IsReplica() bool {
+	return d.isReplica
 }
 
 // Content assigns the value of the result into the valuePtr using default decoding.
@@ -329,10 +336,4 @@ func (mr CounterResult) Content() uint64 {
 // GetReplicaResult is the return type of GetReplica operations.
 type GetReplicaResult struct {
 	GetResult
-	isReplica bool
-}
-
-// IsReplica returns whether or not this result came from a replica server.
-func (r *GetReplicaResult) IsReplica() bool {
-	return r.isReplica
 }
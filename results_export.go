@@ -0,0 +1,540 @@
+package gocb
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ResultExportOptions is the set of options available when writing query or analytics results to an io.Writer.
+// VOLATILE: This API is subject to change at any time.
+type ResultExportOptions struct {
+	// Context cancels the export, the results are closed as soon as it is done.
+	Context context.Context
+	// Columns are the fields written as CSV columns, any other fields of the rows are not written. When not set
+	// the columns are sampled from the rows: up to the first 1000 rows are held in memory and the columns are
+	// the fields named by the signature of the result followed by any other fields of those rows. The export
+	// fails if a later row has a field which is not one of the sampled columns. Results which are served from
+	// the query result cache provide their signature up front, their rows are not sampled unless the signature
+	// contains a wildcard.
+	Columns []string
+	// OmitTrailer stops the metadata trailer being written once every row has been written.
+	OmitTrailer bool
+}
+
+// jsonResultTrailer is the subset of a query response's metadata which is written once the rows have been
+// written.
+type jsonResultTrailer struct {
+	RequestID       string          `json:"requestID,omitempty"`
+	ClientContextID string          `json:"clientContextID,omitempty"`
+	Status          string          `json:"status,omitempty"`
+	Metrics         json.RawMessage `json:"metrics,omitempty"`
+	Warnings        json.RawMessage `json:"warnings,omitempty"`
+}
+
+type jsonResultTrailerLine struct {
+	Meta jsonResultTrailer `json:"meta"`
+}
+
+type exportRowReader interface {
+	NextRow() []byte
+	Err() error
+	MetaData() ([]byte, error)
+	Close() error
+}
+
+// earlyMetadataReader is implemented by readers which can return fields of the metadata, such as the signature
+// of a query, before the rows have been read. Readers of cached query results implement it, the readers of
+// gocbcore do not expose the metadata which the server sends before the rows.
+type earlyMetadataReader interface {
+	EarlyMetadata(key string) json.RawMessage
+}
+
+// readerEarlyMetadata returns the value of a field of the metadata of reader before its rows have been read, or
+// nil if it is not available.
+func readerEarlyMetadata(reader interface{}, key string) json.RawMessage {
+	if earlyReader, ok := reader.(earlyMetadataReader); ok {
+		return earlyReader.EarlyMetadata(key)
+	}
+	return nil
+}
+
+// rowExporter writes each row of a result as it is read. finishRows is called with the metadata of the result
+// once every row has been read.
+type rowExporter interface {
+	writeRow(row []byte) error
+	finishRows(meta []byte) error
+	writeTrailer(trailer []byte) error
+	flush() error
+}
+
+func exportRows(reader exportRowReader, exporter rowExporter, opts *ResultExportOptions) error {
+	if opts == nil {
+		opts = &ResultExportOptions{}
+	}
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	var closeOnce sync.Once
+	var closeErr error
+	closeReader := func() error {
+		closeOnce.Do(func() {
+			closeErr = reader.Close()
+		})
+		return closeErr
+	}
+
+	// The reader is closed as soon as the context is done, rather than once the next row arrives, so that an
+	// export blocked waiting for a row is interrupted.
+	if ctx.Done() != nil {
+		doneCh := make(chan struct{})
+		defer close(doneCh)
+
+		go func() {
+			select {
+			case <-ctx.Done():
+				if err := closeReader(); err != nil {
+					logDebugf("Failed to close results after export was cancelled: %s", err)
+				}
+			case <-doneCh:
+			}
+		}()
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			if closeErr := closeReader(); closeErr != nil {
+				logDebugf("Failed to close results after export was cancelled: %s", closeErr)
+			}
+			return err
+		}
+
+		row := reader.NextRow()
+		if row == nil {
+			break
+		}
+
+		if err := exporter.writeRow(row); err != nil {
+			if closeErr := closeReader(); closeErr != nil {
+				logDebugf("Failed to close results after export failed: %s", closeErr)
+			}
+			return err
+		}
+	}
+
+	// Every failure from here on also closes the reader, as is done whilst reading the rows.
+	fail := func(err error) error {
+		if closeErr := closeReader(); closeErr != nil {
+			logDebugf("Failed to close results after export failed: %s", closeErr)
+		}
+		return err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return fail(err)
+	}
+
+	if err := reader.Err(); err != nil {
+		return fail(err)
+	}
+
+	metaBytes, err := reader.MetaData()
+	if err != nil {
+		return fail(err)
+	}
+
+	if err := exporter.finishRows(metaBytes); err != nil {
+		return fail(err)
+	}
+
+	if !opts.OmitTrailer {
+		var trailer jsonResultTrailer
+		if err := json.Unmarshal(metaBytes, &trailer); err != nil {
+			return fail(wrapError(err, "failed to parse result metadata"))
+		}
+
+		trailerBytes, err := json.Marshal(jsonResultTrailerLine{Meta: trailer})
+		if err != nil {
+			return fail(err)
+		}
+
+		if err := exporter.writeTrailer(trailerBytes); err != nil {
+			return fail(err)
+		}
+	}
+
+	if err := exporter.flush(); err != nil {
+		return fail(err)
+	}
+
+	return closeReader()
+}
+
+type jsonLinesExporter struct {
+	w   io.Writer
+	buf bytes.Buffer
+}
+
+func (e *jsonLinesExporter) writeLine(line []byte) error {
+	e.buf.Reset()
+	// Rows may be pretty printed by the server, they are compacted so that each is written on a single line.
+	if err := json.Compact(&e.buf, line); err != nil {
+		return wrapError(err, "failed to compact row")
+	}
+	e.buf.WriteByte('\n')
+
+	_, err := e.w.Write(e.buf.Bytes())
+	return err
+}
+
+func (e *jsonLinesExporter) writeRow(row []byte) error {
+	return e.writeLine(row)
+}
+
+func (e *jsonLinesExporter) finishRows(meta []byte) error {
+	return nil
+}
+
+func (e *jsonLinesExporter) writeTrailer(trailer []byte) error {
+	return e.writeLine(trailer)
+}
+
+func (e *jsonLinesExporter) flush() error {
+	return nil
+}
+
+// csvRawColumn is the column used when rows are not JSON objects, such as for SELECT RAW statements. It matches
+// the name which the query service gives to unnamed projections.
+const csvRawColumn = "$1"
+
+// csvMaxBufferedRows is the number of rows sampled to determine the columns when they were not specified and
+// cannot be determined from the signature alone.
+const csvMaxBufferedRows = 1000
+
+type csvExporter struct {
+	w       *csv.Writer
+	columns []string
+	record  []string
+	raw     bool
+
+	// earlyMetadata returns the signature of the result before the first row when the results provide it.
+	earlyMetadata    func(key string) json.RawMessage
+	checkedSignature bool
+	signature        json.RawMessage
+	maxBufferedRows  int
+	// sampledColumns is set once the columns have been determined from a sample of the rows, later rows with
+	// other fields cannot be written.
+	sampledColumns map[string]struct{}
+
+	// rows holds the rows which are used to determine the columns when they were not specified.
+	rows [][]byte
+}
+
+// jsonObjectKeys returns the keys of a JSON object in the order that they appear, or nil if row is not an object.
+func jsonObjectKeys(row []byte) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(row))
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := tok.(json.Delim); !ok || delim != '{' {
+		return nil, nil
+	}
+
+	keys := []string{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, tok.(string))
+
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, err
+		}
+	}
+
+	return keys, nil
+}
+
+// csvValue formats a JSON value as a CSV field, strings are written without quoting and other values are
+// written as JSON. Null and missing values are written as empty fields.
+func csvValue(value json.RawMessage) (string, error) {
+	if len(value) == 0 || string(value) == "null" {
+		return "", nil
+	}
+
+	if value[0] == '"' {
+		var str string
+		if err := json.Unmarshal(value, &str); err != nil {
+			return "", err
+		}
+		return str, nil
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, value); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (e *csvExporter) writeRow(row []byte) error {
+	if e.columns == nil && !e.checkedSignature {
+		e.checkedSignature = true
+		if e.earlyMetadata != nil {
+			e.signature = e.earlyMetadata("signature")
+		}
+
+		if csvSignatureHasColumns(e.signature) {
+			if err := e.setColumns(nil); err != nil {
+				return err
+			}
+		}
+	}
+
+	if e.columns == nil {
+		e.rows = append(e.rows, append([]byte(nil), row...))
+		if len(e.rows) < e.maxBufferedRows {
+			return nil
+		}
+
+		if err := e.setColumns(nil); err != nil {
+			return err
+		}
+		if !e.raw {
+			e.sampledColumns = make(map[string]struct{}, len(e.columns))
+			for _, column := range e.columns {
+				e.sampledColumns[column] = struct{}{}
+			}
+		}
+
+		return e.writeBufferedRows()
+	}
+
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	return e.writeRecord(row)
+}
+
+// csvSignatureHasColumns returns whether the columns can be determined from signature alone, without the rows.
+func csvSignatureHasColumns(signature json.RawMessage) bool {
+	if len(signature) == 0 || string(signature) == "null" {
+		return false
+	}
+
+	keys, err := jsonObjectKeys(signature)
+	if err != nil {
+		// The error is returned once the columns are determined from the signature and the rows.
+		return false
+	}
+	for _, key := range keys {
+		if key == "*" {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e *csvExporter) setColumns(meta []byte) error {
+	signature := e.signature
+	if signature == nil && meta != nil {
+		var metaData struct {
+			Signature json.RawMessage `json:"signature"`
+		}
+		if err := json.Unmarshal(meta, &metaData); err != nil {
+			return wrapError(err, "failed to parse result metadata")
+		}
+		signature = metaData.Signature
+	}
+
+	columns, raw, err := csvColumns(signature, e.rows)
+	if err != nil {
+		return err
+	}
+	e.columns = columns
+	e.raw = raw
+
+	return nil
+}
+
+// writeBufferedRows writes the header, determined from the signature and the rows which have been held back,
+// followed by those rows.
+func (e *csvExporter) writeBufferedRows() error {
+	if e.columns == nil {
+		if err := e.setColumns(nil); err != nil {
+			return err
+		}
+	}
+
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	for _, row := range e.rows {
+		if err := e.writeRecord(row); err != nil {
+			return err
+		}
+	}
+	e.rows = nil
+
+	return nil
+}
+
+func (e *csvExporter) writeHeader() error {
+	if e.record != nil {
+		return nil
+	}
+
+	if err := e.w.Write(e.columns); err != nil {
+		return err
+	}
+	e.record = make([]string, len(e.columns))
+
+	return nil
+}
+
+func (e *csvExporter) writeRecord(row []byte) error {
+	var fields map[string]json.RawMessage
+	if e.raw {
+		fields = map[string]json.RawMessage{csvRawColumn: row}
+	} else if err := json.Unmarshal(row, &fields); err != nil {
+		return wrapError(err, "failed to parse row")
+	}
+
+	for field := range fields {
+		if _, ok := e.sampledColumns[field]; !ok && e.sampledColumns != nil {
+			return makeInvalidArgumentsError(fmt.Sprintf(
+				"row has field %s which is not in the first %d rows, Columns must be set to export it",
+				field, e.maxBufferedRows))
+		}
+	}
+
+	for i, column := range e.columns {
+		value, err := csvValue(fields[column])
+		if err != nil {
+			return wrapError(err, "failed to format field "+column)
+		}
+		e.record[i] = value
+	}
+
+	return e.w.Write(e.record)
+}
+
+// finishRows writes the header, and any rows which were held back to determine it, so that a header is
+// written even when there are no rows.
+func (e *csvExporter) finishRows(meta []byte) error {
+	if e.columns == nil {
+		if err := e.setColumns(meta); err != nil {
+			return err
+		}
+	}
+
+	return e.writeBufferedRows()
+}
+
+// csvColumns returns the columns of a result, which are the fields named by its signature followed by any other
+// fields of the rows in the order that they first appear. A single raw column is used when the signature or
+// any row is not an object, such as for SELECT RAW statements.
+func csvColumns(signature json.RawMessage, rows [][]byte) ([]string, bool, error) {
+	columns := []string{}
+	seen := make(map[string]struct{})
+	addColumns := func(keys []string) {
+		for _, key := range keys {
+			if _, ok := seen[key]; ok {
+				continue
+			}
+			seen[key] = struct{}{}
+			columns = append(columns, key)
+		}
+	}
+
+	if len(signature) > 0 && string(signature) != "null" {
+		keys, err := jsonObjectKeys(signature)
+		if err != nil {
+			return nil, false, wrapError(err, "failed to parse result signature")
+		}
+		if keys == nil {
+			return []string{csvRawColumn}, true, nil
+		}
+		// A wildcard is expanded by the fields of the rows.
+		seen["*"] = struct{}{}
+		addColumns(keys)
+	}
+
+	for _, row := range rows {
+		keys, err := jsonObjectKeys(row)
+		if err != nil {
+			return nil, false, wrapError(err, "failed to parse row")
+		}
+		if keys == nil {
+			return []string{csvRawColumn}, true, nil
+		}
+		addColumns(keys)
+	}
+
+	return columns, false, nil
+}
+
+// writeTrailer writes the metadata as a final record with a first field of #meta, so that it can be told apart
+// from the rows.
+func (e *csvExporter) writeTrailer(trailer []byte) error {
+	return e.w.Write([]string{"#meta", string(trailer)})
+}
+
+func (e *csvExporter) flush() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+func newCSVExporter(w io.Writer, reader exportRowReader, opts *ResultExportOptions) *csvExporter {
+	exporter := &csvExporter{
+		w:               csv.NewWriter(w),
+		maxBufferedRows: csvMaxBufferedRows,
+	}
+	exporter.earlyMetadata = func(key string) json.RawMessage {
+		return readerEarlyMetadata(reader, key)
+	}
+	if opts != nil && len(opts.Columns) > 0 {
+		exporter.columns = opts.Columns
+	}
+	return exporter
+}
+
+// WriteJSONLines writes each row of the results to w as a line of JSON, followed by a line containing the
+// metadata of the query under a "meta" field. The results are closed once they have been written.
+// VOLATILE: This API is subject to change at any time.
+func (r *QueryResult) WriteJSONLines(w io.Writer, opts *ResultExportOptions) error {
+	return exportRows(r.reader, &jsonLinesExporter{w: w}, opts)
+}
+
+// WriteCSV writes each row of the results to w as a CSV record, preceded by a header and followed by a
+// #meta record containing the metadata of the query as JSON. The results are closed once they have been written.
+// VOLATILE: This API is subject to change at any time.
+func (r *QueryResult) WriteCSV(w io.Writer, opts *ResultExportOptions) error {
+	return exportRows(r.reader, newCSVExporter(w, r.reader, opts), opts)
+}
+
+// WriteJSONLines writes each row of the results to w as a line of JSON, followed by a line containing the
+// metadata of the query under a "meta" field. The results are closed once they have been written.
+// VOLATILE: This API is subject to change at any time.
+func (r *AnalyticsResult) WriteJSONLines(w io.Writer, opts *ResultExportOptions) error {
+	return exportRows(r.reader, &jsonLinesExporter{w: w}, opts)
+}
+
+// WriteCSV writes each row of the results to w as a CSV record, preceded by a header and followed by a
+// #meta record containing the metadata of the query as JSON. The results are closed once they have been written.
+// VOLATILE: This API is subject to change at any time.
+func (r *AnalyticsResult) WriteCSV(w io.Writer, opts *ResultExportOptions) error {
+	return exportRows(r.reader, newCSVExporter(w, r.reader, opts), opts)
+}
@@ -0,0 +1,224 @@
+package gocb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+func (suite *UnitTestSuite) TestQueryResultWriteJSONLines() {
+	result := newQueryResult(&testQueryRowsReader{
+		rows: [][]byte{
+			[]byte("{\n  \"id\": \"a\",\n  \"count\": 1\n}"),
+			[]byte(`{"id": "b", "count": 2}`),
+		},
+		meta: []byte(`{"requestID": "req", "status": "success", "metrics": {"resultCount": 2}, "signature": {"*": "*"}}`),
+	})
+
+	var buf bytes.Buffer
+	err := result.WriteJSONLines(&buf, nil)
+	suite.Require().Nil(err, err)
+
+	suite.Assert().Equal(`{"id":"a","count":1}`+"\n"+
+		`{"id":"b","count":2}`+"\n"+
+		`{"meta":{"requestID":"req","status":"success","metrics":{"resultCount":2}}}`+"\n", buf.String())
+}
+
+func (suite *UnitTestSuite) TestQueryResultWriteCSV() {
+	result := newQueryResult(&testQueryRowsReader{
+		rows: [][]byte{
+			[]byte(`{"name": "hotel, \"grand\"", "rating": 4.5, "open": true, "tags": ["a", "b"], "owner": null}`),
+			[]byte(`{"rating": 3, "name": "inn", "extra": "late"}`),
+		},
+		meta: []byte(`{"requestID": "req", "warnings": [{"code": 1, "msg": "warn"}]}`),
+	})
+
+	var buf bytes.Buffer
+	err := result.WriteCSV(&buf, nil)
+	suite.Require().Nil(err, err)
+
+	suite.Assert().Equal("name,rating,open,tags,owner,extra\n"+
+		"\"hotel, \"\"grand\"\"\",4.5,true,\"[\"\"a\"\",\"\"b\"\"]\",,\n"+
+		"inn,3,,,,late\n"+
+		"#meta,\"{\"\"meta\"\":{\"\"requestID\"\":\"\"req\"\",\"\"warnings\"\":[{\"\"code\"\":1,\"\"msg\"\":\"\"warn\"\"}]}}\"\n",
+		buf.String())
+}
+
+func (suite *UnitTestSuite) TestQueryResultWriteCSVSignature() {
+	result := newQueryResult(&testQueryRowsReader{
+		rows: [][]byte{
+			[]byte(`{"name": "inn"}`),
+		},
+		meta: []byte(`{"requestID": "req", "signature": {"rating": "json", "name": "json"}}`),
+	})
+
+	var buf bytes.Buffer
+	err := result.WriteCSV(&buf, &ResultExportOptions{OmitTrailer: true})
+	suite.Require().Nil(err, err)
+
+	// The columns are ordered by the signature and include fields missing from every row.
+	suite.Assert().Equal("rating,name\n,inn\n", buf.String())
+}
+
+func (suite *UnitTestSuite) TestQueryResultWriteCSVEmpty() {
+	result := newQueryResult(&testQueryRowsReader{
+		meta: []byte(`{"requestID": "req", "signature": {"name": "json", "city": "json"}}`),
+	})
+
+	var buf bytes.Buffer
+	err := result.WriteCSV(&buf, &ResultExportOptions{OmitTrailer: true})
+	suite.Require().Nil(err, err)
+
+	suite.Assert().Equal("name,city\n", buf.String())
+}
+
+func (suite *UnitTestSuite) TestQueryResultWriteCSVColumns() {
+	result := newQueryResult(&testQueryRowsReader{
+		rows: [][]byte{
+			[]byte(`{"name": "inn", "rating": 3}`),
+		},
+		meta: []byte(`{"requestID": "req"}`),
+	})
+
+	var buf bytes.Buffer
+	err := result.WriteCSV(&buf, &ResultExportOptions{
+		Columns:     []string{"rating", "name"},
+		OmitTrailer: true,
+	})
+	suite.Require().Nil(err, err)
+
+	suite.Assert().Equal("rating,name\n3,inn\n", buf.String())
+}
+
+func (suite *UnitTestSuite) TestAnalyticsResultWriteCSVRaw() {
+	result := newAnalyticsResult(&testQueryRowsReader{
+		rows: [][]byte{
+			[]byte(`"a"`),
+			[]byte(`1`),
+		},
+		meta: []byte(`{"requestID": "req"}`),
+	})
+
+	var buf bytes.Buffer
+	err := result.WriteCSV(&buf, &ResultExportOptions{OmitTrailer: true})
+	suite.Require().Nil(err, err)
+
+	suite.Assert().Equal("$1\na\n1\n", buf.String())
+}
+
+func (suite *UnitTestSuite) TestAnalyticsResultWriteJSONLinesCancelled() {
+	result := newAnalyticsResult(&testQueryRowsReader{
+		rows: [][]byte{
+			[]byte(`{"id": "a"}`),
+		},
+		meta: []byte(`{"requestID": "req"}`),
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var buf bytes.Buffer
+	err := result.WriteJSONLines(&buf, &ResultExportOptions{Context: ctx})
+	suite.Assert().Equal(context.Canceled, err)
+	suite.Assert().Empty(buf.String())
+}
+
+type blockingExportRowReader struct {
+	testQueryRowsReader
+	closedCh chan struct{}
+}
+
+func (r *blockingExportRowReader) NextRow() []byte {
+	<-r.closedCh
+	return nil
+}
+
+func (r *blockingExportRowReader) Close() error {
+	close(r.closedCh)
+	return nil
+}
+
+func (suite *UnitTestSuite) TestQueryResultWriteJSONLinesCancelledWhileBlocked() {
+	reader := &blockingExportRowReader{closedCh: make(chan struct{})}
+	result := newQueryResult(reader)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	var buf bytes.Buffer
+	err := result.WriteJSONLines(&buf, &ResultExportOptions{Context: ctx})
+	suite.Assert().Equal(context.DeadlineExceeded, err)
+	suite.Assert().Empty(buf.String())
+}
+
+type earlyMetadataExportRowReader struct {
+	testQueryRowsReader
+	signature json.RawMessage
+	closed    bool
+}
+
+func (r *earlyMetadataExportRowReader) EarlyMetadata(key string) json.RawMessage {
+	if key == "signature" {
+		return r.signature
+	}
+	return nil
+}
+
+func (r *earlyMetadataExportRowReader) Close() error {
+	r.closed = true
+	return nil
+}
+
+func (suite *UnitTestSuite) TestQueryResultWriteCSVEarlySignature() {
+	reader := &earlyMetadataExportRowReader{
+		signature: json.RawMessage(`{"name": "json", "rating": "json"}`),
+	}
+
+	var buf bytes.Buffer
+	exporter := newCSVExporter(&buf, reader, nil)
+	suite.Require().Nil(exporter.writeRow([]byte(`{"rating": 4, "name": "inn", "extra": "late"}`)))
+	suite.Require().Nil(exporter.flush())
+
+	// The row is written as soon as it is read rather than being held until the metadata arrives.
+	suite.Assert().Equal("name,rating\ninn,4\n", buf.String())
+	suite.Assert().Empty(exporter.rows)
+}
+
+func (suite *UnitTestSuite) TestQueryResultWriteCSVBufferLimit() {
+	reader := &testQueryRowsReader{}
+
+	var buf bytes.Buffer
+	exporter := newCSVExporter(&buf, reader, nil)
+	exporter.maxBufferedRows = 2
+	suite.Require().Nil(exporter.writeRow([]byte(`{"name": "inn"}`)))
+	suite.Require().Nil(exporter.writeRow([]byte(`{"name": "hotel", "rating": 4}`)))
+	suite.Require().Nil(exporter.writeRow([]byte(`{"rating": 3}`)))
+	suite.Require().Nil(exporter.flush())
+
+	// The columns are sampled from the rows held back, later rows are written as they are read.
+	suite.Assert().Equal("name,rating\ninn,\nhotel,4\n,3\n", buf.String())
+	suite.Assert().Empty(exporter.rows)
+
+	// A field which was not sampled fails the export rather than being dropped.
+	err := exporter.writeRow([]byte(`{"name": "hostel", "extra": "late"}`))
+	suite.Assert().True(errors.Is(err, ErrInvalidArgument), err)
+}
+
+func (suite *UnitTestSuite) TestQueryResultWriteJSONLinesClosesOnError() {
+	reader := &earlyMetadataExportRowReader{
+		testQueryRowsReader: testQueryRowsReader{
+			rows: [][]byte{
+				[]byte(`{"id": "a"}`),
+			},
+			err: errors.New("stream failed"),
+		},
+	}
+	result := newQueryResult(reader)
+
+	var buf bytes.Buffer
+	err := result.WriteJSONLines(&buf, nil)
+	suite.Assert().Equal(reader.err, err)
+	suite.Assert().True(reader.closed)
+}
@@ -175,7 +175,18 @@ func newRetryStrategyWrapper(strategy RetryStrategy) *retryStrategyWrapper {
 }
 
 type retryStrategyWrapper struct {
-	wrapped RetryStrategy
+	wrapped  RetryStrategy
+	observer RetryObserver
+	meter    *meterWrapper
+}
+
+// withStrategy returns a wrapper around strategy which reports to the same observer and meter as this wrapper.
+func (rs *retryStrategyWrapper) withStrategy(strategy RetryStrategy) *retryStrategyWrapper {
+	return &retryStrategyWrapper{
+		wrapped:  strategy,
+		observer: rs.observer,
+		meter:    rs.meter,
+	}
 }
 
 // RetryAfter calculates and returns a RetryAction describing how long to wait before retrying an operation.
@@ -184,9 +195,50 @@ func (rs *retryStrategyWrapper) RetryAfter(req gocbcore.RetryRequest, reason goc
 		req: req,
 	}
 	wrappedAction := rs.wrapped.RetryAfter(wreq, RetryReason(reason))
+
+	if wrappedAction != nil && wrappedAction.Duration() > 0 {
+		rs.meter.recordRetry(RetryReason(reason))
+	}
+
+	if rs.observer != nil {
+		var delay time.Duration
+		if wrappedAction != nil {
+			delay = wrappedAction.Duration()
+		}
+
+		rs.observer(RetryEvent{
+			Identifier: req.Identifier(),
+			Reason:     RetryReason(reason),
+			Attempt:    req.RetryAttempts(),
+			Delay:      delay,
+		})
+	}
+
 	return gocbcore.RetryAction(wrappedAction)
 }
 
+// RetryEvent describes a single decision on whether to retry a request.
+type RetryEvent struct {
+	// Identifier is the identifier of the request, such as the operation ID of a key-value request.
+	Identifier string
+
+	// Reason is the reason that the request failed and may be retried.
+	Reason RetryReason
+
+	// Attempt is the number of times that the request has already been retried.
+	Attempt uint32
+
+	// Delay is the duration that will be waited before the request is retried, 0 indicates that the
+	// request will not be retried.
+	Delay time.Duration
+}
+
+// RetryObserver is invoked for every decision on whether to retry a request which is made by a RetryStrategy.
+// Requests which fail for a reason that always retries, such as a not my vbucket response, are retried by
+// gocbcore without consulting the RetryStrategy and so are not observed. It is called synchronously within the
+// request pipeline so must not block.
+type RetryObserver func(event RetryEvent)
+
 // BackoffCalculator defines how backoff durations will be calculated by the retry API.
 type BackoffCalculator func(retryAttempts uint32) time.Duration
 
@@ -215,16 +267,16 @@ func (rs *BestEffortRetryStrategy) RetryAfter(req RetryRequest, reason RetryReas
 	return &NoRetryRetryAction{}
 }
 
-// failFastRetryStrategy represents a strategy that will never retry.
-type failFastRetryStrategy struct {
+// FailFastRetryStrategy represents a strategy that will never retry.
+type FailFastRetryStrategy struct {
 }
 
-// newFailFastRetryStrategy returns a new FailFastRetryStrategy.
-func newFailFastRetryStrategy() *failFastRetryStrategy {
-	return &failFastRetryStrategy{}
+// NewFailFastRetryStrategy returns a new FailFastRetryStrategy.
+func NewFailFastRetryStrategy() *FailFastRetryStrategy {
+	return &FailFastRetryStrategy{}
 }
 
 // RetryAfter calculates and returns a RetryAction describing how long to wait before retrying an operation.
-func (rs *failFastRetryStrategy) RetryAfter(req RetryRequest, reason RetryReason) RetryAction {
+func (rs *FailFastRetryStrategy) RetryAfter(req RetryRequest, reason RetryReason) RetryAction {
 	return &NoRetryRetryAction{}
 }
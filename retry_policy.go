@@ -0,0 +1,310 @@
+package gocb
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/couchbase/gocbcore/v9"
+)
+
+// RetryJitter specifies how randomness is applied to the backoff durations calculated by a RetryPolicy.
+type RetryJitter uint
+
+const (
+	// RetryJitterNone indicates that the calculated backoff should be used as-is.
+	RetryJitterNone = RetryJitter(0)
+
+	// RetryJitterFull indicates that a random duration between zero and the calculated backoff should be used.
+	RetryJitterFull = RetryJitter(1)
+
+	// RetryJitterDecorrelated indicates that a random duration between the initial backoff and three times the
+	// previous backoff of the request should be used, limited to the calculated backoff.
+	RetryJitterDecorrelated = RetryJitter(2)
+)
+
+// RetryBudget is a token bucket which limits the rate at which retries may be performed. Sharing a single
+// RetryBudget between every RetryPolicy used with a cluster prevents a large number of failing requests from
+// amplifying the load on a cluster which is already struggling.
+// VOLATILE: This API is subject to change at any time.
+type RetryBudget struct {
+	lock       sync.Mutex
+	tokens     float64
+	maxTokens  float64
+	refillRate float64
+	lastRefill time.Time
+}
+
+// NewRetryBudget creates a new RetryBudget which allows bursts of up to maxRetries retries, and which is
+// refilled at retriesPerSecond.
+func NewRetryBudget(maxRetries uint32, retriesPerSecond float64) *RetryBudget {
+	return &RetryBudget{
+		tokens:     float64(maxRetries),
+		maxTokens:  float64(maxRetries),
+		refillRate: retriesPerSecond,
+		lastRefill: time.Now(),
+	}
+}
+
+// Available returns the number of retries which can currently be performed.
+func (b *RetryBudget) Available() uint32 {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refill(time.Now())
+	return uint32(b.tokens)
+}
+
+func (b *RetryBudget) refill(now time.Time) {
+	elapsed := now.Sub(b.lastRefill)
+	if elapsed <= 0 {
+		return
+	}
+
+	b.tokens += elapsed.Seconds() * b.refillRate
+	if b.tokens > b.maxTokens {
+		b.tokens = b.maxTokens
+	}
+	b.lastRefill = now
+}
+
+func (b *RetryBudget) tryAcquire() bool {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.refill(time.Now())
+	if b.tokens < 1 {
+		return false
+	}
+
+	b.tokens--
+	return true
+}
+
+// retryPolicyStateSweepInterval is the number of retries between sweeps of the tracked request state.
+const retryPolicyStateSweepInterval = 1024
+
+type retryPolicyRequestState struct {
+	firstSeen   time.Time
+	lastSeen    time.Time
+	lastBackoff time.Duration
+}
+
+// RetryPolicy is a RetryStrategy which is composed of a default backoff, along with backoffs or fail-fast
+// decisions for specific retry reasons, caps on the number of attempts and elapsed time, jitter and an
+// optional RetryBudget. A RetryPolicy with no options behaves as a BestEffortRetryStrategy. A RetryPolicy
+// must be fully configured before it is used, it is then safe for concurrent use.
+// VOLATILE: This API is subject to change at any time.
+type RetryPolicy struct {
+	backoff        BackoffCalculator
+	reasonBackoffs map[RetryReason]BackoffCalculator
+	failFast       map[RetryReason]struct{}
+	maxAttempts    uint32
+	maxElapsed     time.Duration
+	jitter         RetryJitter
+	budget         *RetryBudget
+
+	lock         sync.Mutex
+	requests     map[interface{}]*retryPolicyRequestState
+	sinceSweep   uint32
+	randomSource func(n int64) int64
+}
+
+// NewRetryPolicy returns a new RetryPolicy which will use a controlled backoff for every retry reason.
+func NewRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		backoff:        BackoffCalculator(gocbcore.ExponentialBackoff(1*time.Millisecond, 500*time.Millisecond, 2)),
+		reasonBackoffs: make(map[RetryReason]BackoffCalculator),
+		failFast:       make(map[RetryReason]struct{}),
+		requests:       make(map[interface{}]*retryPolicyRequestState),
+		randomSource:   rand.Int63n,
+	}
+}
+
+// Backoff sets the backoff used for retry reasons which do not have a specific backoff.
+func (p *RetryPolicy) Backoff(calculator BackoffCalculator) *RetryPolicy {
+	p.backoff = calculator
+	return p
+}
+
+// ReasonBackoff sets the backoff used when retrying for any of the specified reasons.
+func (p *RetryPolicy) ReasonBackoff(calculator BackoffCalculator, reasons ...RetryReason) *RetryPolicy {
+	for _, reason := range reasons {
+		p.reasonBackoffs[reason] = calculator
+		delete(p.failFast, reason)
+	}
+	return p
+}
+
+// FailFast specifies that requests should never be retried for any of the specified reasons.
+func (p *RetryPolicy) FailFast(reasons ...RetryReason) *RetryPolicy {
+	for _, reason := range reasons {
+		p.failFast[reason] = struct{}{}
+		delete(p.reasonBackoffs, reason)
+	}
+	return p
+}
+
+// MaxAttempts sets the maximum number of times that a request will be retried, 0 indicates no limit.
+func (p *RetryPolicy) MaxAttempts(attempts uint32) *RetryPolicy {
+	p.maxAttempts = attempts
+	return p
+}
+
+// MaxElapsed sets the maximum duration since the first retry of a request after which it will no longer
+// be retried, 0 indicates no limit. Note that a request will always be limited by its timeout.
+func (p *RetryPolicy) MaxElapsed(elapsed time.Duration) *RetryPolicy {
+	p.maxElapsed = elapsed
+	return p
+}
+
+// Jitter sets how randomness is applied to the calculated backoff durations.
+func (p *RetryPolicy) Jitter(jitter RetryJitter) *RetryPolicy {
+	p.jitter = jitter
+	return p
+}
+
+// Budget sets the RetryBudget which must allow a retry before it is performed.
+func (p *RetryPolicy) Budget(budget *RetryBudget) *RetryPolicy {
+	p.budget = budget
+	return p
+}
+
+// RetryAfter calculates and returns a RetryAction describing how long to wait before retrying an operation.
+func (p *RetryPolicy) RetryAfter(req RetryRequest, reason RetryReason) RetryAction {
+	if _, ok := p.failFast[reason]; ok {
+		p.forgetRequest(req)
+		return &NoRetryRetryAction{}
+	}
+
+	if !req.Idempotent() && !reason.AllowsNonIdempotentRetry() {
+		p.forgetRequest(req)
+		return &NoRetryRetryAction{}
+	}
+
+	attempts := req.RetryAttempts()
+	if p.maxAttempts > 0 && attempts >= p.maxAttempts {
+		p.forgetRequest(req)
+		return &NoRetryRetryAction{}
+	}
+
+	calculator := p.backoff
+	if reasonCalculator, ok := p.reasonBackoffs[reason]; ok {
+		calculator = reasonCalculator
+	}
+	backoff := calculator(attempts)
+	if p.jitter == RetryJitterFull {
+		backoff = time.Duration(p.randomSource(int64(backoff) + 1))
+	}
+
+	if p.maxElapsed > 0 || p.jitter == RetryJitterDecorrelated {
+		var ok bool
+		backoff, ok = p.trackRequest(req, calculator(0), backoff)
+		if !ok {
+			return &NoRetryRetryAction{}
+		}
+	}
+
+	if p.budget != nil && !p.budget.tryAcquire() {
+		p.forgetRequest(req)
+		return &NoRetryRetryAction{}
+	}
+
+	// A duration of 0 indicates that the request should not be retried.
+	if backoff <= 0 {
+		backoff = time.Microsecond
+	}
+
+	return &WithDurationRetryAction{WithDuration: backoff}
+}
+
+func (p *RetryPolicy) decorrelatedJitter(base, last, limit time.Duration) time.Duration {
+	if last < base {
+		last = base
+	}
+
+	upper := 3 * last
+	backoff := base
+	if upper > base {
+		backoff = base + time.Duration(p.randomSource(int64(upper-base)+1))
+	}
+	if backoff > limit {
+		backoff = limit
+	}
+
+	return backoff
+}
+
+// retryRequestKey returns a key which identifies a request across all of its retries. The identifier of a KV
+// request is its opaque, which changes each time that it is dispatched, so the gocbcore request is used instead
+// whenever it is available.
+func retryRequestKey(req RetryRequest) interface{} {
+	if wrapped, ok := req.(*wrappedRetryRequest); ok {
+		return wrapped.req
+	}
+
+	return req.Identifier()
+}
+
+// trackRequest records a retry of a request, applying decorrelated jitter to the backoff and checking it
+// against the maximum elapsed time. It returns false if the request should no longer be retried.
+func (p *RetryPolicy) trackRequest(req RetryRequest, base, backoff time.Duration) (time.Duration, bool) {
+	now := time.Now()
+	key := retryRequestKey(req)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.sinceSweep++
+	if p.sinceSweep >= retryPolicyStateSweepInterval {
+		p.sinceSweep = 0
+		p.sweepRequests(now)
+	}
+
+	state, ok := p.requests[key]
+	if !ok || req.RetryAttempts() == 0 {
+		state = &retryPolicyRequestState{
+			firstSeen: now,
+		}
+		p.requests[key] = state
+	}
+	state.lastSeen = now
+
+	if p.jitter == RetryJitterDecorrelated {
+		backoff = p.decorrelatedJitter(base, state.lastBackoff, backoff)
+	}
+
+	if p.maxElapsed > 0 && now.Add(backoff).Sub(state.firstSeen) > p.maxElapsed {
+		delete(p.requests, key)
+		return 0, false
+	}
+
+	state.lastBackoff = backoff
+
+	return backoff, true
+}
+
+// sweepRequests removes the state of any requests which have not been retried recently, as requests which
+// succeed after being retried are never seen by the policy again.
+func (p *RetryPolicy) sweepRequests(now time.Time) {
+	expiry := time.Minute
+	if p.maxElapsed > expiry {
+		expiry = p.maxElapsed
+	}
+
+	for id, state := range p.requests {
+		if now.Sub(state.lastSeen) > expiry {
+			delete(p.requests, id)
+		}
+	}
+}
+
+func (p *RetryPolicy) forgetRequest(req RetryRequest) {
+	if p.maxElapsed == 0 && p.jitter != RetryJitterDecorrelated {
+		return
+	}
+
+	p.lock.Lock()
+	delete(p.requests, retryRequestKey(req))
+	p.lock.Unlock()
+}
@@ -1,6 +1,7 @@
 package gocb
 
 import (
+	"errors"
 	"time"
 
 	"github.com/couchbase/gocbcore/v9"
@@ -133,7 +134,7 @@ func (suite *UnitTestSuite) TestBestEffortRetryStrategy_RetryAfterDefaultCalcula
 }
 
 func (suite *UnitTestSuite) TestFailFastRetryStrategy_RetryAfterNoRetry() {
-	strategy := newFailFastRetryStrategy()
+	strategy := NewFailFastRetryStrategy()
 	action := strategy.RetryAfter(&mockRetryRequest{}, RetryReason(gocbcore.UnknownRetryReason))
 	if action.Duration() != 0 {
 		suite.T().Fatalf("Expected duration to be %d but was %d", 0, action.Duration())
@@ -141,7 +142,7 @@ func (suite *UnitTestSuite) TestFailFastRetryStrategy_RetryAfterNoRetry() {
 }
 
 func (suite *UnitTestSuite) TestFailFastRetryStrategy_RetryAfterAlwaysRetry() {
-	strategy := newFailFastRetryStrategy()
+	strategy := NewFailFastRetryStrategy()
 	action := strategy.RetryAfter(&mockRetryRequest{}, RetryReason(gocbcore.KVCollectionOutdatedRetryReason))
 	if action.Duration() != 0 {
 		suite.T().Fatalf("Expected duration to be %d but was %d", 0, action.Duration())
@@ -149,9 +150,198 @@ func (suite *UnitTestSuite) TestFailFastRetryStrategy_RetryAfterAlwaysRetry() {
 }
 
 func (suite *UnitTestSuite) TestFailFastRetryStrategy_RetryAfterAllowsNonIdempotent() {
-	strategy := newFailFastRetryStrategy()
+	strategy := NewFailFastRetryStrategy()
 	action := strategy.RetryAfter(&mockRetryRequest{}, RetryReason(gocbcore.KVLockedRetryReason))
 	if action.Duration() != 0 {
 		suite.T().Fatalf("Expected duration to be %d but was %d", 0, action.Duration())
 	}
 }
+
+func (suite *UnitTestSuite) TestRetryPolicy_Default() {
+	policy := NewRetryPolicy()
+
+	action := policy.RetryAfter(&mockRetryRequest{attempts: 0, idempotent: true}, ServiceResponseCodeIndicatedRetryReason)
+	suite.Assert().Equal(1*time.Millisecond, action.Duration())
+
+	action = policy.RetryAfter(&mockRetryRequest{attempts: 5, idempotent: true}, ServiceResponseCodeIndicatedRetryReason)
+	suite.Assert().Equal(32*time.Millisecond, action.Duration())
+
+	action = policy.RetryAfter(&mockRetryRequest{attempts: 0, idempotent: false}, SocketCloseInFlightRetryReason)
+	suite.Assert().Equal(time.Duration(0), action.Duration())
+
+	action = policy.RetryAfter(&mockRetryRequest{attempts: 0, idempotent: false}, KVLockedRetryReason)
+	suite.Assert().Equal(1*time.Millisecond, action.Duration())
+}
+
+func (suite *UnitTestSuite) TestRetryPolicy_PerReason() {
+	policy := NewRetryPolicy().
+		Backoff(mockBackoffCalculator).
+		ReasonBackoff(func(retryAttempts uint32) time.Duration {
+			return 100 * time.Millisecond
+		}, KVLockedRetryReason, SearchTooManyRequestsRetryReason).
+		FailFast(CircuitBreakerOpenRetryReason)
+
+	req := &mockRetryRequest{attempts: 3, idempotent: true}
+
+	suite.Assert().Equal(3*time.Millisecond, policy.RetryAfter(req, KVTemporaryFailureRetryReason).Duration())
+	suite.Assert().Equal(100*time.Millisecond, policy.RetryAfter(req, KVLockedRetryReason).Duration())
+	suite.Assert().Equal(100*time.Millisecond, policy.RetryAfter(req, SearchTooManyRequestsRetryReason).Duration())
+	suite.Assert().Equal(time.Duration(0), policy.RetryAfter(req, CircuitBreakerOpenRetryReason).Duration())
+
+	// A zero duration backoff must still result in a retry.
+	suite.Assert().True(policy.RetryAfter(&mockRetryRequest{idempotent: true}, KVTemporaryFailureRetryReason).Duration() > 0)
+}
+
+func (suite *UnitTestSuite) TestRetryPolicy_MaxAttempts() {
+	policy := NewRetryPolicy().MaxAttempts(3)
+
+	suite.Assert().NotEqual(time.Duration(0), policy.RetryAfter(&mockRetryRequest{attempts: 2, idempotent: true}, KVLockedRetryReason).Duration())
+	suite.Assert().Equal(time.Duration(0), policy.RetryAfter(&mockRetryRequest{attempts: 3, idempotent: true}, KVLockedRetryReason).Duration())
+}
+
+func (suite *UnitTestSuite) TestRetryPolicy_MaxElapsed() {
+	policy := NewRetryPolicy().
+		Backoff(func(retryAttempts uint32) time.Duration {
+			return 20 * time.Millisecond
+		}).
+		MaxElapsed(50 * time.Millisecond)
+
+	req := &mockRetryRequest{identifier: "req1", idempotent: true}
+	suite.Assert().Equal(20*time.Millisecond, policy.RetryAfter(req, KVLockedRetryReason).Duration())
+	req.IncrementRetryAttempts()
+	suite.Assert().Equal(20*time.Millisecond, policy.RetryAfter(req, KVLockedRetryReason).Duration())
+
+	time.Sleep(40 * time.Millisecond)
+	req.IncrementRetryAttempts()
+	suite.Assert().Equal(time.Duration(0), policy.RetryAfter(req, KVLockedRetryReason).Duration())
+	suite.Assert().Empty(policy.requests)
+
+	// Other requests should not be affected.
+	suite.Assert().Equal(20*time.Millisecond, policy.RetryAfter(&mockRetryRequest{identifier: "req2", idempotent: true}, KVLockedRetryReason).Duration())
+}
+
+func (suite *UnitTestSuite) TestRetryPolicy_MaxElapsedKV() {
+	policy := NewRetryPolicy().
+		Backoff(func(retryAttempts uint32) time.Duration {
+			return 20 * time.Millisecond
+		}).
+		MaxElapsed(50 * time.Millisecond)
+
+	// The identifier of a KV request is its opaque, which changes every time that it is dispatched.
+	coreReq := &mockGocbcoreRequest{identifier: "0x1", idempotent: true}
+	req := &wrappedRetryRequest{req: coreReq}
+	suite.Assert().Equal(20*time.Millisecond, policy.RetryAfter(req, KVLockedRetryReason).Duration())
+
+	time.Sleep(40 * time.Millisecond)
+	coreReq.attempts++
+	coreReq.identifier = "0x2"
+	suite.Assert().Equal(time.Duration(0), policy.RetryAfter(req, KVLockedRetryReason).Duration())
+	suite.Assert().Empty(policy.requests)
+}
+
+func (suite *UnitTestSuite) TestRetryPolicy_Jitter() {
+	var lastN int64
+	fullPolicy := NewRetryPolicy().
+		Backoff(func(retryAttempts uint32) time.Duration {
+			return 100 * time.Millisecond
+		}).
+		Jitter(RetryJitterFull)
+	fullPolicy.randomSource = func(n int64) int64 {
+		lastN = n
+		return n / 2
+	}
+
+	action := fullPolicy.RetryAfter(&mockRetryRequest{idempotent: true}, KVLockedRetryReason)
+	suite.Assert().Equal(int64(100*time.Millisecond)+1, lastN)
+	suite.Assert().Equal(50*time.Millisecond, action.Duration())
+
+	decorrelatedPolicy := NewRetryPolicy().
+		Backoff(func(retryAttempts uint32) time.Duration {
+			return 10 * time.Millisecond << retryAttempts
+		}).
+		Jitter(RetryJitterDecorrelated)
+	decorrelatedPolicy.randomSource = func(n int64) int64 {
+		return n - 1
+	}
+
+	req := &mockRetryRequest{identifier: "req1", idempotent: true}
+
+	// The first retry is limited by the backoff calculator.
+	suite.Assert().Equal(10*time.Millisecond, decorrelatedPolicy.RetryAfter(req, KVLockedRetryReason).Duration())
+	req.IncrementRetryAttempts()
+	suite.Assert().Equal(20*time.Millisecond, decorrelatedPolicy.RetryAfter(req, KVLockedRetryReason).Duration())
+	req.attempts = 5
+	suite.Assert().Equal(60*time.Millisecond, decorrelatedPolicy.RetryAfter(req, KVLockedRetryReason).Duration())
+}
+
+func (suite *UnitTestSuite) TestRetryPolicy_Budget() {
+	budget := NewRetryBudget(2, 0)
+	policy := NewRetryPolicy().Budget(budget)
+	otherPolicy := NewRetryPolicy().Budget(budget)
+
+	req := &mockRetryRequest{idempotent: true}
+	suite.Assert().NotEqual(time.Duration(0), policy.RetryAfter(req, KVLockedRetryReason).Duration())
+	suite.Assert().NotEqual(time.Duration(0), otherPolicy.RetryAfter(req, KVLockedRetryReason).Duration())
+	suite.Assert().Equal(time.Duration(0), policy.RetryAfter(req, KVLockedRetryReason).Duration())
+	suite.Assert().Equal(uint32(0), budget.Available())
+
+	// Fail fast decisions should not consume the budget.
+	refilling := NewRetryBudget(1, 1000)
+	suite.Assert().Equal(time.Duration(0), NewRetryPolicy().Budget(refilling).FailFast(KVLockedRetryReason).
+		RetryAfter(req, KVLockedRetryReason).Duration())
+	suite.Assert().Equal(uint32(1), refilling.Available())
+}
+
+func (suite *UnitTestSuite) TestRetryObserver() {
+	var events []RetryEvent
+	c := clusterFromOptions(ClusterOptions{
+		RetryObserver: func(event RetryEvent) {
+			events = append(events, event)
+		},
+	})
+
+	// Strategies given for a single operation report to the observer of the cluster.
+	wrapper := c.sb.RetryStrategyWrapper.withStrategy(NewBestEffortRetryStrategy(mockBackoffCalculator))
+	wrapper.RetryAfter(&mockGocbcoreRequest{attempts: 3, identifier: "0x12", idempotent: true}, gocbcore.KVLockedRetryReason)
+	wrapper.RetryAfter(&mockGocbcoreRequest{attempts: 0, identifier: "0x13", idempotent: false}, gocbcore.SocketCloseInFlightRetryReason)
+
+	suite.Assert().Equal([]RetryEvent{
+		{
+			Identifier: "0x12",
+			Reason:     KVLockedRetryReason,
+			Attempt:    3,
+			Delay:      3 * time.Millisecond,
+		},
+		{
+			Identifier: "0x13",
+			Reason:     SocketCloseInFlightRetryReason,
+			Attempt:    0,
+			Delay:      0,
+		},
+	}, events)
+
+	// Other clusters are not observed.
+	events = nil
+	other := clusterFromOptions(ClusterOptions{})
+	other.sb.RetryStrategyWrapper.RetryAfter(&mockGocbcoreRequest{attempts: 3, identifier: "0x12", idempotent: true}, gocbcore.KVLockedRetryReason)
+	suite.Assert().Empty(events)
+}
+
+func (suite *UnitTestSuite) TestHTTPErrorRetryDetails() {
+	coreErr := &gocbcore.HTTPError{
+		InnerError:    gocbcore.ErrTimeout,
+		Endpoint:      "http://localhost:8091",
+		RetryReasons:  []gocbcore.RetryReason{gocbcore.ServiceNotAvailableRetryReason},
+		RetryAttempts: 4,
+	}
+
+	err := makeGenericHTTPError(coreErr, &gocbcore.HTTPRequest{UniqueID: "unique"}, nil)
+
+	var httpErr HTTPError
+	suite.Require().True(errors.As(err, &httpErr))
+	suite.Assert().Equal("unique", httpErr.UniqueID)
+	suite.Assert().Equal("http://localhost:8091", httpErr.Endpoint)
+	suite.Assert().Equal([]RetryReason{ServiceNotAvailableRetryReason}, httpErr.RetryReasons)
+	suite.Assert().Equal(uint32(4), httpErr.RetryAttempts)
+	suite.Assert().True(errors.Is(err, ErrTimeout))
+}
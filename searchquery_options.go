@@ -54,7 +54,9 @@ type SearchOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
 
-	parentSpan requestSpanContext
+	// ParentSpan is the span context which the spans of this search are created under. The trace is not
+	// propagated to the search service, see W3CTraceContext.
+	ParentSpan RequestSpanContext
 }
 
 func (opts *SearchOptions) toMap() (map[string]interface{}, error) {
@@ -0,0 +1,379 @@
+package gocb
+
+import (
+	"sync"
+	"time"
+
+	cbsearch "github.com/couchbase/gocb/v2/search"
+)
+
+// Session provides read-your-own-writes semantics across the key-value and query services.
+// Every mutation performed through a Session records its MutationToken, and any query, search
+// query or view query performed through the same Session is made consistent with those mutations.
+// A Session is safe for concurrent use, but is intended to be short lived (such as per web request).
+// VOLATILE: This API is subject to change at any time.
+type Session struct {
+	cluster *Cluster
+
+	lock  sync.Mutex
+	state *MutationState
+}
+
+// NewSession creates a new Session which is bound to this cluster.
+// VOLATILE: This API is subject to change at any time.
+func (c *Cluster) NewSession() *Session {
+	return &Session{
+		cluster: c,
+		state:   NewMutationState(),
+	}
+}
+
+// Collection returns a SessionCollection which performs operations against coll and records
+// any resulting mutation tokens into this session.
+func (s *Session) Collection(coll *Collection) *SessionCollection {
+	return &SessionCollection{
+		collection: coll,
+		session:    s,
+	}
+}
+
+// MutationState returns a copy of the mutation state currently tracked by this session.
+func (s *Session) MutationState() *MutationState {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return NewMutationState(s.state.tokens...)
+}
+
+// Add includes additional mutation tokens within this session, for instance from operations
+// that were not performed through a SessionCollection.
+func (s *Session) Add(tokens ...MutationToken) {
+	s.lock.Lock()
+	s.state.Add(tokens...)
+	s.lock.Unlock()
+}
+
+func (s *Session) addToken(token *MutationToken) {
+	if token == nil {
+		return
+	}
+
+	s.Add(*token)
+}
+
+func (s *Session) hasTokens() bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	return len(s.state.tokens) > 0
+}
+
+func (s *Session) hasBucketTokens(bucketName string) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for _, token := range s.state.tokens {
+		if token.bucketName == bucketName {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Query executes the query statement on the server, consistent with all mutations performed
+// within this session. If opts already specifies a ScanConsistency or ConsistentWith then it
+// is used as-is.
+func (s *Session) Query(statement string, opts *QueryOptions) (*QueryResult, error) {
+	if opts == nil {
+		opts = &QueryOptions{}
+	}
+
+	if opts.ScanConsistency == 0 && opts.ConsistentWith == nil && s.hasTokens() {
+		sessionOpts := *opts
+		sessionOpts.ConsistentWith = s.MutationState()
+		opts = &sessionOpts
+	}
+
+	return s.cluster.Query(statement, opts)
+}
+
+// SearchQuery executes the search query on the server, consistent with all mutations performed
+// within this session. If opts already specifies a ScanConsistency or ConsistentWith then it
+// is used as-is.
+func (s *Session) SearchQuery(indexName string, query cbsearch.Query, opts *SearchOptions) (*SearchResult, error) {
+	if opts == nil {
+		opts = &SearchOptions{}
+	}
+
+	if opts.ScanConsistency == searchScanConsistencyNotSet && opts.ConsistentWith == nil && s.hasTokens() {
+		sessionOpts := *opts
+		sessionOpts.ConsistentWith = s.MutationState()
+		opts = &sessionOpts
+	}
+
+	return s.cluster.SearchQuery(indexName, query, opts)
+}
+
+// ViewQuery performs a view query against bucket. The views service does not support consistency
+// with specific mutations, so if this session holds any mutations for the bucket the view query is
+// performed using ViewScanConsistencyRequestPlus instead. If opts already specifies a
+// ScanConsistency then it is used as-is.
+func (s *Session) ViewQuery(bucket *Bucket, designDoc string, viewName string, opts *ViewOptions) (*ViewResult, error) {
+	if opts == nil {
+		opts = &ViewOptions{}
+	}
+
+	if opts.ScanConsistency == 0 && s.hasBucketTokens(bucket.Name()) {
+		sessionOpts := *opts
+		sessionOpts.ScanConsistency = ViewScanConsistencyRequestPlus
+		opts = &sessionOpts
+	}
+
+	return bucket.ViewQuery(designDoc, viewName, opts)
+}
+
+// SessionCollection wraps a Collection, recording the mutation tokens of any mutations performed
+// through it into the owning Session. Operations which do not mutate documents are passed
+// directly through to the underlying Collection. The data structure helpers are not available
+// on a SessionCollection as their mutation tokens cannot be recorded.
+// VOLATILE: This API is subject to change at any time.
+type SessionCollection struct {
+	collection *Collection
+
+	session *Session
+}
+
+// Name returns the name of the underlying collection.
+func (c *SessionCollection) Name() string {
+	return c.collection.Name()
+}
+
+// ScopeName returns the name of the scope to which the underlying collection belongs.
+func (c *SessionCollection) ScopeName() string {
+	return c.collection.ScopeName()
+}
+
+// BucketName returns the name of the bucket to which the underlying collection belongs.
+func (c *SessionCollection) BucketName() string {
+	return c.collection.BucketName()
+}
+
+// Get performs a fetch operation against the collection.
+func (c *SessionCollection) Get(id string, opts *GetOptions) (*GetResult, error) {
+	return c.collection.Get(id, opts)
+}
+
+// Exists checks if a document exists for the given id.
+func (c *SessionCollection) Exists(id string, opts *ExistsOptions) (*ExistsResult, error) {
+	return c.collection.Exists(id, opts)
+}
+
+// GetAllReplicas returns the value of a particular document from all replica servers.
+func (c *SessionCollection) GetAllReplicas(id string, opts *GetAllReplicaOptions) (*GetAllReplicasResult, error) {
+	return c.collection.GetAllReplicas(id, opts)
+}
+
+// GetAnyReplica returns the value of a particular document from a replica server.
+func (c *SessionCollection) GetAnyReplica(id string, opts *GetAnyReplicaOptions) (*GetReplicaResult, error) {
+	return c.collection.GetAnyReplica(id, opts)
+}
+
+// GetAndTouch retrieves a document and simultaneously updates its expiry time.
+func (c *SessionCollection) GetAndTouch(id string, expiry time.Duration, opts *GetAndTouchOptions) (*GetResult, error) {
+	return c.collection.GetAndTouch(id, expiry, opts)
+}
+
+// GetAndLock locks a document for a period of time, providing exclusive RW access to it.
+func (c *SessionCollection) GetAndLock(id string, lockTime time.Duration, opts *GetAndLockOptions) (*GetResult, error) {
+	return c.collection.GetAndLock(id, lockTime, opts)
+}
+
+// Unlock unlocks a document which was locked with GetAndLock.
+func (c *SessionCollection) Unlock(id string, cas Cas, opts *UnlockOptions) error {
+	return c.collection.Unlock(id, cas, opts)
+}
+
+// LookupIn performs a set of subdocument lookup operations on the document identified by id.
+func (c *SessionCollection) LookupIn(id string, ops []LookupInSpec, opts *LookupInOptions) (*LookupInResult, error) {
+	return c.collection.LookupIn(id, ops, opts)
+}
+
+// Insert creates a new document in the Collection.
+func (c *SessionCollection) Insert(id string, val interface{}, opts *InsertOptions) (*MutationResult, error) {
+	res, err := c.collection.Insert(id, val, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.session.addToken(res.MutationToken())
+	return res, nil
+}
+
+// Upsert creates a new document in the Collection if it does not exist, if it does exist then it updates it.
+func (c *SessionCollection) Upsert(id string, val interface{}, opts *UpsertOptions) (*MutationResult, error) {
+	res, err := c.collection.Upsert(id, val, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.session.addToken(res.MutationToken())
+	return res, nil
+}
+
+// Replace updates a document in the collection.
+func (c *SessionCollection) Replace(id string, val interface{}, opts *ReplaceOptions) (*MutationResult, error) {
+	res, err := c.collection.Replace(id, val, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.session.addToken(res.MutationToken())
+	return res, nil
+}
+
+// Remove removes a document from the collection.
+func (c *SessionCollection) Remove(id string, opts *RemoveOptions) (*MutationResult, error) {
+	res, err := c.collection.Remove(id, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.session.addToken(res.MutationToken())
+	return res, nil
+}
+
+// Touch touches a document, specifying a new expiry time for it.
+func (c *SessionCollection) Touch(id string, expiry time.Duration, opts *TouchOptions) (*MutationResult, error) {
+	res, err := c.collection.Touch(id, expiry, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.session.addToken(res.MutationToken())
+	return res, nil
+}
+
+// MutateIn performs a set of subdocument mutations on the document specified by id.
+func (c *SessionCollection) MutateIn(id string, ops []MutateInSpec, opts *MutateInOptions) (*MutateInResult, error) {
+	res, err := c.collection.MutateIn(id, ops, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.session.addToken(res.MutationToken())
+	return res, nil
+}
+
+// Do execute one or more `BulkOp` items in parallel, recording the mutation tokens of any
+// successful mutations.
+// UNCOMMITTED: This API may change in the future.
+func (c *SessionCollection) Do(ops []BulkOp, opts *BulkOpOptions) error {
+	err := c.collection.Do(ops, opts)
+
+	for _, op := range ops {
+		var mt *MutationToken
+		switch item := op.(type) {
+		case *TouchOp:
+			if item.Result != nil {
+				mt = item.Result.MutationToken()
+			}
+		case *RemoveOp:
+			if item.Result != nil {
+				mt = item.Result.MutationToken()
+			}
+		case *UpsertOp:
+			if item.Result != nil {
+				mt = item.Result.MutationToken()
+			}
+		case *InsertOp:
+			if item.Result != nil {
+				mt = item.Result.MutationToken()
+			}
+		case *ReplaceOp:
+			if item.Result != nil {
+				mt = item.Result.MutationToken()
+			}
+		case *AppendOp:
+			if item.Result != nil {
+				mt = item.Result.MutationToken()
+			}
+		case *PrependOp:
+			if item.Result != nil {
+				mt = item.Result.MutationToken()
+			}
+		case *IncrementOp:
+			if item.Result != nil {
+				mt = item.Result.MutationToken()
+			}
+		case *DecrementOp:
+			if item.Result != nil {
+				mt = item.Result.MutationToken()
+			}
+		}
+		c.session.addToken(mt)
+	}
+
+	return err
+}
+
+// Binary creates and returns a SessionBinaryCollection object.
+func (c *SessionCollection) Binary() *SessionBinaryCollection {
+	return &SessionBinaryCollection{
+		binary:  c.collection.Binary(),
+		session: c.session,
+	}
+}
+
+// SessionBinaryCollection wraps a BinaryCollection, recording the mutation tokens of any
+// mutations performed through it into the owning Session.
+// VOLATILE: This API is subject to change at any time.
+type SessionBinaryCollection struct {
+	binary *BinaryCollection
+
+	session *Session
+}
+
+// Append appends a byte value to a document.
+func (c *SessionBinaryCollection) Append(id string, val []byte, opts *AppendOptions) (*MutationResult, error) {
+	res, err := c.binary.Append(id, val, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.session.addToken(res.MutationToken())
+	return res, nil
+}
+
+// Prepend prepends a byte value to a document.
+func (c *SessionBinaryCollection) Prepend(id string, val []byte, opts *PrependOptions) (*MutationResult, error) {
+	res, err := c.binary.Prepend(id, val, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.session.addToken(res.MutationToken())
+	return res, nil
+}
+
+// Increment performs an atomic addition for an integer document.
+func (c *SessionBinaryCollection) Increment(id string, opts *IncrementOptions) (*CounterResult, error) {
+	res, err := c.binary.Increment(id, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.session.addToken(res.MutationToken())
+	return res, nil
+}
+
+// Decrement performs an atomic subtraction for an integer document.
+func (c *SessionBinaryCollection) Decrement(id string, opts *DecrementOptions) (*CounterResult, error) {
+	res, err := c.binary.Decrement(id, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	c.session.addToken(res.MutationToken())
+	return res, nil
+}
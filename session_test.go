@@ -0,0 +1,133 @@
+package gocb
+
+import (
+	"encoding/json"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v9"
+	"github.com/stretchr/testify/mock"
+)
+
+func (suite *UnitTestSuite) sessionCollection(cli *mockClient) *Collection {
+	return &Collection{
+		sb: stateBlock{
+			clientStateBlock: clientStateBlock{
+				BucketName: "mock",
+			},
+
+			cachedClient:         cli,
+			KvTimeout:            2500 * time.Millisecond,
+			Transcoder:           NewJSONTranscoder(),
+			Tracer:               &noopTracer{},
+			RetryStrategyWrapper: newRetryStrategyWrapper(NewBestEffortRetryStrategy(nil)),
+			UseMutationTokens:    true,
+		},
+	}
+}
+
+func (suite *UnitTestSuite) TestSessionQueryConsistentWithMutations() {
+	pendingOp := new(mockPendingOp)
+
+	provider := new(mockKvProvider)
+	provider.
+		On("Set", mock.AnythingOfType("gocbcore.SetOptions"), mock.AnythingOfType("gocbcore.StoreCallback")).
+		Run(func(args mock.Arguments) {
+			cb := args.Get(1).(gocbcore.StoreCallback)
+			cb(&gocbcore.StoreResult{
+				Cas: 1,
+				MutationToken: gocbcore.MutationToken{
+					VbID:   4,
+					VbUUID: 11,
+					SeqNo:  22,
+				},
+			}, nil)
+		}).
+		Return(pendingOp, nil)
+
+	kvCli := new(mockClient)
+	kvCli.On("getKvProvider").Return(provider, nil)
+
+	reader := &mockQueryRowReader{
+		mockQueryRowReaderBase: mockQueryRowReaderBase{
+			Suite: suite,
+		},
+	}
+	var payloads []map[string]interface{}
+	cluster := suite.queryCluster(true, reader, func(args mock.Arguments) {
+		opts := args.Get(0).(gocbcore.N1QLQueryOptions)
+
+		var payload map[string]interface{}
+		suite.Require().Nil(json.Unmarshal(opts.Payload, &payload))
+		payloads = append(payloads, payload)
+	})
+
+	session := cluster.NewSession()
+
+	_, err := session.Query("SELECT 1", nil)
+	suite.Require().Nil(err, err)
+	suite.Require().Len(payloads, 1)
+	suite.Assert().NotContains(payloads[0], "scan_vectors")
+
+	coll := session.Collection(suite.sessionCollection(kvCli))
+	res, err := coll.Upsert("key", "value", nil)
+	suite.Require().Nil(err, err)
+	suite.Require().NotNil(res.MutationToken())
+
+	queryProvider, call := suite.newMockQueryProvider(true, reader)
+	call.Run(func(args mock.Arguments) {
+		opts := args.Get(0).(gocbcore.N1QLQueryOptions)
+
+		var payload map[string]interface{}
+		suite.Require().Nil(json.Unmarshal(opts.Payload, &payload))
+		payloads = append(payloads, payload)
+	})
+	cli := new(mockClient)
+	cli.On("getQueryProvider").Return(queryProvider, nil)
+	cli.On("supportsGCCCP").Return(true)
+	cluster.clusterClient = cli
+
+	opts := &QueryOptions{}
+	_, err = session.Query("SELECT 1", opts)
+	suite.Require().Nil(err, err)
+	suite.Require().Len(payloads, 2)
+	suite.Assert().Equal("at_plus", payloads[1]["scan_consistency"])
+	suite.Assert().Equal(map[string]interface{}{
+		"mock": map[string]interface{}{
+			"4": []interface{}{float64(22), "11"},
+		},
+	}, payloads[1]["scan_vectors"])
+
+	// The options passed by the caller should not have been modified.
+	suite.Assert().Nil(opts.ConsistentWith)
+}
+
+func (suite *UnitTestSuite) TestSessionQueryExplicitConsistency() {
+	session := (&Cluster{}).NewSession()
+	session.Add(MutationToken{
+		bucketName: "mock",
+		token: gocbcore.MutationToken{
+			VbID:   1,
+			VbUUID: 2,
+			SeqNo:  3,
+		},
+	})
+
+	reader := &mockQueryRowReader{
+		mockQueryRowReaderBase: mockQueryRowReaderBase{
+			Suite: suite,
+		},
+	}
+	session.cluster = suite.queryCluster(true, reader, func(args mock.Arguments) {
+		opts := args.Get(0).(gocbcore.N1QLQueryOptions)
+
+		var payload map[string]interface{}
+		suite.Require().Nil(json.Unmarshal(opts.Payload, &payload))
+		suite.Assert().Equal("not_bounded", payload["scan_consistency"])
+		suite.Assert().NotContains(payload, "scan_vectors")
+	})
+
+	_, err := session.Query("SELECT 1", &QueryOptions{
+		ScanConsistency: QueryScanConsistencyNotBounded,
+	})
+	suite.Require().Nil(err, err)
+}
@@ -43,9 +43,14 @@ type stateBlock struct {
 	OrphanLoggerInterval   time.Duration
 	OrphanLoggerSampleSize uint32
 
-	Tracer requestTracer
+	Tracer RequestTracer
+	Meter  *meterWrapper
 
 	CircuitBreakerConfig CircuitBreakerConfig
+	HTTPCircuitBreakers  *httpCircuitBreakers
+	Bulkheads            *bulkheads
+	Ops                  *opTracker
+	KvLatencies          *kvLatencySampler
 	SecurityConfig       SecurityConfig
 	InternalConfig       InternalConfig
 }
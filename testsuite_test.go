@@ -195,6 +195,7 @@ func (suite *UnitTestSuite) bucket(name string, timeouts TimeoutsConfig, cli *mo
 			ViewTimeout:       timeouts.ViewTimeout,
 
 			cachedClient: cli,
+			Tracer:       &noopTracer{},
 		},
 	}
 
@@ -2,17 +2,29 @@ package gocb
 
 import (
 	"encoding/json"
+	"io"
+	"math/bits"
 	"sort"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
+// thresholdLogHistogramSize is the number of buckets used to record operation durations for summaries.
+// Durations below 16us each have their own bucket, above that each power of two is split into 8 buckets,
+// which keeps the error of any reported percentile below 12.5%.
+const thresholdLogHistogramSize = 16 + 60*8
+
 type thresholdLogGroup struct {
-	name  string
-	floor time.Duration
-	ops   []*thresholdLogSpan
-	lock  sync.RWMutex
+	name     string
+	floor    time.Duration
+	opFloors map[string]time.Duration
+	ops      []*thresholdLogSpan
+	lock     sync.RWMutex
+
+	histogram [thresholdLogHistogramSize]uint64
 }
 
 func (g *thresholdLogGroup) init(name string, floor time.Duration, size uint32) {
@@ -21,8 +33,39 @@ func (g *thresholdLogGroup) init(name string, floor time.Duration, size uint32)
 	g.ops = make([]*thresholdLogSpan, 0, size)
 }
 
+func thresholdLogHistogramIndex(duration time.Duration) int {
+	us := uint64(duration / time.Microsecond)
+	if duration < 0 {
+		us = 0
+	}
+	if us < 16 {
+		return int(us)
+	}
+
+	exp := bits.Len64(us)
+	sub := (us >> uint(exp-4)) & 7
+	return 16 + (exp-5)*8 + int(sub)
+}
+
+func thresholdLogHistogramValue(idx int) uint64 {
+	if idx < 16 {
+		return uint64(idx)
+	}
+
+	exp := uint((idx-16)/8 + 5)
+	sub := uint64((idx - 16) % 8)
+	lower := (8 + sub) << (exp - 4)
+	return lower + (1 << (exp - 4)) - 1
+}
+
 func (g *thresholdLogGroup) recordOp(span *thresholdLogSpan) {
-	if span.duration < g.floor {
+	atomic.AddUint64(&g.histogram[thresholdLogHistogramIndex(span.duration)], 1)
+
+	floor := g.floor
+	if opFloor, ok := g.opFloors[span.opName]; ok {
+		floor = opFloor
+	}
+	if span.duration < floor {
 		return
 	}
 
@@ -80,6 +123,7 @@ type thresholdLogItem struct {
 	LastOperationID        string `json:"last_operation_id,omitempty"`
 	LastLocalID            string `json:"last_local_id,omitempty"`
 	DocumentKey            string `json:"document_key,omitempty"`
+	ServiceType            string `json:"service_type,omitempty"`
 }
 
 type thresholdLogService struct {
@@ -88,7 +132,57 @@ type thresholdLogService struct {
 	Top     []thresholdLogItem `json:"top"`
 }
 
-func (g *thresholdLogGroup) logRecordedRecords(sampleSize uint32) {
+type thresholdLogSummary struct {
+	Service       string            `json:"service"`
+	Count         uint64            `json:"count"`
+	PercentilesUs map[string]uint64 `json:"percentiles_us"`
+}
+
+var thresholdLogPercentiles = []float64{50, 90, 99, 99.9, 100}
+
+func (g *thresholdLogGroup) buildSummary() *thresholdLogSummary {
+	var histogram [thresholdLogHistogramSize]uint64
+	for i := range g.histogram {
+		histogram[i] = atomic.SwapUint64(&g.histogram[i], 0)
+	}
+
+	var count uint64
+	for _, bucketCount := range histogram {
+		count += bucketCount
+	}
+	if count == 0 {
+		return nil
+	}
+
+	summary := &thresholdLogSummary{
+		Service:       g.name,
+		Count:         count,
+		PercentilesUs: make(map[string]uint64, len(thresholdLogPercentiles)),
+	}
+
+	for _, percentile := range thresholdLogPercentiles {
+		rank := uint64(percentile / 100 * float64(count))
+		if float64(rank) < percentile/100*float64(count) {
+			rank++
+		}
+		if rank == 0 {
+			rank = 1
+		}
+
+		var seen uint64
+		for idx, bucketCount := range histogram {
+			seen += bucketCount
+			if seen >= rank {
+				summary.PercentilesUs[strconv.FormatFloat(percentile, 'f', 1, 64)] = thresholdLogHistogramValue(idx)
+				break
+			}
+		}
+	}
+
+	return summary
+}
+
+func (g *thresholdLogGroup) buildRecordedRecords(sampleSize uint32) *thresholdLogService {
 	// Preallocate space to copy the ops into...
 	oldOps := make([]*thresholdLogSpan, sampleSize)
 
@@ -96,7 +190,7 @@ func (g *thresholdLogGroup) logRecordedRecords(sampleSize uint32) {
 	// Escape early if we have no ops to log...
 	if len(g.ops) == 0 {
 		g.lock.Unlock()
-		return
+		return nil
 	}
 
 	// Copy out our ops so we can cheaply print them out without blocking
@@ -132,12 +226,32 @@ func (g *thresholdLogGroup) logRecordedRecords(sampleSize uint32) {
 
 	jsonData.Count = uint64(len(jsonData.Top))
 
-	jsonBytes, err := json.Marshal(jsonData)
-	if err != nil {
-		logDebugf("Failed to generate threshold logging service JSON: %s", err)
-	}
+	return &jsonData
+}
+
+// ThresholdLogReportType specifies the type of a ThresholdLogReport.
+type ThresholdLogReportType string
+
+const (
+	// ThresholdLogReportTypeThreshold indicates a report of the slowest operations which exceeded their threshold.
+	ThresholdLogReportTypeThreshold = ThresholdLogReportType("threshold")
+
+	// ThresholdLogReportTypeSummary indicates a report of the number of operations and their latency percentiles.
+	ThresholdLogReportTypeSummary = ThresholdLogReportType("summary")
 
-	logInfof("Threshold Log: %s", jsonBytes)
+	// ThresholdLogReportTypeOrphan indicates a report of responses which were received after their operation had
+	// already timed out or been cancelled.
+	ThresholdLogReportTypeOrphan = ThresholdLogReportType("orphan")
+)
+
+// ThresholdLogReport is a single report generated by the threshold logging tracer.
+type ThresholdLogReport struct {
+	Type    ThresholdLogReportType `json:"type"`
+	Service string                 `json:"service"`
+
+	// Report is the JSON encoded report, this is in the same format that is written to the log when no sink
+	// is configured.
+	Report json.RawMessage `json:"report"`
 }
 
 // ThresholdLoggingOptions is the set of options available for configuring threshold logging.
@@ -151,6 +265,27 @@ type ThresholdLoggingOptions struct {
 	SearchThreshold        time.Duration
 	AnalyticsThreshold     time.Duration
 	ManagementThreshold    time.Duration
+	// EventingThreshold applies to operations tagged with the "eventing" service. The SDK does not yet send
+	// requests to the eventing service itself, the threshold is for spans started by the application.
+	EventingThreshold time.Duration
+
+	// OperationThresholds overrides the threshold of the service for specific operations, keyed by the
+	// operation name (such as "Get" or "Query").
+	OperationThresholds map[string]time.Duration
+
+	// Sink, if specified, receives every report instead of the report being logged.
+	Sink func(report ThresholdLogReport)
+
+	// Writer, if specified and Sink is not, has every report written to it as a single line of JSON
+	// instead of the report being logged.
+	Writer io.Writer
+
+	// SummaryDisabled disables the periodic summary of operation counts and latency percentiles.
+	SummaryDisabled bool
+
+	// OrphanReportingDisabled disables delivering orphaned response reports to the Sink or Writer.
+	// Orphaned responses are only reported through the tracer when a Sink or Writer is specified.
+	OrphanReportingDisabled bool
 }
 
 // thresholdLoggingTracer is a specialized Tracer implementation which will automatically
@@ -166,8 +301,15 @@ type thresholdLoggingTracer struct {
 	SearchThreshold     time.Duration
 	AnalyticsThreshold  time.Duration
 	ManagementThreshold time.Duration
+	EventingThreshold   time.Duration
 
+	sink            func(report ThresholdLogReport)
+	writer          io.Writer
+	writerLock      sync.Mutex
+	summaryEnabled  bool
+	reportOrphans   bool
 	killCh          chan struct{}
+	stoppedCh       chan struct{}
 	refCount        int32
 	nextTick        time.Time
 	kvGroup         thresholdLogGroup
@@ -176,7 +318,14 @@ type thresholdLoggingTracer struct {
 	searchGroup     thresholdLogGroup
 	analyticsGroup  thresholdLogGroup
 	managementGroup thresholdLogGroup
-	mgmtGroup       thresholdLogGroup
+	eventingGroup   thresholdLogGroup
+}
+
+// NewThresholdLoggingTracer creates a new RequestTracer which periodically reports operations which
+// exceed the configured thresholds. This is the tracer used when ClusterOptions.Tracer is not specified.
+// VOLATILE: This API is subject to change at any time.
+func NewThresholdLoggingTracer(opts *ThresholdLoggingOptions) RequestTracer {
+	return newThresholdLoggingTracer(opts)
 }
 
 func newThresholdLoggingTracer(opts *ThresholdLoggingOptions) *thresholdLoggingTracer {
@@ -207,6 +356,9 @@ func newThresholdLoggingTracer(opts *ThresholdLoggingOptions) *thresholdLoggingT
 	if opts.ManagementThreshold == 0 {
 		opts.ManagementThreshold = 1 * time.Second
 	}
+	if opts.EventingThreshold == 0 {
+		opts.EventingThreshold = 1 * time.Second
+	}
 
 	t := &thresholdLoggingTracer{
 		Interval:            opts.Interval,
@@ -217,6 +369,11 @@ func newThresholdLoggingTracer(opts *ThresholdLoggingOptions) *thresholdLoggingT
 		SearchThreshold:     opts.SearchThreshold,
 		AnalyticsThreshold:  opts.AnalyticsThreshold,
 		ManagementThreshold: opts.ManagementThreshold,
+		EventingThreshold:   opts.EventingThreshold,
+		sink:                opts.Sink,
+		writer:              opts.Writer,
+		summaryEnabled:      !opts.SummaryDisabled,
+		reportOrphans:       !opts.OrphanReportingDisabled && (opts.Sink != nil || opts.Writer != nil),
 	}
 
 	t.kvGroup.init("kv", t.KVThreshold, t.SampleSize)
@@ -225,10 +382,24 @@ func newThresholdLoggingTracer(opts *ThresholdLoggingOptions) *thresholdLoggingT
 	t.searchGroup.init("search", t.SearchThreshold, t.SampleSize)
 	t.analyticsGroup.init("analytics", t.AnalyticsThreshold, t.SampleSize)
 	t.managementGroup.init("management", t.ManagementThreshold, t.SampleSize)
+	t.eventingGroup.init("eventing", t.EventingThreshold, t.SampleSize)
+
+	if len(opts.OperationThresholds) > 0 {
+		opFloors := make(map[string]time.Duration, len(opts.OperationThresholds))
+		for opName, threshold := range opts.OperationThresholds {
+			opFloors[opName] = threshold
+		}
+		for _, group := range t.groups() {
+			group.opFloors = opFloors
+		}
+	}
 
 	if t.killCh == nil {
 		t.killCh = make(chan struct{})
 	}
+	if t.stoppedCh == nil {
+		t.stoppedCh = make(chan struct{})
+	}
 
 	if t.nextTick.IsZero() {
 		t.nextTick = time.Now().Add(t.Interval)
@@ -248,22 +419,88 @@ func (t *thresholdLoggingTracer) AddRef() int32 {
 	return newRefCount
 }
 
-// DecRef is the counterpart to AddRef (see AddRef for more information).
+// DecRef is the counterpart to AddRef (see AddRef for more information). When the last reference is
+// released it waits for the final report to be logged.
 func (t *thresholdLoggingTracer) DecRef() int32 {
 	newRefCount := atomic.AddInt32(&t.refCount, -1)
 	if newRefCount == 0 {
 		t.killCh <- struct{}{}
+		<-t.stoppedCh
 	}
 	return newRefCount
 }
 
+func (t *thresholdLoggingTracer) groups() []*thresholdLogGroup {
+	return []*thresholdLogGroup{
+		&t.kvGroup,
+		&t.viewsGroup,
+		&t.queryGroup,
+		&t.searchGroup,
+		&t.analyticsGroup,
+		&t.managementGroup,
+		&t.eventingGroup,
+	}
+}
+
 func (t *thresholdLoggingTracer) logRecordedRecords() {
-	t.kvGroup.logRecordedRecords(t.SampleSize)
-	t.viewsGroup.logRecordedRecords(t.SampleSize)
-	t.queryGroup.logRecordedRecords(t.SampleSize)
-	t.searchGroup.logRecordedRecords(t.SampleSize)
-	t.analyticsGroup.logRecordedRecords(t.SampleSize)
-	t.managementGroup.logRecordedRecords(t.SampleSize)
+	for _, group := range t.groups() {
+		if records := group.buildRecordedRecords(t.SampleSize); records != nil {
+			t.report(ThresholdLogReportTypeThreshold, group.name, records)
+		}
+	}
+
+	if t.summaryEnabled {
+		for _, group := range t.groups() {
+			if summary := group.buildSummary(); summary != nil {
+				t.report(ThresholdLogReportTypeSummary, group.name, summary)
+			}
+		}
+	}
+}
+
+func (t *thresholdLoggingTracer) report(reportType ThresholdLogReportType, service string, data interface{}) {
+	jsonBytes, err := json.Marshal(data)
+	if err != nil {
+		logDebugf("Failed to generate threshold logging service JSON: %s", err)
+		return
+	}
+
+	if t.sink == nil && t.writer == nil {
+		switch reportType {
+		case ThresholdLogReportTypeThreshold:
+			logInfof("Threshold Log: %s", jsonBytes)
+		case ThresholdLogReportTypeSummary:
+			logInfof("Threshold Log Summary: %s", jsonBytes)
+		case ThresholdLogReportTypeOrphan:
+			logWarnf("Orphaned responses observed: %s", jsonBytes)
+		}
+		return
+	}
+
+	report := ThresholdLogReport{
+		Type:    reportType,
+		Service: service,
+		Report:  jsonBytes,
+	}
+
+	if t.sink != nil {
+		t.sink(report)
+		return
+	}
+
+	lineBytes, err := json.Marshal(report)
+	if err != nil {
+		logDebugf("Failed to generate threshold logging report JSON: %s", err)
+		return
+	}
+	lineBytes = append(lineBytes, '\n')
+
+	t.writerLock.Lock()
+	_, err = t.writer.Write(lineBytes)
+	t.writerLock.Unlock()
+	if err != nil {
+		logDebugf("Failed to write threshold logging report: %s", err)
+	}
 }
 
 func (t *thresholdLoggingTracer) startLoggerRoutine() {
@@ -278,6 +515,7 @@ func (t *thresholdLoggingTracer) loggerRoutine() {
 			t.logRecordedRecords()
 		case <-t.killCh:
 			t.logRecordedRecords()
+			t.stoppedCh <- struct{}{}
 			return
 		}
 	}
@@ -289,7 +527,7 @@ func (t *thresholdLoggingTracer) recordOp(span *thresholdLogSpan) {
 		t.managementGroup.recordOp(span)
 	case "kv":
 		t.kvGroup.recordOp(span)
-	case "views":
+	case "view", "views":
 		t.viewsGroup.recordOp(span)
 	case "query":
 		t.queryGroup.recordOp(span)
@@ -297,11 +535,13 @@ func (t *thresholdLoggingTracer) recordOp(span *thresholdLogSpan) {
 		t.searchGroup.recordOp(span)
 	case "analytics":
 		t.analyticsGroup.recordOp(span)
+	case "eventing":
+		t.eventingGroup.recordOp(span)
 	}
 }
 
 // StartSpan belongs to the Tracer interface.
-func (t *thresholdLoggingTracer) StartSpan(operationName string, parentContext requestSpanContext) requestSpan {
+func (t *thresholdLoggingTracer) StartSpan(operationName string, parentContext RequestSpanContext) RequestSpan {
 	span := &thresholdLogSpan{
 		tracer:    t,
 		opName:    operationName,
@@ -310,6 +550,9 @@ func (t *thresholdLoggingTracer) StartSpan(operationName string, parentContext r
 
 	if context, ok := parentContext.(*thresholdLogSpanContext); ok {
 		span.parent = context.span
+		span.remoteParent = context.span.remoteParent
+	} else if remoteParent, ok := parentContext.(W3CTraceContext); ok {
+		span.remoteParent = remoteParent
 	}
 
 	return span
@@ -318,6 +561,7 @@ func (t *thresholdLoggingTracer) StartSpan(operationName string, parentContext r
 type thresholdLogSpan struct {
 	tracer                *thresholdLoggingTracer
 	parent                *thresholdLogSpan
+	remoteParent          W3CTraceContext
 	opName                string
 	startTime             time.Time
 	serviceName           string
@@ -335,11 +579,11 @@ type thresholdLogSpan struct {
 	lock                  sync.Mutex
 }
 
-func (n *thresholdLogSpan) Context() requestSpanContext {
+func (n *thresholdLogSpan) Context() RequestSpanContext {
 	return &thresholdLogSpanContext{n}
 }
 
-func (n *thresholdLogSpan) SetTag(key string, value interface{}) requestSpan {
+func (n *thresholdLogSpan) SetTag(key string, value interface{}) RequestSpan {
 	var ok bool
 
 	switch key {
@@ -413,3 +657,141 @@ func (n *thresholdLogSpan) Finish() {
 type thresholdLogSpanContext struct {
 	span *thresholdLogSpan
 }
+
+// TraceParent returns the traceparent of the externally provided parent span, if there is one.
+func (n *thresholdLogSpanContext) TraceParent() string {
+	if n.span.remoteParent == nil {
+		return ""
+	}
+	return n.span.remoteParent.TraceParent()
+}
+
+// TraceState returns the tracestate of the externally provided parent span, if there is one.
+func (n *thresholdLogSpanContext) TraceState() string {
+	if n.span.remoteParent == nil {
+		return ""
+	}
+	return n.span.remoteParent.TraceState()
+}
+
+// gocbcore reports orphaned responses per agent but only through its global logger, so the client ID of each
+// agent is registered against the cluster which owns it. Every connection ID that gocbcore reports is prefixed
+// with the client ID of its agent, which allows a report to be delivered to the owning cluster alone.
+var (
+	orphanReportClientsLock sync.Mutex
+	orphanReportClients     map[string]*Cluster
+)
+
+func addOrphanReportClient(clientID string, cluster *Cluster) {
+	orphanReportClientsLock.Lock()
+	if orphanReportClients == nil {
+		orphanReportClients = make(map[string]*Cluster)
+	}
+	orphanReportClients[clientID] = cluster
+	orphanReportClientsLock.Unlock()
+
+	updateCoreLogger()
+}
+
+func removeOrphanReportClient(clientID string) {
+	orphanReportClientsLock.Lock()
+	delete(orphanReportClients, clientID)
+	orphanReportClientsLock.Unlock()
+
+	updateCoreLogger()
+}
+
+func hasOrphanReportClients() bool {
+	orphanReportClientsLock.Lock()
+	defer orphanReportClientsLock.Unlock()
+
+	return len(orphanReportClients) > 0
+}
+
+// orphanReportCluster returns the cluster which owns the agent that made a report, or nil if it is not known.
+func orphanReportCluster(report *jsonCoreOrphanReport) *Cluster {
+	if len(report.Top) == 0 {
+		return nil
+	}
+
+	connID := report.Top[0].ConnectionID
+	idx := strings.IndexByte(connID, '/')
+	if idx < 0 {
+		return nil
+	}
+
+	orphanReportClientsLock.Lock()
+	defer orphanReportClientsLock.Unlock()
+
+	return orphanReportClients[connID[:idx]]
+}
+
+type jsonCoreOrphanItem struct {
+	ConnectionID     string `json:"c"`
+	OperationID      string `json:"i"`
+	Endpoint         string `json:"r"`
+	ServerDurationUs uint64 `json:"d"`
+	ServiceType      string `json:"s"`
+}
+
+type jsonCoreOrphanReport struct {
+	Service string               `json:"service"`
+	Count   uint64               `json:"count"`
+	Top     []jsonCoreOrphanItem `json:"top"`
+}
+
+// dispatchOrphanReport converts an orphaned response report from gocbcore into the threshold log format
+// and delivers it to the cluster which owns the reporting agent, returning whether it was delivered.
+func dispatchOrphanReport(data []byte) bool {
+	if !hasOrphanReportClients() {
+		return false
+	}
+
+	var coreReport jsonCoreOrphanReport
+	err := json.Unmarshal(data, &coreReport)
+	if err != nil {
+		logDebugf("Failed to parse orphaned responses report: %s", err)
+		return false
+	}
+
+	cluster := orphanReportCluster(&coreReport)
+	if cluster == nil {
+		return false
+	}
+
+	return cluster.reportOrphans(&coreReport)
+}
+
+// reportOrphans records a report of orphaned responses to the meter of the cluster and delivers it to the
+// tracer of the cluster, if that is a threshold logging tracer which reports orphans. Reports are dropped once
+// the cluster has started closing, as its tracer may already have stopped.
+func (c *Cluster) reportOrphans(coreReport *jsonCoreOrphanReport) bool {
+	if c.sb.Ops.isClosed() {
+		return false
+	}
+
+	c.sb.Meter.recordOrphans(coreReport.Service, coreReport.Count)
+
+	tracer, ok := c.sb.Tracer.(*thresholdLoggingTracer)
+	if !ok || !tracer.reportOrphans {
+		return false
+	}
+
+	report := thresholdLogService{
+		Service: coreReport.Service,
+		Count:   coreReport.Count,
+	}
+	for _, item := range coreReport.Top {
+		report.Top = append(report.Top, thresholdLogItem{
+			ServiceType:       item.ServiceType,
+			ServerDurationUs:  item.ServerDurationUs,
+			LastRemoteAddress: item.Endpoint,
+			LastOperationID:   item.OperationID,
+			LastLocalID:       item.ConnectionID,
+		})
+	}
+
+	tracer.report(ThresholdLogReportTypeOrphan, report.Service, &report)
+
+	return true
+}
@@ -1,7 +1,16 @@
 package gocb
 
 import (
+	"bytes"
+	"encoding/json"
+	"go/build"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v9"
 )
 
 func (suite *UnitTestSuite) TestThresholdGroup() {
@@ -35,3 +44,203 @@ func (suite *UnitTestSuite) TestThresholdGroup() {
 		suite.T().Fatalf("Failed to insert in correct order (3)")
 	}
 }
+
+func (suite *UnitTestSuite) TestThresholdHistogram() {
+	durations := []time.Duration{
+		0,
+		15 * time.Microsecond,
+		16 * time.Microsecond,
+		17 * time.Microsecond,
+		999 * time.Microsecond,
+		1500 * time.Millisecond,
+		75 * time.Second,
+	}
+
+	lastIdx := -1
+	for _, duration := range durations {
+		idx := thresholdLogHistogramIndex(duration)
+		suite.Require().True(idx < thresholdLogHistogramSize, duration.String())
+		suite.Assert().True(idx >= lastIdx, duration.String())
+		lastIdx = idx
+
+		us := uint64(duration / time.Microsecond)
+		value := thresholdLogHistogramValue(idx)
+		suite.Assert().True(value >= us, duration.String())
+		suite.Assert().True(float64(value) <= float64(us)*1.125+1, duration.String())
+	}
+}
+
+func (suite *UnitTestSuite) TestThresholdLoggingTracerSink() {
+	var reports []ThresholdLogReport
+	tracer := newThresholdLoggingTracer(&ThresholdLoggingOptions{
+		KVThreshold: 10 * time.Millisecond,
+		OperationThresholds: map[string]time.Duration{
+			"Upsert": 1 * time.Millisecond,
+		},
+		Sink: func(report ThresholdLogReport) {
+			reports = append(reports, report)
+		},
+	})
+
+	for i := 1; i <= 100; i++ {
+		tracer.recordOp(&thresholdLogSpan{
+			opName:      "Get",
+			serviceName: "kv",
+			duration:    time.Duration(i) * 100 * time.Microsecond,
+		})
+	}
+	tracer.recordOp(&thresholdLogSpan{
+		opName:      "Upsert",
+		serviceName: "kv",
+		duration:    2 * time.Millisecond,
+	})
+	tracer.recordOp(&thresholdLogSpan{
+		opName:      "ViewQuery",
+		serviceName: "view",
+		duration:    2 * time.Second,
+	})
+
+	tracer.logRecordedRecords()
+
+	suite.Require().Len(reports, 4)
+
+	suite.Assert().Equal(ThresholdLogReportTypeThreshold, reports[0].Type)
+	suite.Assert().Equal("kv", reports[0].Service)
+	var kvReport thresholdLogService
+	suite.Require().Nil(json.Unmarshal(reports[0].Report, &kvReport))
+	suite.Require().Len(kvReport.Top, 2)
+	suite.Assert().Equal("Get", kvReport.Top[0].OperationName)
+	suite.Assert().Equal(uint64(10000), kvReport.Top[0].TotalTimeUs)
+	suite.Assert().Equal("Upsert", kvReport.Top[1].OperationName)
+
+	suite.Assert().Equal(ThresholdLogReportTypeThreshold, reports[1].Type)
+	suite.Assert().Equal("views", reports[1].Service)
+
+	suite.Assert().Equal(ThresholdLogReportTypeSummary, reports[2].Type)
+	suite.Assert().Equal("kv", reports[2].Service)
+	var summary thresholdLogSummary
+	suite.Require().Nil(json.Unmarshal(reports[2].Report, &summary))
+	suite.Assert().Equal(uint64(101), summary.Count)
+	suite.Assert().InEpsilon(5000, summary.PercentilesUs["50.0"], 0.125)
+	suite.Assert().InEpsilon(9000, summary.PercentilesUs["90.0"], 0.125)
+	suite.Assert().InEpsilon(10000, summary.PercentilesUs["100.0"], 0.125)
+
+	suite.Assert().Equal(ThresholdLogReportTypeSummary, reports[3].Type)
+	suite.Assert().Equal("views", reports[3].Service)
+
+	// Everything should have been reset after reporting.
+	reports = nil
+	tracer.logRecordedRecords()
+	suite.Assert().Empty(reports)
+}
+
+func (suite *UnitTestSuite) TestThresholdLoggingTracerWriter() {
+	var buf bytes.Buffer
+	tracer := newThresholdLoggingTracer(&ThresholdLoggingOptions{
+		SummaryDisabled: true,
+		Writer:          &buf,
+	})
+
+	tracer.recordOp(&thresholdLogSpan{
+		opName:      "Query",
+		serviceName: "query",
+		duration:    2 * time.Second,
+	})
+	tracer.logRecordedRecords()
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	suite.Require().Len(lines, 1)
+
+	var report ThresholdLogReport
+	suite.Require().Nil(json.Unmarshal([]byte(lines[0]), &report))
+	suite.Assert().Equal(ThresholdLogReportTypeThreshold, report.Type)
+	suite.Assert().Equal("query", report.Service)
+}
+
+func (suite *UnitTestSuite) TestThresholdLoggingTracerEventing() {
+	var reports []ThresholdLogReport
+	tracer := newThresholdLoggingTracer(&ThresholdLoggingOptions{
+		EventingThreshold: 10 * time.Millisecond,
+		SummaryDisabled:   true,
+		Sink: func(report ThresholdLogReport) {
+			reports = append(reports, report)
+		},
+	})
+
+	tracer.recordOp(&thresholdLogSpan{
+		opName:      "DeployFunction",
+		serviceName: "eventing",
+		duration:    5 * time.Millisecond,
+	})
+	tracer.recordOp(&thresholdLogSpan{
+		opName:      "DeployFunction",
+		serviceName: "eventing",
+		duration:    20 * time.Millisecond,
+	})
+	tracer.logRecordedRecords()
+
+	suite.Require().Len(reports, 1)
+	suite.Assert().Equal("eventing", reports[0].Service)
+	var report thresholdLogService
+	suite.Require().Nil(json.Unmarshal(reports[0].Report, &report))
+	suite.Assert().Equal(uint64(1), report.Count)
+}
+
+func (suite *UnitTestSuite) TestThresholdLoggingTracerOrphans() {
+	var reports []ThresholdLogReport
+	tracer := newThresholdLoggingTracer(&ThresholdLoggingOptions{
+		Sink: func(report ThresholdLogReport) {
+			reports = append(reports, report)
+		},
+	})
+
+	cluster := &Cluster{sb: stateBlock{Tracer: tracer}}
+	otherCluster := &Cluster{sb: stateBlock{Tracer: &noopTracer{}}}
+
+	logger := &coreInterceptLogger{}
+	coreReport := []byte(`{"service":"kv","count":1,"top":[{"c":"client1/conn1","i":"0x12","r":"10.0.0.1:11210","d":1500,"s":"kv:Get"}]}`)
+	otherReport := []byte(`{"service":"kv","count":3,"top":[{"c":"client2/conn1","i":"0x13","r":"10.0.0.1:11210","d":1500,"s":"kv:Get"}]}`)
+
+	suite.Require().Nil(logger.Log(gocbcore.LogWarn, 0, coreOrphanLogFormat, coreReport))
+	suite.Assert().Empty(reports)
+
+	addOrphanReportClient("client1", cluster)
+	addOrphanReportClient("client2", otherCluster)
+	suite.Require().Nil(logger.Log(gocbcore.LogWarn, 0, coreOrphanLogFormat, coreReport))
+	suite.Require().Nil(logger.Log(gocbcore.LogWarn, 0, coreOrphanLogFormat, otherReport))
+	removeOrphanReportClient("client1")
+	removeOrphanReportClient("client2")
+
+	suite.Require().Len(reports, 1)
+	suite.Assert().Equal(ThresholdLogReportTypeOrphan, reports[0].Type)
+	suite.Assert().Equal("kv", reports[0].Service)
+
+	var report thresholdLogService
+	suite.Require().Nil(json.Unmarshal(reports[0].Report, &report))
+	suite.Assert().Equal(thresholdLogService{
+		Service: "kv",
+		Count:   1,
+		Top: []thresholdLogItem{
+			{
+				ServiceType:       "kv:Get",
+				ServerDurationUs:  1500,
+				LastRemoteAddress: "10.0.0.1:11210",
+				LastOperationID:   "0x12",
+				LastLocalID:       "client1/conn1",
+			},
+		},
+	}, report)
+}
+
+// TestCoreOrphanLogFormat ensures that the format which gocbcore logs orphaned responses with has not changed, the
+// reports can only be intercepted whilst it matches.
+func (suite *UnitTestSuite) TestCoreOrphanLogFormat() {
+	pkg, err := build.Import("github.com/couchbase/gocbcore/v9", ".", build.FindOnly)
+	if err != nil {
+		suite.T().Skipf("Failed to find gocbcore source: %v", err)
+	}
+
+	src, err := ioutil.ReadFile(filepath.Join(pkg.Dir, "zombielogger_component.go"))
+	suite.Require().Nil(err)
+	suite.Assert().Contains(string(src), strconv.Quote(coreOrphanLogFormat))
+}
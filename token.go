@@ -1,8 +1,12 @@
 package gocb
 
 import (
+	"encoding/base64"
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
+	"math"
+	"sort"
 	"strconv"
 
 	gocbcore "github.com/couchbase/gocbcore/v9"
@@ -66,15 +70,89 @@ func NewMutationState(tokens ...MutationToken) *MutationState {
 	return mt
 }
 
-// Add includes an operation's mutation information in this mutation state.
+// Add includes an operation's mutation information in this mutation state.  Only the token
+// with the highest sequence number is retained for each vbucket, unless the tokens have
+// different vbucket UUIDs (such as following a failover) in which case the added token is
+// retained as it belongs to the newer vbucket history.
 func (mt *MutationState) Add(tokens ...MutationToken) {
 	for _, token := range tokens {
-		if token.bucketName != "" {
+		if token.bucketName == "" {
+			continue
+		}
+
+		idx := mt.indexOf(token.bucketName, token.token.VbID)
+		if idx < 0 {
 			mt.tokens = append(mt.tokens, token)
+			continue
+		}
+
+		if token.token.VbUUID != mt.tokens[idx].token.VbUUID || token.token.SeqNo > mt.tokens[idx].token.SeqNo {
+			mt.tokens[idx] = token
+		}
+	}
+}
+
+func (mt *MutationState) indexOf(bucketName string, vbID uint16) int {
+	for i, token := range mt.tokens {
+		if token.bucketName == bucketName && token.token.VbID == vbID {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// Tokens returns the mutation tokens which are held by this mutation state.
+func (mt *MutationState) Tokens() []MutationToken {
+	tokens := make([]MutationToken, len(mt.tokens))
+	copy(tokens, mt.tokens)
+	return tokens
+}
+
+// Merge includes all of the mutation information from the other mutation states in this
+// mutation state, retaining the highest sequence number for each vbucket.
+func (mt *MutationState) Merge(others ...*MutationState) {
+	for _, other := range others {
+		if other == nil {
+			continue
 		}
+
+		mt.Add(other.tokens...)
 	}
 }
 
+// ForBucket returns a new mutation state containing only the mutation information which
+// belongs to the named bucket.
+func (mt *MutationState) ForBucket(bucketName string) *MutationState {
+	filtered := &MutationState{}
+	for _, token := range mt.tokens {
+		if token.bucketName == bucketName {
+			filtered.tokens = append(filtered.tokens, token)
+		}
+	}
+
+	return filtered
+}
+
+// IsNewerThan returns whether this mutation state contains any mutation which has not been
+// observed by other, that is a vbucket which other holds no token for, holds a token with a
+// different vbucket UUID for or for which this mutation state holds a higher sequence number.
+func (mt *MutationState) IsNewerThan(other *MutationState) bool {
+	for _, token := range mt.tokens {
+		if other == nil {
+			return true
+		}
+
+		idx := other.indexOf(token.bucketName, token.token.VbID)
+		if idx < 0 || token.token.VbUUID != other.tokens[idx].token.VbUUID ||
+			token.token.SeqNo > other.tokens[idx].token.SeqNo {
+			return true
+		}
+	}
+
+	return false
+}
+
 // MarshalJSON marshal's this mutation state to JSON.
 func (mt *MutationState) MarshalJSON() ([]byte, error) {
 	var data mutationStateData
@@ -118,7 +196,7 @@ func (mt *MutationState) UnmarshalJSON(data []byte) error {
 			if err != nil {
 				return err
 			}
-			vbUUID, err := strconv.Atoi(stateToken.VbUUID)
+			vbUUID, err := strconv.ParseUint(stateToken.VbUUID, 10, 64)
 			if err != nil {
 				return err
 			}
@@ -131,7 +209,7 @@ func (mt *MutationState) UnmarshalJSON(data []byte) error {
 				},
 			}
 
-			mt.tokens = append(mt.tokens, token)
+			mt.Add(token)
 		}
 	}
 
@@ -152,3 +230,117 @@ func (mt *MutationState) toSearchMutationState() searchMutationState {
 
 	return data
 }
+
+const mutationStateEncodingVersion = 1
+
+// Encode returns a compact, URL-safe string representation of this mutation state which is
+// suitable for use within HTTP headers and cookies.  The returned string can be converted back
+// to a mutation state using DecodeMutationState.
+func (mt *MutationState) Encode() string {
+	tokens := mt.Tokens()
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].bucketName != tokens[j].bucketName {
+			return tokens[i].bucketName < tokens[j].bucketName
+		}
+		return tokens[i].token.VbID < tokens[j].token.VbID
+	})
+
+	buf := []byte{mutationStateEncodingVersion}
+	for i := 0; i < len(tokens); {
+		bucketName := tokens[i].bucketName
+
+		end := i
+		for end < len(tokens) && tokens[end].bucketName == bucketName {
+			end++
+		}
+
+		buf = appendUvarint(buf, uint64(len(bucketName)))
+		buf = append(buf, bucketName...)
+		buf = appendUvarint(buf, uint64(end-i))
+		for _, token := range tokens[i:end] {
+			buf = appendUvarint(buf, uint64(token.token.VbID))
+			buf = appendUvarint(buf, uint64(token.token.VbUUID))
+			buf = appendUvarint(buf, uint64(token.token.SeqNo))
+		}
+
+		i = end
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+// DecodeMutationState parses a mutation state which was previously encoded using Encode.
+func DecodeMutationState(encoded string) (*MutationState, error) {
+	buf, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, makeInvalidArgumentsError("mutation state is not valid base64")
+	}
+
+	if len(buf) == 0 || buf[0] != mutationStateEncodingVersion {
+		return nil, makeInvalidArgumentsError("unsupported mutation state encoding")
+	}
+	buf = buf[1:]
+
+	readUvarint := func() (uint64, error) {
+		val, n := binary.Uvarint(buf)
+		if n <= 0 {
+			return 0, makeInvalidArgumentsError("mutation state encoding is truncated")
+		}
+		buf = buf[n:]
+		return val, nil
+	}
+
+	state := &MutationState{}
+	for len(buf) > 0 {
+		nameLen, err := readUvarint()
+		if err != nil {
+			return nil, err
+		}
+		if nameLen == 0 || uint64(len(buf)) < nameLen {
+			return nil, makeInvalidArgumentsError("mutation state encoding is truncated")
+		}
+		bucketName := string(buf[:nameLen])
+		buf = buf[nameLen:]
+
+		numTokens, err := readUvarint()
+		if err != nil {
+			return nil, err
+		}
+
+		for i := uint64(0); i < numTokens; i++ {
+			vbID, err := readUvarint()
+			if err != nil {
+				return nil, err
+			}
+			vbUUID, err := readUvarint()
+			if err != nil {
+				return nil, err
+			}
+			seqNo, err := readUvarint()
+			if err != nil {
+				return nil, err
+			}
+
+			if vbID > math.MaxUint16 {
+				return nil, makeInvalidArgumentsError("mutation state contains an invalid vbucket id")
+			}
+
+			state.Add(MutationToken{
+				bucketName: bucketName,
+				token: gocbcore.MutationToken{
+					VbID:   uint16(vbID),
+					VbUUID: gocbcore.VbUUID(vbUUID),
+					SeqNo:  gocbcore.SeqNo(seqNo),
+				},
+			})
+		}
+	}
+
+	return state, nil
+}
+
+func appendUvarint(buf []byte, val uint64) []byte {
+	var scratch [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(scratch[:], val)
+	return append(buf, scratch[:n]...)
+}
@@ -2,6 +2,7 @@ package gocb
 
 import (
 	"encoding/json"
+	"errors"
 	"strings"
 
 	gocbcore "github.com/couchbase/gocbcore/v9"
@@ -100,3 +101,130 @@ func (suite *UnitTestSuite) TestMutationState_toSeachMutationState() {
 		suite.T().Fatalf("Failed to generate correct JSON output %s", bytes)
 	}
 }
+
+func (suite *UnitTestSuite) newFakeToken(bucketName string, vbID uint16, vbUUID, seqNo uint64) MutationToken {
+	return MutationToken{
+		token: gocbcore.MutationToken{
+			VbID:   vbID,
+			VbUUID: gocbcore.VbUUID(vbUUID),
+			SeqNo:  gocbcore.SeqNo(seqNo),
+		},
+		bucketName: bucketName,
+	}
+}
+
+func (suite *UnitTestSuite) TestMutationState_AddDeduplicates() {
+	state := NewMutationState(
+		suite.newFakeToken("frank", 1, 9, 12),
+		suite.newFakeToken("frank", 1, 9, 44),
+		suite.newFakeToken("frank", 1, 9, 30),
+		suite.newFakeToken("bob", 1, 3, 5),
+	)
+
+	suite.Assert().ElementsMatch([]MutationToken{
+		suite.newFakeToken("frank", 1, 9, 44),
+		suite.newFakeToken("bob", 1, 3, 5),
+	}, state.Tokens())
+}
+
+func (suite *UnitTestSuite) TestMutationState_AddFailover() {
+	state := NewMutationState(
+		suite.newFakeToken("frank", 1, 9, 44),
+		suite.newFakeToken("frank", 1, 10, 12),
+	)
+
+	suite.Assert().Equal([]MutationToken{
+		suite.newFakeToken("frank", 1, 10, 12),
+	}, state.Tokens())
+
+	failedOver := NewMutationState(suite.newFakeToken("frank", 1, 11, 3))
+	suite.Assert().True(failedOver.IsNewerThan(state))
+}
+
+func (suite *UnitTestSuite) TestMutationState_Merge() {
+	state := NewMutationState(
+		suite.newFakeToken("frank", 1, 9, 12),
+		suite.newFakeToken("frank", 2, 9, 50),
+	)
+	other := NewMutationState(
+		suite.newFakeToken("frank", 1, 9, 20),
+		suite.newFakeToken("frank", 2, 9, 40),
+		suite.newFakeToken("bob", 7, 3, 5),
+	)
+
+	state.Merge(other, nil)
+
+	suite.Assert().ElementsMatch([]MutationToken{
+		suite.newFakeToken("frank", 1, 9, 20),
+		suite.newFakeToken("frank", 2, 9, 50),
+		suite.newFakeToken("bob", 7, 3, 5),
+	}, state.Tokens())
+	suite.Assert().Len(other.Tokens(), 3)
+}
+
+func (suite *UnitTestSuite) TestMutationState_ForBucket() {
+	state := NewMutationState(
+		suite.newFakeToken("frank", 1, 9, 12),
+		suite.newFakeToken("bob", 7, 3, 5),
+	)
+
+	suite.Assert().Equal([]MutationToken{
+		suite.newFakeToken("bob", 7, 3, 5),
+	}, state.ForBucket("bob").Tokens())
+	suite.Assert().Empty(state.ForBucket("alice").Tokens())
+}
+
+func (suite *UnitTestSuite) TestMutationState_IsNewerThan() {
+	older := NewMutationState(
+		suite.newFakeToken("frank", 1, 9, 12),
+		suite.newFakeToken("frank", 2, 9, 50),
+	)
+	newer := NewMutationState(
+		suite.newFakeToken("frank", 1, 9, 13),
+	)
+	other := NewMutationState(
+		suite.newFakeToken("bob", 1, 9, 1),
+	)
+
+	suite.Assert().True(newer.IsNewerThan(older))
+	suite.Assert().True(older.IsNewerThan(newer))
+	suite.Assert().False(NewMutationState(suite.newFakeToken("frank", 2, 9, 50)).IsNewerThan(older))
+	suite.Assert().True(other.IsNewerThan(older))
+	suite.Assert().True(other.IsNewerThan(nil))
+	suite.Assert().False(NewMutationState().IsNewerThan(older))
+}
+
+func (suite *UnitTestSuite) TestMutationState_Encode() {
+	state := NewMutationState(
+		suite.newFakeToken("frank", 1, 9, 12),
+		suite.newFakeToken("frank", 1023, 0xFFFFFFFFFFFFFFFF, 1<<40),
+		suite.newFakeToken("bob", 7, 3, 5),
+	)
+
+	encoded := state.Encode()
+	suite.Assert().NotContains(encoded, "=")
+	suite.Assert().NotContains(encoded, "+")
+	suite.Assert().NotContains(encoded, "/")
+
+	decoded, err := DecodeMutationState(encoded)
+	suite.Require().Nil(err, err)
+	suite.Assert().ElementsMatch(state.Tokens(), decoded.Tokens())
+
+	// Encoding should not depend upon the order in which tokens were added.
+	reordered := NewMutationState(
+		suite.newFakeToken("bob", 7, 3, 5),
+		suite.newFakeToken("frank", 1023, 0xFFFFFFFFFFFFFFFF, 1<<40),
+		suite.newFakeToken("frank", 1, 9, 12),
+	)
+	suite.Assert().Equal(encoded, reordered.Encode())
+
+	empty, err := DecodeMutationState(NewMutationState().Encode())
+	suite.Require().Nil(err, err)
+	suite.Assert().Empty(empty.Tokens())
+
+	_, err = DecodeMutationState(encoded[:len(encoded)-2])
+	suite.Assert().True(errors.Is(err, ErrInvalidArgument))
+
+	_, err = DecodeMutationState("not*base64")
+	suite.Assert().True(errors.Is(err, ErrInvalidArgument))
+}
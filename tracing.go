@@ -1,10 +1,12 @@
 package gocb
 
 import (
+	"strings"
+
 	"github.com/couchbase/gocbcore/v9"
 )
 
-func tracerAddRef(tracer requestTracer) {
+func tracerAddRef(tracer RequestTracer) {
 	if tracer == nil {
 		return
 	}
@@ -15,7 +17,7 @@ func tracerAddRef(tracer requestTracer) {
 	}
 }
 
-func tracerDecRef(tracer requestTracer) {
+func tracerDecRef(tracer RequestTracer) {
 	if tracer == nil {
 		return
 	}
@@ -26,24 +28,104 @@ func tracerDecRef(tracer requestTracer) {
 	}
 }
 
-// requestTracer describes the tracing abstraction in the SDK.
-type requestTracer interface {
-	StartSpan(operationName string, parentContext requestSpanContext) requestSpan
+// RequestTracer describes the tracing abstraction in the SDK.
+type RequestTracer interface {
+	StartSpan(operationName string, parentContext RequestSpanContext) RequestSpan
 }
 
-// requestSpan is the interface for spans that are created by a requestTracer.
-type requestSpan interface {
+// RequestSpan is the interface for spans that are created by a RequestTracer.
+type RequestSpan interface {
 	Finish()
-	Context() requestSpanContext
-	SetTag(key string, value interface{}) requestSpan
+	Context() RequestSpanContext
+	SetTag(key string, value interface{}) RequestSpan
+}
+
+// RequestSpanContext is the interface for for external span contexts that can be passed in into the SDK option blocks.
+type RequestSpanContext interface {
+}
+
+// W3CTraceContext can be implemented by a RequestSpanContext to have the SDK propagate the trace to the
+// server using the W3C Trace Context traceparent and tracestate headers.
+//
+// The trace is propagated as follows:
+//   - Management requests send the traceparent and tracestate headers.
+//   - Query and analytics requests do not send the headers, as gocbcore does not support adding headers to
+//     them, but can use the trace ID as their client_context_id, see QueryOptions.ClientContextIDFromTrace and
+//     AnalyticsOptions.ClientContextIDFromTrace.
+//   - Search and view requests do not propagate the trace, as gocbcore does not support adding headers to them
+//     and they have no equivalent of the client_context_id.
+//
+// VOLATILE: This API is subject to change at any time.
+type W3CTraceContext interface {
+	// TraceParent returns the traceparent header value, or an empty string if there is none.
+	TraceParent() string
+	// TraceState returns the tracestate header value, or an empty string if there is none.
+	TraceState() string
+}
+
+func w3cTraceParent(tracectx RequestSpanContext) (string, string) {
+	w3cCtx, ok := tracectx.(W3CTraceContext)
+	if !ok {
+		return "", ""
+	}
+
+	traceParent := w3cCtx.TraceParent()
+	if traceParent == "" {
+		return "", ""
+	}
+
+	return traceParent, w3cCtx.TraceState()
 }
 
-// requestSpanContext is the interface for for external span contexts that can be passed in into the SDK option blocks.
-type requestSpanContext interface {
+// addTraceContextHeaders returns a copy of headers with the W3C trace context headers included, if the
+// trace context provides them.
+func addTraceContextHeaders(headers map[string]string, tracectx RequestSpanContext) map[string]string {
+	traceParent, traceState := w3cTraceParent(tracectx)
+	if traceParent == "" {
+		return headers
+	}
+
+	newHeaders := make(map[string]string, len(headers)+2)
+	for k, v := range headers {
+		newHeaders[k] = v
+	}
+	newHeaders["traceparent"] = traceParent
+	if traceState != "" {
+		newHeaders["tracestate"] = traceState
+	}
+
+	return newHeaders
+}
+
+// w3cTraceID returns the trace ID contained within the traceparent of the trace context, or an empty string
+// if there is no valid traceparent.
+func w3cTraceID(tracectx RequestSpanContext) string {
+	traceParent, _ := w3cTraceParent(tracectx)
+	if traceParent == "" {
+		return ""
+	}
+
+	// version "-" trace-id "-" parent-id "-" trace-flags
+	parts := strings.Split(traceParent, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return ""
+	}
+
+	traceID := strings.ToLower(parts[1])
+	if len(traceID) != 32 || strings.Trim(traceID, "0") == "" {
+		return ""
+	}
+	for _, c := range traceID {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return ""
+		}
+	}
+
+	return traceID
 }
 
 type requestTracerWrapper struct {
-	tracer requestTracer
+	tracer RequestTracer
 }
 
 func (tracer *requestTracerWrapper) StartSpan(operationName string, parentContext gocbcore.RequestSpanContext) gocbcore.RequestSpan {
@@ -53,7 +135,7 @@ func (tracer *requestTracerWrapper) StartSpan(operationName string, parentContex
 }
 
 type requestSpanWrapper struct {
-	span requestSpan
+	span RequestSpan
 }
 
 func (span requestSpanWrapper) Finish() {
@@ -80,17 +162,17 @@ var (
 type noopTracer struct {
 }
 
-func (tracer *noopTracer) StartSpan(operationName string, parentContext requestSpanContext) requestSpan {
+func (tracer *noopTracer) StartSpan(operationName string, parentContext RequestSpanContext) RequestSpan {
 	return defaultNoopSpan
 }
 
 func (span noopSpan) Finish() {
 }
 
-func (span noopSpan) Context() requestSpanContext {
+func (span noopSpan) Context() RequestSpanContext {
 	return defaultNoopSpanContext
 }
 
-func (span noopSpan) SetTag(key string, value interface{}) requestSpan {
+func (span noopSpan) SetTag(key string, value interface{}) RequestSpan {
 	return defaultNoopSpan
 }
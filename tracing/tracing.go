@@ -0,0 +1,111 @@
+// Package tracing provides an adapter which allows third party tracers, such as those provided by
+// OpenTracing or OpenTelemetry, to be used as the RequestTracer for the SDK.
+//
+// To use it, implement Tracer and Span as a thin shim over your tracing library and pass the result of
+// NewRequestTracer as ClusterOptions.Tracer. For example, an OpenTracing shim might look like:
+//
+//	type otTracer struct{ tracer opentracing.Tracer }
+//
+//	func (t otTracer) StartSpan(name string, parent interface{}) tracing.Span {
+//		var opts []opentracing.StartSpanOption
+//		if ctx, ok := parent.(opentracing.SpanContext); ok {
+//			opts = append(opts, opentracing.ChildOf(ctx))
+//		}
+//		return otSpan{t.tracer.StartSpan(name, opts...)}
+//	}
+//
+//	type otSpan struct{ span opentracing.Span }
+//
+//	func (s otSpan) SetTag(key string, value interface{}) { s.span.SetTag(key, value) }
+//	func (s otSpan) Finish()                              { s.span.Finish() }
+//	func (s otSpan) Context() interface{}                 { return s.span.Context() }
+//
+// A span created by your own application can then be used to parent the spans created by the SDK by
+// passing its context as the ParentSpan of any operation options:
+//
+//	collection.Get("key", &gocb.GetOptions{ParentSpan: span.Context()})
+//
+// OpenTelemetry propagates spans through a context.Context, so an OpenTelemetry shim would typically
+// accept a context.Context as the parent and return the context containing the new span from Context.
+//
+// If a Span also implements gocb.W3CTraceContext then the SDK sends the W3C Trace Context headers with
+// management requests, and query and analytics requests can use its trace ID as their client context ID.
+// Search and view requests do not propagate the trace, see gocb.W3CTraceContext.
+// VOLATILE: This API is subject to change at any time.
+package tracing
+
+import (
+	gocb "github.com/couchbase/gocb/v2"
+)
+
+// Tracer is the minimal tracer abstraction which a third party tracer must be adapted to.
+// The parent is either nil or a value previously returned by Span.Context, or passed by the
+// application as the ParentSpan option of an operation.
+type Tracer interface {
+	StartSpan(operationName string, parent interface{}) Span
+}
+
+// Span is the minimal span abstraction which a third party span must be adapted to.
+type Span interface {
+	SetTag(key string, value interface{})
+	Finish()
+	Context() interface{}
+}
+
+// NewRequestTracer creates a gocb.RequestTracer which creates its spans using tracer.
+func NewRequestTracer(tracer Tracer) gocb.RequestTracer {
+	return &requestTracer{
+		tracer: tracer,
+	}
+}
+
+type requestTracer struct {
+	tracer Tracer
+}
+
+func (t *requestTracer) StartSpan(operationName string, parentContext gocb.RequestSpanContext) gocb.RequestSpan {
+	if context, ok := parentContext.(*requestSpanContext); ok {
+		parentContext = context.span.Context()
+	}
+
+	return &requestSpan{
+		span: t.tracer.StartSpan(operationName, parentContext),
+	}
+}
+
+type requestSpan struct {
+	span Span
+}
+
+func (s *requestSpan) Finish() {
+	s.span.Finish()
+}
+
+func (s *requestSpan) Context() gocb.RequestSpanContext {
+	return &requestSpanContext{
+		span: s.span,
+	}
+}
+
+func (s *requestSpan) SetTag(key string, value interface{}) gocb.RequestSpan {
+	s.span.SetTag(key, value)
+	return s
+}
+
+type requestSpanContext struct {
+	span Span
+}
+
+func (c *requestSpanContext) TraceParent() string {
+	if w3cSpan, ok := c.span.(gocb.W3CTraceContext); ok {
+		return w3cSpan.TraceParent()
+	}
+	return ""
+}
+
+func (c *requestSpanContext) TraceState() string {
+	if w3cSpan, ok := c.span.(gocb.W3CTraceContext); ok {
+		return w3cSpan.TraceState()
+	}
+	return ""
+}
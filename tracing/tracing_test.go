@@ -0,0 +1,69 @@
+package tracing
+
+import (
+	"testing"
+)
+
+type testSpanContext struct {
+	name string
+}
+
+type testSpan struct {
+	name     string
+	parent   interface{}
+	tags     map[string]interface{}
+	finished bool
+}
+
+func (s *testSpan) SetTag(key string, value interface{}) {
+	s.tags[key] = value
+}
+
+func (s *testSpan) Finish() {
+	s.finished = true
+}
+
+func (s *testSpan) Context() interface{} {
+	return testSpanContext{name: s.name}
+}
+
+type testTracer struct {
+	spans []*testSpan
+}
+
+func (t *testTracer) StartSpan(operationName string, parent interface{}) Span {
+	span := &testSpan{
+		name:   operationName,
+		parent: parent,
+		tags:   make(map[string]interface{}),
+	}
+	t.spans = append(t.spans, span)
+	return span
+}
+
+func TestRequestTracerParentsSpans(t *testing.T) {
+	tracer := &testTracer{}
+	reqTracer := NewRequestTracer(tracer)
+
+	parent := testSpanContext{name: "application"}
+	span := reqTracer.StartSpan("Get", parent).SetTag("couchbase.service", "kv")
+	child := reqTracer.StartSpan("encode", span.Context())
+	child.Finish()
+	span.Finish()
+
+	if len(tracer.spans) != 2 {
+		t.Fatalf("Expected 2 spans but was %d", len(tracer.spans))
+	}
+	if tracer.spans[0].parent != parent {
+		t.Fatalf("Expected span to be parented by %v but was %v", parent, tracer.spans[0].parent)
+	}
+	if tracer.spans[1].parent != (testSpanContext{name: "Get"}) {
+		t.Fatalf("Expected child span to be parented by Get but was %v", tracer.spans[1].parent)
+	}
+	if tracer.spans[0].tags["couchbase.service"] != "kv" {
+		t.Fatalf("Expected service tag to be set but was %v", tracer.spans[0].tags)
+	}
+	if !tracer.spans[0].finished || !tracer.spans[1].finished {
+		t.Fatalf("Expected all spans to be finished")
+	}
+}
@@ -0,0 +1,287 @@
+package gocb
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	gocbcore "github.com/couchbase/gocbcore/v9"
+	"github.com/stretchr/testify/mock"
+)
+
+type testW3CSpanContext struct {
+	traceParent string
+	traceState  string
+}
+
+func (ctx *testW3CSpanContext) TraceParent() string {
+	return ctx.traceParent
+}
+
+func (ctx *testW3CSpanContext) TraceState() string {
+	return ctx.traceState
+}
+
+func (suite *UnitTestSuite) TestW3CTraceID() {
+	type tCase struct {
+		traceParent string
+		expected    string
+	}
+
+	testCases := []tCase{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", "4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", ""},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", ""},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47-00f067aa0ba902b7-01", ""},
+		{"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01", ""},
+		{"garbage", ""},
+		{"", ""},
+	}
+
+	for _, tCase := range testCases {
+		suite.Assert().Equal(tCase.expected, w3cTraceID(&testW3CSpanContext{traceParent: tCase.traceParent}), tCase.traceParent)
+	}
+
+	suite.Assert().Equal("", w3cTraceID(nil))
+	suite.Assert().Equal("", w3cTraceID(defaultNoopSpanContext))
+}
+
+func (suite *UnitTestSuite) TestMgmtRequestTraceContextHeaders() {
+	var headers []map[string]string
+	httpProvider := new(mockHttpProvider)
+	httpProvider.
+		On("DoHTTPRequest", mock.AnythingOfType("*gocbcore.HTTPRequest")).
+		Run(func(args mock.Arguments) {
+			req := args.Get(0).(*gocbcore.HTTPRequest)
+			headers = append(headers, req.Headers)
+		}).
+		Return(&gocbcore.HTTPResponse{
+			StatusCode: http.StatusOK,
+			Body:       ioutil.NopCloser(strings.NewReader("")),
+		}, nil)
+
+	cli := new(mockClient)
+	cli.On("getHTTPProvider").Return(httpProvider, nil)
+	cli.On("supportsGCCCP").Return(true)
+
+	cluster := clusterFromOptions(ClusterOptions{})
+	cluster.clusterClient = cli
+
+	parent := &testW3CSpanContext{
+		traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		traceState:  "congo=t61rcWkgMzE",
+	}
+	span := cluster.sb.Tracer.StartSpan("GetAllBuckets", parent)
+	reqHeaders := map[string]string{"Accept": "application/json"}
+
+	_, err := cluster.executeMgmtRequest(mgmtRequest{
+		Service:    ServiceTypeManagement,
+		Method:     "GET",
+		Path:       "/pools/default/buckets",
+		Headers:    reqHeaders,
+		parentSpan: span.Context(),
+	})
+	suite.Require().Nil(err, err)
+
+	_, err = cluster.executeMgmtRequest(mgmtRequest{
+		Service: ServiceTypeManagement,
+		Method:  "GET",
+		Path:    "/pools/default/buckets",
+	})
+	suite.Require().Nil(err, err)
+
+	suite.Require().Len(headers, 2)
+	suite.Assert().Equal(map[string]string{
+		"Accept":      "application/json",
+		"traceparent": parent.traceParent,
+		"tracestate":  parent.traceState,
+	}, headers[0])
+	suite.Assert().NotContains(headers[1], "traceparent")
+
+	// The headers provided for the request should not have been modified.
+	suite.Assert().Len(reqHeaders, 1)
+}
+
+func (suite *UnitTestSuite) TestQueryClientContextIDFromTrace() {
+	parent := &testW3CSpanContext{
+		traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}
+
+	type tCase struct {
+		name     string
+		opts     *QueryOptions
+		expected string
+	}
+
+	testCases := []tCase{
+		{
+			name: "from trace",
+			opts: &QueryOptions{
+				ParentSpan:               parent,
+				ClientContextIDFromTrace: true,
+			},
+			expected: "4bf92f3577b34da6a3ce929d0e0e4736",
+		},
+		{
+			name: "explicit",
+			opts: &QueryOptions{
+				ParentSpan:               parent,
+				ClientContextIDFromTrace: true,
+				ClientContextID:          "explicit",
+			},
+			expected: "explicit",
+		},
+		{
+			name: "no trace parent",
+			opts: &QueryOptions{
+				ClientContextIDFromTrace: true,
+				ClientContextID:          "explicit",
+			},
+			expected: "explicit",
+		},
+		{
+			name: "not enabled",
+			opts: &QueryOptions{
+				ParentSpan:      parent,
+				ClientContextID: "explicit",
+			},
+			expected: "explicit",
+		},
+	}
+
+	for _, tCase := range testCases {
+		suite.T().Run(tCase.name, func(te *testing.T) {
+			reader := &mockQueryRowReader{
+				mockQueryRowReaderBase: mockQueryRowReaderBase{
+					Suite: suite,
+				},
+			}
+
+			var contextID interface{}
+			cluster := suite.queryCluster(true, reader, func(args mock.Arguments) {
+				opts := args.Get(0).(gocbcore.N1QLQueryOptions)
+
+				var payload map[string]interface{}
+				suite.Require().Nil(json.Unmarshal(opts.Payload, &payload))
+				contextID = payload["client_context_id"]
+			})
+
+			_, err := cluster.Query("SELECT 1", tCase.opts)
+			suite.Require().Nil(err, err)
+			suite.Assert().Equal(tCase.expected, contextID)
+		})
+	}
+}
+
+type testRecordingSpanContext struct {
+	name string
+}
+
+type testRecordingSpan struct {
+	name   string
+	parent RequestSpanContext
+}
+
+func (span *testRecordingSpan) Finish() {
+}
+
+func (span *testRecordingSpan) Context() RequestSpanContext {
+	return testRecordingSpanContext{name: span.name}
+}
+
+func (span *testRecordingSpan) SetTag(key string, value interface{}) RequestSpan {
+	return span
+}
+
+type testRecordingTracer struct {
+	spans []*testRecordingSpan
+}
+
+func (tracer *testRecordingTracer) StartSpan(operationName string, parentContext RequestSpanContext) RequestSpan {
+	span := &testRecordingSpan{name: operationName, parent: parentContext}
+	tracer.spans = append(tracer.spans, span)
+	return span
+}
+
+func (suite *UnitTestSuite) TestKvDispatchSpanParent() {
+	var traceContexts []gocbcore.RequestSpanContext
+
+	provider := new(mockKvProvider)
+	provider.
+		On("Get", mock.AnythingOfType("gocbcore.GetOptions"), mock.AnythingOfType("gocbcore.GetCallback")).
+		Run(func(args mock.Arguments) {
+			traceContexts = append(traceContexts, args.Get(0).(gocbcore.GetOptions).TraceContext)
+			cb := args.Get(1).(gocbcore.GetCallback)
+			cb(&gocbcore.GetResult{Value: []byte(`{}`)}, nil)
+		}).
+		Return(new(mockPendingOp), nil)
+	provider.
+		On("GetMeta", mock.AnythingOfType("gocbcore.GetMetaOptions"), mock.AnythingOfType("gocbcore.GetMetaCallback")).
+		Run(func(args mock.Arguments) {
+			traceContexts = append(traceContexts, args.Get(0).(gocbcore.GetMetaOptions).TraceContext)
+			cb := args.Get(1).(gocbcore.GetMetaCallback)
+			cb(&gocbcore.GetMetaResult{}, nil)
+		}).
+		Return(new(mockPendingOp), nil)
+
+	cli := new(mockClient)
+	cli.On("getKvProvider").Return(provider, nil)
+
+	tracer := &testRecordingTracer{}
+	col := &Collection{
+		sb: stateBlock{
+			clientStateBlock: clientStateBlock{
+				BucketName: "mock",
+			},
+
+			cachedClient:         cli,
+			KvTimeout:            2500 * time.Millisecond,
+			Transcoder:           NewJSONTranscoder(),
+			Tracer:               tracer,
+			RetryStrategyWrapper: newRetryStrategyWrapper(NewBestEffortRetryStrategy(nil)),
+		},
+	}
+
+	_, err := col.Get("key", nil)
+	suite.Require().Nil(err, err)
+	_, err = col.Exists("key", nil)
+	suite.Require().Nil(err, err)
+
+	// gocbcore parents its dispatch spans using the trace context of the operation, which must be the context of
+	// the operation span rather than the span itself.
+	coreTracer := &requestTracerWrapper{tracer: tracer}
+	suite.Require().Len(traceContexts, 2)
+	for i, opName := range []string{"Get", "Exists"} {
+		coreTracer.StartSpan("dispatch", traceContexts[i])
+		dispatch := tracer.spans[len(tracer.spans)-1]
+		suite.Assert().Equal(testRecordingSpanContext{name: opName}, dispatch.parent)
+	}
+}
+
+func (suite *UnitTestSuite) TestAnalyticsClientContextIDFromTrace() {
+	parent := &testW3CSpanContext{
+		traceParent: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	}
+
+	reader := &testQueryRowsReader{meta: []byte(`{}`)}
+
+	var contextID interface{}
+	cluster := suite.analyticsCluster(reader, func(args mock.Arguments) {
+		opts := args.Get(0).(gocbcore.AnalyticsQueryOptions)
+
+		var payload map[string]interface{}
+		suite.Require().Nil(json.Unmarshal(opts.Payload, &payload))
+		contextID = payload["client_context_id"]
+	})
+
+	_, err := cluster.AnalyticsQuery("SELECT 1", &AnalyticsOptions{
+		ParentSpan:               parent,
+		ClientContextIDFromTrace: true,
+	})
+	suite.Require().Nil(err, err)
+	suite.Assert().Equal("4bf92f3577b34da6a3ce929d0e0e4736", contextID)
+}
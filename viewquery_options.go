@@ -69,7 +69,9 @@ type ViewOptions struct {
 	Timeout       time.Duration
 	RetryStrategy RetryStrategy
 
-	parentSpan requestSpanContext
+	// ParentSpan is the span context which the spans of this view query are created under. The trace is not
+	// propagated to the views service, see W3CTraceContext.
+	ParentSpan RequestSpanContext
 }
 
 func (opts *ViewOptions) toURLValues() (*url.Values, error) {